@@ -7,6 +7,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/mike76-dev/sia-satellite/modules"
 )
@@ -17,6 +18,12 @@ const (
 
 	// googleAPI is the endpoint for retrieving the Google public key.
 	googleAPI = "https://www.googleapis.com/oauth2/v1/certs"
+
+	// hCaptchaAPI is the endpoint for verifying hCaptcha tokens.
+	hCaptchaAPI = "https://api.hcaptcha.com/siteverify"
+
+	// reCaptchaAPI is the endpoint for verifying reCAPTCHA tokens.
+	reCaptchaAPI = "https://www.google.com/recaptcha/api/siteverify"
 )
 
 type (
@@ -69,3 +76,64 @@ func GetGooglePublicKey(keyID string) (string, error) {
 	}
 	return key, nil
 }
+
+// CaptchaVerifier verifies the CAPTCHA tokens solved by the users.
+type CaptchaVerifier interface {
+	Verify(token, remoteIP string) (bool, error)
+}
+
+// captchaVerifier implements CaptchaVerifier using a siteverify API,
+// which is the same for hCaptcha and reCAPTCHA.
+type captchaVerifier struct {
+	endpoint string
+	secret   string
+}
+
+// captchaResponse holds the siteverify API response.
+type captchaResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// NewCaptchaVerifier returns a CaptchaVerifier for the given provider.
+// Supported providers are "hcaptcha" and "recaptcha".
+func NewCaptchaVerifier(provider, secret string) (CaptchaVerifier, error) {
+	if secret == "" {
+		return nil, errors.New("captcha secret not provided")
+	}
+	switch provider {
+	case "hcaptcha":
+		return &captchaVerifier{endpoint: hCaptchaAPI, secret: secret}, nil
+	case "recaptcha":
+		return &captchaVerifier{endpoint: reCaptchaAPI, secret: secret}, nil
+	default:
+		return nil, errors.New("unsupported captcha provider")
+	}
+}
+
+// Verify implements CaptchaVerifier.
+func (cv *captchaVerifier) Verify(token, remoteIP string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	values := url.Values{
+		"secret":   {cv.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		values.Set("remoteip", remoteIP)
+	}
+	resp, err := http.PostForm(cv.endpoint, values)
+	if err != nil {
+		return false, modules.AddContext(err, "failed to verify captcha")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.New("failed to verify captcha")
+	}
+	var data captchaResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&data); err != nil {
+		return false, errors.New("wrong format of captcha response")
+	}
+	return data.Success, nil
+}
@@ -6,6 +6,10 @@ DROP TABLE IF EXISTS wt_watched;
 DROP TABLE IF EXISTS wt_addresses;
 DROP TABLE IF EXISTS wt_tip;
 DROP TABLE IF EXISTS wt_info;
+DROP TABLE IF EXISTS wt_audit;
+DROP TABLE IF EXISTS wt_events;
+DROP TABLE IF EXISTS wt_keys;
+DROP TABLE IF EXISTS wt_multisig;
 
 CREATE TABLE wt_addresses (
 	id   BIGINT NOT NULL AUTO_INCREMENT,
@@ -20,6 +24,7 @@ CREATE TABLE wt_sces (
 	merkle_proof    BLOB NOT NULL,
 	leaf_index      BIGINT UNSIGNED NOT NULL,
 	maturity_height BIGINT UNSIGNED NOT NULL,
+	height          BIGINT UNSIGNED NOT NULL DEFAULT 0,
 	address_id      BIGINT NOT NULL,
 	PRIMARY KEY (id),
 	FOREIGN KEY (address_id) REFERENCES wt_addresses(id)
@@ -50,15 +55,57 @@ CREATE TABLE wt_tip (
 );
 
 CREATE TABLE wt_info (
-	id       INT NOT NULL AUTO_INCREMENT,
-	seed     BINARY(16) NOT NULL,
-	progress BIGINT UNSIGNED NOT NULL,
+	id            INT NOT NULL AUTO_INCREMENT,
+	seed          BINARY(16) NOT NULL,
+	progress      BIGINT UNSIGNED NOT NULL,
+	max_ancestors INT,
+	confirmations BIGINT UNSIGNED,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE wt_events (
+	id         BIGINT NOT NULL AUTO_INCREMENT,
+	event_id   BINARY(32) NOT NULL,
+	height     BIGINT UNSIGNED NOT NULL,
+	bid        BINARY(32) NOT NULL,
+	timestamp  BIGINT UNSIGNED NOT NULL,
+	event_type VARCHAR(32) NOT NULL,
+	relevant   MEDIUMBLOB NOT NULL,
+	data       MEDIUMBLOB NOT NULL,
+	PRIMARY KEY (id),
+	INDEX (event_id)
+);
+
+CREATE TABLE wt_keys (
+	id   BIGINT NOT NULL AUTO_INCREMENT,
+	addr BINARY(32) NOT NULL UNIQUE,
+	sk   BLOB NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE wt_audit (
+	id           BIGINT NOT NULL,
+	timestamp    BIGINT UNSIGNED NOT NULL,
+	txid         BINARY(32) NOT NULL,
+	destinations BLOB NOT NULL,
+	amount       BLOB NOT NULL,
+	fee          BLOB NOT NULL,
+	prev_hash    BINARY(32) NOT NULL,
+	hash         BINARY(32) NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE wt_multisig (
+	id   BIGINT NOT NULL AUTO_INCREMENT,
+	addr BINARY(32) NOT NULL UNIQUE,
+	uc   BLOB NOT NULL,
 	PRIMARY KEY (id)
 );
 
 /* provider */
 
 DROP TABLE IF EXISTS pr_info;
+DROP TABLE IF EXISTS pr_history;
 
 CREATE TABLE pr_info (
 	id         INT NOT NULL AUTO_INCREMENT,
@@ -68,11 +115,25 @@ CREATE TABLE pr_info (
 	PRIMARY KEY (id)
 );
 
+CREATE TABLE pr_history (
+	id           INT NOT NULL AUTO_INCREMENT,
+	renter_pk    BINARY(32) NOT NULL,
+	timestamp    BIGINT UNSIGNED NOT NULL,
+	contract_id  BINARY(32) NOT NULL,
+	host_key     BINARY(32) NOT NULL,
+	renewed_from BINARY(32) NOT NULL,
+	total_cost   BINARY(16) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX (renter_pk)
+);
+
 /* portal */
 
 DROP TABLE IF EXISTS pt_payments;
+DROP TABLE IF EXISTS pt_sessions;
 DROP TABLE IF EXISTS pt_accounts;
 DROP TABLE IF EXISTS pt_stats;
+DROP TABLE IF EXISTS pt_lockouts;
 DROP TABLE IF EXISTS pt_credits;
 DROP TABLE IF EXISTS pt_announcement;
 DROP TABLE IF EXISTS pt_tip;
@@ -101,6 +162,17 @@ CREATE TABLE pt_payments (
 	FOREIGN KEY (email) REFERENCES pt_accounts(email)
 );
 
+CREATE TABLE pt_sessions (
+	id          BIGINT NOT NULL AUTO_INCREMENT,
+	email       VARCHAR(64) NOT NULL,
+	nonce       BINARY(16) NOT NULL,
+	remote_host VARCHAR(64) NOT NULL,
+	issued      BIGINT NOT NULL,
+	expires     BIGINT NOT NULL,
+	PRIMARY KEY (id),
+	FOREIGN KEY (email) REFERENCES pt_accounts(email)
+);
+
 CREATE TABLE pt_stats (
 	remote_host  VARCHAR(64) NOT NULL,
 	login_last   BIGINT NOT NULL,
@@ -112,6 +184,14 @@ CREATE TABLE pt_stats (
 	PRIMARY KEY (remote_host)
 );
 
+CREATE TABLE pt_lockouts (
+	email         VARCHAR(64) NOT NULL,
+	failed_logins BIGINT UNSIGNED NOT NULL,
+	last_failed   BIGINT NOT NULL,
+	locked_until  BIGINT NOT NULL,
+	PRIMARY KEY (email)
+);
+
 CREATE TABLE pt_credits (
 	id        INT NOT NULL AUTO_INCREMENT,
 	amount    DOUBLE NOT NULL,
@@ -143,6 +223,7 @@ DROP TABLE IF EXISTS mg_balances;
 DROP TABLE IF EXISTS mg_prices;
 DROP TABLE IF EXISTS mg_maintenance;
 DROP TABLE IF EXISTS mg_tip;
+DROP TABLE IF EXISTS mg_tokens;
 
 CREATE TABLE mg_email (
 	id        INT NOT NULL AUTO_INCREMENT,
@@ -223,6 +304,15 @@ CREATE TABLE mg_tip (
 	PRIMARY KEY (id)
 );
 
+CREATE TABLE mg_tokens (
+	token_hash BINARY(32) NOT NULL,
+	email      VARCHAR(64) NOT NULL,
+	public_key BINARY(32) NOT NULL,
+	session    BINARY(16) NOT NULL,
+	expires    BIGINT NOT NULL,
+	PRIMARY KEY (token_hash)
+);
+
 /* hostdb */
 
 DROP TABLE IF EXISTS hdb_scanhistory;
@@ -7,9 +7,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mike76-dev/sia-satellite/modules"
 )
@@ -18,9 +23,35 @@ import (
 // file.
 const configFilename = "mail.json"
 
+// ErrInvalidHeader is returned when a header value contains a line
+// break, which could be used to inject additional headers.
+var ErrInvalidHeader = errors.New("header value contains a line break")
+
+// ValidHeader returns false if the header value contains a line break.
+func ValidHeader(value string) bool {
+	return !strings.ContainsAny(value, "\r\n")
+}
+
+// writeHeaders writes the From, To, and Subject headers of a message.
+// The subject is Q-encoded, so that it may contain non-ASCII text.
+func writeHeaders(b *bytes.Buffer, from, to, subject string) error {
+	if !ValidHeader(from) || !ValidHeader(to) || !ValidHeader(subject) {
+		return ErrInvalidHeader
+	}
+	b.Write([]byte(fmt.Sprintf("From: %s\r\n", from)))
+	b.Write([]byte(fmt.Sprintf("To: %s\r\n", to)))
+	b.Write([]byte(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))))
+	return nil
+}
+
 // MailSender is an abstraction of a mail client.
 type MailSender interface {
+	// SendMail sends an HTML message.
 	SendMail(from, to, subject string, body *bytes.Buffer) error
+
+	// SendMultipartMail sends a multipart/alternative message
+	// containing both a plain-text and an HTML part.
+	SendMultipartMail(from, to, subject string, text, html *bytes.Buffer) error
 }
 
 type (
@@ -50,9 +81,9 @@ func (mc *mailClient) SendMail(from, to, subject string, body *bytes.Buffer) err
 	rec := []string{to}
 	var b bytes.Buffer
 	mimeHeaders := "MIME-version: 1.0;\r\nContent-Type: text/html; charset=\"UTF-8\";\r\n\r\n"
-	b.Write([]byte(fmt.Sprintf("From: %s\r\n", from)))
-	b.Write([]byte(fmt.Sprintf("To: %s\r\n", to)))
-	b.Write([]byte(fmt.Sprintf("Subject: %s\r\n", subject)))
+	if err := writeHeaders(&b, from, to, subject); err != nil {
+		return err
+	}
 	b.Write([]byte(mimeHeaders))
 	b.Write(body.Bytes())
 	b.Write([]byte("\r\n"))
@@ -61,6 +92,66 @@ func (mc *mailClient) SendMail(from, to, subject string, body *bytes.Buffer) err
 	return smtp.SendMail(mc.smtpHost+":"+mc.smtpPort, auth, mc.from, rec, b.Bytes())
 }
 
+// SendMultipartMail sends a message containing both a plain-text
+// and an HTML version of the body.
+func (mc *mailClient) SendMultipartMail(from, to, subject string, text, html *bytes.Buffer) error {
+	// Authenticate.
+	auth := smtp.PlainAuth("", mc.from, mc.password, mc.smtpHost)
+
+	// Prepare the message.
+	rec := []string{to}
+	body, boundary, err := buildAlternative(text, html)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := writeHeaders(&b, from, to, subject); err != nil {
+		return err
+	}
+	b.Write([]byte("MIME-version: 1.0\r\n"))
+	b.Write([]byte(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", boundary)))
+	b.Write(body)
+
+	// Send the email.
+	return smtp.SendMail(mc.smtpHost+":"+mc.smtpPort, auth, mc.from, rec, b.Bytes())
+}
+
+// buildAlternative encodes the plain-text and the HTML parts of
+// a multipart/alternative message. The parts are ordered from the
+// least to the most preferred one, as required by RFC 2046.
+func buildAlternative(text, html *bytes.Buffer) ([]byte, string, error) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	parts := []struct {
+		contentType string
+		body        *bytes.Buffer
+	}{
+		{"text/plain; charset=\"UTF-8\"", text},
+		{"text/html; charset=\"UTF-8\"", html},
+	}
+	for _, part := range parts {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", part.contentType)
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(part.body.Bytes()); err != nil {
+			return nil, "", err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return b.Bytes(), mw.Boundary(), nil
+}
+
 // New returns an initialized mail client.
 func New(configPath string) (MailSender, error) {
 	// Open the configuration file.
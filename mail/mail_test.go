@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+)
+
+func TestBuildAlternative(t *testing.T) {
+	const link = "https://portal.example.com/verify?token=0123456789abcdef"
+	text := bytes.NewBufferString("Click on the following link:\n\n" + link + "\n")
+	html := bytes.NewBufferString(`<p><a href="` + link + `">` + link + `</a></p>`)
+	body, boundary, err := buildAlternative(text, html)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	var types []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		// The reader decodes the quoted-printable parts itself.
+		b, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(string(b), link) {
+			t.Fatalf("part %v doesn't contain the link: %q", part.Header.Get("Content-Type"), b)
+		}
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+	}
+	if len(types) != 2 || types[0] != "text/plain" || types[1] != "text/html" {
+		t.Fatalf("expected a plain-text and an HTML part, got %v", types)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeHeaders(&b, "Sia Satellite", "user@example.com", "Action Required"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeHeaders(&b, "Sia Satellite", "user@example.com\r\nBcc: evil@example.com", "foo"); err != ErrInvalidHeader {
+		t.Fatal("expected a header injection to be rejected, got", err)
+	}
+}
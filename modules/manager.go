@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -10,6 +12,24 @@ import (
 	"go.sia.tech/core/types"
 )
 
+var (
+	// ErrContractNotFound is returned when the contract is not in the
+	// contract set.
+	ErrContractNotFound = errors.New("contract not found")
+
+	// ErrStaleRevision is returned when a revision has a lower revision
+	// number than the one already known.
+	ErrStaleRevision = errors.New("stale revision")
+
+	// ErrInsufficientSatelliteFunds is returned when the satellite wallet
+	// can't cover the estimated cost of the contracts.
+	ErrInsufficientSatelliteFunds = errors.New("insufficient satellite funds")
+
+	// ErrInvalidRenterToken is returned when a renter token is unknown,
+	// expired, or bound to another key.
+	ErrInvalidRenterToken = errors.New("invalid renter token")
+)
+
 // HostAverages contains the host network averages from HostDB.
 type HostAverages struct {
 	NumHosts               uint64         `json:"numhosts"`
@@ -344,7 +364,8 @@ type Manager interface {
 	FormContract(*RPCSession, types.PublicKey, types.PublicKey, types.PublicKey, uint64, uint64, uint64, uint64, uint64, uint64) (RenterContract, error)
 
 	// FormContracts forms the required number of contracts with the hosts.
-	FormContracts(types.PublicKey, types.PrivateKey, Allowance) ([]RenterContract, error)
+	// If the context is cancelled, the remaining formations are aborted.
+	FormContracts(context.Context, types.PublicKey, types.PrivateKey, Allowance) ([]RenterContract, error)
 
 	// GetAverages retrieves the host network averages.
 	GetAverages() HostAverages
@@ -380,6 +401,11 @@ type Manager interface {
 	// HostDB is completed.
 	InitialScanComplete() (bool, uint64, error)
 
+	// IssueRenterToken issues a token that binds the renter's public key
+	// to the account with the given email. The token belongs to the
+	// portal session with the given nonce.
+	IssueRenterToken(string, types.PublicKey, []byte) (types.Hash256, time.Time, error)
+
 	// LockSiacoins moves a part of the balance to "locked".
 	LockSiacoins(string, float64) error
 
@@ -413,7 +439,8 @@ type Manager interface {
 	RenewContract(*RPCSession, types.PublicKey, types.FileContractID, uint64, uint64, uint64, uint64, uint64, uint64) (RenterContract, error)
 
 	// RenewContracts renews a set of contracts and returns a new set.
-	RenewContracts(types.PublicKey, types.PrivateKey, Allowance, []types.FileContractID) ([]RenterContract, error)
+	// If the context is cancelled, the remaining renewals are aborted.
+	RenewContracts(context.Context, types.PublicKey, types.PrivateKey, Allowance, []types.FileContractID) ([]RenterContract, error)
 
 	// RenewedFrom returns the ID of the contract the given contract was renewed
 	// from, if any.
@@ -422,6 +449,9 @@ type Manager interface {
 	// Renters retrieves the list of renters.
 	Renters() []Renter
 
+	// RenterSummaries returns the status of every renter.
+	RenterSummaries() ([]RenterSummary, error)
+
 	// RetrieveMetadata retrieves the file metadata from the database.
 	RetrieveMetadata(types.PublicKey, []BucketFiles) ([]FileMetadata, error)
 
@@ -429,6 +459,10 @@ type Manager interface {
 	// the previous months in the specified currency.
 	RetrieveSpendings(string, string) ([]UserSpendings, error)
 
+	// SatelliteFunds returns the siacoins the satellite can spend on
+	// forming and renewing contracts right now.
+	SatelliteFunds() types.Currency
+
 	// ScoreBreakdown returns the score breakdown of the specific host.
 	ScoreBreakdown(HostDBEntry) (HostScoreBreakdown, error)
 
@@ -469,6 +503,10 @@ type Manager interface {
 
 	// UpdateSpendings updates the user's spendings.
 	UpdateSpendings(string, UserSpendings, int, int) error
+
+	// VerifyRenterToken checks that the token was issued to the account
+	// the renter's public key belongs to.
+	VerifyRenterToken(types.PublicKey, types.Hash256) error
 }
 
 // MaintenanceSpending is a helper struct that contains a breakdown of costs
@@ -580,6 +618,30 @@ type RenterContract struct {
 	Unlocked bool
 }
 
+// RemainingWindow returns the number of blocks left at the given
+// height until the renewal window of the contract begins, and until the
+// contract expires. renewWindow is the renter's renew window.
+func (rc RenterContract) RemainingWindow(height, renewWindow uint64) (untilRenewal, untilExpiry uint64) {
+	windowEnd := rc.EndHeight
+	if len(rc.Transaction.FileContractRevisions) > 0 {
+		windowEnd = rc.Transaction.FileContractRevisions[0].WindowEnd
+	}
+	return remainingWindow(height, rc.EndHeight, windowEnd, renewWindow)
+}
+
+// remainingWindow returns the number of blocks left until the renewal
+// window begins and until the proof window ends. Both are zero once
+// the respective height has been reached.
+func remainingWindow(height, windowStart, windowEnd, renewWindow uint64) (untilRenewal, untilExpiry uint64) {
+	if windowStart > renewWindow && windowStart-renewWindow > height {
+		untilRenewal = windowStart - renewWindow - height
+	}
+	if windowEnd > height {
+		untilExpiry = windowEnd - height
+	}
+	return
+}
+
 // Size returns the contract size.
 func (rc *RenterContract) Size() uint64 {
 	var size uint64
@@ -780,6 +842,15 @@ type Renter struct {
 	AccountKey    types.PrivateKey
 }
 
+// RenterSummary contains the status of a renter.
+type RenterSummary struct {
+	PublicKey       types.PublicKey `json:"publickey"`
+	Email           string          `json:"email"`
+	Registered      time.Time       `json:"registered"`
+	ActiveContracts int             `json:"activecontracts"`
+	TotalSpent      float64         `json:"totalspent"` // In SC.
+}
+
 // contractEndHeight returns the height at which the renter's contracts
 // end.
 func (r *Renter) ContractEndHeight() uint64 {
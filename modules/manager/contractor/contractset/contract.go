@@ -22,6 +22,11 @@ func (e *revisionNumberMismatchError) Error() string {
 	return fmt.Sprintf("our revision number (%v) does not match the host's (%v); the host may be acting maliciously", e.ours, e.theirs)
 }
 
+// Is implements errors.Is.
+func (e *revisionNumberMismatchError) Is(target error) bool {
+	return target == modules.ErrStaleRevision
+}
+
 // IsRevisionMismatch returns true if err was caused by the host reporting a
 // different revision number than expected.
 func IsRevisionMismatch(err error) bool {
@@ -347,7 +352,7 @@ func (cs *ContractSet) UpdateContract(rev types.FileContractRevision, sigs []typ
 	if !exists {
 		contract, exists = cs.oldContracts[rev.ParentID]
 		if !exists {
-			return errors.New("contract not found")
+			return modules.ErrContractNotFound
 		}
 	}
 
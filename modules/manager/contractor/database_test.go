@@ -0,0 +1,101 @@
+package contractor
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/modules/manager/contractor/contractset"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/testutil"
+	"go.uber.org/zap"
+)
+
+// emptyDriver is a database driver for an empty database: every query
+// returns no rows, and every statement succeeds.
+type (
+	emptyDriver struct{}
+	emptyConn   struct{}
+	emptyStmt   struct{}
+	emptyRows   struct{}
+)
+
+var registerEmptyDriver sync.Once
+
+// Open implements driver.Driver.
+func (emptyDriver) Open(string) (driver.Conn, error) { return emptyConn{}, nil }
+
+// Prepare implements driver.Conn.
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+
+// Close implements driver.Conn.
+func (emptyConn) Close() error { return nil }
+
+// Begin implements driver.Conn.
+func (emptyConn) Begin() (driver.Tx, error) { return emptyConn{}, nil }
+
+// Commit implements driver.Tx.
+func (emptyConn) Commit() error { return nil }
+
+// Rollback implements driver.Tx.
+func (emptyConn) Rollback() error { return nil }
+
+// Close implements driver.Stmt.
+func (emptyStmt) Close() error { return nil }
+
+// NumInput implements driver.Stmt.
+func (emptyStmt) NumInput() int { return -1 }
+
+// Exec implements driver.Stmt.
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+
+// Query implements driver.Stmt.
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error) { return emptyRows{}, nil }
+
+// Columns implements driver.Rows.
+func (emptyRows) Columns() []string { return nil }
+
+// Close implements driver.Rows.
+func (emptyRows) Close() error { return nil }
+
+// Next implements driver.Rows.
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// newTestContractor returns a synced contractor with an empty database
+// and an in-memory chain.
+func newTestContractor(t *testing.T, hdb modules.HostDB) *Contractor {
+	registerEmptyDriver.Do(func() { sql.Register("contractortest", emptyDriver{}) })
+	db, err := sql.Open("contractortest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	n, genesis := testutil.Network()
+	store, tipState, err := chain.NewDBStore(chain.NewMemDB(), n, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := contractset.NewContractSet(db, zap.NewNop(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Contractor{
+		db:  db,
+		cm:  chain.NewManager(store, tipState),
+		hdb: hdb,
+		log: zap.NewNop(),
+
+		synced:  make(chan struct{}),
+		renters: make(map[types.PublicKey]modules.Renter),
+
+		staticContracts: cs,
+	}
+	close(c.synced)
+	return c
+}
@@ -84,7 +84,7 @@ func (c *Contractor) prepareContractFormation(rpk types.PublicKey, host modules.
 	}
 	err = c.wallet.Sign(c.cm.TipState(), &txn, toSign)
 	if err != nil {
-		c.wallet.Release(append(parents, txn))
+		c.wallet.ReleaseInputsExplicit(txn, parents)
 		return nil, types.Transaction{}, nil, types.ZeroCurrency, types.ZeroCurrency, types.ZeroCurrency, modules.AddContext(err, "unable to sign transaction")
 	}
 
@@ -203,6 +203,7 @@ func (c *Contractor) managedNewContract(rpk types.PublicKey, rsk types.PrivateKe
 		return types.ZeroCurrency, modules.RenterContract{}, err
 	}
 	c.s.BroadcastTransactionSet(txnSet)
+	c.wallet.RecordAudit(txnSet[len(txnSet)-1])
 
 	// Add contract to the set.
 	revisionTxn := types.Transaction{
@@ -253,9 +254,20 @@ func (c *Contractor) managedNewContract(rpk types.PublicKey, rsk types.PrivateKe
 	return contractFunding, contract, nil
 }
 
+// renterExists returns true if the renter is known to the contractor.
+func (c *Contractor) renterExists(rpk types.PublicKey) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, exists := c.renters[rpk]
+	return exists
+}
+
 // FormContracts forms contracts according to the renter's allowance,
-// puts them in the contract set, and returns them.
-func (c *Contractor) FormContracts(rpk types.PublicKey, rsk types.PrivateKey) ([]modules.RenterContract, error) {
+// puts them in the contract set, and returns them. If ctx is cancelled,
+// the remaining formations are aborted, and the contracts formed so far
+// are returned together with the error. These contracts stay in the
+// contract set, so the renter can still retrieve them later.
+func (c *Contractor) FormContracts(ctx context.Context, rpk types.PublicKey, rsk types.PrivateKey) ([]modules.RenterContract, error) {
 	// No contract formation until the contractor is synced.
 	if !c.managedSynced() {
 		return nil, errors.New("contractor isn't synced yet")
@@ -317,9 +329,18 @@ func (c *Contractor) FormContracts(rpk types.PublicKey, rsk types.PrivateKey) ([
 		select {
 		case <-c.tg.StopChan():
 			return nil, errors.New("the contractor was stopped")
+		case <-ctx.Done():
+			c.log.Warn("contract formation aborted", zap.Stringer("renter", rpk), zap.Int("formed", len(contractSet)), zap.Error(ctx.Err()))
+			return contractSet, modules.AddContext(ctx.Err(), "contract formation aborted")
 		default:
 		}
 
+		// Stop if the renter has been deleted in the meantime.
+		if !c.renterExists(rpk) {
+			c.log.Warn("renter deleted, contract formation aborted", zap.Stringer("renter", rpk), zap.Int("formed", len(contractSet)))
+			return contractSet, fmt.Errorf("contract formation aborted: %w", ErrRenterNotFound)
+		}
+
 		// If no more contracts are needed, break.
 		if neededContracts <= 0 {
 			break
@@ -513,6 +534,7 @@ func (c *Contractor) managedTrustlessNewContract(s *modules.RPCSession, rpk, epk
 		return types.ZeroCurrency, modules.RenterContract{}, err
 	}
 	c.s.BroadcastTransactionSet(txnSet)
+	c.wallet.RecordAudit(txnSet[len(txnSet)-1])
 
 	// Add contract to the set.
 	revisionTxn := types.Transaction{
@@ -0,0 +1,59 @@
+package contractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+)
+
+// testHostDB is a HostDB that returns a fixed set of hosts.
+type testHostDB struct {
+	modules.HostDB
+	hosts    []modules.HostDBEntry
+	onSelect func()
+}
+
+// RandomHostsWithAllowance implements modules.HostDB.
+func (hdb *testHostDB) RandomHostsWithAllowance(int, []types.PublicKey, []types.PublicKey, modules.Allowance) ([]modules.HostDBEntry, error) {
+	if hdb.onSelect != nil {
+		hdb.onSelect()
+	}
+	return hdb.hosts, nil
+}
+
+func TestFormContractsRenterDeleted(t *testing.T) {
+	hdb := &testHostDB{hosts: make([]modules.HostDBEntry, 3)}
+	c := newTestContractor(t, hdb)
+	rpk := types.GeneratePrivateKey().PublicKey()
+	c.renters[rpk] = modules.Renter{
+		PublicKey: rpk,
+		Allowance: modules.Allowance{
+			Funds:  types.Siacoins(1000),
+			Hosts:  3,
+			Period: 100,
+		},
+	}
+
+	// Delete the renter after the hosts have been selected, before
+	// any contract is formed.
+	hdb.onSelect = func() {
+		c.mu.Lock()
+		delete(c.renters, rpk)
+		c.mu.Unlock()
+	}
+	contracts, err := c.FormContracts(context.Background(), rpk, types.GeneratePrivateKey())
+	if !errors.Is(err, ErrRenterNotFound) {
+		t.Fatalf("expected %v, got %v", ErrRenterNotFound, err)
+	} else if len(contracts) != 0 {
+		t.Fatalf("expected no contracts, got %v", len(contracts))
+	}
+
+	// An unknown renter is rejected right away.
+	hdb.onSelect = func() { t.Fatal("unexpected host selection") }
+	if _, err := c.FormContracts(context.Background(), rpk, types.GeneratePrivateKey()); !errors.Is(err, ErrRenterNotFound) {
+		t.Fatalf("expected %v, got %v", ErrRenterNotFound, err)
+	}
+}
@@ -251,6 +251,7 @@ func (c *Contractor) managedRenewContract(oldContract modules.RenterContract, rp
 			return modules.AddContext(err, "invalid transaction set")
 		}
 		c.s.BroadcastTransactionSet(txnSet)
+		c.wallet.RecordAudit(txnSet[len(txnSet)-1])
 
 		return nil
 	})
@@ -401,8 +402,10 @@ func (c *Contractor) managedRenewContract(oldContract modules.RenterContract, rp
 	return fundsSpent, newContract, nil
 }
 
-// RenewContracts tries to renew a given set of contracts.
-func (c *Contractor) RenewContracts(rpk types.PublicKey, rsk types.PrivateKey, contracts []types.FileContractID) ([]modules.RenterContract, error) {
+// RenewContracts tries to renew a given set of contracts. If ctx is
+// cancelled, the remaining renewals are aborted, and the contracts
+// renewed so far are returned together with the error.
+func (c *Contractor) RenewContracts(ctx context.Context, rpk types.PublicKey, rsk types.PrivateKey, contracts []types.FileContractID) ([]modules.RenterContract, error) {
 	// No contract renewal until the contractor is synced.
 	if !c.managedSynced() {
 		return nil, errors.New("contractor isn't synced yet")
@@ -510,9 +513,18 @@ func (c *Contractor) RenewContracts(rpk types.PublicKey, rsk types.PrivateKey, c
 		case <-c.tg.StopChan():
 			c.log.Info("returning because the manager was stopped")
 			return nil, errors.New("the manager was stopped")
+		case <-ctx.Done():
+			c.log.Warn("contract renewal aborted", zap.Stringer("renter", rpk), zap.Int("renewed", len(contractSet)), zap.Error(ctx.Err()))
+			return contractSet, modules.AddContext(ctx.Err(), "contract renewal aborted")
 		default:
 		}
 
+		// Stop if the renter has been deleted in the meantime.
+		if !c.renterExists(rpk) {
+			c.log.Warn("renter deleted, contract renewal aborted", zap.Stringer("renter", rpk), zap.Int("renewed", len(contractSet)))
+			return contractSet, fmt.Errorf("contract renewal aborted: %w", ErrRenterNotFound)
+		}
+
 		// Skip this renewal if we don't have enough funds remaining.
 		if renewal.amount.Cmp(fundsRemaining) > 0 {
 			c.log.Warn("skipping renewal because there are not enough funds remaining in the allowance", zap.Stringer("fcid", renewal.contract.ID), zap.Stringer("amount", renewal.amount), zap.Stringer("remaining", fundsRemaining))
@@ -742,6 +754,7 @@ func (c *Contractor) managedTrustlessRenewContract(s *modules.RPCSession, rpk ty
 			return modules.AddContext(err, "invalid transaction set")
 		}
 		c.s.BroadcastTransactionSet(txnSet)
+		c.wallet.RecordAudit(txnSet[len(txnSet)-1])
 
 		return nil
 	})
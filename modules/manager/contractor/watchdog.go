@@ -208,8 +208,10 @@ func (w *watchdog) callScanRevertUpdate(cru chain.RevertUpdate) {
 
 // sendTxnSet broadcasts a transaction set and logs errors that are not
 // duplicate transaction errors. (This is because the watchdog may be
-// overzealous in sending out transactions).
-func (w *watchdog) sendTxnSet(txnSet []types.Transaction, reason string) {
+// overzealous in sending out transactions). If audit is set, the last
+// transaction of the set is recorded in the wallet's audit log once it
+// is broadcast.
+func (w *watchdog) sendTxnSet(txnSet []types.Transaction, reason string, audit bool) {
 	w.contractor.log.Info("sending txn set to txpool", zap.String("reason", reason))
 
 	// Send the transaction set in a go-routine to avoid deadlock when this
@@ -226,6 +228,9 @@ func (w *watchdog) sendTxnSet(txnSet []types.Transaction, reason string) {
 			w.contractor.log.Error("watchdog send transaction error", zap.String("reason", reason), zap.Error(err))
 		} else {
 			w.contractor.s.BroadcastTransactionSet(txnSet)
+			if audit {
+				w.contractor.wallet.RecordAudit(txnSet[len(txnSet)-1])
+			}
 		}
 	}()
 }
@@ -724,7 +729,7 @@ func (w *watchdog) checkUnconfirmedContract(fcID types.FileContractID, contractD
 		// Try to broadcast the transaction set again.
 		debugStr := fmt.Sprintf("sending formation txn for contract with id: %v at h=%d wh=%d", fcID, w.blockHeight, contractData.formationSweepHeight)
 		w.contractor.log.Info(debugStr)
-		w.sendTxnSet(contractData.formationTxnSet, debugStr)
+		w.sendTxnSet(contractData.formationTxnSet, debugStr, false)
 	}
 }
 
@@ -767,7 +772,7 @@ func (w *watchdog) managedCheckMonitoredRevision(fcID types.FileContractID, heig
 		// until it sees the revision or the window has closed.)
 		debugStr := fmt.Sprintf("sending revision txn for contract with id: %v revNum: %d", fcID, lastRevNum)
 		w.contractor.log.Info(debugStr)
-		w.sendTxnSet([]types.Transaction{lastRevisionTxn}, debugStr)
+		w.sendTxnSet([]types.Transaction{lastRevisionTxn}, debugStr, false)
 	}
 }
 
@@ -825,7 +830,7 @@ func (w *watchdog) sweepContractInputs(fcID types.FileContractID, contractData *
 
 	signedTxnSet := append(parents, txn)
 	debugStr := fmt.Sprintf("SweepTxn for contract with id: %v", fcID)
-	w.sendTxnSet(signedTxnSet, debugStr)
+	w.sendTxnSet(signedTxnSet, debugStr, true)
 }
 
 // managedContractStatus returns the status of a contract in the watchdog if it
@@ -861,5 +866,5 @@ func (w *watchdog) threadedSendMostRecentRevision(metadata modules.RenterContrac
 	lastRevNum := lastRevisionTxn.FileContractRevisions[0].RevisionNumber
 
 	debugStr := fmt.Sprintf("sending most recent revision txn for contract with id: %v revNum: %d", fcID, lastRevNum)
-	w.sendTxnSet([]types.Transaction{lastRevisionTxn}, debugStr)
+	w.sendTxnSet([]types.Transaction{lastRevisionTxn}, debugStr, false)
 }
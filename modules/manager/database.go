@@ -189,6 +189,39 @@ func (m *Manager) UpdateBalance(email string, ub modules.UserBalance) error {
 	return err
 }
 
+// RenterSummaries returns the status of every renter. The total spend
+// includes the used funds and the overhead over all periods.
+func (m *Manager) RenterSummaries() ([]modules.RenterSummary, error) {
+	rows, err := m.db.Query(`
+		SELECT ctr_renters.public_key, ctr_renters.email, pt_accounts.time,
+			COALESCE(SUM(mg_spendings.used + mg_spendings.overhead), 0)
+		FROM ctr_renters
+		INNER JOIN pt_accounts ON ctr_renters.email = pt_accounts.email
+		LEFT JOIN mg_spendings ON ctr_renters.email = mg_spendings.email
+		GROUP BY ctr_renters.public_key, ctr_renters.email, pt_accounts.time
+	`)
+	if err != nil {
+		return nil, modules.AddContext(err, "couldn't query renters")
+	}
+	defer rows.Close()
+
+	var summaries []modules.RenterSummary
+	for rows.Next() {
+		var rs modules.RenterSummary
+		var pk []byte
+		var registered int64
+		if err := rows.Scan(&pk, &rs.Email, &registered, &rs.TotalSpent); err != nil {
+			return nil, modules.AddContext(err, "couldn't scan renter")
+		}
+		copy(rs.PublicKey[:], pk)
+		rs.Registered = time.Unix(registered, 0)
+		rs.ActiveContracts = len(m.ContractsByRenter(rs.PublicKey))
+		summaries = append(summaries, rs)
+	}
+
+	return summaries, rows.Err()
+}
+
 // GetSpendings retrieves the user's spendings.
 func (m *Manager) GetSpendings(email string, month, year int) (us modules.UserSpendings, err error) {
 	period := fmt.Sprintf("%02d%04d", month, year)
@@ -0,0 +1,41 @@
+package hostdb
+
+import (
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+func TestHostPeriodCostRedundancy(t *testing.T) {
+	he := modules.HostDBEntry{
+		Settings: rhpv2.HostSettings{
+			Collateral:   types.Siacoins(1).Div64(1e12),
+			StoragePrice: types.Siacoins(1).Div64(1e12),
+		},
+	}
+	he.PriceTable.WriteStoreCost = types.Siacoins(1).Div64(1e12)
+	he.PriceTable.WriteLengthCost = types.Siacoins(1).Div64(1e12)
+	he.PriceTable.ReadLengthCost = types.Siacoins(1).Div64(1e12)
+	cost := func(minShards, totalShards uint64) types.Currency {
+		return hostPeriodCostForScore(modules.Allowance{
+			Hosts:            10,
+			Period:           4032,
+			ExpectedStorage:  1 << 40,
+			ExpectedUpload:   1 << 30,
+			ExpectedDownload: 1 << 30,
+			MinShards:        minShards,
+			TotalShards:      totalShards,
+		}, he)
+	}
+
+	// A redundancy of 10/4 is 2.5, not 2.
+	if c, c2 := cost(4, 10), cost(2, 5); !c.Equals(c2) {
+		t.Fatalf("expected 10/4 and 5/2 to cost the same, got %v and %v", c, c2)
+	} else if c.Cmp(cost(5, 10)) <= 0 {
+		t.Fatal("expected 10/4 to cost more than 10/5")
+	} else if c.Cmp(cost(1, 3)) >= 0 {
+		t.Fatal("expected 10/4 to cost less than 3/1")
+	}
+}
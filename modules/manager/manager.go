@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"io"
@@ -94,7 +95,7 @@ type hostContractor interface {
 
 	// FormContracts forms up to the specified number of contracts, puts them
 	// in the contract set, and returns them.
-	FormContracts(types.PublicKey, types.PrivateKey) ([]modules.RenterContract, error)
+	FormContracts(context.Context, types.PublicKey, types.PrivateKey) ([]modules.RenterContract, error)
 
 	// GetModifiedSlabs returns the slabs modified since the last retrieval.
 	GetModifiedSlabs(types.PublicKey) ([]modules.Slab, error)
@@ -120,7 +121,7 @@ type hostContractor interface {
 	RenewContract(*modules.RPCSession, types.PublicKey, modules.RenterContract, types.Currency, uint64) (modules.RenterContract, error)
 
 	// RenewContracts tries to renew the given set of contracts.
-	RenewContracts(types.PublicKey, types.PrivateKey, []types.FileContractID) ([]modules.RenterContract, error)
+	RenewContracts(context.Context, types.PublicKey, types.PrivateKey, []types.FileContractID) ([]modules.RenterContract, error)
 
 	// Renters return the list of renters.
 	Renters() []modules.Renter
@@ -365,8 +366,34 @@ func (m *Manager) OldContractsByRenter(rpk types.PublicKey) []modules.RenterCont
 // the hosts. The estimation will be done using the provided allowance.
 // The final allowance used will be returned.
 func (m *Manager) PriceEstimation(allowance modules.Allowance, invoicing bool) (float64, modules.Allowance, error) {
+	est, allowance, err := m.estimateCost(allowance)
+	if err != nil {
+		return 0, allowance, err
+	}
+	return renterCost(est, invoicing), allowance, nil
+}
+
+// renterCost adds the satellite fee to the estimated cost of forming
+// contracts and converts it to siacoins.
+func renterCost(est types.Currency, invoicing bool) float64 {
+	var fee float64
+	if invoicing {
+		fee = modules.StaticPricing.FormContract.Invoicing
+	} else {
+		fee = modules.StaticPricing.FormContract.PrePayment
+	}
+	cost := modules.Float64(est) * (1 + fee)
+	h := modules.Float64(types.HastingsPerSiacoin)
+	return cost / h
+}
+
+// estimateCost estimates the cost of forming contracts with the hosts,
+// which the satellite pays from its wallet. The estimation will be done
+// using the provided allowance. The final allowance used will be
+// returned.
+func (m *Manager) estimateCost(allowance modules.Allowance) (types.Currency, modules.Allowance, error) {
 	if err := m.tg.Add(); err != nil {
-		return 0, modules.Allowance{}, err
+		return types.ZeroCurrency, modules.Allowance{}, err
 	}
 	defer m.tg.Done()
 
@@ -436,7 +463,7 @@ func (m *Manager) PriceEstimation(allowance modules.Allowance, invoicing bool) (
 		var err error
 		randHosts, err := m.hostDB.RandomHostsWithAllowance(int(allowance.Hosts)-len(hosts), pks, pks, allowance)
 		if err != nil {
-			return 0, allowance, modules.AddContext(err, "could not generate estimate, could not get random hosts")
+			return types.ZeroCurrency, allowance, modules.AddContext(err, "could not generate estimate, could not get random hosts")
 		}
 		// As the returned random hosts are checked for IP violations and double
 		// entries against the current slice of hosts, the returned hosts can be
@@ -445,7 +472,7 @@ func (m *Manager) PriceEstimation(allowance modules.Allowance, invoicing bool) (
 	}
 	// Check if there are zero hosts, which means no estimation can be made.
 	if len(hosts) == 0 {
-		return 0, allowance, errors.New("estimate cannot be made, there are no hosts")
+		return types.ZeroCurrency, allowance, errors.New("estimate cannot be made, there are no hosts")
 	}
 
 	// Add up the costs for each host.
@@ -518,7 +545,7 @@ func (m *Manager) PriceEstimation(allowance modules.Allowance, invoicing bool) (
 	// funding of the allowance is not enough as that would cause the
 	// fundingPerHost to be less than the contract price.
 	if numHosts == 0 {
-		return 0, allowance, errors.New("funding insufficient for number of hosts")
+		return types.ZeroCurrency, allowance, errors.New("funding insufficient for number of hosts")
 	}
 
 	// Calculate average collateral and determine collateral for allowance.
@@ -543,21 +570,13 @@ func (m *Manager) PriceEstimation(allowance modules.Allowance, invoicing bool) (
 	est := totalContractCost.Add(totalDownloadCost)
 	est = est.Add(totalStorageCost)
 	est = est.Add(totalUploadCost)
-	var fee float64
-	if invoicing {
-		fee = modules.StaticPricing.FormContract.Invoicing
-	} else {
-		fee = modules.StaticPricing.FormContract.PrePayment
-	}
-	cost := modules.Float64(est) * (1 + fee)
-	h := modules.Float64(types.HastingsPerSiacoin)
 	allowance.Funds = totalCost
 
 	m.mu.Lock()
 	m.lastEstimationHosts = hosts
 	m.mu.Unlock()
 
-	return cost / h, allowance, nil
+	return est, allowance, nil
 }
 
 // ContractPriceEstimation estimates the cost in siacoins of forming a contract
@@ -667,7 +686,7 @@ func (m *Manager) CreateNewRenter(email string, pk types.PublicKey) {
 
 // FormContracts forms the specified number of contracts with the hosts
 // and returns them.
-func (m *Manager) FormContracts(rpk types.PublicKey, rsk types.PrivateKey, a modules.Allowance) ([]modules.RenterContract, error) {
+func (m *Manager) FormContracts(ctx context.Context, rpk types.PublicKey, rsk types.PrivateKey, a modules.Allowance) ([]modules.RenterContract, error) {
 	// Get the user balance.
 	renter, err := m.GetRenter(rpk)
 	if err != nil {
@@ -679,15 +698,23 @@ func (m *Manager) FormContracts(rpk types.PublicKey, rsk types.PrivateKey, a mod
 	}
 
 	// Get the estimated costs and update the allowance with them.
-	estimation, a, err := m.PriceEstimation(a, ub.Subscribed)
+	cost, a, err := m.estimateCost(a)
 	if err != nil {
 		return nil, err
 	}
+	estimation := renterCost(cost, ub.Subscribed)
 
 	// Check if the balance is sufficient to cover the costs.
 	if !ub.Subscribed && ub.Balance < estimation {
 		return nil, errors.New("insufficient account balance")
 	}
+
+	// Check if the satellite can fund the contracts. The allowance
+	// funds are a generous upper bound, the estimate is what forming
+	// the contracts is expected to cost.
+	if m.SatelliteFunds().Cmp(cost) < 0 {
+		return nil, modules.ErrInsufficientSatelliteFunds
+	}
 	if ub.OnHold > 0 && ub.OnHold < uint64(time.Now().Unix()-int64(modules.OnHoldThreshold.Seconds())) {
 		return nil, errors.New("account on hold")
 	}
@@ -699,13 +726,18 @@ func (m *Manager) FormContracts(rpk types.PublicKey, rsk types.PrivateKey, a mod
 	}
 
 	// Form the contracts.
-	contractSet, err := m.hostContractor.FormContracts(rpk, rsk)
+	contractSet, err := m.hostContractor.FormContracts(ctx, rpk, rsk)
 
 	return contractSet, err
 }
 
+// SatelliteFunds returns the spendable balance of the satellite wallet.
+func (m *Manager) SatelliteFunds() types.Currency {
+	return m.wallet.SpendableBalance()
+}
+
 // RenewContracts renews a set of contracts and returns a new set.
-func (m *Manager) RenewContracts(rpk types.PublicKey, rsk types.PrivateKey, a modules.Allowance, contracts []types.FileContractID) ([]modules.RenterContract, error) {
+func (m *Manager) RenewContracts(ctx context.Context, rpk types.PublicKey, rsk types.PrivateKey, a modules.Allowance, contracts []types.FileContractID) ([]modules.RenterContract, error) {
 	// Get the user balance.
 	renter, err := m.GetRenter(rpk)
 	if err != nil {
@@ -717,15 +749,27 @@ func (m *Manager) RenewContracts(rpk types.PublicKey, rsk types.PrivateKey, a mo
 	}
 
 	// Get the estimated costs and update the allowance with them.
-	estimation, a, err := m.PriceEstimation(a, ub.Subscribed)
+	cost, a, err := m.estimateCost(a)
 	if err != nil {
 		return nil, err
 	}
+	estimation := renterCost(cost, ub.Subscribed)
 
 	// Check if the balance is sufficient to cover the costs.
 	if !ub.Subscribed && ub.Balance < estimation {
 		return nil, errors.New("insufficient account balance")
 	}
+
+	// Check if the satellite can fund the contracts. Only a part of
+	// the contract set may be renewed, so the estimate of the whole set
+	// is scaled down to the batch.
+	batchCost := cost
+	if n := uint64(len(contracts)); n < a.Hosts {
+		batchCost = cost.Mul64(n).Div64(a.Hosts)
+	}
+	if m.SatelliteFunds().Cmp(batchCost) < 0 {
+		return nil, modules.ErrInsufficientSatelliteFunds
+	}
 	if ub.OnHold > 0 && ub.OnHold < uint64(time.Now().Unix()-int64(modules.OnHoldThreshold.Seconds())) {
 		return nil, errors.New("account on hold")
 	}
@@ -737,7 +781,7 @@ func (m *Manager) RenewContracts(rpk types.PublicKey, rsk types.PrivateKey, a mo
 	}
 
 	// Renew the contracts.
-	contractSet, err := m.hostContractor.RenewContracts(rpk, rsk, contracts)
+	contractSet, err := m.hostContractor.RenewContracts(ctx, rpk, rsk, contracts)
 
 	return contractSet, err
 }
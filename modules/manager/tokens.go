@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+const (
+	// renterTokenValidity is how long a renter token stays valid.
+	renterTokenValidity = 30 * 24 * time.Hour
+
+	// maxRenterTokens is the maximum number of live tokens per account.
+	// Issuing another one revokes the oldest.
+	maxRenterTokens = 5
+)
+
+// IssueRenterToken issues a token that binds the renter's public key to
+// the account with the given email. The renter can present it to the
+// provider to prove the ownership of the account. Only the hash of the
+// token is stored. The token belongs to the portal session with the
+// given nonce, and the portal revokes it when the session is closed.
+func (m *Manager) IssueRenterToken(email string, pk types.PublicKey, session []byte) (token types.Hash256, expires time.Time, err error) {
+	renter, err := m.GetRenter(pk)
+	if err != nil {
+		return types.Hash256{}, time.Time{}, modules.AddContext(err, "couldn't find renter")
+	}
+	if renter.Email != email {
+		return types.Hash256{}, time.Time{}, errors.New("public key doesn't belong to the account")
+	}
+
+	// Remove the expired tokens first.
+	if _, err := m.db.Exec("DELETE FROM mg_tokens WHERE expires < ?", time.Now().Unix()); err != nil {
+		return types.Hash256{}, time.Time{}, modules.AddContext(err, "couldn't delete expired tokens")
+	}
+
+	// Make room for the new token.
+	var count int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM mg_tokens WHERE email = ?", email).Scan(&count); err != nil {
+		return types.Hash256{}, time.Time{}, modules.AddContext(err, "couldn't count tokens")
+	}
+	if count >= maxRenterTokens {
+		_, err := m.db.Exec(`
+			DELETE FROM mg_tokens
+			WHERE email = ?
+			ORDER BY expires ASC
+			LIMIT ?
+		`, email, count-maxRenterTokens+1)
+		if err != nil {
+			return types.Hash256{}, time.Time{}, modules.AddContext(err, "couldn't delete oldest tokens")
+		}
+	}
+
+	frand.Read(token[:])
+	hash := types.HashBytes(token[:])
+	expires = time.Now().Add(renterTokenValidity)
+	_, err = m.db.Exec(`
+		INSERT INTO mg_tokens (token_hash, email, public_key, session, expires)
+		VALUES (?, ?, ?, ?, ?)
+	`, hash[:], email, pk[:], session, expires.Unix())
+	if err != nil {
+		return types.Hash256{}, time.Time{}, modules.AddContext(err, "couldn't save token")
+	}
+
+	return token, expires, nil
+}
+
+// VerifyRenterToken checks that the token was issued to the account the
+// renter's public key belongs to, and that it hasn't expired.
+func (m *Manager) VerifyRenterToken(pk types.PublicKey, token types.Hash256) error {
+	var email string
+	var expires int64
+	hash := types.HashBytes(token[:])
+	err := m.db.QueryRow(`
+		SELECT email, expires
+		FROM mg_tokens
+		WHERE token_hash = ? AND public_key = ?
+	`, hash[:], pk[:]).Scan(&email, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return modules.ErrInvalidRenterToken
+	} else if err != nil {
+		return modules.AddContext(err, "couldn't query token")
+	}
+	if time.Now().Unix() > expires {
+		return modules.ErrInvalidRenterToken
+	}
+
+	// Make sure the key is still bound to the same account.
+	renter, err := m.GetRenter(pk)
+	if err != nil || renter.Email != email {
+		return modules.ErrInvalidRenterToken
+	}
+
+	return nil
+}
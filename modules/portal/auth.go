@@ -5,11 +5,13 @@ import (
 	"errors"
 	"net/http"
 	"net/mail"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/mike76-dev/sia-satellite/modules"
 	"go.uber.org/zap"
 )
 
@@ -22,12 +24,21 @@ const (
 		<html>
 		<body>
     	<h2>Please Verify Your Email Address</h2>
-	    <p>Click on the following link to complete your account registration. This link is valid within the next 24 hours.</p>
+	    <p>Click on the following link to complete your account registration. This link is valid within the next {{.TTL}}.</p>
 	    <p><a href="{{.Path}}?token={{.Token}}">{{.Path}}?token={{.Token}}</a></p>
 		</body>
 		</html>
 	`
 
+	// verifyTextTemplate is the plain-text version of
+	// verifyTemplate.
+	verifyTextTemplate = `Please Verify Your Email Address
+
+Click on the following link to complete your account registration. This link is valid within the next {{.TTL}}.
+
+{{.Path}}?token={{.Token}}
+`
+
 	// resetTemplate contains the text send by email when a
 	// user wants to reset their password.
 	resetTemplate = `
@@ -36,18 +47,39 @@ const (
 		<html>
 		<body>
     	<h2>Reset Your Password</h2>
-	    <p>Click on the following link to enter a new password. This link is valid within the next 60 minutes.</p>
+	    <p>Click on the following link to enter a new password. This link is valid within the next {{.TTL}}.</p>
 	    <p><a href="{{.Path}}?token={{.Token}}">{{.Path}}?token={{.Token}}</a></p>
 		</body>
 		</html>
 	`
+
+	// resetTextTemplate is the plain-text version of resetTemplate.
+	resetTextTemplate = `Reset Your Password
+
+Click on the following link to enter a new password. This link is valid within the next {{.TTL}}.
+
+{{.Path}}?token={{.Token}}
+`
 )
 
 type (
-	// authLink holds the parts of an authentication link.
+	// authLink holds the parts of an authentication link. TTL is the
+	// lifetime of the link in English, e.g. "24 hours"; TTLMinutes is
+	// the same in minutes, for the localized templates.
 	authLink struct {
-		Path  string
-		Token string
+		Path       string
+		Token      string
+		TTL        string
+		TTLMinutes int64
+	}
+
+	// session contains the information about an open login session.
+	session struct {
+		ID         int64  `json:"id"`
+		RemoteHost string `json:"remotehost"`
+		Issued     int64  `json:"issued"`
+		Expires    int64  `json:"expires"`
+		Current    bool   `json:"current"`
 	}
 )
 
@@ -90,7 +122,7 @@ func checkPassword(pwd string) Error {
 
 // loginHandlerPOST handles the POST /auth/login requests.
 func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -105,9 +137,12 @@ func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 	email := strings.ToLower(data.Email)
 	password := req.Header.Get("Satellite-Password")
+	host := getRemoteHost(req)
 
-	// Check if the user account exists.
-	exists, cErr := api.portal.userExists(email)
+	// Check if the account is locked. This is checked before the
+	// account lookup, so the response is the same whether the
+	// account exists or not.
+	until, cErr := api.portal.getLockout(email)
 	if cErr != nil {
 		api.portal.log.Error("error querying database", zap.Error(cErr))
 		writeError(w,
@@ -117,27 +152,13 @@ func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request,
 			}, http.StatusInternalServerError)
 		return
 	}
-
-	if !exists {
-		// Wrong email address. Check and update stats.
-		if err := api.portal.checkAndUpdateFailedLogins(getRemoteHost(req)); err != nil {
-			writeError(w,
-				Error{
-					Code:    httpErrorTooManyRequests,
-					Message: "too many failed login attempts",
-				}, http.StatusTooManyRequests)
-			return
-		}
-		writeError(w,
-			Error{
-				Code:    httpErrorWrongCredentials,
-				Message: "invalid combination of email and password",
-			}, http.StatusBadRequest)
+	if until.After(time.Now()) {
+		writeAccountLocked(w, until)
 		return
 	}
 
-	// Check if the account is verified and the password is correct.
-	verified, passwordOK, cErr := api.portal.isVerified(email, password)
+	// Check if the user account exists.
+	exists, cErr := api.portal.userExists(email)
 	if cErr != nil {
 		api.portal.log.Error("error querying database", zap.Error(cErr))
 		writeError(w,
@@ -148,10 +169,48 @@ func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
-	// Wrong password or the email is not verified.
+	verified, passwordOK := false, false
+	if exists {
+		// Check if the account is verified and the password is correct.
+		verified, passwordOK, cErr = api.portal.isVerified(email, password)
+		if cErr != nil {
+			api.portal.log.Error("error querying database", zap.Error(cErr))
+			writeError(w,
+				Error{
+					Code:    httpErrorInternal,
+					Message: "internal error",
+				}, http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// Compute the password hash anyway, so that the response takes
+		// roughly the same time as in the case of a wrong password and
+		// does not leak if the account exists.
+		_ = passwordHash(password)
+	}
+
+	// Wrong email address, wrong password, or the email is not
+	// verified.
 	if !passwordOK || !verified {
+		// Update the account lockout state.
+		until, cErr := api.portal.updateFailedLogins(email)
+		if cErr != nil {
+			api.portal.log.Error("error querying database", zap.Error(cErr))
+			writeError(w,
+				Error{
+					Code:    httpErrorInternal,
+					Message: "internal error",
+				}, http.StatusInternalServerError)
+			return
+		}
+		if until.After(time.Now()) {
+			writeAccountLocked(w, until)
+			return
+		}
+
 		// Check and update login stats.
-		if err := api.portal.checkAndUpdateFailedLogins(getRemoteHost(req)); err != nil {
+		if err := api.portal.checkAndUpdateFailedLogins(host); err != nil {
+			setRetryAfter(w, err)
 			writeError(w,
 				Error{
 					Code:    httpErrorTooManyRequests,
@@ -168,9 +227,20 @@ func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
-	// Login successful, generate a cookie.
+	// Login successful, reset the failed login counter.
+	if cErr := api.portal.resetFailedLogins(email); cErr != nil {
+		api.portal.log.Error("error querying database", zap.Error(cErr))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	// Generate a cookie.
 	t := time.Now().Add(7 * 24 * time.Hour)
-	token, tErr := api.portal.generateToken(cookiePrefix, email, t)
+	token, tErr := api.portal.generateSessionToken(email, getRemoteHost(req), t)
 	if tErr != nil {
 		api.portal.log.Error("error generating token", zap.Error(tErr))
 		writeError(w,
@@ -195,7 +265,7 @@ func (api *portalAPI) loginHandlerPOST(w http.ResponseWriter, req *http.Request,
 // registerHandlerPOST handles the POST /auth/register requests.
 func (api *portalAPI) registerHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Decode request body.
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -221,6 +291,11 @@ func (api *portalAPI) registerHandlerPOST(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	// Verify the CAPTCHA.
+	if !api.checkCaptcha(w, req) {
+		return
+	}
+
 	// Check if the email address is already registered.
 	exists, cErr := api.portal.userExists(email)
 	if cErr != nil {
@@ -259,6 +334,7 @@ func (api *portalAPI) registerHandlerPOST(w http.ResponseWriter, req *http.Reque
 		if !passwordOK {
 			// Check and update login stats.
 			if cErr := api.portal.checkAndUpdateFailedLogins(getRemoteHost(req)); cErr != nil {
+				setRetryAfter(w, cErr)
 				writeError(w,
 					Error{
 						Code:    httpErrorTooManyRequests,
@@ -295,11 +371,25 @@ func (api *portalAPI) registerHandlerPOST(w http.ResponseWriter, req *http.Reque
 	writeSuccess(w)
 }
 
+// linkBase returns the base URL of the links sent by email. If the
+// portal URL is not configured, the Referer header is used.
+func (p *Portal) linkBase(req *http.Request) string {
+	if p.baseURL != "" {
+		return p.baseURL
+	}
+	path := req.Header["Referer"]
+	if len(path) == 0 {
+		return ""
+	}
+	return path[0]
+}
+
 // sendVerificationLinkByMail is a wrapper function for sending a
 // verification link by email.
 func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *http.Request, email string) bool {
 	// Check and update stats.
 	if err := api.portal.checkAndUpdateVerifications(getRemoteHost(req)); err != nil {
+		setRetryAfter(w, err)
 		writeError(w,
 			Error{
 				Code:    httpErrorTooManyRequests,
@@ -309,7 +399,7 @@ func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *htt
 	}
 
 	// Generate a verification link.
-	token, err := api.portal.generateToken(verifyPrefix, email, time.Now().Add(24*time.Hour))
+	token, err := api.portal.generateToken(verifyPrefix, email, time.Now().Add(api.portal.verifyTokenTTL))
 	if err != nil {
 		api.portal.log.Error("error generating token", zap.Error(err))
 		writeError(w,
@@ -319,8 +409,8 @@ func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *htt
 			}, http.StatusInternalServerError)
 		return false
 	}
-	path := req.Header["Referer"]
-	if len(path) == 0 {
+	path := api.portal.linkBase(req)
+	if path == "" {
 		api.portal.log.Error("unable to fetch referer URL")
 		writeError(w,
 			Error{
@@ -329,14 +419,12 @@ func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *htt
 			}, http.StatusInternalServerError)
 		return false
 	}
-	link := authLink{
-		Path:  path[0],
-		Token: token,
-	}
+	link := newAuthLink(path, token, api.portal.verifyTokenTTL)
 
 	// Generate email body.
+	et := api.portal.loadTemplate("verify", getLocale(req))
 	t := template.New("verify")
-	t, err = t.Parse(verifyTemplate)
+	t, err = t.Parse(et.HTML)
 	if err != nil {
 		api.portal.log.Error("unable to parse HTML template", zap.Error(err))
 		writeError(w,
@@ -348,9 +436,22 @@ func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *htt
 	}
 	var b bytes.Buffer
 	t.Execute(&b, link)
+	tt := template.New("verify-text")
+	tt, err = tt.Parse(et.Text)
+	if err != nil {
+		api.portal.log.Error("unable to parse text template", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "unable to send verification link",
+			}, http.StatusInternalServerError)
+		return false
+	}
+	var tb bytes.Buffer
+	tt.Execute(&tb, link)
 
 	// Send verification link by email.
-	err = api.portal.ms.SendMail("Sia Satellite", email, "Action Required", &b)
+	err = api.portal.ms.SendMultipartMail(api.portal.mailFromName, email, et.Subject, &tb, &b)
 	if err != nil {
 		api.portal.log.Error("unable to send verification link", zap.Error(err))
 		writeError(w,
@@ -364,11 +465,37 @@ func (api *portalAPI) sendVerificationLinkByMail(w http.ResponseWriter, req *htt
 	return true
 }
 
+// newAuthLink returns the template data of a link valid for the
+// given time.
+func newAuthLink(path, token string, ttl time.Duration) authLink {
+	return authLink{
+		Path:       path,
+		Token:      token,
+		TTL:        formatTTL(ttl),
+		TTLMinutes: int64(ttl / time.Minute),
+	}
+}
+
+// formatTTL formats the lifetime of a link in whole hours, or in
+// minutes if it isn't a multiple of an hour.
+func formatTTL(ttl time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return strconv.FormatInt(n, 10) + " " + unit + "s"
+	}
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		return plural(int64(ttl/time.Hour), "hour")
+	}
+	return plural(int64(ttl/time.Minute), "minute")
+}
+
 // sendPasswordResetLinkByMail is a wrapper function for sending a
 // password reset link by email.
 func (api *portalAPI) sendPasswordResetLinkByMail(w http.ResponseWriter, req *http.Request, email string) bool {
 	// Generate a password reset link.
-	token, err := api.portal.generateToken(resetPrefix, email, time.Now().Add(time.Hour))
+	token, err := api.portal.generateToken(resetPrefix, email, time.Now().Add(api.portal.resetTokenTTL))
 	if err != nil {
 		api.portal.log.Error("error generating token", zap.Error(err))
 		writeError(w,
@@ -378,8 +505,8 @@ func (api *portalAPI) sendPasswordResetLinkByMail(w http.ResponseWriter, req *ht
 			}, http.StatusInternalServerError)
 		return false
 	}
-	path := req.Header["Referer"]
-	if len(path) == 0 {
+	path := api.portal.linkBase(req)
+	if path == "" {
 		api.portal.log.Error("unable to fetch referer URL")
 		writeError(w,
 			Error{
@@ -388,14 +515,12 @@ func (api *portalAPI) sendPasswordResetLinkByMail(w http.ResponseWriter, req *ht
 			}, http.StatusInternalServerError)
 		return false
 	}
-	link := authLink{
-		Path:  path[0],
-		Token: token,
-	}
+	link := newAuthLink(path, token, api.portal.resetTokenTTL)
 
 	// Generate email body.
+	et := api.portal.loadTemplate("reset", getLocale(req))
 	t := template.New("reset")
-	t, err = t.Parse(resetTemplate)
+	t, err = t.Parse(et.HTML)
 	if err != nil {
 		api.portal.log.Error("unable to parse HTML template", zap.Error(err))
 		writeError(w,
@@ -407,9 +532,22 @@ func (api *portalAPI) sendPasswordResetLinkByMail(w http.ResponseWriter, req *ht
 	}
 	var b bytes.Buffer
 	t.Execute(&b, link)
+	tt := template.New("reset-text")
+	tt, err = tt.Parse(et.Text)
+	if err != nil {
+		api.portal.log.Error("unable to parse text template", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "unable to send password reset link",
+			}, http.StatusInternalServerError)
+		return false
+	}
+	var tb bytes.Buffer
+	tt.Execute(&tb, link)
 
 	// Send password reset link by email.
-	err = api.portal.ms.SendMail("Sia Satellite", email, "Reset Your Password", &b)
+	err = api.portal.ms.SendMultipartMail(api.portal.mailFromName, email, et.Subject, &tb, &b)
 	if err != nil {
 		api.portal.log.Error("unable to send password reset link", zap.Error(err))
 		writeError(w,
@@ -426,7 +564,7 @@ func (api *portalAPI) sendPasswordResetLinkByMail(w http.ResponseWriter, req *ht
 // registerResendHandlerPOST handles the POST /auth/register/resend requests.
 func (api *portalAPI) registerResendHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Decode request body.
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -563,6 +701,7 @@ func (api *portalAPI) authHandlerGET(w http.ResponseWriter, req *http.Request, _
 func (api *portalAPI) resetHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Check and update password reset stats.
 	if cErr := api.portal.checkAndUpdatePasswordResets(getRemoteHost(req)); cErr != nil {
+		setRetryAfter(w, cErr)
 		writeError(w,
 			Error{
 				Code:    httpErrorTooManyRequests,
@@ -572,7 +711,7 @@ func (api *portalAPI) resetHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 
 	// Decode request body.
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -586,6 +725,11 @@ func (api *portalAPI) resetHandlerPOST(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
+	// Verify the CAPTCHA.
+	if !api.checkCaptcha(w, req) {
+		return
+	}
+
 	// Check if such account exists.
 	exists, cErr := api.portal.userExists(data.Email)
 	if cErr != nil {
@@ -617,6 +761,7 @@ func (api *portalAPI) resetHandlerPOST(w http.ResponseWriter, req *http.Request,
 func (api *portalAPI) resetResendHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Check and update stats.
 	if err := api.portal.checkAndUpdatePasswordResets(getRemoteHost(req)); err != nil {
+		setRetryAfter(w, err)
 		writeError(w,
 			Error{
 				Code:    httpErrorTooManyRequests,
@@ -626,7 +771,7 @@ func (api *portalAPI) resetResendHandlerPOST(w http.ResponseWriter, req *http.Re
 	}
 
 	// Decode request body.
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -719,6 +864,33 @@ func (api *portalAPI) changeHandlerPOST(w http.ResponseWriter, req *http.Request
 		return
 	}
 
+	// A password change revokes the renter tokens, which were issued
+	// under the old password.
+	if cErr := api.portal.deleteRenterTokens(email); cErr != nil {
+		api.portal.log.Error("error querying database", zap.Error(cErr))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	// A confirmed password reset also unlocks the account and
+	// closes all open sessions, so that anyone who might have
+	// gained access to the account is logged out.
+	if reset {
+		if cErr := modules.ComposeErrors(api.portal.resetFailedLogins(email), api.portal.deleteSessions(email)); cErr != nil {
+			api.portal.log.Error("error querying database", zap.Error(cErr))
+			writeError(w,
+				Error{
+					Code:    httpErrorInternal,
+					Message: "internal error",
+				}, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	writeSuccess(w)
 }
 
@@ -839,3 +1011,115 @@ func (p *Portal) creditAccount(email string) error {
 	p.credits.Remaining--
 	return p.saveCredits()
 }
+
+// logoutHandlerPOST handles the POST /auth/logout requests.
+func (api *portalAPI) logoutHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode and verify the token.
+	token := getCookie(req, "satellite")
+	email, err := api.verifyCookie(w, token)
+	if err != nil {
+		return
+	}
+	_, _, nonce, err := api.portal.decryptToken(token)
+	if err != nil {
+		writeError(w,
+			Error{
+				Code:    httpErrorTokenInvalid,
+				Message: "invalid token",
+			}, http.StatusBadRequest)
+		return
+	}
+
+	// Close the session.
+	if err := api.portal.deleteSessionByNonce(email, nonce); err != nil {
+		api.portal.log.Error("error querying database", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	// Remove the cookie.
+	cookie := http.Cookie{
+		Name:    "satellite",
+		Value:   "",
+		Expires: time.Unix(0, 0),
+		MaxAge:  -1,
+		Path:    "/",
+	}
+	http.SetCookie(w, &cookie)
+	writeSuccess(w)
+}
+
+// sessionsHandlerGET handles the GET /auth/sessions requests.
+func (api *portalAPI) sessionsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode and verify the token.
+	token := getCookie(req, "satellite")
+	email, err := api.verifyCookie(w, token)
+	if err != nil {
+		return
+	}
+	_, _, nonce, err := api.portal.decryptToken(token)
+	if err != nil {
+		writeError(w,
+			Error{
+				Code:    httpErrorTokenInvalid,
+				Message: "invalid token",
+			}, http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := api.portal.getSessions(email, nonce)
+	if err != nil {
+		api.portal.log.Error("error querying database", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, sessions)
+}
+
+// sessionsRevokeHandlerPOST handles the POST /auth/sessions/revoke
+// requests.
+func (api *portalAPI) sessionsRevokeHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode and verify the token.
+	token := getCookie(req, "satellite")
+	email, err := api.verifyCookie(w, token)
+	if err != nil {
+		return
+	}
+
+	// Decode request body.
+	dec, decErr := api.prepareDecoder(w, req)
+	if decErr != nil {
+		return
+	}
+
+	var data struct {
+		ID int64 `json:"id"`
+	}
+	hErr, code := api.handleDecodeError(dec.Decode(&data))
+	if code != http.StatusOK {
+		writeError(w, hErr, code)
+		return
+	}
+
+	// Close the session.
+	if err := api.portal.deleteSession(email, data.ID); err != nil {
+		api.portal.log.Error("error querying database", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	writeSuccess(w)
+}
@@ -0,0 +1,361 @@
+package portal
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkTokenRejected asserts that verifyCookie rejects the token.
+func checkTokenRejected(t *testing.T, api *portalAPI, token string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if _, err := api.verifyCookie(rec, token); err == nil {
+		t.Fatal("expected the token to be rejected")
+	}
+	var e Error
+	if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	} else if rec.Code != http.StatusBadRequest || e.Code != httpErrorTokenInvalid {
+		t.Fatalf("expected an invalid token error, got %v %+v", rec.Code, e)
+	}
+}
+
+// sessionRequest returns a request carrying the login token.
+func sessionRequest(method, path, token string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(&http.Cookie{Name: "satellite", Value: token})
+	return req
+}
+
+func TestLogout(t *testing.T) {
+	p, _, ta := newTestPortal(t)
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+
+	// Log in from two devices.
+	expires := time.Now().Add(time.Hour)
+	token, err := p.generateSessionToken(email, "192.0.2.1", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := p.generateSessionToken(email, "192.0.2.2", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range []string{token, other} {
+		if e, err := api.verifyCookie(httptest.NewRecorder(), tok); err != nil || e != email {
+			t.Fatal("expected the token to be valid:", err)
+		}
+	}
+
+	// Log out from the first one.
+	rec := httptest.NewRecorder()
+	api.logoutHandlerPOST(rec, sessionRequest(http.MethodPost, "/auth/logout", token), nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %v, got %v: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+
+	// The logged-out token can no longer access the protected routes,
+	// the other session stays open.
+	checkTokenRejected(t, api, token)
+	rec = httptest.NewRecorder()
+	api.logoutHandlerPOST(rec, sessionRequest(http.MethodPost, "/auth/logout", token), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %v, got %v", http.StatusBadRequest, rec.Code)
+	}
+	if _, err := api.verifyCookie(httptest.NewRecorder(), other); err != nil {
+		t.Fatal("expected the other session to stay open:", err)
+	}
+
+	// An expired session is rejected, too.
+	expired, err := p.generateSessionToken(email, "192.0.2.3", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTokenRejected(t, api, expired)
+}
+
+// testMailSender records the sent messages.
+type testMailSender struct {
+	to         string
+	subject    string
+	text, html string
+}
+
+// SendMail implements mail.MailSender.
+func (ms *testMailSender) SendMail(from, to, subject string, body *bytes.Buffer) error {
+	ms.to, ms.subject, ms.text, ms.html = to, subject, "", body.String()
+	return nil
+}
+
+// SendMultipartMail implements mail.MailSender.
+func (ms *testMailSender) SendMultipartMail(from, to, subject string, text, html *bytes.Buffer) error {
+	ms.to, ms.subject, ms.text, ms.html = to, subject, text.String(), html.String()
+	return nil
+}
+
+// mailLink returns the link found in the plain-text part of the mail.
+func (ms *testMailSender) mailLink(base string) string {
+	for _, line := range strings.Split(ms.text, "\n") {
+		if strings.HasPrefix(line, base+"?token=") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+func TestVerificationMail(t *testing.T) {
+	p, _, ta := newTestPortal(t)
+	ms := &testMailSender{}
+	p.ms = ms
+	p.baseURL = "https://portal.example.com/verify"
+	p.verifyTokenTTL = time.Hour
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", nil)
+	if !api.sendVerificationLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the verification link")
+	} else if ms.to != email {
+		t.Fatalf("expected the mail to be sent to %v, got %v", email, ms.to)
+	}
+
+	// Both parts must be present and contain the link.
+	link := ms.mailLink(p.baseURL)
+	if link == "" {
+		t.Fatalf("no link in the plain-text part: %q", ms.text)
+	} else if !strings.Contains(ms.html, `href="`+link+`"`) {
+		t.Fatalf("no link in the HTML part: %q", ms.html)
+	}
+	prefix, e, _, err := p.decodeToken(strings.TrimPrefix(link, p.baseURL+"?token="))
+	if err != nil {
+		t.Fatal(err)
+	} else if prefix != verifyPrefix || e != email {
+		t.Fatal("wrong token in the link")
+	}
+}
+
+func TestLinkBase(t *testing.T) {
+	p := &Portal{}
+	req := httptest.NewRequest(http.MethodPost, "/auth/reset", nil)
+
+	// No portal URL and no Referer.
+	if base := p.linkBase(req); base != "" {
+		t.Fatalf("expected no link base, got %q", base)
+	}
+
+	// Fallback to the Referer.
+	req.Header.Set("Referer", "https://referer.example.com/reset")
+	if base := p.linkBase(req); base != "https://referer.example.com/reset" {
+		t.Fatalf("expected the Referer as the link base, got %q", base)
+	}
+
+	// The configured URL takes precedence over a spoofed Referer.
+	p.baseURL = "https://portal.example.com/reset"
+	req.Header.Set("Referer", "https://evil.example.com/reset")
+	if base := p.linkBase(req); base != p.baseURL {
+		t.Fatalf("expected the configured link base, got %q", base)
+	}
+}
+
+func TestResetMailLinkBase(t *testing.T) {
+	p, _, ta := newTestPortal(t)
+	ms := &testMailSender{}
+	p.ms = ms
+	p.resetTokenTTL = time.Hour
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+
+	// Without the portal URL and the Referer the link can't be built.
+	req := httptest.NewRequest(http.MethodPost, "/auth/reset", nil)
+	rec := httptest.NewRecorder()
+	if api.sendPasswordResetLinkByMail(rec, req, email) {
+		t.Fatal("expected the mail not to be sent")
+	} else if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, rec.Code)
+	}
+
+	// The Referer is used as a fallback.
+	req.Header.Set("Referer", "https://referer.example.com/reset")
+	if !api.sendPasswordResetLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the reset link")
+	} else if ms.mailLink("https://referer.example.com/reset") == "" {
+		t.Fatalf("expected a link to the Referer, got %q", ms.text)
+	}
+
+	// The configured URL is used even with a Referer.
+	p.baseURL = "https://portal.example.com/reset"
+	if !api.sendPasswordResetLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the reset link")
+	} else if ms.mailLink(p.baseURL) == "" {
+		t.Fatalf("expected a link to the portal, got %q", ms.text)
+	}
+}
+
+func TestPasswordResetClosesSessions(t *testing.T) {
+	p, _, ta := newTestPortal(t)
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+
+	expires := time.Now().Add(time.Hour)
+	session, err := p.generateSessionToken(email, "192.0.2.1", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A password change from within a session keeps the sessions open.
+	req := sessionRequest(http.MethodPost, "/auth/change", session)
+	req.Header.Set("Satellite-Password", "new password")
+	rec := httptest.NewRecorder()
+	api.changeHandlerPOST(rec, req, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %v, got %v: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	if _, err := api.verifyCookie(httptest.NewRecorder(), session); err != nil {
+		t.Fatal("expected the session to stay open:", err)
+	}
+
+	// A confirmed password reset closes them.
+	change, err := p.generateToken(changePrefix, email, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/auth/change", nil)
+	req.AddCookie(&http.Cookie{Name: "satellite-change", Value: change})
+	req.Header.Set("Satellite-Password", "another password")
+	rec = httptest.NewRecorder()
+	api.changeHandlerPOST(rec, req, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %v, got %v: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	pwh := passwordHash("another password")
+	if !bytes.Equal(ta.accounts[email].pwHash, pwh[:]) {
+		t.Fatal("password not updated")
+	}
+	checkTokenRejected(t, api, session)
+}
+
+func TestLoginRequest(t *testing.T) {
+	p, tdb, ta := newTestPortal(t)
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+	tdb.handle("SELECT locked_until FROM pt_lockouts WHERE email = ?", func([]driver.Value) ([][]driver.Value, error) {
+		return nil, nil
+	})
+	tdb.handle("SELECT password_hash, verified FROM pt_accounts WHERE email = ?", func(args []driver.Value) ([][]driver.Value, error) {
+		acc, ok := ta.accounts[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return [][]driver.Value{{acc.pwHash, true}}, nil
+	})
+
+	// The email is bound from the JSON body.
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"User@Example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Satellite-Password", "password")
+	rec := httptest.NewRecorder()
+	api.loginHandlerPOST(rec, req, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %v, got %v: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "satellite" {
+			token = c.Value
+		}
+	}
+	if e, err := api.verifyCookie(httptest.NewRecorder(), token); err != nil || e != email {
+		t.Fatal("expected a session for the account:", err)
+	}
+
+	// Unknown fields are rejected.
+	req = httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"mail":"user@example.com"}`))
+	rec = httptest.NewRecorder()
+	api.loginHandlerPOST(rec, req, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %v, got %v", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestFormatTTL(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{24 * time.Hour, "24 hours"},
+		{time.Hour, "1 hour"},
+		{90 * time.Minute, "90 minutes"},
+		{30 * time.Minute, "30 minutes"},
+		{time.Minute, "1 minute"},
+	}
+	for _, test := range tests {
+		if got := formatTTL(test.ttl); got != test.want {
+			t.Errorf("formatTTL(%v): expected %q, got %q", test.ttl, test.want, got)
+		}
+	}
+}
+
+func TestMailTTL(t *testing.T) {
+	p, _, ta := newTestPortal(t)
+	ms := &testMailSender{}
+	p.ms = ms
+	p.baseURL = "https://portal.example.com/auth"
+	p.verifyTokenTTL = 2 * time.Hour
+	p.resetTokenTTL = 30 * time.Minute
+	api := &portalAPI{portal: p}
+	const email = "user@example.com"
+	ta.addAccount(email, "password")
+
+	checkTTL := func(ttl string) {
+		t.Helper()
+		text := "valid within the next " + ttl + "."
+		if !strings.Contains(ms.text, text) {
+			t.Fatalf("expected %q in the plain-text part: %q", text, ms.text)
+		} else if !strings.Contains(ms.html, text) {
+			t.Fatalf("expected %q in the HTML part: %q", text, ms.html)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", nil)
+	if !api.sendVerificationLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the verification link")
+	}
+	checkTTL("2 hours")
+	if !api.sendPasswordResetLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the reset link")
+	}
+	checkTTL("30 minutes")
+
+	// The localized templates get the TTL, too.
+	p.templatesDir = t.TempDir()
+	dir := filepath.Join(p.templatesDir, "de")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	const tmpl = "Gültig für {{.TTLMinutes}} Minuten: {{.Path}}?token={{.Token}}\n"
+	for _, name := range []string{"reset.html", "reset.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(tmpl), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req.Header.Set("Accept-Language", "de-DE")
+	if !api.sendPasswordResetLinkByMail(httptest.NewRecorder(), req, email) {
+		t.Fatal("couldn't send the reset link")
+	} else if !strings.Contains(ms.text, "Gültig für 30 Minuten") || !strings.Contains(ms.html, "Gültig für 30 Minuten") {
+		t.Fatalf("expected the TTL in the localized mail: %q", ms.text)
+	}
+}
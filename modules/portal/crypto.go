@@ -29,6 +29,16 @@ var (
 	threeFishTweak = [16]byte{'S', 'i', 'a', '-', 'S', 'a', 't', 'e', 'l', 'l', 'i', 't', 'e'}
 )
 
+const (
+	// defaultVerifyTokenTTL is how long a verification link is valid
+	// if no lifetime is provided in the config.
+	defaultVerifyTokenTTL = 24 * time.Hour
+
+	// defaultResetTokenTTL is how long a password reset link is valid
+	// if no lifetime is provided in the config.
+	defaultResetTokenTTL = time.Hour
+)
+
 type (
 	// authPrefix is the same as [8]byte.
 	authPrefix [8]byte
@@ -41,8 +51,8 @@ type (
 	}
 )
 
-// generateToken generates an authorization token.
-func (p *Portal) generateToken(prefix authPrefix, email string, expires time.Time) (string, error) {
+// encryptToken encrypts the token data with the provided nonce.
+func (p *Portal) encryptToken(prefix authPrefix, email string, expires time.Time, nonce []byte) (string, error) {
 	// Generate a new Threefish cipher.
 	key := p.provider.SecretKey()
 	cipher, err := threefish.NewCipher(key[:], threeFishTweak[:])
@@ -56,34 +66,63 @@ func (p *Portal) generateToken(prefix authPrefix, email string, expires time.Tim
 	copy(src[:8], prefix[:])
 	copy(src[8:72], email[:])
 	binary.BigEndian.PutUint64(src[72:80], uint64(expires.Unix()))
-	nonce := make([]byte, 16)
-	frand.Read(nonce)
 	copy(src[80:96], nonce[:])
 	cipher.Encrypt(dst[:64], src[:64])
 	cipher.Encrypt(dst[64:], src[64:])
+
+	return hex.EncodeToString(dst), nil
+}
+
+// generateToken generates an authorization token.
+func (p *Portal) generateToken(prefix authPrefix, email string, expires time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	frand.Read(nonce)
+	token, err := p.encryptToken(prefix, email, expires, nonce)
+	if err != nil {
+		return "", err
+	}
 	err = p.saveNonce(email, nonce)
 	if err != nil {
 		return "", err
 	}
 
-	return hex.EncodeToString(dst), nil
+	return token, nil
 }
 
-func (p *Portal) decodeToken(token string) (authPrefix, string, time.Time, error) {
+// generateSessionToken generates a login token and opens a new
+// session. Unlike other tokens, session tokens do not invalidate
+// each other, so a user can be logged in from several devices.
+func (p *Portal) generateSessionToken(email, host string, expires time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	frand.Read(nonce)
+	token, err := p.encryptToken(cookiePrefix, email, expires, nonce)
+	if err != nil {
+		return "", err
+	}
+	err = p.saveSession(email, host, nonce, expires)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// decryptToken decrypts the token without verifying it.
+func (p *Portal) decryptToken(token string) (at authToken, email string, nonce []byte, err error) {
 	// Convert hex to bytes.
 	b, err := hex.DecodeString(token)
 	if err != nil {
-		return authPrefix{}, "", time.Unix(0, 0), err
+		return authToken{}, "", nil, err
 	}
 	if len(b) != 128 {
-		return authPrefix{}, "", time.Unix(0, 0), errors.New("wrong token length")
+		return authToken{}, "", nil, errors.New("wrong token length")
 	}
 
 	// Generate a new Threefish cipher.
 	key := p.provider.SecretKey()
 	cipher, err := threefish.NewCipher(key[:], threeFishTweak[:])
 	if err != nil {
-		return authPrefix{}, "", time.Unix(0, 0), errors.New("wrong key length")
+		return authToken{}, "", nil, errors.New("wrong key length")
 	}
 
 	// Decrypt the data.
@@ -92,21 +131,39 @@ func (p *Portal) decodeToken(token string) (authPrefix, string, time.Time, error
 	copy(src[:], b[:])
 	cipher.Decrypt(dst[:64], src[:64])
 	cipher.Decrypt(dst[64:], src[64:])
-	at := authToken{
+	at = authToken{
 		Email: make([]byte, 64),
 	}
 	copy(at.Prefix[:], dst[:8])
 	copy(at.Email[:], dst[8:72])
 	at.Expires = int64(binary.BigEndian.Uint64(dst[72:80]))
-	nonce := make([]byte, 16)
+	nonce = make([]byte, 16)
 	copy(nonce[:], dst[80:96])
 
 	// Find the length of email.
 	l := bytes.IndexByte(at.Email[:], 0)
-	email := string(at.Email[:l])
+	if l < 0 {
+		return authToken{}, "", nil, errors.New("invalid token")
+	}
+	email = string(at.Email[:l])
+
+	return at, email, nonce, nil
+}
+
+func (p *Portal) decodeToken(token string) (authPrefix, string, time.Time, error) {
+	at, email, nonce, err := p.decryptToken(token)
+	if err != nil {
+		return authPrefix{}, "", time.Unix(0, 0), err
+	}
 
-	// Verify the nonce.
-	ok, err := p.verifyNonce(email, nonce)
+	// Verify the nonce. Login tokens are checked against the open
+	// sessions, so that a revoked token is rejected.
+	var ok bool
+	if at.Prefix == cookiePrefix {
+		ok, err = p.verifySession(email, nonce)
+	} else {
+		ok, err = p.verifyNonce(email, nonce)
+	}
 	if err != nil {
 		return authPrefix{}, "", time.Unix(0, 0), errors.New("couldn't verify nonce")
 	}
@@ -122,6 +122,13 @@ type (
 		MuxPort int    `json:"muxport"`
 		Key     string `json:"key"`
 	}
+
+	// renterToken contains the response to a /dashboard/token request.
+	renterToken struct {
+		Token     string `json:"token"`
+		PublicKey string `json:"publickey"`
+		Expires   int64  `json:"expires"`
+	}
 )
 
 // balanceHandlerGET handles the GET /dashboard/balance requests.
@@ -208,7 +215,7 @@ func (api *portalAPI) hostsHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 
 	// Decode the request body.
-	dec, err := prepareDecoder(w, req)
+	dec, err := api.prepareDecoder(w, req)
 	if err != nil {
 		return
 	}
@@ -753,7 +760,7 @@ func (api *portalAPI) filesHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 
 	// Decode the request body.
-	dec, err := prepareDecoder(w, req)
+	dec, err := api.prepareDecoderLimit(w, req, api.portal.maxFilesBodySize)
 	if err != nil {
 		return
 	}
@@ -807,7 +814,7 @@ func (api *portalAPI) settingsHandlerPOST(w http.ResponseWriter, req *http.Reque
 	}
 
 	// Decode the request body.
-	dec, err := prepareDecoder(w, req)
+	dec, err := api.prepareDecoder(w, req)
 	if err != nil {
 		return
 	}
@@ -1034,3 +1041,61 @@ func (api *portalAPI) announcementHandlerGET(w http.ResponseWriter, _ *http.Requ
 		Maintenance  bool   `json:"maintenance"`
 	}{Announcement: text, Maintenance: maintenance})
 }
+
+// tokenHandlerPOST handles the POST /dashboard/token requests. It issues
+// a token that binds the renter's public key to the account, so that the
+// renter can prove the ownership of the account to the provider.
+func (api *portalAPI) tokenHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode and verify the token.
+	token := getCookie(req, "satellite")
+	email, err := api.verifyCookie(w, token)
+	if err != nil {
+		return
+	}
+
+	// Get the renter.
+	var renter modules.Renter
+	var found bool
+	for _, r := range api.portal.manager.Renters() {
+		if r.Email == email {
+			renter = r
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeError(w,
+			Error{
+				Code:    httpErrorNotFound,
+				Message: "no renter found",
+			}, http.StatusBadRequest)
+		return
+	}
+
+	// Issue the token. It is revoked when the session is closed.
+	_, _, nonce, err := api.portal.decryptToken(token)
+	if err != nil {
+		writeError(w,
+			Error{
+				Code:    httpErrorTokenInvalid,
+				Message: "invalid token",
+			}, http.StatusBadRequest)
+		return
+	}
+	rt, expires, err := api.portal.manager.IssueRenterToken(email, renter.PublicKey, nonce)
+	if err != nil {
+		api.portal.log.Error("couldn't issue renter token", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "internal error",
+			}, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, renterToken{
+		Token:     hex.EncodeToString(rt[:]),
+		PublicKey: hex.EncodeToString(renter.PublicKey[:]),
+		Expires:   expires.Unix(),
+	})
+}
@@ -2,6 +2,7 @@ package portal
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
 	"errors"
@@ -47,7 +48,7 @@ func (p *Portal) userExists(email string) (bool, error) {
 
 // isVerified checks if the user account is verified. If password
 // is not empty, it also checks if the password matches the one
-// in the database.
+// in the database. The hashes are compared in constant time.
 func (p *Portal) isVerified(email, password string) (verified bool, ok bool, err error) {
 	pwHash := make([]byte, 32)
 	if password != "" {
@@ -57,7 +58,7 @@ func (p *Portal) isVerified(email, password string) (verified bool, ok bool, err
 	ph := make([]byte, 32)
 	var v bool
 	err = p.db.QueryRow("SELECT password_hash, verified FROM pt_accounts WHERE email = ?", email).Scan(&ph, &v)
-	return v, bytes.Equal(ph, pwHash), err
+	return v, subtle.ConstantTimeCompare(ph, pwHash) == 1, err
 }
 
 // updateAccount updates the user account in the database.
@@ -93,7 +94,82 @@ func (p *Portal) updateAccount(email, password string, verified bool) error {
 	return err
 }
 
-// passwordHash implements the Argon2id hashing mechanism.
+// initLockouts creates the table of the account lockouts, which
+// databases created before the lockouts were introduced lack.
+func (p *Portal) initLockouts() error {
+	_, err := p.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pt_lockouts (
+			email         VARCHAR(64) NOT NULL,
+			failed_logins BIGINT UNSIGNED NOT NULL,
+			last_failed   BIGINT NOT NULL,
+			locked_until  BIGINT NOT NULL,
+			PRIMARY KEY (email)
+		)
+	`)
+	return modules.AddContext(err, "couldn't create lockouts table")
+}
+
+// getLockout returns the time until which the account is locked.
+// The failed logins are counted per email address, whether there is
+// an account with this address or not, so that the lockout doesn't
+// reveal which accounts exist.
+func (p *Portal) getLockout(email string) (time.Time, error) {
+	var until int64
+	err := p.db.QueryRow("SELECT locked_until FROM pt_lockouts WHERE email = ?", email).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return time.Unix(until, 0), err
+}
+
+// updateFailedLogins registers a failed login attempt and locks the
+// account if there have been too many of them. It returns the time
+// until which the account is locked.
+func (p *Portal) updateFailedLogins(email string) (time.Time, error) {
+	var failed uint64
+	var last, until int64
+	err := p.db.QueryRow(`
+		SELECT failed_logins, last_failed, locked_until
+		FROM pt_lockouts
+		WHERE email = ?
+	`, email).Scan(&failed, &last, &until)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+
+	// Start counting anew if the last failed attempt was long ago
+	// and the account is not locked.
+	now := time.Now()
+	if now.Unix() > until && now.Sub(time.Unix(last, 0)) > p.authWindow {
+		failed = 0
+	}
+	failed++
+	if failed%maxAccountFailedLogins == 0 {
+		until = now.Add(lockoutDuration(failed)).Unix()
+	}
+
+	_, err = p.db.Exec(`
+		INSERT INTO pt_lockouts (email, failed_logins, last_failed, locked_until)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			failed_logins = VALUES(failed_logins),
+			last_failed = VALUES(last_failed),
+			locked_until = VALUES(locked_until)
+	`, email, failed, now.Unix(), until)
+
+	return time.Unix(until, 0), err
+}
+
+// resetFailedLogins unlocks the account and resets the failed login
+// counter.
+func (p *Portal) resetFailedLogins(email string) error {
+	_, err := p.db.Exec("DELETE FROM pt_lockouts WHERE email = ?", email)
+	return err
+}
+
+// passwordHash implements the Argon2id hashing mechanism. The
+// passwords are stored as 32-byte Argon2id hashes (one pass, 64MiB
+// of memory, one thread per CPU) computed with a fixed salt.
 func passwordHash(password string) (pwh types.Hash256) {
 	t := uint8(runtime.NumCPU())
 	hash := argon2.IDKey([]byte(password), []byte(argon2Salt), 1, 64*1024, t, 32)
@@ -101,8 +177,8 @@ func passwordHash(password string) (pwh types.Hash256) {
 	return
 }
 
-// threadedPruneUnverifiedAccounts deletes unverified user accounts
-// from the database.
+// threadedPruneUnverifiedAccounts deletes unverified user accounts,
+// expired sessions, and stale lockouts from the database.
 func (p *Portal) threadedPruneUnverifiedAccounts() {
 	for {
 		select {
@@ -122,10 +198,28 @@ func (p *Portal) threadedPruneUnverifiedAccounts() {
 			defer p.mu.Unlock()
 
 			now := time.Now().Unix()
+			_, err = p.db.Exec(`
+				DELETE FROM mg_tokens
+				WHERE session IN (
+					SELECT nonce FROM pt_sessions
+					WHERE expires < ?
+				)
+			`, now)
+			if err != nil {
+				p.log.Error("error querying database", zap.Error(err))
+			}
+			_, err = p.db.Exec("DELETE FROM pt_sessions WHERE expires < ?", now)
+			if err != nil {
+				p.log.Error("error querying database", zap.Error(err))
+			}
 			_, err = p.db.Exec("DELETE FROM pt_accounts WHERE verified = FALSE AND time < ?", now-pruneUnverifiedAccountsThreshold.Milliseconds()/1000)
 			if err != nil {
 				p.log.Error("error querying database", zap.Error(err))
 			}
+			_, err = p.db.Exec("DELETE FROM pt_lockouts WHERE locked_until < ? AND last_failed < ?", now, now-int64(p.authWindow.Seconds()))
+			if err != nil {
+				p.log.Error("error querying database", zap.Error(err))
+			}
 		}()
 	}
 }
@@ -169,6 +263,12 @@ func (p *Portal) deleteAccount(email string) error {
 	errs = append(errs, err)
 	_, err = p.db.Exec("DELETE FROM mg_balances WHERE email = ?", email)
 	errs = append(errs, err)
+	_, err = p.db.Exec("DELETE FROM mg_tokens WHERE email = ?", email)
+	errs = append(errs, err)
+	_, err = p.db.Exec("DELETE FROM pt_sessions WHERE email = ?", email)
+	errs = append(errs, err)
+	_, err = p.db.Exec("DELETE FROM pt_lockouts WHERE email = ?", email)
+	errs = append(errs, err)
 	_, err = p.db.Exec("DELETE FROM pt_accounts WHERE email = ?", email)
 	errs = append(errs, err)
 
@@ -515,6 +615,98 @@ func (p *Portal) verifyNonce(email string, nonce []byte) (bool, error) {
 	return bytes.Equal(n, nonce), nil
 }
 
+// saveSession opens a new login session.
+func (p *Portal) saveSession(email, host string, nonce []byte, expires time.Time) error {
+	_, err := p.db.Exec(`
+		INSERT INTO pt_sessions (email, nonce, remote_host, issued, expires)
+		VALUES (?, ?, ?, ?, ?)
+	`, email, nonce, host, time.Now().Unix(), expires.Unix())
+	return err
+}
+
+// verifySession checks if there is an open session with the given
+// nonce.
+func (p *Portal) verifySession(email string, nonce []byte) (bool, error) {
+	var count int
+	err := p.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM pt_sessions
+		WHERE email = ? AND nonce = ? AND expires > ?
+	`, email, nonce, time.Now().Unix()).Scan(&count)
+	return count > 0, err
+}
+
+// getSessions returns the open sessions of the user.
+func (p *Portal) getSessions(email string, nonce []byte) ([]session, error) {
+	rows, err := p.db.Query(`
+		SELECT id, nonce, remote_host, issued, expires
+		FROM pt_sessions
+		WHERE email = ? AND expires > ?
+		ORDER BY issued DESC
+	`, email, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []session
+	for rows.Next() {
+		var s session
+		n := make([]byte, 16)
+		if err := rows.Scan(&s.ID, &n, &s.RemoteHost, &s.Issued, &s.Expires); err != nil {
+			return nil, err
+		}
+		s.Current = bytes.Equal(n, nonce)
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
+
+// deleteSession closes the session with the given ID and revokes the
+// renter tokens issued in it.
+func (p *Portal) deleteSession(email string, id int64) error {
+	_, err := p.db.Exec(`
+		DELETE FROM mg_tokens
+		WHERE email = ? AND session IN (
+			SELECT nonce FROM pt_sessions
+			WHERE email = ? AND id = ?
+		)
+	`, email, email, id)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec("DELETE FROM pt_sessions WHERE email = ? AND id = ?", email, id)
+	return err
+}
+
+// deleteSessions closes all sessions of the user and revokes all of
+// their renter tokens.
+func (p *Portal) deleteSessions(email string) error {
+	if err := p.deleteRenterTokens(email); err != nil {
+		return err
+	}
+	_, err := p.db.Exec("DELETE FROM pt_sessions WHERE email = ?", email)
+	return err
+}
+
+// deleteSessionByNonce closes the session with the given nonce and
+// revokes the renter tokens issued in it.
+func (p *Portal) deleteSessionByNonce(email string, nonce []byte) error {
+	_, err := p.db.Exec("DELETE FROM mg_tokens WHERE email = ? AND session = ?", email, nonce)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec("DELETE FROM pt_sessions WHERE email = ? AND nonce = ?", email, nonce)
+	return err
+}
+
+// deleteRenterTokens revokes all renter tokens of the user.
+func (p *Portal) deleteRenterTokens(email string) error {
+	_, err := p.db.Exec("DELETE FROM mg_tokens WHERE email = ?", email)
+	return err
+}
+
 // saveStats updates the authentication stats in the database.
 func (p *Portal) saveStats() error {
 	tx, err := p.db.Begin()
@@ -924,7 +1116,7 @@ func (p *Portal) requestPayment(id string, invoice string, amount float64, curre
 		Name:   p.name,
 		Amount: fmt.Sprintf("%.2f %s", amount, currency),
 	})
-	err = p.ms.SendMail("Sia Satellite", email, "Action Required", &b)
+	err = p.ms.SendMail(p.mailFromName, email, "Action Required", &b)
 	if err != nil {
 		return fmt.Errorf("unable to send request to %s", email)
 	}
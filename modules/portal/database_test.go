@@ -0,0 +1,284 @@
+package portal
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// A testQueryFunc answers a query of the testDB with the resulting rows.
+type testQueryFunc func(args []driver.Value) ([][]driver.Value, error)
+
+// testDB is an in-memory stand-in for the database. It answers the
+// queries registered with handle, so the portal can be tested without
+// a database server. Any other query fails.
+type testDB struct {
+	mu       sync.Mutex
+	handlers map[string]testQueryFunc
+}
+
+var (
+	testDBsMu sync.Mutex
+	testDBs   = make(map[string]*testDB)
+	testDBReg sync.Once
+)
+
+// newTestDB returns a database connected to a new testDB.
+func newTestDB(t *testing.T) (*sql.DB, *testDB) {
+	testDBReg.Do(func() { sql.Register("portaltest", testDriver{}) })
+	tdb := &testDB{handlers: make(map[string]testQueryFunc)}
+	testDBsMu.Lock()
+	name := strconv.Itoa(len(testDBs))
+	testDBs[name] = tdb
+	testDBsMu.Unlock()
+	db, err := sql.Open("portaltest", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, tdb
+}
+
+// normalizeQuery collapses the whitespace of the query.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// handle registers the function answering the query.
+func (db *testDB) handle(query string, fn testQueryFunc) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.handlers[normalizeQuery(query)] = fn
+}
+
+func (db *testDB) run(query string, args []driver.Value) ([][]driver.Value, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	fn, ok := db.handlers[normalizeQuery(query)]
+	if !ok {
+		return nil, errors.New("unexpected query: " + normalizeQuery(query))
+	}
+	return fn(args)
+}
+
+type (
+	testDriver struct{}
+	testConn   struct{ db *testDB }
+	testStmt   struct {
+		db    *testDB
+		query string
+	}
+	testRows struct {
+		rows [][]driver.Value
+		pos  int
+	}
+)
+
+// Open implements driver.Driver.
+func (testDriver) Open(name string) (driver.Conn, error) {
+	testDBsMu.Lock()
+	defer testDBsMu.Unlock()
+	return &testConn{db: testDBs[name]}, nil
+}
+
+// Prepare implements driver.Conn.
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{db: c.db, query: query}, nil
+}
+
+// Close implements driver.Conn.
+func (c *testConn) Close() error { return nil }
+
+// Begin implements driver.Conn.
+func (c *testConn) Begin() (driver.Tx, error) { return c, nil }
+
+// Commit implements driver.Tx.
+func (c *testConn) Commit() error { return nil }
+
+// Rollback implements driver.Tx.
+func (c *testConn) Rollback() error { return nil }
+
+// Close implements driver.Stmt.
+func (s *testStmt) Close() error { return nil }
+
+// NumInput implements driver.Stmt.
+func (s *testStmt) NumInput() int { return -1 }
+
+// Exec implements driver.Stmt.
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+// Query implements driver.Stmt.
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &testRows{rows: rows}, nil
+}
+
+// Columns implements driver.Rows.
+func (r *testRows) Columns() []string {
+	n := 1
+	if len(r.rows) > 0 {
+		n = len(r.rows[0])
+	}
+	return make([]string, n)
+}
+
+// Close implements driver.Rows.
+func (r *testRows) Close() error { return nil }
+
+// Next implements driver.Rows.
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// testProvider is a provider that only has a secret key.
+type testProvider struct {
+	modules.Provider
+	sk types.PrivateKey
+}
+
+// SecretKey implements modules.Provider.
+func (tp *testProvider) SecretKey() types.PrivateKey { return tp.sk }
+
+// testAccount is an account of the testAccounts.
+type testAccount struct {
+	pwHash []byte
+	nonce  []byte
+}
+
+// testSession is a login session of the testAccounts.
+type testSession struct {
+	id      int64
+	email   string
+	nonce   []byte
+	expires int64
+}
+
+// testAccounts emulates the account and session tables.
+type testAccounts struct {
+	accounts map[string]*testAccount
+	sessions []testSession
+	nextID   int64
+}
+
+// newTestPortal returns a portal backed by a testDB holding the
+// accounts and the sessions.
+func newTestPortal(t *testing.T) (*Portal, *testDB, *testAccounts) {
+	db, tdb := newTestDB(t)
+	ta := &testAccounts{accounts: make(map[string]*testAccount)}
+	exec := func(fn func(args []driver.Value)) testQueryFunc {
+		return func(args []driver.Value) ([][]driver.Value, error) {
+			fn(args)
+			return nil, nil
+		}
+	}
+	email := func(v driver.Value) string {
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return v.(string)
+	}
+
+	tdb.handle("SELECT COUNT(*) FROM pt_accounts WHERE email = ?", func(args []driver.Value) ([][]driver.Value, error) {
+		var count int64
+		if _, ok := ta.accounts[email(args[0])]; ok {
+			count = 1
+		}
+		return [][]driver.Value{{count}}, nil
+	})
+	tdb.handle("SELECT nonce FROM pt_accounts WHERE email = ?", func(args []driver.Value) ([][]driver.Value, error) {
+		acc, ok := ta.accounts[email(args[0])]
+		if !ok {
+			return nil, nil
+		}
+		return [][]driver.Value{{acc.nonce}}, nil
+	})
+	tdb.handle("UPDATE pt_accounts SET nonce = ? WHERE email = ?", exec(func(args []driver.Value) {
+		if acc, ok := ta.accounts[email(args[1])]; ok {
+			acc.nonce = args[0].([]byte)
+		}
+	}))
+	tdb.handle("UPDATE pt_accounts SET password_hash = ?, verified = ? WHERE email = ?", exec(func(args []driver.Value) {
+		if acc, ok := ta.accounts[email(args[2])]; ok {
+			acc.pwHash = args[0].([]byte)
+		}
+	}))
+	tdb.handle("INSERT INTO pt_sessions (email, nonce, remote_host, issued, expires) VALUES (?, ?, ?, ?, ?)", exec(func(args []driver.Value) {
+		ta.nextID++
+		ta.sessions = append(ta.sessions, testSession{
+			id:      ta.nextID,
+			email:   email(args[0]),
+			nonce:   args[1].([]byte),
+			expires: args[4].(int64),
+		})
+	}))
+	tdb.handle("SELECT COUNT(*) FROM pt_sessions WHERE email = ? AND nonce = ? AND expires > ?", func(args []driver.Value) ([][]driver.Value, error) {
+		var count int64
+		for _, s := range ta.sessions {
+			if s.email == email(args[0]) && bytes.Equal(s.nonce, args[1].([]byte)) && s.expires > args[2].(int64) {
+				count++
+			}
+		}
+		return [][]driver.Value{{count}}, nil
+	})
+	deleteSessions := func(match func(testSession) bool) {
+		sessions := ta.sessions[:0]
+		for _, s := range ta.sessions {
+			if !match(s) {
+				sessions = append(sessions, s)
+			}
+		}
+		ta.sessions = sessions
+	}
+	tdb.handle("DELETE FROM pt_sessions WHERE email = ? AND nonce = ?", exec(func(args []driver.Value) {
+		deleteSessions(func(s testSession) bool {
+			return s.email == email(args[0]) && bytes.Equal(s.nonce, args[1].([]byte))
+		})
+	}))
+	tdb.handle("DELETE FROM pt_sessions WHERE email = ?", exec(func(args []driver.Value) {
+		deleteSessions(func(s testSession) bool { return s.email == email(args[0]) })
+	}))
+	tdb.handle("DELETE FROM mg_tokens WHERE email = ?", exec(func([]driver.Value) {}))
+	tdb.handle("DELETE FROM mg_tokens WHERE email = ? AND session = ?", exec(func([]driver.Value) {}))
+	tdb.handle("DELETE FROM pt_lockouts WHERE email = ?", exec(func([]driver.Value) {}))
+
+	p := &Portal{
+		db:        db,
+		provider:  &testProvider{sk: types.GeneratePrivateKey()},
+		authStats: make(map[string]authenticationStats),
+		log:       zap.NewNop(),
+
+		maxBodySize:      httpMaxBodySize,
+		maxFilesBodySize: httpMaxFilesBodySize,
+	}
+	return p, tdb, ta
+}
+
+// addAccount adds a verified account to the testAccounts.
+func (ta *testAccounts) addAccount(email, password string) {
+	pwh := passwordHash(password)
+	ta.accounts[email] = &testAccount{pwHash: pwh[:], nonce: make([]byte, 16)}
+}
@@ -41,7 +41,7 @@ func (api *portalAPI) authHandlerPOST(w http.ResponseWriter, req *http.Request,
 		}
 
 		// Decode the request body.
-		dec, err := prepareDecoder(w, req)
+		dec, err := api.prepareDecoder(w, req)
 		if err != nil {
 			return
 		}
@@ -159,6 +159,7 @@ func (api *portalAPI) authHandlerPOST(w http.ResponseWriter, req *http.Request,
 		if !exists {
 			// Check and update stats.
 			if err := api.portal.checkAndUpdateFailedLogins(getRemoteHost(req)); err != nil {
+				setRetryAfter(w, err)
 				writeError(w,
 					Error{
 						Code:    httpErrorTooManyRequests,
@@ -200,7 +201,7 @@ func (api *portalAPI) authHandlerPOST(w http.ResponseWriter, req *http.Request,
 
 		// Login successful, generate a cookie.
 		t := time.Now().Add(7 * 24 * time.Hour)
-		token, err := api.portal.generateToken(cookiePrefix, email, t)
+		token, err := api.portal.generateSessionToken(email, getRemoteHost(req), t)
 		if err != nil {
 			api.portal.log.Error("error generating token", zap.Error(err))
 			writeError(w,
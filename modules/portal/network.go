@@ -20,8 +20,13 @@ const (
 	// "application/json".
 	httpContentTypeError = "Content-Type header is not application/json"
 
-	// httpMaxBodySize enforces a maximum read of 1MiB from the request body.
-	httpMaxBodySize = 1048576 // 1MiB.
+	// httpMaxBodySize is the default maximum size of a request body.
+	httpMaxBodySize = 16384 // 16KiB.
+
+	// httpMaxFilesBodySize is the default maximum size of a request body
+	// deleting files. The request lists every file to delete, so it can
+	// be much larger than the others.
+	httpMaxFilesBodySize = 16 << 20 // 16MiB.
 )
 
 // Error codes provided in an HTTP response.
@@ -39,6 +44,8 @@ const (
 
 	httpErrorWrongCredentials = 30
 	httpErrorTooManyRequests  = 31
+	httpErrorAccountLocked    = 32
+	httpErrorCaptchaFailed    = 33
 
 	httpErrorTokenInvalid = 40
 	httpErrorTokenExpired = 41
@@ -93,6 +100,15 @@ func (api *portalAPI) buildHTTPRoutes() {
 	router.GET("/auth/delete", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		api.deleteHandlerGET(w, req, ps)
 	})
+	router.POST("/auth/logout", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		api.logoutHandlerPOST(w, req, ps)
+	})
+	router.GET("/auth/sessions", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		api.sessionsHandlerGET(w, req, ps)
+	})
+	router.POST("/auth/sessions/revoke", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		api.sessionsRevokeHandlerPOST(w, req, ps)
+	})
 
 	// /dashboard requests.
 	router.GET("/dashboard/balance", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -152,6 +168,9 @@ func (api *portalAPI) buildHTTPRoutes() {
 	router.GET("/dashboard/announcement", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		api.announcementHandlerGET(w, req, ps)
 	})
+	router.POST("/dashboard/token", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		api.tokenHandlerPOST(w, req, ps)
+	})
 
 	// /stripe requests.
 	router.POST("/stripe/create-payment-intent", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -257,7 +276,13 @@ func checkHeader(r *http.Request) Error {
 
 // prepareDecoder is a helper function that returns an initialized
 // json.Decoder.
-func prepareDecoder(w http.ResponseWriter, r *http.Request) (*json.Decoder, error) {
+func (api *portalAPI) prepareDecoder(w http.ResponseWriter, r *http.Request) (*json.Decoder, error) {
+	return api.prepareDecoderLimit(w, r, api.portal.maxBodySize)
+}
+
+// prepareDecoderLimit is like prepareDecoder, but limits the request
+// body to the specified size.
+func (api *portalAPI) prepareDecoderLimit(w http.ResponseWriter, r *http.Request, limit int64) (*json.Decoder, error) {
 	// Check the response header first.
 	if err := checkHeader(r); err.Code != httpErrorNone {
 		writeError(w, err, http.StatusUnsupportedMediaType)
@@ -265,7 +290,7 @@ func prepareDecoder(w http.ResponseWriter, r *http.Request) (*json.Decoder, erro
 	}
 
 	// Limit the request body size.
-	r.Body = http.MaxBytesReader(w, r.Body, httpMaxBodySize)
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
 
 	// Initialize the decoder and instruct it to not accept any undeclared
 	// fields in the body JSON.
@@ -284,6 +309,7 @@ func (api *portalAPI) handleDecodeError(err error) (Error, int) {
 	}
 	var syntaxError *json.SyntaxError
 	var unmarshalTypeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
 
 	switch {
 	// Catch any syntax errors in the JSON.
@@ -324,7 +350,7 @@ func (api *portalAPI) handleDecodeError(err error) (Error, int) {
 		}, http.StatusBadRequest
 
 	// Catch the error caused by the request body being too large.
-	case err.Error() == "http: request body too large":
+	case errors.As(err, &maxBytesError):
 		return Error{
 			Code:    httpErrorBadRequest,
 			Message: "request body too large",
@@ -0,0 +1,45 @@
+package portal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodySizeLimit(t *testing.T) {
+	p := &Portal{
+		maxBodySize:      64,
+		maxFilesBodySize: 1024,
+	}
+	api := &portalAPI{portal: p}
+	body := `{"email":"` + strings.Repeat("a", 100) + `@example.com"}`
+
+	// An oversized body is rejected before any further processing.
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	api.loginHandlerPOST(rec, req, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %v, got %v", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	var e Error
+	if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	} else if e.Code != httpErrorBadRequest {
+		t.Fatalf("expected error code %v, got %v", httpErrorBadRequest, e.Code)
+	}
+
+	// The same body fits into a larger limit.
+	req = httptest.NewRequest(http.MethodPost, "/dashboard/files", strings.NewReader(body))
+	dec, err := api.prepareDecoderLimit(httptest.NewRecorder(), req, p.maxFilesBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		Email string `json:"email"`
+	}
+	if e, code := api.handleDecodeError(dec.Decode(&data)); code != http.StatusOK {
+		t.Fatalf("expected the body to be decoded, got %v %+v", code, e)
+	}
+}
@@ -18,6 +18,10 @@ func (p *Portal) load() error {
 		return err
 	}
 
+	if err := p.initLockouts(); err != nil {
+		return err
+	}
+
 	if err := p.loadStats(); err != nil {
 		return err
 	}
@@ -3,9 +3,12 @@ package portal
 import (
 	"database/sql"
 	"net"
+	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/mike76-dev/sia-satellite/external"
 	siasync "github.com/mike76-dev/sia-satellite/internal/sync"
 	"github.com/mike76-dev/sia-satellite/mail"
 	"github.com/mike76-dev/sia-satellite/modules"
@@ -30,9 +33,10 @@ type Portal struct {
 	muxAddr string
 
 	// Atomic stats.
-	authStats map[string]authenticationStats
-	credits   modules.CreditData
-	tip       types.ChainIndex
+	authStats  map[string]authenticationStats
+	authWindow time.Duration
+	credits    modules.CreditData
+	tip        types.ChainIndex
 
 	// Watch list of SC payment transactions.
 	transactions map[types.TransactionID]types.Address
@@ -40,6 +44,28 @@ type Portal struct {
 	// Name of the satellite node.
 	name string
 
+	// Base URL of the links sent by email.
+	baseURL string
+
+	// Directory containing the localized email templates.
+	templatesDir string
+
+	// Sender name and configured subjects of the emails.
+	mailFromName string
+	subjects     map[string]string
+
+	// Maximum sizes of a request body: the general one and the one of
+	// the bulk file deletion.
+	maxBodySize      int64
+	maxFilesBodySize int64
+
+	// Lifetimes of the verification and password reset links.
+	verifyTokenTTL time.Duration
+	resetTokenTTL  time.Duration
+
+	// CAPTCHA verifier, nil if disabled.
+	captcha external.CaptchaVerifier
+
 	// Utilities.
 	listener  net.Listener
 	log       *zap.Logger
@@ -67,12 +93,63 @@ func New(config *persist.SatdConfig, db *sql.DB, ms mail.MailSender, cm *chain.M
 		muxAddr: config.MuxAddr,
 
 		authStats:    make(map[string]authenticationStats),
+		authWindow:   authStatsCountResetThreshold,
 		transactions: make(map[types.TransactionID]types.Address),
 		name:         config.Name,
+		templatesDir: config.TemplatesDir,
+		mailFromName: defaultMailFromName,
+		baseURL:      config.PortalBaseURL,
+		maxBodySize:  httpMaxBodySize,
+
+		maxFilesBodySize: httpMaxFilesBodySize,
+
+		verifyTokenTTL: defaultVerifyTokenTTL,
+		resetTokenTTL:  defaultResetTokenTTL,
+		subjects: map[string]string{
+			"verify": config.VerifySubject,
+			"reset":  config.ResetSubject,
+		},
 
 		closeChan: make(chan int, 1),
 	}
 
+	if config.MailFromName != "" {
+		pt.mailFromName = config.MailFromName
+	}
+
+	// The subjects and the sender name end up in the email headers.
+	for _, header := range []string{pt.mailFromName, config.VerifySubject, config.ResetSubject} {
+		if !mail.ValidHeader(header) {
+			return nil, modules.AddContext(mail.ErrInvalidHeader, "invalid email config")
+		}
+	}
+
+	if config.AuthWindow > 0 {
+		pt.authWindow = time.Duration(config.AuthWindow) * time.Second
+	}
+
+	if config.PortalMaxBodySize > 0 {
+		pt.maxBodySize = int64(config.PortalMaxBodySize)
+	}
+
+	if config.PortalMaxFilesBodySize > 0 {
+		pt.maxFilesBodySize = int64(config.PortalMaxFilesBodySize)
+	}
+
+	if config.VerifyTokenTTL > 0 {
+		pt.verifyTokenTTL = time.Duration(config.VerifyTokenTTL) * time.Second
+	}
+	if config.ResetTokenTTL > 0 {
+		pt.resetTokenTTL = time.Duration(config.ResetTokenTTL) * time.Second
+	}
+
+	if config.Captcha != "" {
+		pt.captcha, err = external.NewCaptchaVerifier(config.Captcha, os.Getenv("SATD_CAPTCHA_SECRET"))
+		if err != nil {
+			return nil, modules.AddContext(err, "unable to create captcha verifier")
+		}
+	}
+
 	// Call stop in the event of a partial startup.
 	defer func() {
 		if err != nil {
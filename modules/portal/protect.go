@@ -1,8 +1,14 @@
 package portal
 
 import (
+	"encoding/json"
 	"errors"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 const (
@@ -15,7 +21,8 @@ const (
 	authStatsPruneThreshold = 24 * time.Hour
 
 	// authStatsCountResetThreshold defines when the counter needs
-	// to be reset to zero after the last activity.
+	// to be reset to zero after the last activity. It is used if
+	// no window is provided in the config.
 	authStatsCountResetThreshold = time.Hour
 
 	// maxVerifications is how many times a verification link may be
@@ -29,15 +36,48 @@ const (
 	// maxPasswordResets is how many times a password reset link may
 	// be requested per hour from the same IP.
 	maxPasswordResets = 3
+
+	// maxAccountFailedLogins is how many failed login attempts within
+	// the auth window lock the account.
+	maxAccountFailedLogins = 5
+
+	// accountLockoutDuration is how long the account is locked after
+	// the first lockout. Each following lockout doubles the duration.
+	accountLockoutDuration = 15 * time.Minute
+
+	// maxAccountLockoutDuration is the longest time the account may
+	// be locked.
+	maxAccountLockoutDuration = 24 * time.Hour
+
+	// unlockPath is the API path that unlocks a locked account by
+	// resetting its password.
+	unlockPath = "/auth/reset"
 )
 
 type (
+	// tooManyRequestsError is returned when an IP exceeds one of the
+	// rate limits. It carries the time until the limit is reset.
+	tooManyRequestsError struct {
+		msg        string
+		retryAfter time.Duration
+	}
+
 	// authAttempts keeps track of specific authentication activities.
 	authAttempts struct {
 		LastAttempt int64
 		Count       int64
 	}
 
+	// accountLockedError is returned when a login is attempted while
+	// the account is locked. Besides the usual error fields, it tells
+	// the user until when the account is locked and how to unlock it.
+	accountLockedError struct {
+		Error
+		Locked      bool      `json:"locked"`
+		LockedUntil time.Time `json:"lockedUntil"`
+		UnlockPath  string    `json:"unlockPath"`
+	}
+
 	// authenticationStats is the summary of authentication attempts
 	// from a single IP address.
 	authenticationStats struct {
@@ -48,6 +88,88 @@ type (
 	}
 )
 
+// Error implements the error interface.
+func (e *tooManyRequestsError) Error() string {
+	return e.msg
+}
+
+// setRetryAfter sets the Retry-After header if the error was caused
+// by a rate limit.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var tmr *tooManyRequestsError
+	if !errors.As(err, &tmr) {
+		return
+	}
+	seconds := int64(math.Ceil(tmr.retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// checkCaptcha verifies the CAPTCHA token provided in the request
+// header, if the CAPTCHA is enabled. It writes an error response and
+// returns false if the verification fails.
+func (api *portalAPI) checkCaptcha(w http.ResponseWriter, req *http.Request) bool {
+	if api.portal.captcha == nil {
+		return true
+	}
+	ok, err := api.portal.captcha.Verify(req.Header.Get("Satellite-Captcha"), getRemoteHost(req))
+	if err != nil {
+		api.portal.log.Error("couldn't verify captcha", zap.Error(err))
+		writeError(w,
+			Error{
+				Code:    httpErrorInternal,
+				Message: "unable to verify captcha",
+			}, http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		writeError(w,
+			Error{
+				Code:    httpErrorCaptchaFailed,
+				Message: "captcha verification failed",
+			}, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeAccountLocked informs the user that the account is locked
+// and how it can be unlocked.
+func writeAccountLocked(w http.ResponseWriter, until time.Time) {
+	setRetryAfter(w, &tooManyRequestsError{retryAfter: time.Until(until)})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(accountLockedError{
+		Error: Error{
+			Code:    httpErrorAccountLocked,
+			Message: "account locked due to too many failed login attempts until " + until.UTC().Format(time.RFC1123) + ", reset your password to unlock it now",
+		},
+		Locked:      true,
+		LockedUntil: until.UTC(),
+		UnlockPath:  unlockPath,
+	})
+}
+
+// lockoutDuration returns the duration of the account lockout after
+// the given number of failed login attempts.
+func lockoutDuration(failed uint64) time.Duration {
+	d := accountLockoutDuration
+	for n := failed / maxAccountFailedLogins; n > 1; n-- {
+		d *= 2
+		if d >= maxAccountLockoutDuration {
+			return maxAccountLockoutDuration
+		}
+	}
+	return d
+}
+
+// cooldown returns the time remaining until the counter is reset.
+func (p *Portal) cooldown(aa authAttempts) time.Duration {
+	return time.Until(time.Unix(aa.LastAttempt, 0).Add(p.authWindow))
+}
+
 // threadedPruneAuthStats checks if any of the stats have expired
 // and removes them.
 func (p *Portal) threadedPruneAuthStats() {
@@ -88,13 +210,13 @@ func (p *Portal) threadedPruneAuthStats() {
 
 				// Check if the counters need to be reset.
 				stats := p.authStats[entry.RemoteHost]
-				if fl > authStatsCountResetThreshold.Seconds() {
+				if fl > p.authWindow.Seconds() {
 					stats.FailedLogins.Count = 0
 				}
-				if vr > authStatsCountResetThreshold.Seconds() {
+				if vr > p.authWindow.Seconds() {
 					stats.Verifications.Count = 0
 				}
-				if pr > authStatsCountResetThreshold.Seconds() {
+				if pr > p.authWindow.Seconds() {
 					stats.PasswordResets.Count = 0
 				}
 				p.authStats[entry.RemoteHost] = stats
@@ -125,8 +247,9 @@ func (p *Portal) checkAndUpdateVerifications(host string) error {
 		return nil
 	}
 
-	// IP exists but no verification requests yet.
-	if stats.Verifications.Count == 0 {
+	// IP exists but no verification requests yet or the counter
+	// has expired.
+	if stats.Verifications.Count == 0 || time.Now().Unix()-stats.Verifications.LastAttempt > int64(p.authWindow.Seconds()) {
 		stats.Verifications.LastAttempt = time.Now().Unix()
 		stats.Verifications.Count = 1
 		p.authStats[host] = stats
@@ -143,7 +266,10 @@ func (p *Portal) checkAndUpdateVerifications(host string) error {
 	p.authStats[host] = stats
 
 	if float64(stats.Verifications.Count)/float64(span) > maxVerifications {
-		return errors.New("too many verification requests from " + host)
+		return &tooManyRequestsError{
+			msg:        "too many verification requests from " + host,
+			retryAfter: p.cooldown(stats.Verifications),
+		}
 	}
 
 	return nil
@@ -171,8 +297,8 @@ func (p *Portal) checkAndUpdateFailedLogins(host string) error {
 		return nil
 	}
 
-	// IP exists but no failed logins yet.
-	if stats.FailedLogins.Count == 0 {
+	// IP exists but no failed logins yet or the counter has expired.
+	if stats.FailedLogins.Count == 0 || time.Now().Unix()-stats.FailedLogins.LastAttempt > int64(p.authWindow.Seconds()) {
 		stats.FailedLogins.LastAttempt = time.Now().Unix()
 		stats.FailedLogins.Count = 1
 		p.authStats[host] = stats
@@ -189,7 +315,10 @@ func (p *Portal) checkAndUpdateFailedLogins(host string) error {
 	p.authStats[host] = stats
 
 	if float64(stats.FailedLogins.Count)/float64(span) > maxFailedLogins {
-		return errors.New("too many failed logins from " + host)
+		return &tooManyRequestsError{
+			msg:        "too many failed logins from " + host,
+			retryAfter: p.cooldown(stats.FailedLogins),
+		}
 	}
 
 	return nil
@@ -217,8 +346,9 @@ func (p *Portal) checkAndUpdatePasswordResets(host string) error {
 		return nil
 	}
 
-	// IP exists but no password resets yet.
-	if stats.PasswordResets.Count == 0 {
+	// IP exists but no password resets yet or the counter has
+	// expired.
+	if stats.PasswordResets.Count == 0 || time.Now().Unix()-stats.PasswordResets.LastAttempt > int64(p.authWindow.Seconds()) {
 		stats.PasswordResets.LastAttempt = time.Now().Unix()
 		stats.PasswordResets.Count = 1
 		p.authStats[host] = stats
@@ -235,7 +365,10 @@ func (p *Portal) checkAndUpdatePasswordResets(host string) error {
 	p.authStats[host] = stats
 
 	if float64(stats.PasswordResets.Count)/float64(span) > maxPasswordResets {
-		return errors.New("too many password reset requests from " + host)
+		return &tooManyRequestsError{
+			msg:        "too many password reset requests from " + host,
+			retryAfter: p.cooldown(stats.PasswordResets),
+		}
 	}
 
 	return nil
@@ -0,0 +1,48 @@
+package portal
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	p := &Portal{
+		authStats:  make(map[string]authenticationStats),
+		authWindow: 10 * time.Minute,
+	}
+	checks := map[string]func(string) error{
+		"verifications":   p.checkAndUpdateVerifications,
+		"failed logins":   p.checkAndUpdateFailedLogins,
+		"password resets": p.checkAndUpdatePasswordResets,
+	}
+	for name, check := range checks {
+		host := "192.0.2." + strconv.Itoa(len(p.authStats)+1)
+		var err error
+		for i := 0; i < 10 && err == nil; i++ {
+			err = check(host)
+		}
+		if err == nil {
+			t.Fatalf("%v: expected the requests to be throttled", name)
+		}
+
+		rec := httptest.NewRecorder()
+		setRetryAfter(rec, err)
+		ra := rec.Header().Get("Retry-After")
+		seconds, pErr := strconv.Atoi(ra)
+		if pErr != nil {
+			t.Fatalf("%v: invalid Retry-After header %q", name, ra)
+		} else if seconds < 1 || seconds > int(p.authWindow.Seconds()) {
+			t.Fatalf("%v: expected Retry-After within the window, got %v", name, seconds)
+		}
+	}
+
+	// Other errors don't set the header.
+	rec := httptest.NewRecorder()
+	setRetryAfter(rec, errors.New("foo"))
+	if ra := rec.Header().Get("Retry-After"); ra != "" {
+		t.Fatalf("unexpected Retry-After header %q", ra)
+	}
+}
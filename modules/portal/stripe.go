@@ -175,7 +175,7 @@ func (api *portalAPI) paymentHandlerPOST(w http.ResponseWriter, req *http.Reques
 	}
 
 	// Prepare the decoder and decode the parameters.
-	dec, decErr := prepareDecoder(w, req)
+	dec, decErr := api.prepareDecoder(w, req)
 	if decErr != nil {
 		return
 	}
@@ -0,0 +1,134 @@
+package portal
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mike76-dev/sia-satellite/mail"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultLocale is the locale of the built-in email templates.
+	defaultLocale = "en"
+
+	// subjectsFilename is the name of the file containing the email
+	// subjects for a locale.
+	subjectsFilename = "subjects.json"
+
+	// defaultMailFromName is the default sender name of the emails.
+	defaultMailFromName = "Sia Satellite"
+)
+
+type (
+	// emailTemplate contains the subject and the body of an email.
+	emailTemplate struct {
+		Subject string
+		Text    string
+		HTML    string
+	}
+)
+
+// defaultTemplates contains the built-in English templates.
+var defaultTemplates = map[string]emailTemplate{
+	"verify": {
+		Subject: "Action Required",
+		Text:    verifyTextTemplate,
+		HTML:    verifyTemplate,
+	},
+	"reset": {
+		Subject: "Reset Your Password",
+		Text:    resetTextTemplate,
+		HTML:    resetTemplate,
+	},
+}
+
+// getLocale returns the preferred locale of the client derived from
+// the Accept-Language header.
+func getLocale(req *http.Request) string {
+	header := req.Header.Get("Accept-Language")
+	for _, tag := range strings.Split(header, ",") {
+		// Strip the quality value.
+		tag = strings.TrimSpace(strings.Split(tag, ";")[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		// Use only the primary language subtag.
+		tag = strings.ToLower(strings.Split(tag, "-")[0])
+		if isValidLocale(tag) {
+			return tag
+		}
+	}
+	return defaultLocale
+}
+
+// isValidLocale checks if the locale is safe to be used as a
+// directory name.
+func isValidLocale(locale string) bool {
+	if len(locale) == 0 || len(locale) > 8 {
+		return false
+	}
+	for _, c := range locale {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// loadTemplate loads the email template with the given name for the
+// given locale. The templates are read from <dir>/<locale>/<name>.html
+// and <dir>/<locale>/<name>.txt, and the subjects from
+// <dir>/<locale>/subjects.json. If any of them is missing, the built-in
+// English template is used, with the subject set in the config if any.
+// The templates of the links can use the fields of authLink.
+func (p *Portal) loadTemplate(name, locale string) emailTemplate {
+	et := defaultTemplates[name]
+	if subject := p.subjects[name]; subject != "" {
+		et.Subject = subject
+	}
+	if p.templatesDir == "" || !isValidLocale(locale) {
+		return et
+	}
+
+	dir := filepath.Join(p.templatesDir, locale)
+	html, err := os.ReadFile(filepath.Join(dir, name+".html"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.log.Error("couldn't read email template", zap.String("locale", locale), zap.String("name", name), zap.Error(err))
+		}
+		return et
+	}
+
+	text, err := os.ReadFile(filepath.Join(dir, name+".txt"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.log.Error("couldn't read email template", zap.String("locale", locale), zap.String("name", name), zap.Error(err))
+		}
+		return et
+	}
+
+	subjects := make(map[string]string)
+	js, err := os.ReadFile(filepath.Join(dir, subjectsFilename))
+	if err == nil {
+		err = json.Unmarshal(js, &subjects)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		p.log.Error("couldn't read email subjects", zap.String("locale", locale), zap.Error(err))
+	}
+
+	et.Text = string(text)
+	et.HTML = string(html)
+	if subject, ok := subjects[name]; ok && subject != "" {
+		if mail.ValidHeader(subject) {
+			et.Subject = subject
+		} else {
+			p.log.Error("email subject contains a line break", zap.String("locale", locale), zap.String("name", name))
+		}
+	}
+
+	return et
+}
@@ -49,6 +49,20 @@ func (ec ExtendedContract) DecodeFrom(d *types.Decoder) {
 	// Nothing to do here.
 }
 
+// RemainingBalance returns the amount that the renter can still spend
+// in the contract.
+func (ec ExtendedContract) RemainingBalance() types.Currency {
+	return ec.Contract.RenterFunds()
+}
+
+// RemainingWindow returns the number of blocks left at the given
+// height until the renewal window of the contract begins, and until the
+// contract expires. renewWindow is the renter's renew window.
+func (ec ExtendedContract) RemainingWindow(height, renewWindow uint64) (untilRenewal, untilExpiry uint64) {
+	rev := ec.Contract.Revision
+	return remainingWindow(height, rev.WindowStart, rev.WindowEnd, renewWindow)
+}
+
 // ExtendedContractSet is a collection of extendedContracts.
 type ExtendedContractSet struct {
 	Contracts []ExtendedContract
@@ -71,6 +71,38 @@ const (
 	// complete a multipart upload.
 	completeMultipartTime = 1 * time.Minute
 
+	// requestHistoryTime defines the amount of time that the provider has to
+	// retrieve the formation history and send it.
+	requestHistoryTime = 1 * time.Minute
+
+	// maxHistoryEntries is the maximum number of formation history entries
+	// returned at once.
+	maxHistoryEntries = 1000
+
+	// updateRevisionsTime defines the amount of time that the provider
+	// has to update a batch of contracts and send back a response.
+	updateRevisionsTime = 1 * time.Minute
+
+	// maxRevisionUpdates is the maximum number of revisions that can be
+	// updated in a single batch.
+	maxRevisionUpdates = 1000
+
+	// defaultMaxRenewBatch is the default maximum number of contracts
+	// that can be renewed in one RPC.
+	defaultMaxRenewBatch = 250
+
+	// requestLimitsTime defines the amount of time that the provider has
+	// to send its limits.
+	requestLimitsTime = 15 * time.Second
+
+	// requestFundingTime defines the amount of time that the provider
+	// has to send the available satellite funds.
+	requestFundingTime = 15 * time.Second
+
+	// verifyTokenTime defines the amount of time that the provider has
+	// to verify a renter token.
+	verifyTokenTime = 15 * time.Second
+
 	// defaultConnectionDeadline is the default read and write deadline which is set
 	// on a connection. This ensures it times out if I/O exceeds this deadline.
 	defaultConnectionDeadline = 5 * time.Minute
@@ -82,6 +114,17 @@ const (
 	// rpcRatelimit prevents someone from spamming the provider connections,
 	// causing it to spin up enough goroutines to crash.
 	rpcRatelimit = time.Millisecond * 50
+
+	// defaultMaxBlockHeightLeeway is the default maximum number of blocks
+	// the host's block height may differ from the satellite's one.
+	defaultMaxBlockHeightLeeway = 144
+)
+
+var (
+	// defaultMaxMinMaxCollateral is the default ceiling of the minimum
+	// MaxCollateral a host has to offer. No host offers more, so higher
+	// values would only make the contract formation fail.
+	defaultMaxMinMaxCollateral = types.Siacoins(100000)
 )
 
 var (
@@ -93,6 +136,10 @@ var (
 	// contracts on their behalf.
 	formContractsSpecifier = types.NewSpecifier("FormContracts")
 
+	// formContractsMinSpecifier is used when a renter requests to form a
+	// number of contracts, but accepts no fewer than a given minimum.
+	formContractsMinSpecifier = types.NewSpecifier("FormContractsMin")
+
 	// renewContractsSpecifier is used when a renter requests to renew a set of
 	// contracts.
 	renewContractsSpecifier = types.NewSpecifier("RenewContracts")
@@ -144,4 +191,40 @@ var (
 
 	// completeMultipartSpecifier is used when a multipart upload is completed.
 	completeMultipartSpecifier = types.NewSpecifier("FinishMultipart")
+
+	// requestHistorySpecifier is used when a renter requests the history of
+	// the contracts formed or renewed on their behalf.
+	requestHistorySpecifier = types.NewSpecifier("RequestHistory")
+
+	// updateRevisionsSpecifier is used when a renter submits a batch of
+	// new revisions.
+	updateRevisionsSpecifier = types.NewSpecifier("UpdateRevisions")
+
+	// requestLimitsSpecifier is used when a renter requests the limits
+	// of the batch RPCs.
+	requestLimitsSpecifier = types.NewSpecifier("RequestLimits")
+
+	// requestFundingSpecifier is used when a renter requests the funds
+	// available to the satellite for forming contracts.
+	requestFundingSpecifier = types.NewSpecifier("RequestFunding")
+
+	// verifyTokenSpecifier is used when a renter presents a token issued
+	// by the portal.
+	verifyTokenSpecifier = types.NewSpecifier("VerifyToken")
+
+	// withTokenSpecifier is used when a renter presents a token and then
+	// calls a token-gated RPC on the same connection.
+	withTokenSpecifier = types.NewSpecifier("WithToken")
+)
+
+// Error types reported to the renter, so that the renter can tell the
+// causes of a failed RPC apart.
+var (
+	errTypeInvalidSignature  = types.NewSpecifier("InvalidSignature")
+	errTypeRenterNotFound    = types.NewSpecifier("RenterNotFound")
+	errTypeContractNotFound  = types.NewSpecifier("ContractNotFound")
+	errTypeStaleRevision     = types.NewSpecifier("StaleRevision")
+	errTypeInternal          = types.NewSpecifier("Internal")
+	errTypeInsufficientFunds = types.NewSpecifier("InsufficientFunds")
+	errTypeInvalidToken      = types.NewSpecifier("InvalidToken")
 )
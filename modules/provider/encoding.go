@@ -1,6 +1,9 @@
 package provider
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/mike76-dev/sia-satellite/modules"
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
@@ -125,6 +128,12 @@ type formRequest struct {
 
 // DecodeFrom implements requestBody.
 func (fr *formRequest) DecodeFrom(d *types.Decoder) {
+	fr.decodeFields(d)
+	fr.Signature.DecodeFrom(d)
+}
+
+// decodeFields decodes the fields of the request except the signature.
+func (fr *formRequest) decodeFields(d *types.Decoder) {
 	d.Read(fr.PubKey[:])
 	sk := d.ReadBytes()
 	fr.SecretKey = types.PrivateKey(sk)
@@ -145,7 +154,6 @@ func (fr *formRequest) DecodeFrom(d *types.Decoder) {
 	(*types.V1Currency)(&fr.MinMaxCollateral).DecodeFrom(d)
 	fr.BlockHeightLeeway = d.ReadUint64()
 	fr.UploadPacking = d.ReadBool()
-	fr.Signature.DecodeFrom(d)
 }
 
 // EncodeTo implements requestBody.
@@ -171,6 +179,30 @@ func (fr *formRequest) EncodeTo(e *types.Encoder) {
 	e.WriteBool(fr.UploadPacking)
 }
 
+// formMinRequest is a formRequest that also specifies the minimum
+// number of contracts the renter accepts. It is sent with its own
+// specifier, so that the layout of formRequest stays unchanged.
+type formMinRequest struct {
+	formRequest
+
+	// MinHosts is the minimum number of contracts the renter accepts.
+	// Zero means that any number is accepted.
+	MinHosts uint64
+}
+
+// DecodeFrom implements requestBody.
+func (fmr *formMinRequest) DecodeFrom(d *types.Decoder) {
+	fmr.formRequest.decodeFields(d)
+	fmr.MinHosts = d.ReadUint64()
+	fmr.Signature.DecodeFrom(d)
+}
+
+// EncodeTo implements requestBody.
+func (fmr *formMinRequest) EncodeTo(e *types.Encoder) {
+	fmr.formRequest.EncodeTo(e)
+	e.WriteUint64(fmr.MinHosts)
+}
+
 // renewRequest is used when the renter requests contract renewals.
 type renewRequest struct {
 	PubKey      types.PublicKey
@@ -860,3 +892,205 @@ func (cmr *completeMultipartRequest) EncodeTo(e *types.Encoder) {
 	e.Write(cmr.PubKey[:])
 	e.Write(cmr.UploadID[:])
 }
+
+// requestHistoryRequest is used when the renter requests the history
+// of the contracts formed or renewed on their behalf.
+type requestHistoryRequest struct {
+	PubKey types.PublicKey
+	Offset uint64
+	Limit  uint64
+
+	Signature types.Signature
+}
+
+// DecodeFrom implements requestBody.
+func (rhr *requestHistoryRequest) DecodeFrom(d *types.Decoder) {
+	d.Read(rhr.PubKey[:])
+	rhr.Offset = d.ReadUint64()
+	rhr.Limit = d.ReadUint64()
+	rhr.Signature.DecodeFrom(d)
+}
+
+// EncodeTo implements requestBody.
+func (rhr *requestHistoryRequest) EncodeTo(e *types.Encoder) {
+	e.Write(rhr.PubKey[:])
+	e.WriteUint64(rhr.Offset)
+	e.WriteUint64(rhr.Limit)
+}
+
+// historyEntry describes a single contract formation or renewal.
+type historyEntry struct {
+	Timestamp   time.Time
+	ContractID  types.FileContractID
+	HostKey     types.PublicKey
+	RenewedFrom types.FileContractID
+	TotalCost   types.Currency
+}
+
+// EncodeTo implements requestBody.
+func (he historyEntry) EncodeTo(e *types.Encoder) {
+	e.WriteTime(he.Timestamp)
+	he.ContractID.EncodeTo(e)
+	he.HostKey.EncodeTo(e)
+	he.RenewedFrom.EncodeTo(e)
+	types.V1Currency(he.TotalCost).EncodeTo(e)
+}
+
+// requestHistoryResponse is the response type for requestHistoryRequest.
+type requestHistoryResponse struct {
+	entries []historyEntry
+	total   uint64
+}
+
+// EncodeTo implements requestBody.
+func (rhr requestHistoryResponse) EncodeTo(e *types.Encoder) {
+	e.WriteUint64(rhr.total)
+	e.WritePrefix(len(rhr.entries))
+	for _, entry := range rhr.entries {
+		entry.EncodeTo(e)
+	}
+}
+
+// DecodeFrom implements requestBody.
+func (rhr requestHistoryResponse) DecodeFrom(d *types.Decoder) {
+	// Nothing to do here.
+}
+
+// revisionUpdate contains a single revision within updateRevisionsRequest.
+type revisionUpdate struct {
+	Contract    rhpv2.ContractRevision
+	Uploads     types.Currency
+	Downloads   types.Currency
+	FundAccount types.Currency
+}
+
+// DecodeFrom implements requestBody.
+func (ru *revisionUpdate) DecodeFrom(d *types.Decoder) {
+	ru.Contract.Revision.DecodeFrom(d)
+	ru.Contract.Signatures[0].DecodeFrom(d)
+	ru.Contract.Signatures[1].DecodeFrom(d)
+	(*types.V1Currency)(&ru.Uploads).DecodeFrom(d)
+	(*types.V1Currency)(&ru.Downloads).DecodeFrom(d)
+	(*types.V1Currency)(&ru.FundAccount).DecodeFrom(d)
+}
+
+// EncodeTo implements requestBody.
+func (ru *revisionUpdate) EncodeTo(e *types.Encoder) {
+	ru.Contract.Revision.EncodeTo(e)
+	ru.Contract.Signatures[0].EncodeTo(e)
+	ru.Contract.Signatures[1].EncodeTo(e)
+	types.V1Currency(ru.Uploads).EncodeTo(e)
+	types.V1Currency(ru.Downloads).EncodeTo(e)
+	types.V1Currency(ru.FundAccount).EncodeTo(e)
+}
+
+// updateRevisionsRequest is used when the renter submits a batch of new
+// revisions. A single signature covers the whole batch.
+type updateRevisionsRequest struct {
+	PubKey  types.PublicKey
+	Updates []revisionUpdate
+
+	Signature types.Signature
+}
+
+// DecodeFrom implements requestBody.
+func (ur *updateRevisionsRequest) DecodeFrom(d *types.Decoder) {
+	d.Read(ur.PubKey[:])
+	n := d.ReadPrefix()
+	if n > maxRevisionUpdates {
+		d.SetErr(fmt.Errorf("too many revision updates: %d > %d", n, maxRevisionUpdates))
+		return
+	}
+	ur.Updates = make([]revisionUpdate, n)
+	for i := range ur.Updates {
+		ur.Updates[i].DecodeFrom(d)
+	}
+	ur.Signature.DecodeFrom(d)
+}
+
+// EncodeTo implements requestBody.
+func (ur *updateRevisionsRequest) EncodeTo(e *types.Encoder) {
+	e.Write(ur.PubKey[:])
+	e.WritePrefix(len(ur.Updates))
+	for i := range ur.Updates {
+		ur.Updates[i].EncodeTo(e)
+	}
+}
+
+// updateRevisionsResponse is the response type for updateRevisionsRequest.
+// It contains an error for each failed update, or nil if the update
+// succeeded, in the order of the request.
+type updateRevisionsResponse struct {
+	errors []*modules.RPCError
+}
+
+// EncodeTo implements requestBody.
+func (ur *updateRevisionsResponse) EncodeTo(e *types.Encoder) {
+	e.WritePrefix(len(ur.errors))
+	for _, re := range ur.errors {
+		e.WriteBool(re != nil)
+		if re != nil {
+			re.EncodeTo(e)
+		}
+	}
+}
+
+// DecodeFrom implements requestBody.
+func (ur *updateRevisionsResponse) DecodeFrom(d *types.Decoder) {
+	// Nothing to do here.
+}
+
+// limitsResponse contains the maximum batch sizes accepted by the
+// provider.
+type limitsResponse struct {
+	maxRenewBatch      uint64
+	maxRevisionUpdates uint64
+}
+
+// EncodeTo implements requestBody.
+func (lr *limitsResponse) EncodeTo(e *types.Encoder) {
+	e.WriteUint64(lr.maxRenewBatch)
+	e.WriteUint64(lr.maxRevisionUpdates)
+}
+
+// DecodeFrom implements requestBody.
+func (lr *limitsResponse) DecodeFrom(d *types.Decoder) {
+	// Nothing to do here.
+}
+
+// fundingResponse contains the siacoins available to the satellite for
+// forming contracts.
+type fundingResponse struct {
+	available types.Currency
+}
+
+// EncodeTo implements requestBody.
+func (fr *fundingResponse) EncodeTo(e *types.Encoder) {
+	types.V1Currency(fr.available).EncodeTo(e)
+}
+
+// DecodeFrom implements requestBody.
+func (fr *fundingResponse) DecodeFrom(d *types.Decoder) {
+	// Nothing to do here.
+}
+
+// tokenRequest is used when the renter proves the ownership of their
+// account with a token issued by the portal.
+type tokenRequest struct {
+	PubKey    types.PublicKey
+	Token     types.Hash256
+	Signature types.Signature
+}
+
+// DecodeFrom implements requestBody.
+func (tr *tokenRequest) DecodeFrom(d *types.Decoder) {
+	d.Read(tr.PubKey[:])
+	d.Read(tr.Token[:])
+	tr.Signature.DecodeFrom(d)
+}
+
+// EncodeTo implements requestBody.
+func (tr *tokenRequest) EncodeTo(e *types.Encoder) {
+	e.Write(tr.PubKey[:])
+	e.Write(tr.Token[:])
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"crypto/tls"
 	"errors"
 	"io"
 	"net"
@@ -22,13 +23,28 @@ import (
 
 // initNetworking performs actions like port forwarding, and gets the
 // Satellite established on the network.
-func (p *Provider) initNetworking(address, muxAddr string) (err error) {
+func (p *Provider) initNetworking(address, muxAddr, tlsCert, tlsKey string) (err error) {
 	// Create the listener and setup the close procedures.
 	p.listener, err = net.Listen("tcp", address)
 	if err != nil {
 		return err
 	}
 
+	// Wrap the listener in TLS if requested. The RPC handshake runs
+	// inside the TLS tunnel unchanged.
+	if tlsCert != "" || tlsKey != "" {
+		cr, err := newCertReloader(tlsCert, tlsKey, p.log)
+		if err != nil {
+			p.listener.Close()
+			return modules.AddContext(err, "couldn't load TLS certificate")
+		}
+		p.listener = tls.NewListener(p.listener, &tls.Config{
+			GetCertificate: cr.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		})
+		p.log.Info("renter RPCs are served over TLS")
+	}
+
 	// Automatically close the listener when p.tg.Stop() is called.
 	threadedListenerClosedChan := make(chan struct{})
 	p.tg.OnStop(func() {
@@ -272,31 +288,15 @@ func (p *Provider) threadedHandleConn(conn net.Conn) {
 		if err != nil {
 			err = modules.AddContext(err, "incoming RPCRequestContracts failed")
 		}
-	case formContractsSpecifier:
-		err = p.managedFormContracts(s)
-		if err != nil {
-			err = modules.AddContext(err, "incoming RPCFormContracts failed")
-		}
-	case renewContractsSpecifier:
-		err = p.managedRenewContracts(s)
-		if err != nil {
-			err = modules.AddContext(err, "incoming RPCRenewContracts failed")
-		}
-	case updateRevisionSpecifier:
-		err = p.managedUpdateRevision(s)
-		if err != nil {
-			err = modules.AddContext(err, "incoming RPCUpdateRevision failed")
-		}
-	case formContractSpecifier:
-		err = p.managedFormContract(s)
-		if err != nil {
-			err = modules.AddContext(err, "incoming RPCFormContract failed")
-		}
-	case renewContractSpecifier:
-		err = p.managedRenewContract(s)
-		if err != nil {
-			err = modules.AddContext(err, "incoming RPCRenewContract failed")
+	case formContractsSpecifier, formContractsMinSpecifier, renewContractsSpecifier,
+		updateRevisionSpecifier, formContractSpecifier, renewContractSpecifier,
+		updateRevisionsSpecifier:
+		if p.requireToken {
+			err = errors.New("this RPC requires a renter token")
+			s.WriteTypedError(errTypeInvalidToken, err)
+			break
 		}
+		err = p.managedGatedRPC(s, id, nil)
 	case getSettingsSpecifier:
 		err = p.managedGetSettings(s)
 		if err != nil {
@@ -337,6 +337,31 @@ func (p *Provider) threadedHandleConn(conn net.Conn) {
 		if err != nil {
 			err = modules.AddContext(err, "incoming RPCCompleteMultipart failed")
 		}
+	case requestHistorySpecifier:
+		err = p.managedRequestHistory(s)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCRequestHistory failed")
+		}
+	case requestLimitsSpecifier:
+		err = p.managedRequestLimits(s)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCRequestLimits failed")
+		}
+	case requestFundingSpecifier:
+		err = p.managedRequestFunding(s)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCRequestFunding failed")
+		}
+	case verifyTokenSpecifier:
+		err = p.managedVerifyToken(s)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCVerifyToken failed")
+		}
+	case withTokenSpecifier:
+		err = p.managedWithToken(s)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCWithToken failed")
+		}
 	default:
 		p.log.Info("inbound connection from", zap.Stringer("host", conn.RemoteAddr())) //TODO
 	}
@@ -345,6 +370,52 @@ func (p *Provider) threadedHandleConn(conn net.Conn) {
 	}
 }
 
+// managedGatedRPC calls one of the RPCs that can be gated on a renter
+// token. If bound is not nil, the request must be signed with that key.
+func (p *Provider) managedGatedRPC(s *modules.RPCSession, id types.Specifier, bound *types.PublicKey) (err error) {
+	switch id {
+	case formContractsSpecifier:
+		err = p.managedFormContracts(s, false, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCFormContracts failed")
+		}
+	case formContractsMinSpecifier:
+		err = p.managedFormContracts(s, true, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCFormContractsMin failed")
+		}
+	case renewContractsSpecifier:
+		err = p.managedRenewContracts(s, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCRenewContracts failed")
+		}
+	case updateRevisionSpecifier:
+		err = p.managedUpdateRevision(s, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCUpdateRevision failed")
+		}
+	case formContractSpecifier:
+		err = p.managedFormContract(s, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCFormContract failed")
+		}
+	case renewContractSpecifier:
+		err = p.managedRenewContract(s, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCRenewContract failed")
+		}
+	case updateRevisionsSpecifier:
+		err = p.managedUpdateRevisions(s, bound)
+		if err != nil {
+			err = modules.AddContext(err, "incoming RPCUpdateRevisions failed")
+		}
+	default:
+		err = errors.New("RPC " + id.String() + " can't be called with a token")
+		s.WriteError(err)
+	}
+	return
+}
+
 // threadedHandleStream handles an incoming RHP3 stream.
 func (p *Provider) threadedHandleStream(s *rhpv3.Stream, addr string) {
 	err := p.tg.Add()
@@ -1,6 +1,9 @@
 package provider
 
 import (
+	"bytes"
+	"time"
+
 	"github.com/mike76-dev/sia-satellite/modules"
 	"go.uber.org/zap"
 
@@ -68,3 +71,89 @@ func (p *Provider) save() error {
 
 	return err
 }
+
+// recordHistory saves the contracts formed or renewed on behalf of the
+// renter in the formation history.
+func (p *Provider) recordHistory(rpk types.PublicKey, contracts []modules.RenterContract) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return modules.AddContext(err, "couldn't start transaction")
+	}
+
+	for _, contract := range contracts {
+		var buf bytes.Buffer
+		e := types.NewEncoder(&buf)
+		types.V2Currency(contract.TotalCost).EncodeTo(e)
+		e.Flush()
+		renewedFrom := p.m.RenewedFrom(contract.ID)
+		_, err = tx.Exec(`
+			INSERT INTO pr_history (
+				renter_pk,
+				timestamp,
+				contract_id,
+				host_key,
+				renewed_from,
+				total_cost
+			)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`,
+			rpk[:],
+			time.Now().Unix(),
+			contract.ID[:],
+			contract.HostPublicKey[:],
+			renewedFrom[:],
+			buf.Bytes(),
+		)
+		if err != nil {
+			tx.Rollback()
+			return modules.AddContext(err, "couldn't save history entry")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getHistory retrieves a page of the renter's formation history, oldest
+// entries first, together with the total number of entries.
+func (p *Provider) getHistory(rpk types.PublicKey, offset, limit uint64) (entries []historyEntry, total uint64, err error) {
+	err = p.db.QueryRow("SELECT COUNT(*) FROM pr_history WHERE renter_pk = ?", rpk[:]).Scan(&total)
+	if err != nil {
+		return nil, 0, modules.AddContext(err, "couldn't count history entries")
+	}
+
+	rows, err := p.db.Query(`
+		SELECT timestamp, contract_id, host_key, renewed_from, total_cost
+		FROM pr_history
+		WHERE renter_pk = ?
+		ORDER BY id ASC
+		LIMIT ?, ?
+	`, rpk[:], offset, limit)
+	if err != nil {
+		return nil, 0, modules.AddContext(err, "couldn't query history entries")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry historyEntry
+		var timestamp uint64
+		id := make([]byte, 32)
+		hk := make([]byte, 32)
+		rf := make([]byte, 32)
+		cost := make([]byte, 16)
+		if err := rows.Scan(&timestamp, &id, &hk, &rf, &cost); err != nil {
+			return nil, 0, modules.AddContext(err, "couldn't scan history entry")
+		}
+		entry.Timestamp = time.Unix(int64(timestamp), 0)
+		copy(entry.ContractID[:], id)
+		copy(entry.HostKey[:], hk)
+		copy(entry.RenewedFrom[:], rf)
+		d := types.NewBufDecoder(cost)
+		(*types.V2Currency)(&entry.TotalCost).DecodeFrom(d)
+		if err := d.Err(); err != nil {
+			return nil, 0, modules.AddContext(err, "couldn't decode total cost")
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, nil
+}
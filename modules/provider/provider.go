@@ -26,6 +26,19 @@ type Provider struct {
 	publicKey   types.PublicKey
 	secretKey   types.PrivateKey
 
+	// maxRenewBatch is the maximum number of contracts that can be
+	// renewed in one RPC.
+	maxRenewBatch uint64
+
+	// maxBlockHeightLeeway and maxMinMaxCollateral are the ceilings of
+	// the BlockHeightLeeway and the MinMaxCollateral of an allowance.
+	maxBlockHeightLeeway uint64
+	maxMinMaxCollateral  types.Currency
+
+	// requireToken is true if the contract RPCs can only be called
+	// with a renter token.
+	requireToken bool
+
 	// Utilities.
 	listener net.Listener
 	mux      net.Listener
@@ -35,8 +48,16 @@ type Provider struct {
 	tg       siasync.ThreadGroup
 }
 
-// New returns an initialized Provider.
-func New(db *sql.DB, s modules.Syncer, m modules.Manager, satelliteAddr string, muxAddr string, dir string) (*Provider, <-chan error) {
+// New returns an initialized Provider. maxRenewBatch is the maximum
+// number of contracts that can be renewed in one RPC; if zero, the
+// default value is used. maxLeeway and maxMinMaxCollateral are the
+// ceilings of the BlockHeightLeeway and the MinMaxCollateral a renter
+// can request; if zero, the default values are used. If tlsCert and
+// tlsKey are set, the renter
+// RPC listener is wrapped in TLS. The certificate is reloaded when the
+// files change. If requireToken is true, the contract RPCs can only be
+// called with a renter token.
+func New(db *sql.DB, s modules.Syncer, m modules.Manager, satelliteAddr string, muxAddr string, dir string, maxRenewBatch, maxLeeway uint64, maxMinMaxCollateral types.Currency, tlsCert, tlsKey string, requireToken bool) (*Provider, <-chan error) {
 	errChan := make(chan error, 1)
 	var err error
 
@@ -45,6 +66,20 @@ func New(db *sql.DB, s modules.Syncer, m modules.Manager, satelliteAddr string,
 		db: db,
 		s:  s,
 		m:  m,
+
+		maxRenewBatch:        defaultMaxRenewBatch,
+		maxBlockHeightLeeway: defaultMaxBlockHeightLeeway,
+		maxMinMaxCollateral:  defaultMaxMinMaxCollateral,
+		requireToken:         requireToken,
+	}
+	if maxRenewBatch > 0 {
+		p.maxRenewBatch = maxRenewBatch
+	}
+	if maxLeeway > 0 {
+		p.maxBlockHeightLeeway = maxLeeway
+	}
+	if !maxMinMaxCollateral.IsZero() {
+		p.maxMinMaxCollateral = maxMinMaxCollateral
 	}
 
 	// Call stop in the event of a partial startup.
@@ -74,7 +109,7 @@ func New(db *sql.DB, s modules.Syncer, m modules.Manager, satelliteAddr string,
 	}
 
 	// Initialize the networking.
-	err = p.initNetworking(satelliteAddr, muxAddr)
+	err = p.initNetworking(satelliteAddr, muxAddr, tlsCert, tlsKey)
 	if err != nil {
 		p.log.Error("could not initialize provider networking", zap.Error(err))
 		errChan <- err
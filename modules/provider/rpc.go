@@ -1,10 +1,13 @@
 package provider
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/mike76-dev/sia-satellite/modules"
@@ -19,6 +22,31 @@ import (
 // The maximum allowed total size of temporary files.
 const maxBufferSize = uint64(40 * 1024 * 1024 * 1024) // 40 GiB
 
+// sessionContext returns a context that is cancelled when the renter
+// closes the connection or the deadline passes. The renter is not
+// expected to send anything while waiting for the response, so a
+// pending read only returns if the connection is gone. The returned
+// function stops watching the connection and must be called before
+// the session is used again.
+func sessionContext(s *modules.RPCSession, deadline time.Time) (context.Context, func()) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var buf [1]byte
+		if _, err := s.Conn.Read(buf[:]); !errors.Is(err, os.ErrDeadlineExceeded) {
+			cancel()
+		}
+	}()
+	return ctx, func() {
+		// Unblock the pending read and restore the deadline.
+		s.Conn.SetReadDeadline(time.Now())
+		<-done
+		s.Conn.SetDeadline(deadline)
+		cancel()
+	}
+}
+
 // managedRequestContracts returns a slice containing the list of the
 // renter's active contracts.
 func (p *Provider) managedRequestContracts(s *modules.RPCSession) error {
@@ -35,7 +63,7 @@ func (p *Provider) managedRequestContracts(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if ok := rr.PubKey.VerifyHash(hash, rr.Signature); !ok {
+	if !verifyRenterSignature(rr.PubKey, hash, rr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -56,31 +84,32 @@ func (p *Provider) managedRequestContracts(s *modules.RPCSession) error {
 	}
 
 	for _, contract := range contracts {
-		cr := convertContract(contract)
-		ecs.Contracts = append(ecs.Contracts, modules.ExtendedContract{
-			Contract:            cr,
-			StartHeight:         contract.StartHeight,
-			ContractPrice:       contract.ContractFee,
-			TotalCost:           contract.TotalCost,
-			UploadSpending:      contract.UploadSpending,
-			DownloadSpending:    contract.DownloadSpending,
-			FundAccountSpending: contract.FundAccountSpending,
-			RenewedFrom:         p.m.RenewedFrom(contract.ID),
-		})
+		ecs.Contracts = append(ecs.Contracts, p.extendContract(contract))
 	}
 
 	return s.WriteResponse(&ecs)
 }
 
 // managedFormContracts forms the specified number of contracts with the hosts
-// on behalf of the renter.
-func (p *Provider) managedFormContracts(s *modules.RPCSession) error {
+// on behalf of the renter. If withMin is set, the request also specifies the
+// minimum number of contracts the renter accepts.
+func (p *Provider) managedFormContracts(s *modules.RPCSession, withMin bool, bound *types.PublicKey) error {
 	// Extend the deadline to meet the formation of multiple contracts.
-	s.Conn.SetDeadline(time.Now().Add(formContractsTime))
+	deadline := time.Now().Add(formContractsTime)
+	s.Conn.SetDeadline(deadline)
 
 	// Read the request.
 	var fr formRequest
-	hash, err := s.ReadRequest(&fr, 65536)
+	var minHosts uint64
+	var hash types.Hash256
+	var err error
+	if withMin {
+		var fmr formMinRequest
+		hash, err = s.ReadRequest(&fmr, 65536)
+		fr, minHosts = fmr.formRequest, fmr.MinHosts
+	} else {
+		hash, err = s.ReadRequest(&fr, 65536)
+	}
 	if err != nil {
 		err = fmt.Errorf("could not read renter request: %v", err)
 		s.WriteError(err)
@@ -88,12 +117,17 @@ func (p *Provider) managedFormContracts(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !fr.PubKey.VerifyHash(hash, fr.Signature) {
+	if !verifyRenterSignature(fr.PubKey, hash, fr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
 	}
 
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, fr.PubKey); err != nil {
+		return err
+	}
+
 	// Check if we know this renter.
 	_, err = p.m.GetRenter(fr.PubKey)
 	if err != nil {
@@ -108,6 +142,11 @@ func (p *Provider) managedFormContracts(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
+	if minHosts > fr.Hosts {
+		err := errors.New("minimum number of hosts exceeds the number of hosts")
+		s.WriteError(err)
+		return err
+	}
 	if fr.Period == 0 {
 		err := errors.New("can't form contracts with zero period")
 		s.WriteError(err)
@@ -123,11 +162,16 @@ func (p *Provider) managedFormContracts(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
-	if fr.MinShards == 0 || fr.TotalShards == 0 {
+	if !validRedundancy(fr.MinShards, fr.TotalShards) {
 		err := errors.New("can't form contracts with such redundancy params")
 		s.WriteError(err)
 		return err
 	}
+	if err := p.checkAllowanceBounds(fr.BlockHeightLeeway, fr.MinMaxCollateral); err != nil {
+		err = fmt.Errorf("can't form contracts: %v", err)
+		s.WriteError(err)
+		return err
+	}
 
 	ecs := modules.ExtendedContractSet{
 		Contracts: make([]modules.ExtendedContract, 0, fr.Hosts),
@@ -157,30 +201,51 @@ func (p *Provider) managedFormContracts(s *modules.RPCSession) error {
 		UploadPacking: fr.UploadPacking,
 	}
 
-	// Form the contracts.
-	contracts, err := p.m.FormContracts(fr.PubKey, fr.SecretKey, a)
+	// Form the contracts. The formations are aborted if the renter
+	// disconnects in the meantime.
+	ctx, stop := sessionContext(s, deadline)
+	contracts, err := p.m.FormContracts(ctx, fr.PubKey, fr.SecretKey, a)
+	stop()
+
+	// Record the contracts even if the formation was aborted, so that
+	// the renter can find them later.
+	if len(contracts) > 0 {
+		if err := p.recordHistory(fr.PubKey, contracts); err != nil {
+			p.log.Error("couldn't record formation history", zap.Error(err))
+		}
+	}
+	if errors.Is(err, modules.ErrInsufficientSatelliteFunds) {
+		s.WriteTypedError(errTypeInsufficientFunds, err)
+		return err
+	}
 	if err != nil {
-		err = fmt.Errorf("could not form contracts: %v", err)
+		err = fmt.Errorf("could not form contracts (%d formed): %v", len(contracts), err)
 		s.WriteError(err)
 		return err
 	}
 
+	// Fail if fewer contracts than requested were formed. The formed
+	// contracts are kept and can be retrieved by the renter later.
+	if uint64(len(contracts)) < minHosts {
+		err = fmt.Errorf("could only form %d contracts out of the required minimum of %d", len(contracts), minHosts)
+		s.WriteError(err)
+		return err
+	}
+
+	sortFormedContracts(contracts)
+
 	for _, contract := range contracts {
-		cr := convertContract(contract)
-		ecs.Contracts = append(ecs.Contracts, modules.ExtendedContract{
-			Contract:    cr,
-			StartHeight: contract.StartHeight,
-			TotalCost:   contract.TotalCost,
-		})
+		ecs.Contracts = append(ecs.Contracts, p.extendContract(contract))
 	}
 
 	return s.WriteResponse(&ecs)
 }
 
 // managedRenewContracts tries to renew the given set of contracts.
-func (p *Provider) managedRenewContracts(s *modules.RPCSession) error {
+func (p *Provider) managedRenewContracts(s *modules.RPCSession, bound *types.PublicKey) error {
 	// Extend the deadline to meet the renewal of multiple contracts.
-	s.Conn.SetDeadline(time.Now().Add(renewContractsTime))
+	deadline := time.Now().Add(renewContractsTime)
+	s.Conn.SetDeadline(deadline)
 
 	// Read the request.
 	var rr renewRequest
@@ -192,12 +257,17 @@ func (p *Provider) managedRenewContracts(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !rr.PubKey.VerifyHash(hash, rr.Signature) {
+	if !verifyRenterSignature(rr.PubKey, hash, rr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
 	}
 
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, rr.PubKey); err != nil {
+		return err
+	}
+
 	// Check if we know this renter.
 	_, err = p.m.GetRenter(rr.PubKey)
 	if err != nil {
@@ -212,6 +282,11 @@ func (p *Provider) managedRenewContracts(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
+	if uint64(len(rr.Contracts)) > p.maxRenewBatch {
+		err := fmt.Errorf("can't renew %d contracts at once, at most %d are allowed; split the request into smaller batches", len(rr.Contracts), p.maxRenewBatch)
+		s.WriteError(err)
+		return err
+	}
 	if rr.Period == 0 {
 		err := errors.New("can't renew contracts with zero period")
 		s.WriteError(err)
@@ -227,11 +302,16 @@ func (p *Provider) managedRenewContracts(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
-	if rr.MinShards == 0 || rr.TotalShards == 0 {
+	if !validRedundancy(rr.MinShards, rr.TotalShards) {
 		err := errors.New("can't renew contracts with such redundancy params")
 		s.WriteError(err)
 		return err
 	}
+	if err := p.checkAllowanceBounds(rr.BlockHeightLeeway, rr.MinMaxCollateral); err != nil {
+		err = fmt.Errorf("can't renew contracts: %v", err)
+		s.WriteError(err)
+		return err
+	}
 
 	ecs := modules.ExtendedContractSet{
 		Contracts: make([]modules.ExtendedContract, 0, len(rr.Contracts)),
@@ -261,26 +341,77 @@ func (p *Provider) managedRenewContracts(s *modules.RPCSession) error {
 		UploadPacking: rr.UploadPacking,
 	}
 
-	// Renew the contracts.
-	contracts, err := p.m.RenewContracts(rr.PubKey, rr.SecretKey, a, rr.Contracts)
+	// Renew the contracts. The renewals are aborted if the renter
+	// disconnects in the meantime.
+	ctx, stop := sessionContext(s, deadline)
+	contracts, err := p.m.RenewContracts(ctx, rr.PubKey, rr.SecretKey, a, rr.Contracts)
+	stop()
+
+	// Record the contracts even if the renewal was aborted, so that
+	// the renter can find them later.
+	if len(contracts) > 0 {
+		if err := p.recordHistory(rr.PubKey, contracts); err != nil {
+			p.log.Error("couldn't record renewal history", zap.Error(err))
+		}
+	}
+	if errors.Is(err, modules.ErrInsufficientSatelliteFunds) {
+		s.WriteTypedError(errTypeInsufficientFunds, err)
+		return err
+	}
 	if err != nil {
-		err = fmt.Errorf("could not renew contracts: %v", err)
+		err = fmt.Errorf("could not renew contracts (%d renewed): %v", len(contracts), err)
 		s.WriteError(err)
 		return err
 	}
 
+	sortRenewedContracts(contracts, p.m.RenewedFrom)
+
 	for _, contract := range contracts {
-		cr := convertContract(contract)
-		ecs.Contracts = append(ecs.Contracts, modules.ExtendedContract{
-			Contract:    cr,
-			StartHeight: contract.StartHeight,
-			TotalCost:   contract.TotalCost,
-		})
+		ecs.Contracts = append(ecs.Contracts, p.extendContract(contract))
 	}
 
 	return s.WriteResponse(&ecs)
 }
 
+// sortFormedContracts sorts the contracts by the host key, so that the
+// order is stable.
+func sortFormedContracts(contracts []modules.RenterContract) {
+	sort.Slice(contracts, func(i, j int) bool {
+		return bytes.Compare(contracts[i].HostPublicKey[:], contracts[j].HostPublicKey[:]) < 0
+	})
+}
+
+// sortRenewedContracts sorts the contracts by the ID of the contract
+// they were renewed from, so that the order is stable.
+func sortRenewedContracts(contracts []modules.RenterContract, renewedFrom func(types.FileContractID) types.FileContractID) {
+	from := make(map[types.FileContractID]types.FileContractID)
+	for _, contract := range contracts {
+		from[contract.ID] = renewedFrom(contract.ID)
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		rfi, rfj := from[contracts[i].ID], from[contracts[j].ID]
+		return bytes.Compare(rfi[:], rfj[:]) < 0
+	})
+}
+
+// validRedundancy returns true if the redundancy params can be used to
+// erasure-code the data.
+func validRedundancy(minShards, totalShards uint64) bool {
+	return minShards > 0 && totalShards > 0 && minShards <= totalShards
+}
+
+// checkAllowanceBounds checks that the BlockHeightLeeway and the
+// MinMaxCollateral of an allowance are within the configured bounds.
+func (p *Provider) checkAllowanceBounds(leeway uint64, minMaxCollateral types.Currency) error {
+	if leeway > p.maxBlockHeightLeeway {
+		return fmt.Errorf("block height leeway of %v exceeds the maximum of %v", leeway, p.maxBlockHeightLeeway)
+	}
+	if minMaxCollateral.Cmp(p.maxMinMaxCollateral) > 0 {
+		return fmt.Errorf("minimum max collateral of %v exceeds the maximum of %v", minMaxCollateral, p.maxMinMaxCollateral)
+	}
+	return nil
+}
+
 // convertContract converts the contract metadata into `core` style.
 func convertContract(c modules.RenterContract) rhpv2.ContractRevision {
 	txn := modules.CopyTransaction(c.Transaction)
@@ -293,8 +424,23 @@ func convertContract(c modules.RenterContract) rhpv2.ContractRevision {
 	}
 }
 
+// extendContract converts the contract into an ExtendedContract,
+// including the breakdown of the renter's spendings.
+func (p *Provider) extendContract(c modules.RenterContract) modules.ExtendedContract {
+	return modules.ExtendedContract{
+		Contract:            convertContract(c),
+		StartHeight:         c.StartHeight,
+		ContractPrice:       c.ContractFee,
+		TotalCost:           c.TotalCost,
+		UploadSpending:      c.UploadSpending,
+		DownloadSpending:    c.DownloadSpending,
+		FundAccountSpending: c.FundAccountSpending,
+		RenewedFrom:         p.m.RenewedFrom(c.ID),
+	}
+}
+
 // managedUpdateRevision updates the contract with a new revision.
-func (p *Provider) managedUpdateRevision(s *modules.RPCSession) error {
+func (p *Provider) managedUpdateRevision(s *modules.RPCSession, bound *types.PublicKey) error {
 	// Extend the deadline to meet the revision update.
 	s.Conn.SetDeadline(time.Now().Add(updateRevisionTime))
 
@@ -308,9 +454,14 @@ func (p *Provider) managedUpdateRevision(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if ok := ur.PubKey.VerifyHash(hash, ur.Signature); !ok {
+	if !verifyRenterSignature(ur.PubKey, hash, ur.Signature) {
 		err = errors.New("could not verify renter signature")
-		s.WriteError(err)
+		s.WriteTypedError(errTypeInvalidSignature, err)
+		return err
+	}
+
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, ur.PubKey); err != nil {
 		return err
 	}
 
@@ -318,7 +469,15 @@ func (p *Provider) managedUpdateRevision(s *modules.RPCSession) error {
 	_, err = p.m.GetRenter(ur.PubKey)
 	if err != nil {
 		err = fmt.Errorf("could not find renter in the database: %v", err)
-		s.WriteError(err)
+		s.WriteTypedError(errTypeRenterNotFound, err)
+		return err
+	}
+
+	// Verify the signatures of the revision.
+	shs, ok := revisionSignatures(ur.Contract)
+	if !ok || verifyRequestSignatures(shs) != nil {
+		err = errors.New("could not verify revision signatures")
+		s.WriteTypedError(errTypeInvalidSignature, err)
 		return err
 	}
 
@@ -332,17 +491,109 @@ func (p *Provider) managedUpdateRevision(s *modules.RPCSession) error {
 
 	// Send a response.
 	if err != nil {
-		err = fmt.Errorf("couldn't update contract: %v", err)
-		s.WriteError(err)
+		errType := updateErrorType(err)
+		err = fmt.Errorf("couldn't update contract: %w", err)
+		s.WriteTypedError(errType, err)
 		return err
 	}
 
 	return s.WriteResponse(nil)
 }
 
+// updateErrorType returns the RPC error type matching the cause of
+// a failed revision update.
+func updateErrorType(err error) types.Specifier {
+	switch {
+	case errors.Is(err, modules.ErrContractNotFound):
+		return errTypeContractNotFound
+	case errors.Is(err, modules.ErrStaleRevision):
+		return errTypeStaleRevision
+	default:
+		return errTypeInternal
+	}
+}
+
+// managedUpdateRevisions updates a batch of contract revisions. The
+// updates are independent of each other, and the result of each one
+// is reported back to the renter.
+func (p *Provider) managedUpdateRevisions(s *modules.RPCSession, bound *types.PublicKey) error {
+	// Extend the deadline to meet the revision updates.
+	s.Conn.SetDeadline(time.Now().Add(updateRevisionsTime))
+
+	// Read the request.
+	var ur updateRevisionsRequest
+	hash, err := s.ReadRequest(&ur, 1<<20)
+	if err != nil {
+		err = fmt.Errorf("could not read renter request: %v", err)
+		s.WriteError(err)
+		return err
+	}
+
+	// Verify the signature.
+	if !verifyRenterSignature(ur.PubKey, hash, ur.Signature) {
+		err = errors.New("could not verify renter signature")
+		s.WriteTypedError(errTypeInvalidSignature, err)
+		return err
+	}
+
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, ur.PubKey); err != nil {
+		return err
+	}
+
+	// Check if we know this renter.
+	_, err = p.m.GetRenter(ur.PubKey)
+	if err != nil {
+		err = fmt.Errorf("could not find renter in the database: %v", err)
+		s.WriteTypedError(errTypeRenterNotFound, err)
+		return err
+	}
+
+	// Verify the signatures of all revisions in one batch.
+	resp := updateRevisionsResponse{
+		errors: make([]*modules.RPCError, len(ur.Updates)),
+	}
+	invalidSignatures := &modules.RPCError{
+		Type:        errTypeInvalidSignature,
+		Description: "could not verify revision signatures",
+	}
+	var batch []signedHash
+	var owners []int
+	for i, u := range ur.Updates {
+		shs, ok := revisionSignatures(u.Contract)
+		if !ok {
+			resp.errors[i] = invalidSignatures
+			continue
+		}
+		for range shs {
+			owners = append(owners, i)
+		}
+		batch = append(batch, shs...)
+	}
+	for _, j := range verifyRequestSignatures(batch) {
+		resp.errors[owners[j]] = invalidSignatures
+	}
+
+	// Update the contracts.
+	for i, u := range ur.Updates {
+		if resp.errors[i] != nil {
+			continue
+		}
+		rev, sigs := u.Contract.Revision, u.Contract.Signatures
+		if err := p.m.UpdateContract(rev, sigs[:], u.Uploads, u.Downloads, u.FundAccount); err != nil {
+			resp.errors[i] = &modules.RPCError{
+				Type:        updateErrorType(err),
+				Description: fmt.Sprintf("couldn't update contract: %v", err),
+			}
+		}
+	}
+
+	return s.WriteResponse(&resp)
+}
+
 // managedFormContract forms a single contract using the new Renter-Satellite
 // protocol.
-func (p *Provider) managedFormContract(s *modules.RPCSession) error {
+func (p *Provider) managedFormContract(s *modules.RPCSession, bound *types.PublicKey) error {
 	// Extend the deadline to meet the contract formation.
 	s.Conn.SetDeadline(time.Now().Add(formContractTime))
 
@@ -356,12 +607,17 @@ func (p *Provider) managedFormContract(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !fcr.PubKey.VerifyHash(hash, fcr.Signature) {
+	if !verifyRenterSignature(fcr.PubKey, hash, fcr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
 	}
 
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, fcr.PubKey); err != nil {
+		return err
+	}
+
 	// Check if we know this renter.
 	_, err = p.m.GetRenter(fcr.PubKey)
 	if err != nil {
@@ -391,7 +647,7 @@ func (p *Provider) managedFormContract(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
-	if fcr.MinShards == 0 || fcr.TotalShards == 0 {
+	if !validRedundancy(fcr.MinShards, fcr.TotalShards) {
 		err := errors.New("can't form contract with such redundancy params")
 		s.WriteError(err)
 		return err
@@ -405,18 +661,18 @@ func (p *Provider) managedFormContract(s *modules.RPCSession) error {
 		return err
 	}
 
-	ec := modules.ExtendedContract{
-		Contract:    convertContract(contract),
-		StartHeight: contract.StartHeight,
-		TotalCost:   contract.TotalCost,
+	if err := p.recordHistory(fcr.PubKey, []modules.RenterContract{contract}); err != nil {
+		p.log.Error("couldn't record formation history", zap.Error(err))
 	}
 
+	ec := p.extendContract(contract)
+
 	return s.WriteResponse(&ec)
 }
 
 // managedRenewContract renews a contract using the new Renter-Satellite
 // protocol.
-func (p *Provider) managedRenewContract(s *modules.RPCSession) error {
+func (p *Provider) managedRenewContract(s *modules.RPCSession, bound *types.PublicKey) error {
 	// Extend the deadline to meet the contract renewal.
 	s.Conn.SetDeadline(time.Now().Add(renewContractTime))
 
@@ -430,12 +686,17 @@ func (p *Provider) managedRenewContract(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !rcr.PubKey.VerifyHash(hash, rcr.Signature) {
+	if !verifyRenterSignature(rcr.PubKey, hash, rcr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
 	}
 
+	// Check the key bound by the token, if any.
+	if err := checkBoundKey(s, bound, rcr.PubKey); err != nil {
+		return err
+	}
+
 	// Check if we know this renter.
 	_, err = p.m.GetRenter(rcr.PubKey)
 	if err != nil {
@@ -460,7 +721,7 @@ func (p *Provider) managedRenewContract(s *modules.RPCSession) error {
 		s.WriteError(err)
 		return err
 	}
-	if rcr.MinShards == 0 || rcr.TotalShards == 0 {
+	if !validRedundancy(rcr.MinShards, rcr.TotalShards) {
 		err := errors.New("can't renew contract with such redundancy params")
 		s.WriteError(err)
 		return err
@@ -474,12 +735,12 @@ func (p *Provider) managedRenewContract(s *modules.RPCSession) error {
 		return err
 	}
 
-	ec := modules.ExtendedContract{
-		Contract:    convertContract(contract),
-		StartHeight: contract.StartHeight,
-		TotalCost:   contract.TotalCost,
+	if err := p.recordHistory(rcr.PubKey, []modules.RenterContract{contract}); err != nil {
+		p.log.Error("couldn't record renewal history", zap.Error(err))
 	}
 
+	ec := p.extendContract(contract)
+
 	return s.WriteResponse(&ec)
 }
 
@@ -498,7 +759,7 @@ func (p *Provider) managedGetSettings(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !gsr.PubKey.VerifyHash(hash, gsr.Signature) {
+	if !verifyRenterSignature(gsr.PubKey, hash, gsr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -537,7 +798,7 @@ func (p *Provider) managedUpdateSettings(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !usr.PubKey.VerifyHash(hash, usr.Signature) {
+	if !verifyRenterSignature(usr.PubKey, hash, usr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -587,7 +848,7 @@ func (p *Provider) managedUpdateSettings(s *modules.RPCSession) error {
 			s.WriteError(err)
 			return err
 		}
-		if usr.MinShards == 0 || usr.TotalShards == 0 {
+		if !validRedundancy(usr.MinShards, usr.TotalShards) {
 			err := errors.New("can't set such redundancy params")
 			s.WriteError(err)
 			return err
@@ -690,7 +951,7 @@ func (p *Provider) managedSaveMetadata(s *rhpv3.Stream) error {
 	// Verify the signature.
 	h := types.NewHasher()
 	smr.EncodeTo(h.E)
-	if ok := smr.PubKey.VerifyHash(h.Sum(), smr.Signature); !ok {
+	if !verifyRenterSignature(smr.PubKey, h.Sum(), smr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteResponseErr(err)
 		return err
@@ -767,7 +1028,7 @@ func (p *Provider) managedRequestMetadata(s *rhpv3.Stream) error {
 	// Verify the signature.
 	h := types.NewHasher()
 	rmr.EncodeTo(h.E)
-	if ok := rmr.PubKey.VerifyHash(h.Sum(), rmr.Signature); !ok {
+	if !verifyRenterSignature(rmr.PubKey, h.Sum(), rmr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteResponseErr(err)
 		return err
@@ -879,7 +1140,7 @@ func (p *Provider) managedUpdateSlab(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !usr.PubKey.VerifyHash(hash, usr.Signature) {
+	if !verifyRenterSignature(usr.PubKey, hash, usr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -927,7 +1188,7 @@ func (p *Provider) managedRequestSlabs(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !rsr.PubKey.VerifyHash(hash, rsr.Signature) {
+	if !verifyRenterSignature(rsr.PubKey, hash, rsr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -963,7 +1224,7 @@ func (p *Provider) managedAcceptContracts(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if ok := sr.PubKey.VerifyHash(hash, sr.Signature); !ok {
+	if !verifyRenterSignature(sr.PubKey, hash, sr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -997,7 +1258,7 @@ func (p *Provider) managedReceiveFile(s *rhpv3.Stream) error {
 	// Verify the signature.
 	h := types.NewHasher()
 	ur.EncodeTo(h.E)
-	if ok := ur.PubKey.VerifyHash(h.Sum(), ur.Signature); !ok {
+	if !verifyRenterSignature(ur.PubKey, h.Sum(), ur.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteResponseErr(err)
 		return err
@@ -1158,7 +1419,7 @@ func (p *Provider) managedRegisterMultipart(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !rmr.PubKey.VerifyHash(hash, rmr.Signature) {
+	if !verifyRenterSignature(rmr.PubKey, hash, rmr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -1194,7 +1455,7 @@ func (p *Provider) managedDeleteMultipart(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !dmr.PubKey.VerifyHash(hash, dmr.Signature) {
+	if !verifyRenterSignature(dmr.PubKey, hash, dmr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -1225,7 +1486,7 @@ func (p *Provider) managedReceivePart(s *rhpv3.Stream) error {
 	// Verify the signature.
 	h := types.NewHasher()
 	upr.EncodeTo(h.E)
-	if ok := upr.PubKey.VerifyHash(h.Sum(), upr.Signature); !ok {
+	if !verifyRenterSignature(upr.PubKey, h.Sum(), upr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteResponseErr(err)
 		return err
@@ -1350,7 +1611,7 @@ func (p *Provider) managedCompleteMultipart(s *modules.RPCSession) error {
 	}
 
 	// Verify the signature.
-	if !cmr.PubKey.VerifyHash(hash, cmr.Signature) {
+	if !verifyRenterSignature(cmr.PubKey, hash, cmr.Signature) {
 		err = errors.New("could not verify renter signature")
 		s.WriteError(err)
 		return err
@@ -1366,3 +1627,182 @@ func (p *Provider) managedCompleteMultipart(s *modules.RPCSession) error {
 
 	return s.WriteResponse(nil)
 }
+
+// managedRequestHistory returns a page of the history of the contracts
+// formed or renewed on behalf of the renter.
+func (p *Provider) managedRequestHistory(s *modules.RPCSession) error {
+	// Extend the deadline.
+	s.Conn.SetDeadline(time.Now().Add(requestHistoryTime))
+
+	// Read the request.
+	var rhr requestHistoryRequest
+	hash, err := s.ReadRequest(&rhr, 65536)
+	if err != nil {
+		err = fmt.Errorf("could not read renter request: %v", err)
+		s.WriteError(err)
+		return err
+	}
+
+	// Verify the signature.
+	if !verifyRenterSignature(rhr.PubKey, hash, rhr.Signature) {
+		err = errors.New("could not verify renter signature")
+		s.WriteError(err)
+		return err
+	}
+
+	// Check if we know this renter.
+	_, err = p.m.GetRenter(rhr.PubKey)
+	if err != nil {
+		err = fmt.Errorf("could not find renter in the database: %v", err)
+		s.WriteError(err)
+		return err
+	}
+
+	// Retrieve the history.
+	if rhr.Limit == 0 || rhr.Limit > maxHistoryEntries {
+		rhr.Limit = maxHistoryEntries
+	}
+	entries, total, err := p.getHistory(rhr.PubKey, rhr.Offset, rhr.Limit)
+	if err != nil {
+		err = fmt.Errorf("couldn't retrieve history: %v", err)
+		s.WriteError(err)
+		return err
+	}
+
+	resp := requestHistoryResponse{
+		entries: entries,
+		total:   total,
+	}
+
+	return s.WriteResponse(&resp)
+}
+
+// managedRequestLimits sends the maximum batch sizes accepted by the
+// provider, so that the renter can size its requests.
+func (p *Provider) managedRequestLimits(s *modules.RPCSession) error {
+	s.Conn.SetDeadline(time.Now().Add(requestLimitsTime))
+
+	return s.WriteResponse(&limitsResponse{
+		maxRenewBatch:      p.maxRenewBatch,
+		maxRevisionUpdates: maxRevisionUpdates,
+	})
+}
+
+// managedRequestFunding sends the amount of siacoins the satellite can
+// spend on contracts right now, so that the renter can check it before
+// forming or renewing contracts.
+func (p *Provider) managedRequestFunding(s *modules.RPCSession) error {
+	s.Conn.SetDeadline(time.Now().Add(requestFundingTime))
+
+	// Read the request.
+	var rr requestRequest
+	hash, err := s.ReadRequest(&rr, 65536)
+	if err != nil {
+		err = fmt.Errorf("could not read renter request: %v", err)
+		s.WriteError(err)
+		return err
+	}
+
+	// Verify the signature.
+	if !verifyRenterSignature(rr.PubKey, hash, rr.Signature) {
+		err = errors.New("could not verify renter signature")
+		s.WriteTypedError(errTypeInvalidSignature, err)
+		return err
+	}
+
+	// Check if we know this renter.
+	_, err = p.m.GetRenter(rr.PubKey)
+	if err != nil {
+		err = fmt.Errorf("could not find renter in the database: %v", err)
+		s.WriteTypedError(errTypeRenterNotFound, err)
+		return err
+	}
+
+	return s.WriteResponse(&fundingResponse{
+		available: p.m.SatelliteFunds(),
+	})
+}
+
+// managedVerifyToken checks the token the renter has obtained from the
+// portal, proving that the renter's public key is bound to the account.
+func (p *Provider) managedVerifyToken(s *modules.RPCSession) error {
+	if _, err := p.managedCheckToken(s); err != nil {
+		return err
+	}
+	return s.WriteResponse(nil)
+}
+
+// managedWithToken checks the renter token and then handles the RPC that
+// follows it on the same connection. The RPC must be signed with the key
+// the token was issued for.
+func (p *Provider) managedWithToken(s *modules.RPCSession) error {
+	pk, err := p.managedCheckToken(s)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteResponse(nil); err != nil {
+		return err
+	}
+
+	// Read the specifier of the gated RPC.
+	var id types.Specifier
+	if err := s.ReadMessage(&id, modules.MinMessageSize); err != nil {
+		return fmt.Errorf("could not read request specifier: %v", err)
+	}
+
+	return p.managedGatedRPC(s, id, &pk)
+}
+
+// managedCheckToken reads a token request and checks the token the
+// renter has obtained from the portal. It returns the renter's public
+// key the token is bound to.
+func (p *Provider) managedCheckToken(s *modules.RPCSession) (types.PublicKey, error) {
+	s.Conn.SetDeadline(time.Now().Add(verifyTokenTime))
+
+	// Read the request.
+	var tr tokenRequest
+	hash, err := s.ReadRequest(&tr, 65536)
+	if err != nil {
+		err = fmt.Errorf("could not read renter request: %v", err)
+		s.WriteError(err)
+		return types.PublicKey{}, err
+	}
+
+	// Verify the signature.
+	if !verifyRenterSignature(tr.PubKey, hash, tr.Signature) {
+		err = errors.New("could not verify renter signature")
+		s.WriteTypedError(errTypeInvalidSignature, err)
+		return types.PublicKey{}, err
+	}
+
+	// Check if we know this renter.
+	_, err = p.m.GetRenter(tr.PubKey)
+	if err != nil {
+		err = fmt.Errorf("could not find renter in the database: %v", err)
+		s.WriteTypedError(errTypeRenterNotFound, err)
+		return types.PublicKey{}, err
+	}
+
+	// Check the binding.
+	if err := p.m.VerifyRenterToken(tr.PubKey, tr.Token); errors.Is(err, modules.ErrInvalidRenterToken) {
+		s.WriteTypedError(errTypeInvalidToken, err)
+		return types.PublicKey{}, err
+	} else if err != nil {
+		s.WriteTypedError(errTypeInternal, err)
+		return types.PublicKey{}, err
+	}
+
+	return tr.PubKey, nil
+}
+
+// checkBoundKey makes sure that an RPC called through the token-gated
+// path is signed with the key the token was verified for. bound is nil
+// if the RPC was called directly.
+func checkBoundKey(s *modules.RPCSession, bound *types.PublicKey, pk types.PublicKey) error {
+	if bound != nil && *bound != pk {
+		err := errors.New("request is not signed with the key bound by the token")
+		s.WriteTypedError(errTypeInvalidToken, err)
+		return err
+	}
+	return nil
+}
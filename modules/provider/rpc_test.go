@@ -0,0 +1,250 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+func TestValidRedundancy(t *testing.T) {
+	tests := []struct {
+		minShards, totalShards uint64
+		valid                  bool
+	}{
+		{10, 30, true},
+		{4, 10, true},
+		{1, 1, true},
+		{10, 10, true},
+		{11, 10, false},
+		{0, 10, false},
+		{10, 0, false},
+		{0, 0, false},
+	}
+	for _, test := range tests {
+		if valid := validRedundancy(test.minShards, test.totalShards); valid != test.valid {
+			t.Errorf("validRedundancy(%v, %v): expected %v, got %v", test.minShards, test.totalShards, test.valid, valid)
+		}
+	}
+}
+
+func TestSortContracts(t *testing.T) {
+	contracts := make([]modules.RenterContract, 20)
+	renewedFrom := make(map[types.FileContractID]types.FileContractID)
+	for i := range contracts {
+		contracts[i].ID = frand.Entropy256()
+		contracts[i].HostPublicKey = frand.Entropy256()
+		renewedFrom[contracts[i].ID] = frand.Entropy256()
+	}
+	from := func(id types.FileContractID) types.FileContractID { return renewedFrom[id] }
+
+	// Any input order produces the same response order.
+	shuffled := func() []modules.RenterContract {
+		cs := append([]modules.RenterContract(nil), contracts...)
+		frand.Shuffle(len(cs), func(i, j int) { cs[i], cs[j] = cs[j], cs[i] })
+		return cs
+	}
+	formed, renewed := shuffled(), shuffled()
+	sortFormedContracts(formed)
+	sortRenewedContracts(renewed, from)
+	for n := 0; n < 5; n++ {
+		f, r := shuffled(), shuffled()
+		sortFormedContracts(f)
+		sortRenewedContracts(r, from)
+		for i := range f {
+			if f[i].ID != formed[i].ID {
+				t.Fatal("formed contracts not in a deterministic order")
+			} else if r[i].ID != renewed[i].ID {
+				t.Fatal("renewed contracts not in a deterministic order")
+			}
+		}
+	}
+
+	for i := 1; i < len(contracts); i++ {
+		if bytes.Compare(formed[i-1].HostPublicKey[:], formed[i].HostPublicKey[:]) > 0 {
+			t.Fatal("formed contracts not sorted by the host key")
+		}
+		prev, cur := renewedFrom[renewed[i-1].ID], renewedFrom[renewed[i].ID]
+		if bytes.Compare(prev[:], cur[:]) > 0 {
+			t.Fatal("renewed contracts not sorted by the renewed contract ID")
+		}
+	}
+}
+
+func TestUpdateErrorType(t *testing.T) {
+	tests := []struct {
+		err     error
+		errType types.Specifier
+	}{
+		{modules.ErrContractNotFound, errTypeContractNotFound},
+		{fmt.Errorf("couldn't update contract: %w", modules.ErrContractNotFound), errTypeContractNotFound},
+		{modules.ErrStaleRevision, errTypeStaleRevision},
+		{fmt.Errorf("revision 5 is older than 6: %w", modules.ErrStaleRevision), errTypeStaleRevision},
+		{errors.New("database is down"), errTypeInternal},
+	}
+	for _, test := range tests {
+		if errType := updateErrorType(test.err); errType != test.errType {
+			t.Errorf("%v: expected %v, got %v", test.err, test.errType, errType)
+		}
+	}
+
+	// Every failure of the RPC maps to a distinct code.
+	codes := []types.Specifier{
+		errTypeInvalidSignature,
+		errTypeRenterNotFound,
+		errTypeContractNotFound,
+		errTypeStaleRevision,
+		errTypeInternal,
+	}
+	seen := make(map[types.Specifier]bool)
+	for _, code := range codes {
+		if seen[code] {
+			t.Fatal("duplicate error code", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRPCErrorEncoding(t *testing.T) {
+	re := &modules.RPCError{
+		Type:        errTypeStaleRevision,
+		Description: "couldn't update contract: stale revision",
+	}
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	re.EncodeTo(e)
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded modules.RPCError
+	d := types.NewBufDecoder(buf.Bytes())
+	decoded.DecodeFrom(d)
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	} else if decoded.Type != re.Type || decoded.Description != re.Description {
+		t.Fatalf("expected %+v, got %+v", re, decoded)
+	}
+}
+
+// testManager is a manager that knows a fixed set of renters.
+type testManager struct {
+	modules.Manager
+	renters map[types.PublicKey]modules.Renter
+}
+
+// GetRenter implements modules.Manager.
+func (tm *testManager) GetRenter(rpk types.PublicKey) (modules.Renter, error) {
+	r, ok := tm.renters[rpk]
+	if !ok {
+		return modules.Renter{}, errors.New("renter not found")
+	}
+	return r, nil
+}
+
+// signedRequest is a request followed by the signature of its hash, as
+// sent by the renter.
+type signedRequest struct {
+	body modules.RequestBody
+	sig  types.Signature
+}
+
+// newSignedRequest signs the request with the renter key.
+func newSignedRequest(body modules.RequestBody, sk types.PrivateKey) *signedRequest {
+	h := types.NewHasher()
+	body.EncodeTo(h.E)
+	return &signedRequest{body: body, sig: sk.SignHash(h.Sum())}
+}
+
+// EncodeTo implements modules.RequestBody.
+func (sr *signedRequest) EncodeTo(e *types.Encoder) {
+	sr.body.EncodeTo(e)
+	sr.sig.EncodeTo(e)
+}
+
+// DecodeFrom implements modules.RequestBody.
+func (sr *signedRequest) DecodeFrom(d *types.Decoder) {}
+
+// newTestSessions returns the renter and the provider ends of an RPC
+// session.
+func newTestSessions(t *testing.T) (renter, provider *modules.RPCSession) {
+	block, err := aes.NewCipher(frand.Bytes(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, pc := net.Pipe()
+	t.Cleanup(func() {
+		rc.Close()
+		pc.Close()
+	})
+	return &modules.RPCSession{Conn: rc, Aead: aead}, &modules.RPCSession{Conn: pc, Aead: aead}
+}
+
+func TestRenewBatchCap(t *testing.T) {
+	sk := types.GeneratePrivateKey()
+	rpk := sk.PublicKey()
+	p := &Provider{
+		m: &testManager{
+			renters: map[types.PublicKey]modules.Renter{rpk: {PublicKey: rpk}},
+		},
+		maxRenewBatch: 3,
+	}
+
+	// renew sends a renewal request with n contracts and returns the
+	// error reported to the renter.
+	renew := func(n int) error {
+		rs, ps := newTestSessions(t)
+		rr := &renewRequest{
+			PubKey:    rpk,
+			SecretKey: sk,
+			Contracts: make([]types.FileContractID, n),
+		}
+		go rs.WriteMessage(newSignedRequest(rr, sk))
+		go p.managedRenewContracts(ps, nil)
+		return rs.ReadResponse(nil, 4096)
+	}
+
+	// At the cap, the request passes the batch check and fails on the
+	// zero period.
+	if err := renew(3); err == nil || !strings.Contains(err.Error(), "zero period") {
+		t.Fatal("expected the request to pass the batch check, got", err)
+	}
+	// Above the cap, it is rejected.
+	if err := renew(4); err == nil || !strings.Contains(err.Error(), "at most 3 are allowed") {
+		t.Fatal("expected the request to be rejected, got", err)
+	}
+}
+
+func TestCheckAllowanceBounds(t *testing.T) {
+	p := &Provider{
+		maxBlockHeightLeeway: 10,
+		maxMinMaxCollateral:  types.Siacoins(100),
+	}
+	tests := []struct {
+		leeway           uint64
+		minMaxCollateral types.Currency
+		valid            bool
+	}{
+		{0, types.ZeroCurrency, true},
+		{10, types.Siacoins(100), true},
+		{11, types.Siacoins(100), false},
+		{10, types.Siacoins(101), false},
+		{defaultMaxBlockHeightLeeway, defaultMaxMinMaxCollateral, false},
+	}
+	for _, test := range tests {
+		if err := p.checkAllowanceBounds(test.leeway, test.minMaxCollateral); (err == nil) != test.valid {
+			t.Errorf("checkAllowanceBounds(%v, %v): expected valid to be %v, got %v", test.leeway, test.minMaxCollateral, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// certCheckInterval is how often the TLS certificate files are checked
+// for changes.
+const certCheckInterval = time.Minute
+
+// certReloader provides the TLS certificate of the renter RPC listener.
+// The certificate is reloaded when the files change, so that a renewed
+// certificate is picked up without restarting the satellite.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *zap.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+// newCertReloader loads the certificate and returns a certReloader
+// serving it.
+func newCertReloader(certFile, keyFile string, log *zap.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      log,
+	}
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err := cr.load(certMod, keyMod); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// modTimes returns the modification times of the certificate files.
+func (cr *certReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	fi, err := os.Stat(cr.certFile)
+	if err != nil {
+		return
+	}
+	certMod = fi.ModTime()
+	fi, err = os.Stat(cr.keyFile)
+	if err != nil {
+		return
+	}
+	keyMod = fi.ModTime()
+	return
+}
+
+// load reads the certificate from the files. cr.mu must be held, unless
+// cr is not shared yet.
+func (cr *certReloader) load(certMod, keyMod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert = &cert
+	cr.certMod = certMod
+	cr.keyMod = keyMod
+	cr.checked = time.Now()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. If the files
+// have changed but the new certificate can't be loaded, e.g. because
+// only one of the files has been replaced so far, the old certificate
+// is served until the next check.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if time.Since(cr.checked) < certCheckInterval {
+		return cr.cert, nil
+	}
+	cr.checked = time.Now()
+
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		cr.log.Warn("couldn't check TLS certificate", zap.Error(err))
+		return cr.cert, nil
+	}
+	if certMod.Equal(cr.certMod) && keyMod.Equal(cr.keyMod) {
+		return cr.cert, nil
+	}
+	if err := cr.load(certMod, keyMod); err != nil {
+		cr.log.Warn("couldn't reload TLS certificate", zap.Error(err))
+		return cr.cert, nil
+	}
+	cr.log.Info("reloaded TLS certificate")
+	return cr.cert, nil
+}
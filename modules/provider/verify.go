@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+// minParallelSignatures is the smallest number of signatures that is
+// worth verifying in parallel.
+const minParallelSignatures = 8
+
+// A signedHash is a hash signed with the given key.
+type signedHash struct {
+	pk   types.PublicKey
+	hash types.Hash256
+	sig  types.Signature
+}
+
+// renterPublicKey converts a renter key in the siad encoding, i.e. a
+// types.UnlockKey with the Ed25519 specifier, to a types.PublicKey.
+func renterPublicKey(uk types.UnlockKey) (types.PublicKey, bool) {
+	if uk.Algorithm != types.SpecifierEd25519 || len(uk.Key) != len(types.PublicKey{}) {
+		return types.PublicKey{}, false
+	}
+	return types.PublicKey(uk.Key), true
+}
+
+// verifyRenterSignature checks the renter's signature of the hash. Keys
+// in the siad encoding are converted with renterPublicKey first.
+func verifyRenterSignature(pk types.PublicKey, hash types.Hash256, sig types.Signature) bool {
+	return pk.VerifyHash(hash, sig)
+}
+
+// hashRevision returns the hash of the revision signed by the renter
+// and the host.
+func hashRevision(rev types.FileContractRevision) types.Hash256 {
+	h := types.NewHasher()
+	rev.EncodeTo(h.E)
+	return h.Sum()
+}
+
+// revisionSignatures returns the renter's and the host's signatures of
+// the contract revision. It returns false if a signature or the key it
+// refers to is malformed.
+func revisionSignatures(c rhpv2.ContractRevision) ([]signedHash, bool) {
+	hash := hashRevision(c.Revision)
+	keys := c.Revision.UnlockConditions.PublicKeys
+	shs := make([]signedHash, 0, len(c.Signatures))
+	for _, ts := range c.Signatures {
+		if ts.PublicKeyIndex >= uint64(len(keys)) || len(ts.Signature) != len(types.Signature{}) {
+			return nil, false
+		}
+		pk, ok := renterPublicKey(keys[ts.PublicKeyIndex])
+		if !ok {
+			return nil, false
+		}
+		shs = append(shs, signedHash{pk: pk, hash: hash, sig: types.Signature(ts.Signature)})
+	}
+	return shs, true
+}
+
+// verifyBatch reports whether all signatures of the batch are valid.
+// The standard library offers no batch verification of Ed25519
+// signatures, so the cost is amortized by spreading the batch over the
+// available CPUs instead. The verification stops at the first invalid
+// signature.
+func verifyBatch(batch []signedHash) bool {
+	if len(batch) < minParallelSignatures {
+		for _, sh := range batch {
+			if !sh.pk.VerifyHash(sh.hash, sh.sig) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var failed atomic.Bool
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(batch) && !failed.Load(); i += workers {
+				if !batch[i].pk.VerifyHash(batch[i].hash, batch[i].sig) {
+					failed.Store(true)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return !failed.Load()
+}
+
+// verifyRequestSignatures verifies a batch of signatures and returns
+// the indices of the invalid ones, or nil if all of them are valid.
+// The batch is verified at once first; only if that fails, the
+// signatures are verified one by one to pinpoint the bad ones.
+func verifyRequestSignatures(batch []signedHash) (bad []int) {
+	if verifyBatch(batch) {
+		return nil
+	}
+	for i, sh := range batch {
+		if !sh.pk.VerifyHash(sh.hash, sh.sig) {
+			bad = append(bad, i)
+		}
+	}
+	return
+}
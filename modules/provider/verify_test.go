@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"testing"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+func TestVerifyRenterSignature(t *testing.T) {
+	sk := types.GeneratePrivateKey()
+	hash := types.Hash256(frand.Entropy256())
+	sig := sk.SignHash(hash)
+
+	if !verifyRenterSignature(sk.PublicKey(), hash, sig) {
+		t.Fatal("expected a valid signature")
+	}
+	if verifyRenterSignature(types.GeneratePrivateKey().PublicKey(), hash, sig) {
+		t.Fatal("expected a signature by another key to be rejected")
+	}
+	if verifyRenterSignature(sk.PublicKey(), frand.Entropy256(), sig) {
+		t.Fatal("expected a signature of another hash to be rejected")
+	}
+
+	// Keys in the siad encoding are converted first.
+	pk, ok := renterPublicKey(sk.PublicKey().UnlockKey())
+	if !ok || !verifyRenterSignature(pk, hash, sig) {
+		t.Fatal("expected a valid signature with the siad key")
+	}
+	if _, ok := renterPublicKey(types.UnlockKey{Algorithm: types.SpecifierEntropy, Key: pk[:]}); ok {
+		t.Fatal("expected a key of another algorithm to be rejected")
+	}
+	if _, ok := renterPublicKey(types.UnlockKey{Algorithm: types.SpecifierEd25519, Key: pk[:16]}); ok {
+		t.Fatal("expected a truncated key to be rejected")
+	}
+}
+
+func TestRevisionSignatures(t *testing.T) {
+	renter, host := types.GeneratePrivateKey(), types.GeneratePrivateKey()
+	rev := types.FileContractRevision{
+		ParentID: frand.Entropy256(),
+		UnlockConditions: types.UnlockConditions{
+			PublicKeys:         []types.UnlockKey{renter.PublicKey().UnlockKey(), host.PublicKey().UnlockKey()},
+			SignaturesRequired: 2,
+		},
+		FileContract: types.FileContract{RevisionNumber: 10},
+	}
+	hash := hashRevision(rev)
+	rsig, hsig := renter.SignHash(hash), host.SignHash(hash)
+	c := rhpv2.ContractRevision{
+		Revision: rev,
+		Signatures: [2]types.TransactionSignature{
+			{PublicKeyIndex: 0, Signature: rsig[:]},
+			{PublicKeyIndex: 1, Signature: hsig[:]},
+		},
+	}
+	shs, ok := revisionSignatures(c)
+	if !ok {
+		t.Fatal("expected the signatures to be well-formed")
+	} else if bad := verifyRequestSignatures(shs); bad != nil {
+		t.Fatal("expected valid signatures, got bad ones at", bad)
+	}
+
+	// A signature of another revision is pinpointed.
+	c.Revision.RevisionNumber++
+	shs, _ = revisionSignatures(c)
+	if bad := verifyRequestSignatures(shs); len(bad) != 2 {
+		t.Fatal("expected both signatures to be invalid, got", bad)
+	}
+
+	// A key index out of range is malformed.
+	c.Signatures[1].PublicKeyIndex = 2
+	if _, ok := revisionSignatures(c); ok {
+		t.Fatal("expected a malformed signature")
+	}
+}
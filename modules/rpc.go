@@ -62,8 +62,16 @@ func (s *RPCSession) ReadMessage(message RequestBody, maxLen uint64) error {
 	return err
 }
 
-// writeMessage sends an encrypted message to the renter.
+// WriteMessage sends an encrypted message, padded to MinMessageSize,
+// to the renter.
 func (s *RPCSession) WriteMessage(message RequestBody) error {
+	return s.writeMessage(message, true)
+}
+
+// writeMessage sends an encrypted message to the renter. If pad is
+// true, the message is padded to MinMessageSize. The message length is
+// always sent in front of it, so the renter can read both forms.
+func (s *RPCSession) writeMessage(message RequestBody, pad bool) error {
 	nonce := make([]byte, 32)[:s.Aead.NonceSize()]
 	frand.Read(nonce)
 
@@ -77,7 +85,7 @@ func (s *RPCSession) WriteMessage(message RequestBody) error {
 
 	// Overwrite message length.
 	msgSize := buf.Len() + s.Aead.Overhead()
-	if msgSize < MinMessageSize {
+	if pad && msgSize < MinMessageSize {
 		msgSize = MinMessageSize
 	}
 	buf.Grow(s.Aead.Overhead())
@@ -94,18 +102,29 @@ func (s *RPCSession) WriteMessage(message RequestBody) error {
 	return err
 }
 
-// WriteResponse sends an encrypted RPC responce to the renter.
+// WriteResponse sends an encrypted RPC responce to the renter. Only the
+// responses carrying data are padded; a bare acknowledgement (nil resp)
+// reveals nothing worth hiding.
 func (s *RPCSession) WriteResponse(resp RequestBody) error {
-	return s.WriteMessage(&RPCResponse{nil, resp})
+	return s.writeMessage(&RPCResponse{nil, resp}, resp != nil)
 }
 
-// WriteError sends an error message to the renter.
+// WriteError sends an error message to the renter. Error messages are
+// not padded.
 func (s *RPCSession) WriteError(err error) error {
 	var re *RPCError
 	if err != nil {
 		re = &RPCError{Description: err.Error()}
 	}
-	return s.WriteMessage(&RPCResponse{re, nil})
+	return s.writeMessage(&RPCResponse{re, nil}, false)
+}
+
+// WriteTypedError sends an error message of the given type to the renter,
+// so that the renter can react to the cause of the error. Error messages
+// are not padded.
+func (s *RPCSession) WriteTypedError(t types.Specifier, err error) error {
+	re := &RPCError{Type: t, Description: err.Error()}
+	return s.writeMessage(&RPCResponse{re, nil}, false)
 }
 
 // ReadResponse reads an encrypted RPC response from the renter.
@@ -2,14 +2,50 @@ package modules
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"go.sia.tech/core/gateway"
 	"go.sia.tech/core/types"
 	"go.sia.tech/coreutils/syncer"
 )
 
+// ErrTransactionSetTooLarge is returned when the encoded size of a
+// transaction set exceeds the configured limit.
+var ErrTransactionSetTooLarge = errors.New("transaction set too large")
+
+// ErrInsufficientRelayFee is returned when a transaction set pays less
+// than the fee floor.
+var ErrInsufficientRelayFee = errors.New("insufficient fees")
+
+// A PeerRelayError is the error returned by a peer a transaction set
+// couldn't be relayed to.
+type PeerRelayError struct {
+	Peer  string `json:"peer"`
+	Error string `json:"error"`
+}
+
+// TxnPropagation describes how a broadcast transaction set has
+// propagated to the peers. The set is identified by the ID of its last
+// transaction.
+type TxnPropagation struct {
+	ID        types.TransactionID `json:"id"`
+	Timestamp time.Time           `json:"timestamp"`
+
+	// Peers is the number of peers the set was relayed to. Relayed
+	// peers accepted it and Failed peers returned an error; the others
+	// haven't answered yet.
+	Peers   int              `json:"peers"`
+	Relayed int              `json:"relayed"`
+	Failed  []PeerRelayError `json:"failed"`
+}
+
 // A Syncer synchronizes blockchain data with peers.
 type Syncer interface {
+	// AddPersistentPeer marks the peer as persistent, so that the Syncer
+	// reconnects to it after a restart or a disconnect.
+	AddPersistentPeer(addr string) error
+
 	// Addr returns the address of the Syncer.
 	Addr() string
 
@@ -25,18 +61,44 @@ type Syncer interface {
 	// BroadcastV2TransactionSet broadcasts a v2 transaction set to all peers.
 	BroadcastV2TransactionSet(index types.ChainIndex, txns []types.V2Transaction)
 
+	// CheckTransactionSetSize returns ErrTransactionSetTooLarge if the
+	// encoded size of the transaction set exceeds the limit.
+	CheckTransactionSetSize(txns []types.Transaction, v2txns []types.V2Transaction) error
+
+	// CheckRelayFee returns ErrInsufficientRelayFee if the transaction
+	// set pays less than the fee floor.
+	CheckRelayFee(txns []types.Transaction, v2txns []types.V2Transaction) error
+
 	// Close shuts down the Syncer.
 	Close() error
 
 	// Connect forms an outbound connection to a peer.
 	Connect(ctx context.Context, addr string) (*syncer.Peer, error)
 
+	// FeeFloor returns the minimum fee (per weight unit) a transaction
+	// set must pay to be accepted and relayed.
+	FeeFloor() types.Currency
+
 	// PeerInfo returns the information about the current peers.
 	PeerInfo() []syncer.PeerInfo
 
 	// Peers returns the set of currently-connected peers.
 	Peers() []*syncer.Peer
 
+	// Propagation returns the propagation of a recently broadcast
+	// transaction set.
+	Propagation(id types.TransactionID) (TxnPropagation, bool)
+
+	// PersistentPeers returns the addresses of the persistent peers.
+	PersistentPeers() []string
+
+	// RemovePersistentPeer removes the peer from the persistent set.
+	RemovePersistentPeer(addr string) error
+
+	// SetFeeFloor sets the minimum fee (per weight unit) a transaction
+	// set must pay to be accepted and relayed.
+	SetFeeFloor(floor types.Currency) error
+
 	// Synced returns if the syncer is synced to the blockchain.
 	Synced() bool
 }
@@ -0,0 +1,176 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// reconnectInterval is how often the persistent peers are checked.
+	reconnectInterval = 10 * time.Second
+
+	// minReconnectBackoff is the delay after the first failed attempt
+	// to connect to a persistent peer.
+	minReconnectBackoff = 10 * time.Second
+
+	// maxReconnectBackoff is the maximum delay between two attempts
+	// to connect to a persistent peer.
+	maxReconnectBackoff = time.Hour
+
+	// connectTimeout is the timeout for connecting to a persistent peer.
+	connectTimeout = 10 * time.Second
+)
+
+// persistentPeers is the set of manually added peers that the Syncer
+// keeps connecting to across restarts. It is backed by a JSON file.
+type persistentPeers struct {
+	path  string
+	peers map[string]struct{}
+	mu    sync.Mutex
+}
+
+func (pp *persistentPeers) load() error {
+	js, err := os.ReadFile(pp.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var addrs []string
+	if err := json.Unmarshal(js, &addrs); err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		pp.peers[addr] = struct{}{}
+	}
+	return nil
+}
+
+// save writes the set to disk. pp.mu must be held.
+func (pp *persistentPeers) save() error {
+	js, err := json.MarshalIndent(pp.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(pp.path+"_tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(js); err != nil {
+		return err
+	} else if err = f.Sync(); err != nil {
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	} else if err := os.Rename(pp.path+"_tmp", pp.path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// list returns the sorted addresses. pp.mu must be held.
+func (pp *persistentPeers) list() []string {
+	addrs := make([]string, 0, len(pp.peers))
+	for addr := range pp.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// newPersistentPeers returns a persistentPeers backed by the specified file.
+func newPersistentPeers(path string) (*persistentPeers, error) {
+	pp := &persistentPeers{
+		path:  path,
+		peers: make(map[string]struct{}),
+	}
+	return pp, pp.load()
+}
+
+// AddPersistentPeer marks the peer as persistent, so that the Syncer
+// reconnects to it after a restart or a disconnect.
+func (s *Syncer) AddPersistentPeer(addr string) error {
+	s.pp.mu.Lock()
+	defer s.pp.mu.Unlock()
+	if _, ok := s.pp.peers[addr]; ok {
+		return nil
+	}
+	s.pp.peers[addr] = struct{}{}
+	return s.pp.save()
+}
+
+// RemovePersistentPeer removes the peer from the persistent set. It
+// does not disconnect the peer.
+func (s *Syncer) RemovePersistentPeer(addr string) error {
+	s.pp.mu.Lock()
+	defer s.pp.mu.Unlock()
+	if _, ok := s.pp.peers[addr]; !ok {
+		return errPeerNotPersistent
+	}
+	delete(s.pp.peers, addr)
+	return s.pp.save()
+}
+
+// PersistentPeers returns the addresses of the persistent peers.
+func (s *Syncer) PersistentPeers() []string {
+	s.pp.mu.Lock()
+	defer s.pp.mu.Unlock()
+	return s.pp.list()
+}
+
+// threadedConnectPersistentPeers keeps the Syncer connected to the
+// persistent peers. Failed attempts are retried with an exponential
+// backoff.
+func (s *Syncer) threadedConnectPersistentPeers() {
+	type retry struct {
+		next    time.Time
+		backoff time.Duration
+	}
+	retries := make(map[string]retry)
+
+	for {
+		connected := make(map[string]struct{})
+		for _, p := range s.Peers() {
+			connected[p.Addr()] = struct{}{}
+		}
+
+		for _, addr := range s.PersistentPeers() {
+			if _, ok := connected[addr]; ok {
+				delete(retries, addr)
+				continue
+			}
+			r := retries[addr]
+			if time.Now().Before(r.next) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+			_, err := s.Connect(ctx, addr)
+			cancel()
+			if err == nil {
+				delete(retries, addr)
+				continue
+			}
+			if r.backoff == 0 {
+				r.backoff = minReconnectBackoff
+			} else if r.backoff *= 2; r.backoff > maxReconnectBackoff {
+				r.backoff = maxReconnectBackoff
+			}
+			r.next = time.Now().Add(r.backoff)
+			retries[addr] = r
+			s.log.Debug("couldn't connect to persistent peer", zap.String("address", addr), zap.Duration("retryIn", r.backoff), zap.Error(err))
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+)
+
+// maxPropagationRecords is the number of the most recent broadcasts
+// whose propagation is remembered.
+const maxPropagationRecords = 1000
+
+// A propagationTracker records how the recently broadcast transaction
+// sets have propagated to the peers.
+type propagationTracker struct {
+	mu      sync.Mutex
+	records map[types.TransactionID]*modules.TxnPropagation
+	order   []types.TransactionID
+}
+
+// newPropagationTracker returns an empty propagationTracker.
+func newPropagationTracker() *propagationTracker {
+	return &propagationTracker{
+		records: make(map[types.TransactionID]*modules.TxnPropagation),
+	}
+}
+
+// add starts tracking a transaction set relayed to the given number of
+// peers. The oldest record is dropped if the tracker is full.
+func (pt *propagationTracker) add(id types.TransactionID, peers int) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if _, ok := pt.records[id]; !ok {
+		pt.order = append(pt.order, id)
+	}
+	pt.records[id] = &modules.TxnPropagation{
+		ID:        id,
+		Timestamp: time.Now(),
+		Peers:     peers,
+	}
+	if len(pt.order) > maxPropagationRecords {
+		delete(pt.records, pt.order[0])
+		pt.order = pt.order[1:]
+	}
+}
+
+// update records the result of relaying the set to a peer.
+func (pt *propagationTracker) update(id types.TransactionID, peer string, err error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	r, ok := pt.records[id]
+	if !ok {
+		return
+	}
+	if err != nil {
+		r.Failed = append(r.Failed, modules.PeerRelayError{
+			Peer:  peer,
+			Error: err.Error(),
+		})
+	} else {
+		r.Relayed++
+	}
+}
+
+// Propagation returns the propagation of a recently broadcast
+// transaction set, identified by the ID of its last transaction.
+func (s *Syncer) Propagation(id types.TransactionID) (modules.TxnPropagation, bool) {
+	s.propagation.mu.Lock()
+	defer s.propagation.mu.Unlock()
+
+	r, ok := s.propagation.records[id]
+	if !ok {
+		return modules.TxnPropagation{}, false
+	}
+	p := *r
+	p.Failed = append([]modules.PeerRelayError(nil), r.Failed...)
+	return p, true
+}
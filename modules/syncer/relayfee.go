@@ -0,0 +1,125 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+)
+
+// relayFee is the minimum fee (per weight unit) a transaction set must
+// pay to be accepted and relayed. It is backed by a JSON file.
+type relayFee struct {
+	path  string
+	floor types.Currency
+	mu    sync.Mutex
+}
+
+func (rf *relayFee) load() error {
+	js, err := os.ReadFile(rf.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, &rf.floor)
+}
+
+// save writes the floor to disk. rf.mu must be held.
+func (rf *relayFee) save() error {
+	js, err := json.Marshal(rf.floor)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf.path+"_tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(js); err != nil {
+		return err
+	} else if err = f.Sync(); err != nil {
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	} else if err := os.Rename(rf.path+"_tmp", rf.path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newRelayFee returns a relayFee backed by the specified file.
+func newRelayFee(path string) (*relayFee, error) {
+	rf := &relayFee{path: path}
+	return rf, rf.load()
+}
+
+// FeeFloor returns the minimum fee (per weight unit) a transaction set
+// must pay to be accepted and relayed.
+func (s *Syncer) FeeFloor() types.Currency {
+	s.rf.mu.Lock()
+	defer s.rf.mu.Unlock()
+	return s.rf.floor
+}
+
+// SetFeeFloor sets the minimum fee (per weight unit) a transaction set
+// must pay to be accepted and relayed. The floor is kept across
+// restarts.
+func (s *Syncer) SetFeeFloor(floor types.Currency) error {
+	s.rf.mu.Lock()
+	defer s.rf.mu.Unlock()
+	s.rf.floor = floor
+	return s.rf.save()
+}
+
+// CheckRelayFee returns modules.ErrInsufficientRelayFee if the
+// transaction set pays less than the fee floor.
+func (s *Syncer) CheckRelayFee(txns []types.Transaction, v2txns []types.V2Transaction) error {
+	floor := s.FeeFloor()
+	if floor.IsZero() {
+		return nil
+	}
+	cs := s.cm.TipState()
+	var fees types.Currency
+	var weight uint64
+	for _, txn := range txns {
+		fees = fees.Add(txn.TotalFees())
+		weight += cs.TransactionWeight(txn)
+	}
+	for _, txn := range v2txns {
+		fees = fees.Add(txn.MinerFee)
+		weight += cs.V2TransactionWeight(txn)
+	}
+	if minFee := floor.Mul64(weight); fees.Cmp(minFee) < 0 {
+		return fmt.Errorf("%w: transaction set pays %v, at least %v required", modules.ErrInsufficientRelayFee, fees, minFee)
+	}
+	return nil
+}
+
+// relayGate wraps the chain manager used by the core syncer, so that
+// the transaction sets relayed by the peers are checked against the
+// fee floor before they enter the pool and are relayed any further.
+type relayGate struct {
+	*chain.Manager
+	s *Syncer
+}
+
+// AddPoolTransactions implements syncer.ChainManager.
+func (rg relayGate) AddPoolTransactions(txns []types.Transaction) (bool, error) {
+	if err := rg.s.CheckRelayFee(txns, nil); err != nil {
+		return false, err
+	}
+	return rg.Manager.AddPoolTransactions(txns)
+}
+
+// AddV2PoolTransactions implements syncer.ChainManager.
+func (rg relayGate) AddV2PoolTransactions(basis types.ChainIndex, txns []types.V2Transaction) (bool, error) {
+	if err := rg.s.CheckRelayFee(nil, txns); err != nil {
+		return false, err
+	}
+	return rg.Manager.AddV2PoolTransactions(basis, txns)
+}
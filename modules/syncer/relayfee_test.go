@@ -0,0 +1,101 @@
+package syncer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/testutil"
+	"lukechampine.com/frand"
+)
+
+// newTestChain returns a chain manager with an in-memory store.
+func newTestChain(t *testing.T) *chain.Manager {
+	n, genesis := testutil.Network()
+	store, tipState, err := chain.NewDBStore(chain.NewMemDB(), n, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain.NewManager(store, tipState)
+}
+
+func TestRelayFee(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relayfee.json")
+	rf, err := newRelayFee(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Syncer{
+		cm: newTestChain(t),
+		rf: rf,
+	}
+
+	txn := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: frand.Entropy256()}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.Siacoins(1)}},
+		MinerFees:      []types.Currency{types.Siacoins(1).Div64(1000)},
+	}
+	weight := s.cm.TipState().TransactionWeight(txn)
+	txns := []types.Transaction{txn}
+
+	// No floor, no requirement.
+	if err := s.CheckRelayFee(txns, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction paying exactly the floor is accepted.
+	floor := txn.MinerFees[0].Div64(weight)
+	if err := s.SetFeeFloor(floor); err != nil {
+		t.Fatal(err)
+	} else if err := s.CheckRelayFee(txns, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The required fee scales with the floor.
+	if err := s.SetFeeFloor(floor.Mul64(2)); err != nil {
+		t.Fatal(err)
+	} else if err := s.CheckRelayFee(txns, nil); !errors.Is(err, modules.ErrInsufficientRelayFee) {
+		t.Fatal("expected ErrInsufficientRelayFee, got", err)
+	}
+	txns[0].MinerFees[0] = txn.MinerFees[0].Mul64(2)
+	if err := s.CheckRelayFee(txns, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The floor is kept across restarts.
+	rf, err = newRelayFee(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if !rf.floor.Equals(floor.Mul64(2)) {
+		t.Fatalf("expected floor %v after reloading, got %v", floor.Mul64(2), rf.floor)
+	}
+}
+
+func TestRelayGateRepeatedInvalidSet(t *testing.T) {
+	rf, err := newRelayFee(filepath.Join(t.TempDir(), "relayfee.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := newTestChain(t)
+	rg := relayGate{cm, &Syncer{cm: cm, rf: rf}}
+
+	// The set spends a nonexistent output, so it is invalid.
+	txns := []types.Transaction{{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: frand.Entropy256()}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.Siacoins(1)}},
+	}}
+	known, err := rg.AddPoolTransactions(txns)
+	if err == nil || known {
+		t.Fatalf("expected the set to be rejected as unknown, got %v, %v", known, err)
+	}
+
+	// The chain manager remembers the rejection, so the same set is
+	// reported as known and isn't relayed any further by the syncer.
+	known, again := rg.AddPoolTransactions(txns)
+	if !known || again == nil || again.Error() != err.Error() {
+		t.Fatalf("expected the cached rejection %v, got %v, %v", err, known, again)
+	}
+}
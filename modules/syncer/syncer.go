@@ -2,8 +2,12 @@ package syncer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net"
 	"path/filepath"
+	"time"
 
 	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/persist"
@@ -12,6 +16,7 @@ import (
 	"go.sia.tech/coreutils/chain"
 	"go.sia.tech/coreutils/syncer"
 	"go.uber.org/zap"
+	"lukechampine.com/frand"
 )
 
 // Network bootstrap.
@@ -49,16 +54,58 @@ var (
 	}
 )
 
+// errPeerNotPersistent is returned when removing a peer that is not
+// in the persistent set.
+var errPeerNotPersistent = errors.New("peer is not persistent")
+
 // We consider ourselves synced if minSyncedPeers say that we are.
 const minSyncedPeers = 5
 
+const (
+	// minRelayFanout is the minimum number of peers a transaction set
+	// is relayed to.
+	minRelayFanout = 3
+
+	// relayTimeout is the timeout for relaying a transaction set to
+	// a single peer.
+	relayTimeout = 10 * time.Second
+
+	// defaultMaxTransactionSetSize is the default maximum encoded size
+	// of a relayed transaction set, equal to the block size limit.
+	defaultMaxTransactionSetSize = 2e6
+)
+
 // A Syncer synchronizes blockchain data with peers.
 type Syncer struct {
 	s       *syncer.Syncer
+	cm      *chain.Manager
 	ps      syncer.PeerStore
 	l       net.Listener
 	log     *zap.Logger
 	closeFn func()
+
+	// pp contains the manually added peers that are remembered across
+	// restarts.
+	pp       *persistentPeers
+	stopChan chan struct{}
+
+	// fanout is the number of peers a transaction set is relayed to.
+	// Zero means the square root of the peer count, a negative value
+	// means all peers.
+	fanout int
+
+	// bootstrap contains the bootstrap peers supplied by the operator.
+	bootstrap []string
+
+	// maxTxnSetSize is the maximum encoded size of a relayed
+	// transaction set.
+	maxTxnSetSize uint64
+
+	// propagation tracks the recently broadcast transaction sets.
+	propagation *propagationTracker
+
+	// rf is the minimum fee a transaction set must pay to be relayed.
+	rf *relayFee
 }
 
 // Synced returns if the syncer is synced to the blockchain.
@@ -78,9 +125,31 @@ func (s *Syncer) Synced() bool {
 // error occurs, upon which all connections are closed and goroutines are
 // terminated.
 func (s *Syncer) Run() error {
+	go s.threadedConnectPersistentPeers()
+	go s.threadedCheckBootstrapPeers()
 	return s.s.Run()
 }
 
+// threadedCheckBootstrapPeers tries to connect to the bootstrap peers
+// supplied by the operator and logs which of them were reachable.
+func (s *Syncer) threadedCheckBootstrapPeers() {
+	var reachable int
+	for _, addr := range s.bootstrap {
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		_, err := s.Connect(ctx, addr)
+		cancel()
+		if err != nil {
+			s.log.Warn("bootstrap peer unreachable", zap.String("address", addr), zap.Error(err))
+			continue
+		}
+		reachable++
+		s.log.Info("bootstrap peer reachable", zap.String("address", addr))
+	}
+	if len(s.bootstrap) > 0 {
+		s.log.Info("checked bootstrap peers", zap.Int("reachable", reachable), zap.Int("total", len(s.bootstrap)))
+	}
+}
+
 // Connect forms an outbound connection to a peer.
 func (s *Syncer) Connect(ctx context.Context, addr string) (*syncer.Peer, error) {
 	return s.s.Connect(ctx, addr)
@@ -95,12 +164,112 @@ func (s *Syncer) BroadcastV2Header(h gateway.V2BlockHeader) { s.s.BroadcastV2Hea
 // BroadcastV2BlockOutline broadcasts a v2 block outline to all peers.
 func (s *Syncer) BroadcastV2BlockOutline(b gateway.V2BlockOutline) { s.s.BroadcastV2BlockOutline(b) }
 
-// BroadcastTransactionSet broadcasts a transaction set to all peers.
-func (s *Syncer) BroadcastTransactionSet(txns []types.Transaction) { s.s.BroadcastTransactionSet(txns) }
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n uint64
+}
+
+// Write implements io.Writer.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += uint64(len(p))
+	return len(p), nil
+}
+
+// CheckTransactionSetSize returns modules.ErrTransactionSetTooLarge if
+// the encoded size of the transaction set exceeds the limit.
+func (s *Syncer) CheckTransactionSetSize(txns []types.Transaction, v2txns []types.V2Transaction) error {
+	var cw countingWriter
+	e := types.NewEncoder(&cw)
+	e.WritePrefix(len(txns))
+	for _, txn := range txns {
+		txn.EncodeTo(e)
+	}
+	e.WritePrefix(len(v2txns))
+	for _, txn := range v2txns {
+		txn.EncodeTo(e)
+	}
+	e.Flush()
+	if cw.n > s.maxTxnSetSize {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", modules.ErrTransactionSetTooLarge, cw.n, s.maxTxnSetSize)
+	}
+	return nil
+}
+
+// BroadcastTransactionSet broadcasts a transaction set to a random
+// subset of peers, or to all peers if configured so. Sets exceeding
+// the size limit are not relayed.
+func (s *Syncer) BroadcastTransactionSet(txns []types.Transaction) {
+	if err := s.CheckTransactionSetSize(txns, nil); err != nil {
+		s.log.Warn("not relaying transaction set", zap.Error(err))
+		return
+	}
+	if len(txns) == 0 {
+		return
+	}
+	peers := s.relayPeers()
+	id := txns[len(txns)-1].ID()
+	s.propagation.add(id, len(peers))
+	for _, p := range peers {
+		go func(p *syncer.Peer) {
+			err := p.RelayTransactionSet(txns, relayTimeout)
+			if err != nil {
+				s.log.Debug("unable to relay transaction set", zap.String("peer", p.String()), zap.Error(err))
+			}
+			s.propagation.update(id, p.String(), err)
+		}(p)
+	}
+}
 
-// BroadcastV2TransactionSet broadcasts a v2 transaction set to all peers.
+// BroadcastV2TransactionSet broadcasts a v2 transaction set to a random
+// subset of peers, or to all peers if configured so. Sets exceeding
+// the size limit are not relayed.
 func (s *Syncer) BroadcastV2TransactionSet(index types.ChainIndex, txns []types.V2Transaction) {
-	s.s.BroadcastV2TransactionSet(index, txns)
+	if err := s.CheckTransactionSetSize(nil, txns); err != nil {
+		s.log.Warn("not relaying v2 transaction set", zap.Error(err))
+		return
+	}
+	if len(txns) == 0 {
+		return
+	}
+	peers := s.relayPeers()
+	id := txns[len(txns)-1].ID()
+	s.propagation.add(id, len(peers))
+	for _, p := range peers {
+		go func(p *syncer.Peer) {
+			err := p.RelayV2TransactionSet(index, txns, relayTimeout)
+			if err != nil {
+				s.log.Debug("unable to relay v2 transaction set", zap.String("peer", p.String()), zap.Error(err))
+			}
+			s.propagation.update(id, p.String(), err)
+		}(p)
+	}
+}
+
+// relayPeers returns a random subset of peers to relay a transaction
+// set to, or all peers if configured so.
+func (s *Syncer) relayPeers() []*syncer.Peer {
+	peers := s.s.Peers()
+	if s.fanout < 0 {
+		return peers
+	}
+	frand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	return peers[:relayFanout(len(peers), s.fanout)]
+}
+
+// relayFanout returns the number of peers out of n a transaction set
+// is relayed to. If fanout is zero, it is the square root of n, but
+// not less than minRelayFanout.
+func relayFanout(n, fanout int) int {
+	if fanout <= 0 {
+		fanout = int(math.Ceil(math.Sqrt(float64(n))))
+		if fanout < minRelayFanout {
+			fanout = minRelayFanout
+		}
+	}
+	if fanout > n {
+		fanout = n
+	}
+	return fanout
 }
 
 // Peers returns the set of currently-connected peers.
@@ -121,6 +290,7 @@ func (s *Syncer) Addr() string {
 
 // Close shuts down the Syncer.
 func (s *Syncer) Close() error {
+	close(s.stopChan)
 	err := s.l.Close()
 	if err != nil {
 		s.log.Sugar().Error("unable to close listener", err)
@@ -129,8 +299,13 @@ func (s *Syncer) Close() error {
 	return err
 }
 
-// New returns a new Syncer.
-func New(cm *chain.Manager, addr, dir string) (*Syncer, error) {
+// New returns a new Syncer. fanout is the number of peers a transaction
+// set is relayed to; zero means the square root of the peer count, and
+// a negative value means all peers. bootstrap is the list of additional
+// bootstrap peers; if bootstrapOnly is set, the built-in peers are not
+// used. maxTxnSetSize is the maximum encoded size of a relayed
+// transaction set; if zero, the default value is used.
+func New(cm *chain.Manager, addr, dir string, fanout int, bootstrap []string, bootstrapOnly bool, maxTxnSetSize uint64) (*Syncer, error) {
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, modules.AddContext(err, "unable to start listener")
@@ -146,10 +321,25 @@ func New(cm *chain.Manager, addr, dir string) (*Syncer, error) {
 	if err != nil {
 		return nil, modules.AddContext(err, "unable to create store")
 	}
-	for _, peer := range bootstrapPeers {
+	for _, peer := range bootstrap {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			return nil, modules.AddContext(err, "invalid bootstrap peer")
+		}
+	}
+	if !bootstrapOnly {
+		for _, peer := range bootstrapPeers {
+			ps.AddPeer(peer)
+		}
+	}
+	for _, peer := range bootstrap {
 		ps.AddPeer(peer)
 	}
 
+	pp, err := newPersistentPeers(filepath.Join(dir, "persistent.json"))
+	if err != nil {
+		return nil, modules.AddContext(err, "unable to load persistent peers")
+	}
+
 	_, genesisBlock := chain.Mainnet()
 	header := gateway.Header{
 		GenesisID:  genesisBlock.ID(),
@@ -162,13 +352,31 @@ func New(cm *chain.Manager, addr, dir string) (*Syncer, error) {
 		return nil, modules.AddContext(err, "unable to create logger")
 	}
 
-	s := syncer.New(l, cm, ps, header, syncer.WithLogger(logger))
+	rf, err := newRelayFee(filepath.Join(dir, "relayfee.json"))
+	if err != nil {
+		return nil, modules.AddContext(err, "unable to load fee floor")
+	}
+
+	if maxTxnSetSize == 0 {
+		maxTxnSetSize = defaultMaxTransactionSetSize
+	}
+
+	s := &Syncer{
+		cm:        cm,
+		ps:        ps,
+		l:         l,
+		log:       logger,
+		closeFn:   closeFn,
+		pp:        pp,
+		stopChan:  make(chan struct{}),
+		fanout:    fanout,
+		bootstrap: bootstrap,
+
+		maxTxnSetSize: maxTxnSetSize,
+		propagation:   newPropagationTracker(),
+		rf:            rf,
+	}
+	s.s = syncer.New(l, relayGate{cm, s}, ps, header, syncer.WithLogger(logger))
 
-	return &Syncer{
-		s:       s,
-		ps:      ps,
-		l:       l,
-		log:     logger,
-		closeFn: closeFn,
-	}, nil
+	return s, nil
 }
@@ -0,0 +1,64 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+func TestRelayFanout(t *testing.T) {
+	tests := []struct {
+		peers, fanout, want int
+	}{
+		// The square root of the peer count, but at least three.
+		{0, 0, 0},
+		{1, 0, 1},
+		{3, 0, 3},
+		{8, 0, 3},
+		{9, 0, 3},
+		{10, 0, 4},
+		{64, 0, 8},
+		{100, 0, 10},
+		{101, 0, 11},
+		// A configured fanout.
+		{100, 5, 5},
+		{100, 1, 1},
+		{4, 5, 4},
+	}
+	for _, test := range tests {
+		if got := relayFanout(test.peers, test.fanout); got != test.want {
+			t.Errorf("relayFanout(%v, %v): expected %v, got %v", test.peers, test.fanout, test.want, got)
+		}
+	}
+}
+
+func TestCheckTransactionSetSize(t *testing.T) {
+	txns := []types.Transaction{{ArbitraryData: [][]byte{frand.Bytes(1000)}}}
+	v2txns := []types.V2Transaction{{ArbitraryData: frand.Bytes(1000)}}
+
+	// Measure the encoded size of the set.
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	e.WritePrefix(len(txns))
+	txns[0].EncodeTo(e)
+	e.WritePrefix(len(v2txns))
+	v2txns[0].EncodeTo(e)
+	e.Flush()
+	size := uint64(buf.Len())
+
+	s := &Syncer{maxTxnSetSize: size}
+	if err := s.CheckTransactionSetSize(txns, v2txns); err != nil {
+		t.Fatal("expected a set at the limit to pass:", err)
+	}
+	s.maxTxnSetSize = size - 1
+	if err := s.CheckTransactionSetSize(txns, v2txns); !errors.Is(err, modules.ErrTransactionSetTooLarge) {
+		t.Fatalf("expected %v, got %v", modules.ErrTransactionSetTooLarge, err)
+	}
+	if err := s.CheckTransactionSetSize(nil, v2txns); err != nil {
+		t.Fatal("expected a smaller set to pass:", err)
+	}
+}
@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -12,6 +14,10 @@ var (
 	// ErrInsufficientBalance is returned when there aren't enough unused outputs
 	// to cover the requested amount.
 	ErrInsufficientBalance = errors.New("insufficient balance")
+
+	// ErrWalletLocked is returned when an operation requires the wallet
+	// to be unlocked.
+	ErrWalletLocked = errors.New("wallet is locked")
 )
 
 // Wallet stores and manages Siacoins.
@@ -25,18 +31,71 @@ type Wallet interface {
 	// AddWatch adds the given watched address to the wallet.
 	AddWatch(addr types.Address) error
 
+	// AutoLock returns the period of inactivity after which the wallet
+	// is locked.
+	AutoLock() time.Duration
+
+	// AuditLog returns a page of the audit log of the outgoing wallet
+	// transactions, together with the total number of entries.
+	AuditLog(offset, limit uint64) ([]AuditEntry, uint64, error)
+
 	// Annotate annotates a transaction set.
 	Annotate(txns []types.Transaction) (ptxns []PoolTransaction)
 
+	// BumpFee replaces an unconfirmed wallet transaction with one paying
+	// a higher fee rate.
+	BumpFee(id types.TransactionID, feeRate types.Currency) ([]types.Transaction, error)
+
+	// ClaimBalance returns the Siacoins that can be claimed by spending
+	// the Siafunds of the wallet.
+	ClaimBalance() types.Currency
+
 	// Close shuts down the wallet.
 	Close() error
 
+	// Shutdown shuts down the wallet after the operations in flight
+	// have finished, or returns when ctx expires.
+	Shutdown(ctx context.Context) error
+
 	// ConfirmedBalance returns the total balance of the wallet.
 	ConfirmedBalance() (siacoins, immatureSiacoins types.Currency, siafunds uint64)
 
+	// DustThreshold returns the quantity below which a Currency is
+	// considered to be Dust.
+	DustThreshold() types.Currency
+
+	// Fingerprint returns a salted hash of the wallet seed, which can be
+	// used to check if two nodes use the same seed.
+	Fingerprint() (types.Hash256, error)
+
 	// Fund adds Siacoin inputs with the required amount to the transaction.
 	Fund(txn *types.Transaction, amount types.Currency) (parents []types.Transaction, toSign []types.Hash256, err error)
 
+	// FundDetailed works like Fund, but also reports if the transaction
+	// depends on any unconfirmed transactions.
+	FundDetailed(txn *types.Transaction, amount types.Currency) (FundResult, error)
+
+	// FundSignBroadcast creates a transaction paying to the specified
+	// outputs, funds it, signs it, and broadcasts it. The reserved inputs
+	// are released if any of the steps fails.
+	FundSignBroadcast(outputs []types.SiacoinOutput, feeRate types.Currency) (types.TransactionID, error)
+
+	// ImportKey adds a standalone private key to the wallet. Imported
+	// keys can't be recovered from the wallet seed.
+	ImportKey(sk types.PrivateKey) error
+
+	// CreateMultisigAddress builds and registers an M-of-N multisig
+	// address. If sign is true, one of the keys must belong to the
+	// wallet.
+	CreateMultisigAddress(pubkeys []types.PublicKey, required uint64, sign bool) (types.UnlockConditions, types.Address, error)
+
+	// Lock locks the wallet. A locked wallet refuses the spends
+	// requested by the user, but not those of the satellite's modules.
+	Lock()
+
+	// LockState returns the current lock state of the wallet.
+	LockState() WalletLockEvent
+
 	// MarkAddressUnused marks the provided address as unused which causes it to be
 	// handed out by a subsequent call to `NextAddresses` again.
 	MarkAddressUnused(addrs ...types.UnlockConditions) error
@@ -49,43 +108,248 @@ type Wallet interface {
 	// Siafunds.
 	NextAddress() (types.UnlockConditions, error)
 
+	// NextAddresses returns n unlock hashes that are ready to receive
+	// Siacoins or Siafunds.
+	NextAddresses(n uint64) ([]types.UnlockConditions, error)
+
 	// Release marks the outputs as unused.
 	Release(txnSet []types.Transaction)
 
+	// ReleaseInputsExplicit marks the inputs of the transaction and
+	// of its parents as unused.
+	ReleaseInputsExplicit(txn types.Transaction, parents []types.Transaction)
+
 	// RemoveWatch removes the given watched address from the wallet.
 	RemoveWatch(addr types.Address) error
 
 	// Reserve reserves the given ids for the given duration.
 	Reserve(ids []types.Hash256, duration time.Duration) error
 
+	// MaxAncestorDepth returns the maximum length of the chain of
+	// unconfirmed transactions a funded transaction may depend on.
+	MaxAncestorDepth() int
+
+	// PublicKey returns the public key of the given wallet address.
+	PublicKey(addr types.Address) (types.PublicKey, bool)
+
 	// RenterSeed derives a renter seed.
 	RenterSeed(email string) []byte
 
+	// RequiredConfirmations returns the number of blocks an output must
+	// have been confirmed for to count towards the balance.
+	RequiredConfirmations() uint64
+
+	// SetRequiredConfirmations sets the number of blocks an output must
+	// have been confirmed for to count towards the balance.
+	SetRequiredConfirmations(n uint64) error
+
+	// SetMaxAncestorDepth sets the maximum length of the chain of
+	// unconfirmed transactions a funded transaction may depend on. Zero
+	// removes the limit.
+	SetMaxAncestorDepth(depth int) error
+
+	// SetAutoLock sets the period of inactivity after which the wallet
+	// is locked. Zero disables the auto-lock.
+	SetAutoLock(idle time.Duration)
+
+	// SiacoinElement returns the unspent SC element with the given ID,
+	// together with the wallet tip its Merkle proof is valid at.
+	SiacoinElement(id types.SiacoinOutputID) (types.SiacoinElement, types.ChainIndex, bool)
+
+	// SiafundElement returns the unspent SF element with the given ID,
+	// together with the wallet tip its Merkle proof is valid at.
+	SiafundElement(id types.SiafundOutputID) (types.SiafundElement, types.ChainIndex, bool)
+
+	// SignMessage signs a free-form message with the key of the given
+	// wallet address.
+	SignMessage(addr types.Address, msg []byte) (types.Signature, error)
+
+	// SignReceipt signs the receipt of a transaction sent by the wallet
+	// with the key of the transaction's first input.
+	SignReceipt(r *SendReceipt, txn types.Transaction) error
+
 	// SendSiacoins creates a transaction sending 'amount' to 'dest'. The
 	// transaction is submitted to the transaction pool and is also returned. Fees
-	// are added to the amount sent.
-	SendSiacoins(amount types.Currency, dest types.Address) ([]types.Transaction, error)
+	// are added to the amount sent. If feeRate (per weight unit) is zero, the
+	// recommended fee rate is used.
+	SendSiacoins(amount types.Currency, dest types.Address, feeRate types.Currency) ([]types.Transaction, error)
+
+	// RecordAudit appends a transaction funded by the wallet and
+	// broadcast by the caller to the audit log.
+	RecordAudit(txn types.Transaction)
+
+	// SendMax creates a transaction sending all spendable Siacoins to
+	// 'dest', the fee deducted, leaving no change. If feeRate (per
+	// weight unit) is zero, the recommended fee rate is used.
+	SendMax(dest types.Address, feeRate types.Currency) ([]types.Transaction, error)
 
 	// Sign signs the specified transaction using keys derived from the wallet seed.
 	Sign(cs consensus.State, txn *types.Transaction, toSign []types.Hash256) error
 
+	// SpendableBalance returns the part of the confirmed balance that
+	// can be spent right now.
+	SpendableBalance() types.Currency
+
+	// SpentOutputs returns the number of outputs marked as spent by
+	// the wallet, and how many of them are due to be pruned.
+	SpentOutputs() (count, expired int)
+
+	// SubscribeLock returns a channel that receives the lock state
+	// changes, and a function that closes the subscription.
+	SubscribeLock() (<-chan WalletLockEvent, func())
+
 	// Tip returns the wallet's internal processed chain index.
 	Tip() types.ChainIndex
 
+	// TransactionEvent returns the event of the confirmed transaction
+	// with the given ID, if the transaction is relevant to the wallet.
+	TransactionEvent(id types.TransactionID) (WalletEvent, bool, error)
+
+	// EstimateSize returns the projected encoded size of a signed
+	// transaction paying the given outputs, and its number of inputs.
+	EstimateSize(outputs []types.SiacoinOutput) (size uint64, inputs int, err error)
+
+	// EventCounts returns the number of recorded events per type.
+	EventCounts() map[string]int
+
+	// ExportEvents calls fn for each event of the event log, oldest
+	// first.
+	ExportEvents(fn func(WalletEvent) error) error
+
+	// ImportEvents adds previously exported events to the event log.
+	ImportEvents(events []WalletEvent) (imported, skipped uint64, err error)
+
 	// UnconfirmedBalance returns the balance of the wallet contained in
 	// the unconfirmed transactions.
 	UnconfirmedBalance() (outgoing, incoming types.Currency)
 
+	// Touch records wallet activity, resetting the auto-lock timer.
+	Touch()
+
+	// Unlock unlocks the wallet. The seed phrase must match the wallet
+	// seed.
+	Unlock(seed string) error
+
 	// UnspentSiacoinOutputs returns the unspent SC outputs of the wallet.
 	UnspentSiacoinOutputs() (sces []types.SiacoinElement)
 
 	// UnspentSiafundOutputs returns the unspent SF outputs of the wallet.
 	UnspentSiafundOutputs() (sfes []types.SiafundElement)
 
+	// VerifyMessage checks the signature of a message signed with the
+	// key of the given wallet address.
+	VerifyMessage(addr types.Address, msg []byte, sig types.Signature) (bool, error)
+
 	// WatchedAddresses returns a list of the addresses watched by the wallet.
 	WatchedAddresses() (addrs []types.Address)
 }
 
+// AuditEntry is an entry of the wallet's audit log. Each entry commits
+// to the hash of the previous one, so that tampering is detectable.
+type AuditEntry struct {
+	Index         uint64                `json:"index"`
+	Timestamp     time.Time             `json:"timestamp"`
+	TransactionID types.TransactionID   `json:"transactionID"`
+	Destinations  []types.SiacoinOutput `json:"destinations"`
+	Amount        types.Currency        `json:"amount"`
+	Fee           types.Currency        `json:"fee"`
+	PrevHash      types.Hash256         `json:"prevHash"`
+	Hash          types.Hash256         `json:"hash"`
+}
+
+// WalletEvent is an event relevant to the wallet, as recorded in the
+// wallet's event log.
+type WalletEvent struct {
+	ID        types.Hash256    `json:"id"`
+	Index     types.ChainIndex `json:"index"`
+	Timestamp time.Time        `json:"timestamp"`
+	Type      string           `json:"type"`
+	Relevant  []types.Address  `json:"relevant"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+// SendReceipt is a shareable summary of a transaction sent by the
+// wallet. PublicKey and Signature are only set if the receipt has been
+// signed.
+type SendReceipt struct {
+	TransactionID types.TransactionID `json:"transactionID"`
+	Destination   types.Address       `json:"destination"`
+	Amount        types.Currency      `json:"amount"`
+	Fee           types.Currency      `json:"fee"`
+	FeeRate       types.Currency      `json:"feeRate"`
+	Size          uint64              `json:"size"`
+	Link          string              `json:"link"`
+	PublicKey     *types.PublicKey    `json:"publicKey,omitempty"`
+	Signature     *types.Signature    `json:"signature,omitempty"`
+
+	// SignError is set if the receipt was requested signed, but
+	// couldn't be signed. The transaction has been broadcast anyway.
+	SignError string `json:"signError,omitempty"`
+}
+
+// SigHash returns the hash of the receipt that is signed. The hash is
+// prefixed, so that it can't be mistaken for a transaction signature.
+func (r SendReceipt) SigHash() types.Hash256 {
+	h := types.NewHasher()
+	h.E.Write([]byte("sia-satellite/receipt|"))
+	r.TransactionID.EncodeTo(h.E)
+	r.Destination.EncodeTo(h.E)
+	types.V2Currency(r.Amount).EncodeTo(h.E)
+	types.V2Currency(r.Fee).EncodeTo(h.E)
+	types.V2Currency(r.FeeRate).EncodeTo(h.E)
+	h.E.WriteUint64(r.Size)
+	return h.Sum()
+}
+
+// Verify checks the signature of the receipt. It doesn't check that
+// the public key belongs to an input of the transaction.
+func (r SendReceipt) Verify() bool {
+	if r.PublicKey == nil || r.Signature == nil {
+		return false
+	}
+	return r.PublicKey.VerifyHash(r.SigHash(), *r.Signature)
+}
+
+// MessageHash returns the hash of a free-form message that is signed
+// by a wallet key. The hash is prefixed, so that it can't be mistaken
+// for a transaction signature.
+func MessageHash(msg []byte) types.Hash256 {
+	h := types.NewHasher()
+	h.E.Write([]byte("sia-satellite/message|"))
+	h.E.WriteBytes(msg)
+	return h.Sum()
+}
+
+// VerifyMessage checks that the message was signed with the key of the
+// given address. pk must be the public key of the address.
+func VerifyMessage(pk types.PublicKey, addr types.Address, msg []byte, sig types.Signature) bool {
+	return types.StandardUnlockHash(pk) == addr && pk.VerifyHash(MessageHash(msg), sig)
+}
+
+// WalletLockEvent is a change of the wallet lock state.
+type WalletLockEvent struct {
+	Locked    bool      `json:"locked"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// FundResult is the result of funding a transaction.
+type FundResult struct {
+	// Parents are the unconfirmed transactions the funded transaction
+	// depends on.
+	Parents []types.Transaction `json:"parents"`
+
+	// ToSign are the IDs of the inputs that need to be signed.
+	ToSign []types.Hash256 `json:"toSign"`
+
+	// Unconfirmed is true if the funded transaction spends any outputs
+	// of unconfirmed transactions.
+	Unconfirmed bool `json:"unconfirmed"`
+
+	// UnconfirmedParents are the IDs of the parent transactions.
+	UnconfirmedParents []types.TransactionID `json:"unconfirmedParents"`
+}
+
 // A PoolTransaction summarizes the wallet-relevant data in a txpool
 // transaction.
 type PoolTransaction struct {
@@ -95,4 +359,5 @@ type PoolTransaction struct {
 	Sent     types.Currency      `json:"sent"`
 	Received types.Currency      `json:"received"`
 	Locked   types.Currency      `json:"locked"`
+	Fee      types.Currency      `json:"fee"`
 }
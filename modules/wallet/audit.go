@@ -0,0 +1,316 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// auditTipFile is the name of the file holding the tip of the audit
+// log's hash chain. It is kept outside of the database, so that entries
+// deleted from the end of the log can't go unnoticed by also rewriting
+// the tip.
+const auditTipFile = "audittip.dat"
+
+var (
+	// errAuditLogBroken is returned when the hash chain of the audit
+	// log doesn't match.
+	errAuditLogBroken = errors.New("audit log hash chain is broken")
+
+	// errInvalidAuditTip is returned when the audit tip file is
+	// corrupted.
+	errInvalidAuditTip = errors.New("invalid audit tip file")
+)
+
+// auditTip is the number of entries in the audit log and the hash of
+// the last one.
+type auditTip struct {
+	Count uint64
+	Hash  types.Hash256
+}
+
+// loadAuditTip loads the audit tip from the specified directory. ok is
+// false if there is no tip file yet.
+func loadAuditTip(dir string) (tip auditTip, ok bool, err error) {
+	b, err := os.ReadFile(filepath.Join(dir, auditTipFile))
+	if os.IsNotExist(err) {
+		return auditTip{}, false, nil
+	} else if err != nil {
+		return auditTip{}, false, err
+	}
+	if len(b) != 8+len(tip.Hash) {
+		return auditTip{}, false, errInvalidAuditTip
+	}
+	tip.Count = binary.LittleEndian.Uint64(b)
+	copy(tip.Hash[:], b[8:])
+	return tip, true, nil
+}
+
+// saveAuditTip atomically saves the audit tip to the specified
+// directory.
+func saveAuditTip(dir string, tip auditTip) error {
+	path := filepath.Join(dir, auditTipFile)
+	b := binary.LittleEndian.AppendUint64(nil, tip.Count)
+	b = append(b, tip.Hash[:]...)
+	f, err := os.OpenFile(path+"_tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(b); err != nil {
+		return err
+	} else if err = f.Sync(); err != nil {
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+"_tmp", path)
+}
+
+// auditHash returns the hash of the audit log entry, which commits to
+// the previous entry's hash.
+func auditHash(entry modules.AuditEntry) types.Hash256 {
+	h := types.NewHasher()
+	entry.PrevHash.EncodeTo(h.E)
+	h.E.WriteUint64(entry.Index)
+	h.E.WriteTime(entry.Timestamp)
+	entry.TransactionID.EncodeTo(h.E)
+	h.E.Write(encodeOutputs(entry.Destinations))
+	types.V2Currency(entry.Amount).EncodeTo(h.E)
+	types.V2Currency(entry.Fee).EncodeTo(h.E)
+	return h.Sum()
+}
+
+func encodeOutputs(scos []types.SiacoinOutput) []byte {
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	e.WritePrefix(len(scos))
+	for _, sco := range scos {
+		types.V2SiacoinOutput(sco).EncodeTo(e)
+	}
+	e.Flush()
+	return buf.Bytes()
+}
+
+func decodeOutputs(buf []byte) ([]types.SiacoinOutput, error) {
+	d := types.NewBufDecoder(buf)
+	scos := make([]types.SiacoinOutput, d.ReadPrefix())
+	for i := range scos {
+		(*types.V2SiacoinOutput)(&scos[i]).DecodeFrom(d)
+	}
+	return scos, d.Err()
+}
+
+// recordAudit appends the outgoing transaction to the audit log. It is
+// called once the transaction has been broadcast. The amount includes
+// the payouts of the file contracts formed by the transaction. The entry
+// is written outside of the wallet's database transaction, so that it
+// is persisted immediately, and the new tip of the hash chain is saved
+// to the tip file.
+func (w *Wallet) recordAudit(txn types.Transaction) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry := modules.AuditEntry{
+		Index:         w.auditIndex,
+		Timestamp:     time.Now(),
+		TransactionID: txn.ID(),
+		PrevHash:      w.auditHash,
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if _, ok := w.keys[sco.Address]; ok {
+			continue
+		}
+		entry.Destinations = append(entry.Destinations, sco)
+		entry.Amount = entry.Amount.Add(sco.Value)
+	}
+	for _, fc := range txn.FileContracts {
+		entry.Amount = entry.Amount.Add(fc.Payout)
+	}
+	for _, fee := range txn.MinerFees {
+		entry.Fee = entry.Fee.Add(fee)
+	}
+	entry.Hash = auditHash(entry)
+
+	_, err := w.db.Exec(`
+		INSERT INTO wt_audit (
+			id,
+			timestamp,
+			txid,
+			destinations,
+			amount,
+			fee,
+			prev_hash,
+			hash
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		entry.Index+1,
+		entry.Timestamp.Unix(),
+		entry.TransactionID[:],
+		encodeOutputs(entry.Destinations),
+		encodeCurrency(entry.Amount),
+		encodeCurrency(entry.Fee),
+		entry.PrevHash[:],
+		entry.Hash[:],
+	)
+	if err != nil {
+		return modules.AddContext(err, "couldn't save audit log entry")
+	}
+
+	w.auditIndex++
+	w.auditHash = entry.Hash
+	if err := saveAuditTip(w.dir, auditTip{Count: w.auditIndex, Hash: w.auditHash}); err != nil {
+		return modules.AddContext(err, "couldn't save audit log tip")
+	}
+	return nil
+}
+
+// RecordAudit appends a transaction funded by the wallet and broadcast
+// by the caller to the audit log.
+func (w *Wallet) RecordAudit(txn types.Transaction) {
+	if err := w.recordAudit(txn); err != nil {
+		w.log.Error("failed to record audit log entry", zap.Stringer("txid", txn.ID()), zap.Error(err))
+	}
+}
+
+// auditEntries retrieves the audit log entries in the given range.
+func (w *Wallet) auditEntries(offset, limit uint64) ([]modules.AuditEntry, error) {
+	rows, err := w.db.Query(`
+		SELECT id, timestamp, txid, destinations, amount, fee, prev_hash, hash
+		FROM wt_audit
+		ORDER BY id ASC
+		LIMIT ?, ?
+	`, offset, limit)
+	if err != nil {
+		return nil, modules.AddContext(err, "couldn't query audit log")
+	}
+	defer rows.Close()
+
+	var entries []modules.AuditEntry
+	for rows.Next() {
+		var entry modules.AuditEntry
+		var id, timestamp uint64
+		var txid, dests, amount, fee, prev, hash []byte
+		if err := rows.Scan(&id, &timestamp, &txid, &dests, &amount, &fee, &prev, &hash); err != nil {
+			return nil, modules.AddContext(err, "couldn't scan audit log entry")
+		}
+		entry.Index = id - 1
+		entry.Timestamp = time.Unix(int64(timestamp), 0)
+		copy(entry.TransactionID[:], txid)
+		entry.Destinations, err = decodeOutputs(dests)
+		if err != nil {
+			return nil, modules.AddContext(err, "couldn't decode audit log destinations")
+		}
+		entry.Amount = decodeCurrency(amount)
+		entry.Fee = decodeCurrency(fee)
+		copy(entry.PrevHash[:], prev)
+		copy(entry.Hash[:], hash)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// AuditLog returns a page of the audit log of the outgoing wallet
+// transactions, oldest entries first, together with the total number
+// of entries.
+func (w *Wallet) AuditLog(offset, limit uint64) ([]modules.AuditEntry, uint64, error) {
+	var total uint64
+	if err := w.db.QueryRow("SELECT COUNT(*) FROM wt_audit").Scan(&total); err != nil {
+		return nil, 0, modules.AddContext(err, "couldn't count audit log entries")
+	}
+	entries, err := w.auditEntries(offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// auditChain follows the hash chain of the audit log while it is being
+// verified. last is the predecessor hash of the last entry.
+type auditChain struct {
+	last     types.Hash256
+	prev     types.Hash256
+	next     uint64
+	broken   bool
+	brokenAt uint64
+}
+
+// add checks that the entry extends the chain.
+func (ac *auditChain) add(entry modules.AuditEntry) {
+	if !ac.broken && (entry.Index != ac.next || entry.PrevHash != ac.prev || auditHash(entry) != entry.Hash) {
+		ac.broken = true
+		ac.brokenAt = ac.next
+	}
+	ac.last = entry.PrevHash
+	ac.prev = entry.Hash
+	ac.next = entry.Index + 1
+}
+
+// end checks that the chain ends at the tip. This detects entries
+// deleted from the end of the log.
+func (ac *auditChain) end(tip auditTip) {
+	if !ac.broken && (ac.next != tip.Count || ac.prev != tip.Hash) {
+		ac.broken = true
+		ac.brokenAt = ac.next
+	}
+}
+
+// verifyAuditLog checks the hash chain of the audit log against the tip
+// file, and loads the tip. If there is no tip file yet, it is created
+// from the end of the chain. A broken chain is returned as an error, so
+// that the wallet doesn't start on a log that may have been tampered
+// with.
+func (w *Wallet) verifyAuditLog() error {
+	tip, ok, err := loadAuditTip(w.dir)
+	if err != nil {
+		return modules.AddContext(err, "couldn't load audit log tip")
+	}
+
+	const batchSize = 1000
+	var ac auditChain
+	var offset uint64
+	for {
+		entries, err := w.auditEntries(offset, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			ac.add(entry)
+		}
+		offset += uint64(len(entries))
+		if len(entries) < batchSize {
+			break
+		}
+	}
+	if ok && !ac.broken && ac.next == tip.Count+1 && ac.last == tip.Hash {
+		// The wallet stopped between saving the last entry and the tip.
+		tip = auditTip{Count: ac.next, Hash: ac.prev}
+		if err := saveAuditTip(w.dir, tip); err != nil {
+			return modules.AddContext(err, "couldn't save audit log tip")
+		}
+	} else if !ok {
+		w.log.Warn("no audit log tip found, anchoring the audit log at its last entry", zap.Uint64("entries", ac.next))
+		tip = auditTip{Count: ac.next, Hash: ac.prev}
+		if err := saveAuditTip(w.dir, tip); err != nil {
+			return modules.AddContext(err, "couldn't save audit log tip")
+		}
+	}
+	ac.end(tip)
+	if ac.broken {
+		w.log.Error("audit log hash chain is broken, the log may have been tampered with", zap.Uint64("index", ac.brokenAt), zap.Uint64("entries", ac.next), zap.Uint64("expected", tip.Count))
+		return fmt.Errorf("%w at entry %d", errAuditLogBroken, ac.brokenAt)
+	}
+
+	w.auditIndex, w.auditHash = tip.Count, tip.Hash
+	return nil
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+)
+
+func TestAuditTip(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok, err := loadAuditTip(dir); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected no tip before it is saved")
+	}
+
+	tip := auditTip{Count: 42, Hash: types.Hash256{1, 2, 3}}
+	if err := saveAuditTip(dir, tip); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, ok, err := loadAuditTip(dir); err != nil {
+		t.Fatal(err)
+	} else if !ok || loaded != tip {
+		t.Fatalf("expected %+v, got %+v", tip, loaded)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, auditTipFile), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadAuditTip(dir); !errors.Is(err, errInvalidAuditTip) {
+		t.Fatalf("expected %v, got %v", errInvalidAuditTip, err)
+	}
+}
+
+// testAuditEntries returns a valid audit log hash chain of n entries.
+func testAuditEntries(n int) []modules.AuditEntry {
+	var entries []modules.AuditEntry
+	var prev types.Hash256
+	for i := 0; i < n; i++ {
+		entry := modules.AuditEntry{
+			Index:         uint64(i),
+			Timestamp:     time.Unix(int64(i), 0),
+			TransactionID: types.TransactionID{byte(i)},
+			Destinations:  []types.SiacoinOutput{{Value: types.Siacoins(uint32(i)), Address: types.VoidAddress}},
+			Amount:        types.Siacoins(uint32(i)),
+			Fee:           types.Siacoins(1),
+			PrevHash:      prev,
+		}
+		entry.Hash = auditHash(entry)
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditChain(t *testing.T) {
+	entries := testAuditEntries(5)
+	tip := auditTip{Count: 5, Hash: entries[4].Hash}
+	check := func(entries []modules.AuditEntry) auditChain {
+		var ac auditChain
+		for _, entry := range entries {
+			ac.add(entry)
+		}
+		ac.end(tip)
+		return ac
+	}
+
+	if ac := check(entries); ac.broken {
+		t.Fatalf("expected a valid chain, broken at %v", ac.brokenAt)
+	}
+
+	// Entries deleted from the end of the log don't reach the tip.
+	if ac := check(entries[:3]); !ac.broken || ac.brokenAt != 3 {
+		t.Fatalf("expected the chain to be broken at 3, got %v, %v", ac.broken, ac.brokenAt)
+	}
+
+	// A modified entry doesn't match its hash.
+	tampered := append([]modules.AuditEntry(nil), entries...)
+	tampered[2].Amount = types.Siacoins(100)
+	if ac := check(tampered); !ac.broken || ac.brokenAt != 2 {
+		t.Fatalf("expected the chain to be broken at 2, got %v, %v", ac.broken, ac.brokenAt)
+	}
+
+	// A deleted entry leaves a gap.
+	gap := append(append([]modules.AuditEntry(nil), entries[:1]...), entries[2:]...)
+	if ac := check(gap); !ac.broken || ac.brokenAt != 1 {
+		t.Fatalf("expected the chain to be broken at 1, got %v, %v", ac.broken, ac.brokenAt)
+	}
+}
@@ -2,7 +2,9 @@ package wallet
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/mike76-dev/sia-satellite/modules"
 	"go.sia.tech/core/types"
@@ -93,10 +95,134 @@ func (w *Wallet) updateTip(ci types.ChainIndex) error {
 	return nil
 }
 
+// insertEvent inserts the given event into the event log.
+func (w *Wallet) insertEvent(event Event) error {
+	relevant, err := json.Marshal(event.Relevant)
+	if err != nil {
+		return modules.AddContext(err, "couldn't encode relevant addresses")
+	}
+	data, err := json.Marshal(event.Val)
+	if err != nil {
+		return modules.AddContext(err, "couldn't encode event")
+	}
+	id := event.ID()
+	_, err = w.tx.Exec(`
+		INSERT INTO wt_events (
+			event_id,
+			height,
+			bid,
+			timestamp,
+			event_type,
+			relevant,
+			data
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		id[:],
+		event.Index.Height,
+		event.Index.ID[:],
+		event.Timestamp.Unix(),
+		event.Val.EventType(),
+		relevant,
+		data,
+	)
+	if err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't insert event")
+	}
+	w.eventCounts[event.Val.EventType()]++
+
+	return nil
+}
+
+// deleteEvent deletes the given event from the event log.
+func (w *Wallet) deleteEvent(event Event) error {
+	id := event.ID()
+	res, err := w.tx.Exec("DELETE FROM wt_events WHERE event_id = ?", id[:])
+	if err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't delete event")
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		w.eventCounts[event.Val.EventType()] -= int(n)
+	}
+
+	return nil
+}
+
+// loadEventCounts loads the number of recorded events per type.
+func (w *Wallet) loadEventCounts() error {
+	rows, err := w.db.Query("SELECT event_type, COUNT(*) FROM wt_events GROUP BY event_type")
+	if err != nil {
+		return modules.AddContext(err, "couldn't count events")
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var eventType string
+		var count int
+		if err := rows.Scan(&eventType, &count); err != nil {
+			return modules.AddContext(err, "couldn't scan event count")
+		}
+		counts[eventType] = count
+	}
+	w.eventCounts = counts
+
+	return rows.Err()
+}
+
+// EventCounts returns the number of recorded events per type.
+func (w *Wallet) EventCounts() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	counts := make(map[string]int, len(w.eventCounts))
+	for eventType, count := range w.eventCounts {
+		counts[eventType] = count
+	}
+	return counts
+}
+
+// scanEvent scans a row of the event log.
+func scanEvent(row interface{ Scan(...any) error }) (event modules.WalletEvent, err error) {
+	var timestamp uint64
+	var relevant, data []byte
+	id := make([]byte, 32)
+	bid := make([]byte, 32)
+	if err := row.Scan(&id, &event.Index.Height, &bid, &timestamp, &event.Type, &relevant, &data); err != nil {
+		return modules.WalletEvent{}, err
+	}
+	copy(event.ID[:], id)
+	copy(event.Index.ID[:], bid)
+	event.Timestamp = time.Unix(int64(timestamp), 0)
+	event.Data = data
+	if err := json.Unmarshal(relevant, &event.Relevant); err != nil {
+		return modules.WalletEvent{}, modules.AddContext(err, "couldn't decode relevant addresses")
+	}
+	return event, nil
+}
+
+// TransactionEvent returns the event of the confirmed transaction with
+// the given ID, if the transaction is relevant to the wallet.
+func (w *Wallet) TransactionEvent(id types.TransactionID) (modules.WalletEvent, bool, error) {
+	event, err := scanEvent(w.db.QueryRow(`
+		SELECT event_id, height, bid, timestamp, event_type, relevant, data
+		FROM wt_events
+		WHERE event_id = ? AND event_type = ?
+	`, id[:], EventTypeTransaction))
+	if errors.Is(err, sql.ErrNoRows) {
+		return modules.WalletEvent{}, false, nil
+	} else if err != nil {
+		return modules.WalletEvent{}, false, modules.AddContext(err, "couldn't retrieve event")
+	}
+	return event, true, nil
+}
+
 // insertSiacoinElement inserts the given Siacoin element.
-func (w *Wallet) insertSiacoinElement(sce types.SiacoinElement) error {
+func (w *Wallet) insertSiacoinElement(sce types.SiacoinElement, height uint64) error {
 	sce.MerkleProof = append([]types.Hash256(nil), sce.MerkleProof...)
 	w.sces[sce.SiacoinOutput.Address] = sce
+	w.sceHeights[sce.SiacoinOutput.Address] = height
 	_, err := w.tx.Exec(`
 		INSERT INTO wt_sces (
 			scoid,
@@ -104,9 +230,10 @@ func (w *Wallet) insertSiacoinElement(sce types.SiacoinElement) error {
 			merkle_proof,
 			leaf_index,
 			maturity_height,
+			height,
 			address_id
 		)
-		VALUES (?, ?, ?, ?, ?, (
+		VALUES (?, ?, ?, ?, ?, ?, (
 			SELECT id FROM wt_addresses
 			WHERE addr = ?
 		))
@@ -116,6 +243,7 @@ func (w *Wallet) insertSiacoinElement(sce types.SiacoinElement) error {
 		encodeProof(sce.MerkleProof),
 		sce.LeafIndex,
 		sce.MaturityHeight,
+		height,
 		sce.SiacoinOutput.Address[:],
 	)
 	if err != nil {
@@ -128,6 +256,7 @@ func (w *Wallet) insertSiacoinElement(sce types.SiacoinElement) error {
 // deleteSiacoinElement deletes the Siacoin element with the given ID.
 func (w *Wallet) deleteSiacoinElement(addr types.Address) error {
 	delete(w.sces, addr)
+	delete(w.sceHeights, addr)
 	_, err := w.tx.Exec(`
 		DELETE FROM wt_sces
 		WHERE address_id IN (
@@ -241,23 +370,78 @@ func (w *Wallet) updateSiafundElementProofs(cu chainUpdate) error {
 	return nil
 }
 
+// addColumn adds the column to the table, unless the table has it
+// already. It is used for upgrading the databases created before the
+// column was introduced.
+func (w *Wallet) addColumn(table, column, definition string) error {
+	var count int
+	if err := w.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		AND table_name = ?
+		AND column_name = ?
+	`, table, column).Scan(&count); err != nil {
+		return modules.AddContext(err, "couldn't check column "+table+"."+column)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := w.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return modules.AddContext(err, "couldn't add column "+table+"."+column)
+}
+
+// upgradeSchema adds the columns that the older databases lack.
+func (w *Wallet) upgradeSchema() error {
+	if err := w.addColumn("wt_sces", "height", "BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER maturity_height"); err != nil {
+		return err
+	}
+	if err := w.addColumn("wt_info", "max_ancestors", "INT"); err != nil {
+		return err
+	}
+	return w.addColumn("wt_info", "confirmations", "BIGINT UNSIGNED")
+}
+
 // load loads the wallet data from the database.
 func (w *Wallet) load() (err error) {
+	if err := w.upgradeSchema(); err != nil {
+		return err
+	}
+
 	s := make([]byte, 32)
 	var progress uint64
+	var maxAncestors, confirmations sql.NullInt64
 	if err := w.db.QueryRow(`
-		SELECT seed, progress
+		SELECT seed, progress, max_ancestors, confirmations
 		FROM wt_info
 		WHERE id = 1
-	`).Scan(&s, &progress); err != nil && !errors.Is(err, sql.ErrNoRows) {
+	`).Scan(&s, &progress, &maxAncestors, &confirmations); err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return modules.AddContext(err, "couldn't load seed")
 	}
 	copy(w.seed[:], s)
+	if maxAncestors.Valid {
+		w.maxAncestorDepth = int(maxAncestors.Int64)
+	}
+	if confirmations.Valid {
+		w.requiredConfirmations = uint64(confirmations.Int64)
+	}
 	for _, key := range generateKeys(w.seed, 0, progress) {
 		w.keys[types.StandardUnlockHash(key.PublicKey())] = key
 	}
 	w.regenerateLookahead(progress)
 
+	if err := w.loadImportedKeys(); err != nil {
+		return err
+	}
+
+	if err := w.loadMultisig(); err != nil {
+		return err
+	}
+
+	if err := w.loadEventCounts(); err != nil {
+		return err
+	}
+
 	b := make([]byte, 32)
 	if err := w.db.QueryRow(`
 		SELECT height, bid
@@ -319,6 +503,7 @@ func (w *Wallet) load() (err error) {
 			wt_sces.merkle_proof,
 			wt_sces.leaf_index,
 			wt_sces.maturity_height,
+			wt_sces.height,
 			wt_addresses.addr
 		FROM wt_sces
 		INNER JOIN wt_addresses
@@ -332,8 +517,8 @@ func (w *Wallet) load() (err error) {
 		id := make([]byte, 32)
 		addr := make([]byte, 32)
 		var v, proof []byte
-		var li, mh uint64
-		if err = rows.Scan(&id, &v, &proof, &li, &mh, &addr); err != nil {
+		var li, mh, height uint64
+		if err = rows.Scan(&id, &v, &proof, &li, &mh, &height, &addr); err != nil {
 			return modules.AddContext(err, "couldn't scan SC element")
 		}
 		sce := types.SiacoinElement{
@@ -349,6 +534,7 @@ func (w *Wallet) load() (err error) {
 		copy(sce.ID[:], id)
 		copy(sce.SiacoinOutput.Address[:], addr)
 		w.sces[sce.SiacoinOutput.Address] = sce
+		w.sceHeights[sce.SiacoinOutput.Address] = height
 	}
 
 	rows.Close()
@@ -409,6 +595,10 @@ func (w *Wallet) save() (err error) {
 			return err
 		}
 		w.dbError = false
+		// The counters may include the rolled back events.
+		if err := w.loadEventCounts(); err != nil {
+			return err
+		}
 	} else {
 		err = w.tx.Commit()
 		if err != nil {
@@ -439,6 +629,12 @@ func (w *Wallet) reset() (err error) {
 		w.dbError = true
 		return modules.AddContext(err, "couldn't drop watched addresses")
 	}
+	_, err = w.tx.Exec("DROP TABLE wt_events")
+	if err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't drop events")
+	}
+	w.eventCounts = make(map[string]int)
 	_, err = w.tx.Exec("DROP TABLE wt_addresses")
 	if err != nil {
 		w.dbError = true
@@ -465,6 +661,7 @@ func (w *Wallet) reset() (err error) {
 			merkle_proof    BLOB NOT NULL,
 			leaf_index      BIGINT UNSIGNED NOT NULL,
 			maturity_height BIGINT UNSIGNED NOT NULL,
+			height          BIGINT UNSIGNED NOT NULL DEFAULT 0,
 			address_id      BIGINT NOT NULL,
 			PRIMARY KEY (id),
 			FOREIGN KEY (address_id) REFERENCES wt_addresses(id)
@@ -504,6 +701,25 @@ func (w *Wallet) reset() (err error) {
 		return modules.AddContext(err, "couldn't create watched addresses")
 	}
 
+	_, err = w.tx.Exec(`
+		CREATE TABLE wt_events (
+			id         BIGINT NOT NULL AUTO_INCREMENT,
+			event_id   BINARY(32) NOT NULL,
+			height     BIGINT UNSIGNED NOT NULL,
+			bid        BINARY(32) NOT NULL,
+			timestamp  BIGINT UNSIGNED NOT NULL,
+			event_type VARCHAR(32) NOT NULL,
+			relevant   MEDIUMBLOB NOT NULL,
+			data       MEDIUMBLOB NOT NULL,
+			PRIMARY KEY (id),
+			INDEX (event_id)
+		)
+	`)
+	if err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't create events")
+	}
+
 	if err := w.updateTip(w.tip); err != nil {
 		return err
 	}
@@ -514,8 +730,9 @@ func (w *Wallet) reset() (err error) {
 // saveSeed saves the new seed.
 func (w *Wallet) saveSeed(progress uint64) error {
 	_, err := w.tx.Exec(`
-		REPLACE INTO wt_info (id, seed, progress)
+		INSERT INTO wt_info (id, seed, progress)
 		VALUES (1, ?, ?)
+		ON DUPLICATE KEY UPDATE seed = VALUES(seed), progress = VALUES(progress)
 	`, w.seed[:], progress)
 	if err != nil {
 		w.dbError = true
@@ -131,12 +131,12 @@ func (w *Wallet) managedCreateDefragTransaction() (_ []types.Transaction, err er
 
 	// Mark all outputs that were spent as spent.
 	for _, scoid := range spentScoids {
-		w.used[types.Hash256(scoid)] = true
+		w.used[types.Hash256(scoid)] = w.tip.Height
 	}
 
 	// Mark the parent output as spent. Must be done after the transaction is
 	// finished because otherwise the txid and output id will change.
-	w.used[types.Hash256(parentTxn.SiacoinOutputID(0))] = true
+	w.used[types.Hash256(parentTxn.SiacoinOutputID(0))] = w.tip.Height
 
 	// Construct the final transaction set.
 	return []types.Transaction{parentTxn, txn}, nil
@@ -153,6 +153,8 @@ func (w *Wallet) threadedDefragWallet() {
 	}
 	defer w.tg.Done()
 
+	log := w.log.With(zap.String("op", newOperationID()))
+
 	// Create the defrag transaction.
 	txnSet, err := w.managedCreateDefragTransaction()
 	defer func() {
@@ -170,17 +172,21 @@ func (w *Wallet) threadedDefragWallet() {
 	if modules.ContainsError(err, errDefragNotNeeded) {
 		return
 	} else if err != nil {
-		w.log.Warn("couldn't create defrag transaction", zap.Error(err))
+		log.Warn("couldn't create defrag transaction", zap.Error(err))
 		return
 	}
+	log = log.With(zap.Stringer("txid", txnSet[len(txnSet)-1].ID()))
 
 	// Submit the defrag to the transaction pool.
 	_, err = w.cm.AddPoolTransactions(txnSet)
 	if err != nil {
 		w.Release(txnSet)
-		w.log.Error("invalid transaction set", zap.Error(err))
+		log.Error("invalid transaction set", zap.Error(err))
 		return
 	}
 	w.s.BroadcastTransactionSet(txnSet)
-	w.log.Info("submitting a transaction set to defragment the wallet's outputs")
+	log.Info("submitting a transaction set to defragment the wallet's outputs")
+	if err := w.recordAudit(txnSet[len(txnSet)-1]); err != nil {
+		log.Error("failed to record audit log entry", zap.Error(err))
+	}
 }
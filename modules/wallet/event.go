@@ -29,6 +29,7 @@ func Annotate(txn types.Transaction, ownsAddress func(types.Address) bool) modul
 	}
 	for _, fee := range txn.MinerFees {
 		totalValue = totalValue.Add(fee)
+		ptxn.Fee = ptxn.Fee.Add(fee)
 	}
 
 	var ownedIn, ownedOut int
@@ -126,6 +127,21 @@ func (*EventMinerPayout) EventType() string { return EventTypeMinerPayout }
 // EventType implements Event.
 func (*EventMissedFileContract) EventType() string { return EventTypeMissedFileContract }
 
+// ID returns the ID of the object the event refers to: the transaction
+// ID, the ID of the miner payout output, or the ID of the missed
+// contract.
+func (e *Event) ID() types.Hash256 {
+	switch v := e.Val.(type) {
+	case *EventTransaction:
+		return types.Hash256(v.ID)
+	case *EventMinerPayout:
+		return types.Hash256(v.SiacoinOutput.ID)
+	case *EventMissedFileContract:
+		return types.Hash256(v.FileContract.ID)
+	}
+	return types.Hash256{}
+}
+
 // String implements fmt.Stringer.
 func (e *Event) String() string {
 	return fmt.Sprintf("%s at %s: %s", e.Val.EventType(), e.Timestamp, e.Val)
@@ -137,6 +153,30 @@ type HostAnnouncement struct {
 	NetAddress string          `json:"netAddress"`
 }
 
+// ScanV2HostAnnouncements returns the v2 host announcements found within
+// the given block. Only the attestations carrying a valid signature of the
+// announced public key are returned.
+func ScanV2HostAnnouncements(b types.Block) (announcements []HostAnnouncement) {
+	// The attestation signature hash does not depend on the actual state,
+	// so a zero state can be used here.
+	var cs consensus.State
+	for _, txn := range b.V2Transactions() {
+		for _, a := range txn.Attestations {
+			if a.Key != "HostAnnouncement" {
+				continue
+			}
+			if !a.PublicKey.VerifyHash(cs.AttestationSigHash(a), a.Signature) {
+				continue
+			}
+			announcements = append(announcements, HostAnnouncement{
+				PublicKey:  a.PublicKey,
+				NetAddress: string(a.Value),
+			})
+		}
+	}
+	return
+}
+
 // A SiafundInput represents a siafund input within an EventTransaction.
 type SiafundInput struct {
 	SiafundElement types.SiafundElement `json:"siafundElement"`
@@ -285,6 +325,9 @@ func AppliedEvents(cs consensus.State, b types.Block, cu ChainUpdate, relevant f
 			return relevantV2Contract(types.V2FileContract(*r))
 		case *types.V2FileContractRenewal:
 			return relevantV2Contract(r.FinalRevision)
+		case *types.V2StorageProof, *types.V2FileContractExpiration:
+			// The outputs are paid to the addresses of the resolved
+			// contract, which is checked separately.
 		}
 		return
 	}
@@ -408,6 +451,8 @@ func AppliedEvents(cs consensus.State, b types.Block, cu ChainUpdate, relevant f
 				addrs = append(addrs, relevantV2Contract(types.V2FileContract(*r))...)
 			case *types.V2FileContractRenewal:
 				addrs = append(addrs, relevantV2Contract(r.FinalRevision)...)
+			case *types.V2StorageProof, *types.V2FileContractExpiration:
+				// Already covered by the parent contract.
 			}
 		}
 		return
@@ -540,6 +585,10 @@ func AppliedEvents(cs consensus.State, b types.Block, cu ChainUpdate, relevant f
 			fc.Revision = &fcr.Revision
 		}
 		for _, fcr := range txn.FileContractResolutions {
+			// All resolution types (finalization, renewal, storage proof,
+			// and expiration) create the renter and the host outputs. In
+			// case of an expiration, the host output carries the missed
+			// host value.
 			fc := addContract(types.FileContractID(fcr.Parent.ID))
 			fc.Resolution = fcr.Resolution
 			fc.Outputs = []types.SiacoinElement{
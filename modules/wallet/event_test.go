@@ -0,0 +1,386 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+// testFileContractUpdate is a v1 contract element of a testChainUpdate.
+type testFileContractUpdate struct {
+	fce      types.FileContractElement
+	rev      *types.FileContractElement
+	resolved bool
+	valid    bool
+}
+
+// testV2FileContractUpdate is a v2 contract element of a testChainUpdate.
+type testV2FileContractUpdate struct {
+	fce types.V2FileContractElement
+	rev *types.V2FileContractElement
+	res types.V2FileContractResolutionType
+}
+
+// testChainUpdate is a ChainUpdate replaying an arbitrary sequence of
+// elements, so that the events can be tested without a chain.
+type testChainUpdate struct {
+	sces   []types.SiacoinElement
+	spent  map[types.Hash256]bool
+	sfes   []types.SiafundElement
+	fces   []testFileContractUpdate
+	v2fces []testV2FileContractUpdate
+}
+
+// ForEachSiacoinElement implements ChainUpdate.
+func (cu *testChainUpdate) ForEachSiacoinElement(fn func(sce types.SiacoinElement, spent bool)) {
+	for _, sce := range cu.sces {
+		fn(sce, cu.spent[sce.ID])
+	}
+}
+
+// ForEachSiafundElement implements ChainUpdate.
+func (cu *testChainUpdate) ForEachSiafundElement(fn func(sfe types.SiafundElement, spent bool)) {
+	for _, sfe := range cu.sfes {
+		fn(sfe, cu.spent[sfe.ID])
+	}
+}
+
+// ForEachFileContractElement implements ChainUpdate.
+func (cu *testChainUpdate) ForEachFileContractElement(fn func(fce types.FileContractElement, rev *types.FileContractElement, resolved, valid bool)) {
+	for _, u := range cu.fces {
+		fn(u.fce, u.rev, u.resolved, u.valid)
+	}
+}
+
+// ForEachV2FileContractElement implements ChainUpdate.
+func (cu *testChainUpdate) ForEachV2FileContractElement(fn func(fce types.V2FileContractElement, rev *types.V2FileContractElement, res types.V2FileContractResolutionType)) {
+	for _, u := range cu.v2fces {
+		fn(u.fce, u.rev, u.res)
+	}
+}
+
+// testBlock is a block together with its update.
+type testBlock struct {
+	index types.ChainIndex
+	b     types.Block
+	cu    *testChainUpdate
+}
+
+// testEventLog mimics the event log of the wallet: the events are
+// inserted on apply and deleted by their ID on revert.
+type testEventLog struct {
+	relevant func(types.Address) bool
+	events   map[types.Hash256]Event
+}
+
+func newTestEventLog(addrs ...types.Address) *testEventLog {
+	owned := make(map[types.Address]bool)
+	for _, addr := range addrs {
+		owned[addr] = true
+	}
+	return &testEventLog{
+		relevant: func(addr types.Address) bool { return owned[addr] },
+		events:   make(map[types.Hash256]Event),
+	}
+}
+
+func (l *testEventLog) apply(tb testBlock) {
+	cs := consensus.State{Index: tb.index}
+	for _, e := range AppliedEvents(cs, tb.b, tb.cu, l.relevant) {
+		l.events[e.ID()] = e
+	}
+}
+
+func (l *testEventLog) revert(tb testBlock) {
+	// A revert update carries the parent state.
+	cs := consensus.State{Index: types.ChainIndex{Height: tb.index.Height - 1, ID: tb.b.ParentID}}
+	for _, e := range AppliedEvents(cs, tb.b, tb.cu, l.relevant) {
+		delete(l.events, e.ID())
+	}
+}
+
+// v2Block returns a v2 block containing the given transactions.
+func v2Block(height uint64, parentID types.BlockID, txns ...types.V2Transaction) testBlock {
+	b := types.Block{
+		ParentID:  parentID,
+		Timestamp: time.Unix(1700000000+int64(height), 0),
+		V2: &types.V2BlockData{
+			Height:       height,
+			Transactions: txns,
+		},
+	}
+	return testBlock{
+		index: types.ChainIndex{Height: height, ID: b.ID()},
+		b:     b,
+		cu:    &testChainUpdate{spent: make(map[types.Hash256]bool)},
+	}
+}
+
+// testV2Contract returns a v2 contract element paying out to the given
+// renter and host addresses.
+func testV2Contract(renter, host types.Address) types.V2FileContractElement {
+	return types.V2FileContractElement{
+		StateElement: types.StateElement{ID: frand.Entropy256()},
+		V2FileContract: types.V2FileContract{
+			ProofHeight:      100,
+			ExpirationHeight: 110,
+			RenterOutput:     types.SiacoinOutput{Address: renter, Value: types.Siacoins(10)},
+			HostOutput:       types.SiacoinOutput{Address: host, Value: types.Siacoins(20)},
+			MissedHostValue:  types.Siacoins(15),
+		},
+	}
+}
+
+// resolve adds the resolution of the contract to the block.
+func (tb *testBlock) resolve(fce types.V2FileContractElement, res types.V2FileContractResolutionType) {
+	tb.b.V2.Transactions = append(tb.b.V2.Transactions, types.V2Transaction{
+		FileContractResolutions: []types.V2FileContractResolution{{
+			Parent:     fce,
+			Resolution: res,
+		}},
+	})
+	tb.cu.v2fces = append(tb.cu.v2fces, testV2FileContractUpdate{fce: fce, res: res})
+	id := types.FileContractID(fce.ID)
+	tb.cu.sces = append(tb.cu.sces,
+		types.SiacoinElement{
+			StateElement:  types.StateElement{ID: types.Hash256(id.V2RenterOutputID())},
+			SiacoinOutput: fce.V2FileContract.RenterOutput,
+		},
+		types.SiacoinElement{
+			StateElement:  types.StateElement{ID: types.Hash256(id.V2HostOutputID())},
+			SiacoinOutput: fce.V2FileContract.HostOutput,
+		},
+	)
+	tb.index.ID = tb.b.ID()
+}
+
+func TestAppliedEventsReorg(t *testing.T) {
+	renter, host := types.Address(frand.Entropy256()), types.Address(frand.Entropy256())
+	log := newTestEventLog(renter)
+
+	// Renew a contract of the wallet.
+	fce := testV2Contract(renter, host)
+	renewal := &types.V2FileContractRenewal{
+		FinalRevision: fce.V2FileContract,
+		NewContract:   fce.V2FileContract,
+	}
+	renewal.NewContract.RevisionNumber = 0
+	renewal.NewContract.ExpirationHeight += 100
+	parentID := types.BlockID(frand.Entropy256())
+	a := v2Block(50, parentID)
+	a.resolve(fce, renewal)
+	a.cu.v2fces = append(a.cu.v2fces, testV2FileContractUpdate{
+		fce: types.V2FileContractElement{
+			StateElement:   types.StateElement{ID: types.Hash256(types.FileContractID(fce.ID).V2RenewalID())},
+			V2FileContract: renewal.NewContract,
+		},
+	})
+
+	log.apply(a)
+	if len(log.events) != 1 {
+		t.Fatalf("expected 1 event, got %v", len(log.events))
+	}
+	txid := a.b.V2.Transactions[0].ID()
+	e, ok := log.events[types.Hash256(txid)]
+	if !ok {
+		t.Fatal("renewal event missing")
+	} else if e.Index != a.index {
+		t.Fatalf("expected event at %v, got %v", a.index, e.Index)
+	}
+	et := e.Val.(*EventTransaction)
+	if len(et.V2FileContracts) != 1 {
+		t.Fatalf("expected 1 contract, got %v", len(et.V2FileContracts))
+	}
+	fc := et.V2FileContracts[0]
+	if _, ok := fc.Resolution.(*types.V2FileContractRenewal); !ok {
+		t.Fatalf("expected a renewal, got %T", fc.Resolution)
+	} else if len(fc.Outputs) != 2 || fc.Outputs[0].SiacoinOutput.Address != renter {
+		t.Fatal("renewal outputs missing")
+	}
+
+	// An unrelated block on top doesn't change anything.
+	c := v2Block(51, a.index.ID)
+	c.resolve(testV2Contract(types.VoidAddress, host), &types.V2FileContractExpiration{})
+	log.apply(c)
+	if len(log.events) != 1 {
+		t.Fatalf("expected 1 event, got %v", len(log.events))
+	}
+
+	// Reorg to a fork which renews the contract one block later.
+	log.revert(c)
+	log.revert(a)
+	if len(log.events) != 0 {
+		t.Fatalf("expected the reverted events to be retracted, got %v", len(log.events))
+	}
+	b1 := v2Block(50, parentID)
+	b2 := v2Block(51, b1.index.ID)
+	b2.resolve(fce, renewal)
+	log.apply(b1)
+	if len(log.events) != 0 {
+		t.Fatalf("expected no events, got %v", len(log.events))
+	}
+	log.apply(b2)
+	if len(log.events) != 1 {
+		t.Fatalf("expected 1 event, got %v", len(log.events))
+	}
+	if e := log.events[types.Hash256(txid)]; e.Index != b2.index {
+		t.Fatalf("expected the renewal at %v, got %v", b2.index, e.Index)
+	}
+
+	// Reorging back retracts the renewal again.
+	log.revert(b2)
+	log.revert(b1)
+	log.apply(a)
+	if e := log.events[types.Hash256(txid)]; len(log.events) != 1 || e.Index != a.index {
+		t.Fatal("expected the renewal to be back at", a.index)
+	}
+}
+
+func TestAppliedEventsMissedContract(t *testing.T) {
+	renter, host := types.Address(frand.Entropy256()), types.Address(frand.Entropy256())
+	log := newTestEventLog(renter)
+
+	fce := types.FileContractElement{
+		StateElement: types.StateElement{ID: frand.Entropy256()},
+		FileContract: types.FileContract{
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: renter, Value: types.Siacoins(10)},
+				{Address: host, Value: types.Siacoins(20)},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Address: renter, Value: types.Siacoins(10)},
+				{Address: host, Value: types.Siacoins(5)},
+			},
+		},
+	}
+	tb := v2Block(100, types.BlockID(frand.Entropy256()))
+	tb.cu.fces = append(tb.cu.fces, testFileContractUpdate{fce: fce, resolved: true})
+	for i, sco := range fce.FileContract.MissedProofOutputs {
+		tb.cu.sces = append(tb.cu.sces, types.SiacoinElement{
+			StateElement:  types.StateElement{ID: types.Hash256(types.FileContractID(fce.ID).MissedOutputID(i))},
+			SiacoinOutput: sco,
+		})
+	}
+
+	log.apply(tb)
+	e, ok := log.events[fce.ID]
+	if !ok {
+		t.Fatal("missed contract event missing")
+	}
+	emfc, ok := e.Val.(*EventMissedFileContract)
+	if !ok {
+		t.Fatalf("expected a missed contract event, got %T", e.Val)
+	} else if len(emfc.MissedOutputs) != 2 || emfc.MissedOutputs[1].SiacoinOutput.Value != types.Siacoins(5) {
+		t.Fatal("missed outputs not populated")
+	}
+
+	log.revert(tb)
+	if len(log.events) != 0 {
+		t.Fatal("expected the missed contract event to be retracted")
+	}
+
+	// A contract resolved with a valid proof produces no such event.
+	tb.cu.fces[0].valid = true
+	log.apply(tb)
+	if len(log.events) != 0 {
+		t.Fatal("expected no event for a valid contract")
+	}
+}
+
+func TestScanV2HostAnnouncements(t *testing.T) {
+	var cs consensus.State
+	sk := types.GeneratePrivateKey()
+	announce := func(key string, value string, signer types.PrivateKey) types.Attestation {
+		a := types.Attestation{
+			PublicKey: sk.PublicKey(),
+			Key:       key,
+			Value:     []byte(value),
+		}
+		a.Signature = signer.SignHash(cs.AttestationSigHash(a))
+		return a
+	}
+
+	valid := announce("HostAnnouncement", "host.example.com:9982", sk)
+	tampered := announce("HostAnnouncement", "host.example.com:9982", sk)
+	tampered.Value = []byte("evil.example.com:9982")
+	wrongKey := announce("HostAnnouncement", "other.example.com:9982", types.GeneratePrivateKey())
+	unsigned := types.Attestation{
+		PublicKey: sk.PublicKey(),
+		Key:       "HostAnnouncement",
+		Value:     []byte("unsigned.example.com:9982"),
+	}
+	other := announce("Foo", "bar", sk)
+
+	b := types.Block{
+		V2: &types.V2BlockData{
+			Transactions: []types.V2Transaction{
+				{Attestations: []types.Attestation{tampered, valid}},
+				{Attestations: []types.Attestation{wrongKey, unsigned, other}},
+			},
+		},
+	}
+	announcements := ScanV2HostAnnouncements(b)
+	if len(announcements) != 1 {
+		t.Fatalf("expected 1 announcement, got %v", len(announcements))
+	} else if announcements[0].PublicKey != sk.PublicKey() || announcements[0].NetAddress != "host.example.com:9982" {
+		t.Fatal("wrong announcement:", announcements[0])
+	}
+
+	// A v1 block has no v2 announcements.
+	if announcements := ScanV2HostAnnouncements(types.Block{}); len(announcements) != 0 {
+		t.Fatal("expected no announcements in a v1 block")
+	}
+}
+
+func TestAppliedEventsV2Resolutions(t *testing.T) {
+	renter, host := types.Address(frand.Entropy256()), types.Address(frand.Entropy256())
+	tests := []struct {
+		name string
+		res  func(fce types.V2FileContractElement) types.V2FileContractResolutionType
+	}{
+		{"finalization", func(fce types.V2FileContractElement) types.V2FileContractResolutionType {
+			fc := types.V2FileContractFinalization(fce.V2FileContract)
+			fc.RevisionNumber = types.MaxRevisionNumber
+			return &fc
+		}},
+		{"renewal", func(fce types.V2FileContractElement) types.V2FileContractResolutionType {
+			return &types.V2FileContractRenewal{FinalRevision: fce.V2FileContract, NewContract: fce.V2FileContract}
+		}},
+		{"storage proof", func(fce types.V2FileContractElement) types.V2FileContractResolutionType {
+			return &types.V2StorageProof{}
+		}},
+		{"expiration", func(fce types.V2FileContractElement) types.V2FileContractResolutionType {
+			return &types.V2FileContractExpiration{}
+		}},
+	}
+	for _, test := range tests {
+		log := newTestEventLog(renter)
+		fce := testV2Contract(renter, host)
+		tb := v2Block(200, types.BlockID(frand.Entropy256()))
+		res := test.res(fce)
+		tb.resolve(fce, res)
+
+		log.apply(tb)
+		e, ok := log.events[types.Hash256(tb.b.V2.Transactions[0].ID())]
+		if !ok {
+			t.Fatalf("%v: event missing", test.name)
+		}
+		et := e.Val.(*EventTransaction)
+		if len(et.V2FileContracts) != 1 {
+			t.Fatalf("%v: expected 1 contract, got %v", test.name, len(et.V2FileContracts))
+		}
+		fc := et.V2FileContracts[0]
+		if fc.Resolution != res {
+			t.Fatalf("%v: expected resolution %T, got %T", test.name, res, fc.Resolution)
+		} else if len(fc.Outputs) != 2 {
+			t.Fatalf("%v: expected 2 outputs, got %v", test.name, len(fc.Outputs))
+		} else if fc.Outputs[0].SiacoinOutput.Address != renter || fc.Outputs[1].SiacoinOutput.Address != host {
+			t.Fatalf("%v: wrong outputs", test.name)
+		} else if types.FileContractID(fc.FileContract.ID) != types.FileContractID(fce.ID) {
+			t.Fatalf("%v: wrong contract", test.name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package wallet
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// ExportEvents calls fn for each event of the event log, oldest first.
+// The iteration stops at the first error returned by fn.
+func (w *Wallet) ExportEvents(fn func(modules.WalletEvent) error) error {
+	rows, err := w.db.Query(`
+		SELECT event_id, height, bid, timestamp, event_type, relevant, data
+		FROM wt_events
+		ORDER BY height ASC, id ASC
+	`)
+	if err != nil {
+		return modules.AddContext(err, "couldn't query events")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return modules.AddContext(err, "couldn't scan event")
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ImportEvents adds previously exported events to the event log. Each
+// event is checked against its block: the block must be part of the
+// best chain and already processed by the wallet, and the wallet must
+// derive the same event from it. The derived event is stored, not the
+// imported data, so a crafted file can't plant a fake history. Invalid
+// events and the ones already present are skipped. Only the event
+// history is restored this way: the balances are computed from the
+// tracked outputs, which the wallet keeps up to date itself.
+func (w *Wallet) ImportEvents(events []modules.WalletEvent) (imported, skipped uint64, err error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, 0, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	derived := make(map[types.ChainIndex][]Event)
+	for _, event := range events {
+		if _, ok := derived[event.Index]; !ok {
+			de, err := w.blockEvents(event.Index)
+			if err != nil {
+				w.log.Warn("couldn't derive block events", zap.Stringer("index", event.Index), zap.Error(err))
+			}
+			derived[event.Index] = de
+		}
+		e, ok := matchEvent(derived[event.Index], event)
+		if !ok {
+			skipped++
+			continue
+		}
+		var exists bool
+		err := w.tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM wt_events
+				WHERE event_id = ? AND bid = ?
+			)
+		`, event.ID[:], event.Index.ID[:]).Scan(&exists)
+		if err != nil {
+			w.dbError = true
+			return 0, 0, modules.ComposeErrors(modules.AddContext(err, "couldn't check event"), w.save())
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		if err := w.insertEvent(e); err != nil {
+			return 0, 0, modules.ComposeErrors(modules.AddContext(err, "couldn't import event"), w.save())
+		}
+		imported++
+	}
+
+	if err := w.save(); err != nil {
+		return 0, 0, modules.AddContext(err, "couldn't save imported events")
+	}
+	w.log.Info("imported events", zap.Uint64("imported", imported), zap.Uint64("skipped", skipped))
+
+	return imported, skipped, nil
+}
+
+// blockEvents derives the wallet events of the block at the given
+// index. It returns nil if the block is not part of the best chain or
+// hasn't been processed by the wallet yet. w.mu must be held.
+func (w *Wallet) blockEvents(index types.ChainIndex) ([]Event, error) {
+	if index.Height > w.tip.Height {
+		return nil, nil
+	}
+	if best, ok := w.cm.BestIndex(index.Height); !ok || best != index {
+		return nil, nil
+	}
+	var parent types.ChainIndex
+	if index.Height > 0 {
+		var ok bool
+		if parent, ok = w.cm.BestIndex(index.Height - 1); !ok {
+			return nil, nil
+		}
+	}
+	_, caus, err := w.cm.UpdatesSince(parent, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, cau := range caus {
+		if cau.State.Index == index {
+			return AppliedEvents(cau.State, cau.Block, cau, w.ownsAddress), nil
+		}
+	}
+	return nil, nil
+}
+
+// matchEvent returns the event of the list that has the same ID, type
+// and data as the imported one.
+func matchEvent(events []Event, event modules.WalletEvent) (Event, bool) {
+	for _, e := range events {
+		if e.ID() != event.ID || e.Val.EventType() != event.Type {
+			continue
+		}
+		data, err := json.Marshal(e.Val)
+		if err != nil || !jsonEqual(data, event.Data) {
+			return Event{}, false
+		}
+		return e, true
+	}
+	return Event{}, false
+}
+
+// jsonEqual returns true if both JSON documents encode the same value.
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
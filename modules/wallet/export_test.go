@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+func TestMatchEvent(t *testing.T) {
+	addr := types.Address(frand.Entropy256())
+	e := Event{
+		Index:    types.ChainIndex{Height: 10, ID: frand.Entropy256()},
+		Relevant: []types.Address{addr},
+		Val: &EventMinerPayout{
+			SiacoinOutput: types.SiacoinElement{
+				StateElement:  types.StateElement{ID: frand.Entropy256()},
+				SiacoinOutput: types.SiacoinOutput{Address: addr, Value: types.Siacoins(300)},
+			},
+		},
+	}
+	data, err := json.Marshal(e.Val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := modules.WalletEvent{
+		ID:    e.ID(),
+		Index: e.Index,
+		Type:  EventTypeMinerPayout,
+		Data:  data,
+	}
+
+	if _, ok := matchEvent([]Event{e}, event); !ok {
+		t.Fatal("expected the event to match")
+	}
+
+	// A different type doesn't match.
+	wrongType := event
+	wrongType.Type = EventTypeTransaction
+	if _, ok := matchEvent([]Event{e}, wrongType); ok {
+		t.Fatal("expected an event of another type not to match")
+	}
+
+	// Tampered data doesn't match.
+	forged := *e.Val.(*EventMinerPayout)
+	forged.SiacoinOutput.SiacoinOutput.Value = types.Siacoins(3000)
+	forgedData, _ := json.Marshal(&forged)
+	tampered := event
+	tampered.Data = forgedData
+	if _, ok := matchEvent([]Event{e}, tampered); ok {
+		t.Fatal("expected an event with tampered data not to match")
+	}
+
+	// Unknown events don't match.
+	if _, ok := matchEvent(nil, event); ok {
+		t.Fatal("expected an unknown event not to match")
+	}
+}
@@ -0,0 +1,244 @@
+package wallet
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/chacha20poly1305"
+	"lukechampine.com/frand"
+)
+
+// importKeyFile is the name of the file holding the key that encrypts
+// the imported private keys. It is kept outside of the database, so
+// that a copy of the database alone doesn't reveal the imported keys.
+const importKeyFile = "importkey.dat"
+
+var (
+	// errInvalidKey is returned when an invalid private key is imported.
+	errInvalidKey = errors.New("invalid private key")
+
+	// errKeyExists is returned when the imported key is already known
+	// to the wallet.
+	errKeyExists = errors.New("key already exists in the wallet")
+
+	// errInvalidImportKey is returned when the import key file is
+	// corrupted.
+	errInvalidImportKey = errors.New("invalid import key file")
+)
+
+// loadImportKey loads the key that encrypts the imported keys from the
+// specified directory. A new key is generated if there is none yet.
+func loadImportKey(dir string) (key [32]byte, err error) {
+	path := filepath.Join(dir, importKeyFile)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		frand.Read(key[:])
+		f, err := os.OpenFile(path+"_tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return key, err
+		}
+		defer f.Close()
+		if _, err = f.Write(key[:]); err != nil {
+			return key, err
+		} else if err = f.Sync(); err != nil {
+			return key, err
+		} else if err = f.Close(); err != nil {
+			return key, err
+		}
+		return key, os.Rename(path+"_tmp", path)
+	} else if err != nil {
+		return key, err
+	}
+	if len(b) != len(key) {
+		return key, errInvalidImportKey
+	}
+	copy(key[:], b)
+	return key, nil
+}
+
+// importCipher returns the cipher used for encrypting the imported
+// keys.
+func importCipher(key [32]byte) cipher.AEAD {
+	aead, _ := chacha20poly1305.NewX(key[:])
+	return aead
+}
+
+// encryptKey encrypts the private key with the import key.
+func encryptKey(key [32]byte, sk types.PrivateKey) []byte {
+	aead := importCipher(key)
+	nonce := frand.Bytes(aead.NonceSize())
+	return aead.Seal(nonce, nonce, sk, nil)
+}
+
+// decryptKey decrypts the private key with the import key.
+func decryptKey(key [32]byte, b []byte) (types.PrivateKey, error) {
+	aead := importCipher(key)
+	if len(b) < aead.NonceSize() {
+		return nil, errInvalidKey
+	}
+	sk, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	return types.PrivateKey(sk), nil
+}
+
+// loadImportedKeys loads the imported keys and adds them to the wallet.
+func (w *Wallet) loadImportedKeys() error {
+	rows, err := w.db.Query("SELECT sk FROM wt_keys")
+	if err != nil {
+		return modules.AddContext(err, "couldn't query imported keys")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return modules.AddContext(err, "couldn't scan imported key")
+		}
+		sk, err := decryptKey(w.importKey, b)
+		if err != nil {
+			return modules.AddContext(err, "couldn't decrypt imported key, check "+importKeyFile)
+		}
+		addr := types.StandardUnlockHash(sk.PublicKey())
+		w.imported[addr] = sk
+		w.keys[addr] = sk
+	}
+
+	return nil
+}
+
+// restoreImportedKeys adds the imported keys to the wallet after the
+// addresses have been reset.
+func (w *Wallet) restoreImportedKeys() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for addr, sk := range w.imported {
+		w.keys[addr] = sk
+		if _, ok := w.addrs[addr]; ok {
+			continue
+		}
+		if err := w.insertAddress(addr); err != nil {
+			return err
+		}
+	}
+	return w.save()
+}
+
+// ImportKey adds a standalone private key to the wallet. The key is
+// stored encrypted with the key kept in importkey.dat, and is used for funding and
+// signing transactions like the seed-derived keys. Imported keys are
+// not derived from the seed, so they can't be recovered from it. The
+// wallet rescans the blockchain to find the outputs that already
+// belong to the key.
+func (w *Wallet) ImportKey(sk types.PrivateKey) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	if len(sk) != ed25519.PrivateKeySize {
+		return errInvalidKey
+	}
+	addr := types.StandardUnlockHash(sk.PublicKey())
+
+	w.mu.Lock()
+	if w.lockState.Locked {
+		w.mu.Unlock()
+		return modules.ErrWalletLocked
+	}
+	if _, ok := w.keys[addr]; ok {
+		w.mu.Unlock()
+		return errKeyExists
+	}
+	_, err := w.tx.Exec(`
+		INSERT INTO wt_keys (addr, sk)
+		VALUES (?, ?)
+	`, addr[:], encryptKey(w.importKey, sk))
+	if err != nil {
+		w.dbError = true
+		w.mu.Unlock()
+		return modules.AddContext(err, "couldn't save imported key")
+	}
+	w.imported[addr] = sk
+	w.keys[addr] = sk
+	if _, ok := w.addrs[addr]; !ok {
+		if err := w.insertAddress(addr); err != nil {
+			w.mu.Unlock()
+			return err
+		}
+	}
+	err = w.save()
+	w.mu.Unlock()
+	if err != nil {
+		return modules.AddContext(err, "couldn't save wallet")
+	}
+
+	w.log.Info("imported private key, rescanning", zap.Stringer("address", addr))
+	select {
+	case w.rescanChan <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// rescan resets the wallet state, keeping the addresses, so that the
+// blockchain is scanned again from the genesis block.
+func (w *Wallet) rescan() error {
+	w.mu.Lock()
+	addrs := make(map[types.Address]uint64, len(w.addrs))
+	for addr, index := range w.addrs {
+		addrs[addr] = index
+	}
+	watched := make(map[types.Address]struct{}, len(w.watchedAddrs))
+	for addr := range w.watchedAddrs {
+		watched[addr] = struct{}{}
+	}
+	w.tip = types.ChainIndex{}
+	w.addrs = make(map[types.Address]uint64)
+	w.watchedAddrs = make(map[types.Address]uint64)
+	w.sces = make(map[types.Address]types.SiacoinElement)
+	w.sceHeights = make(map[types.Address]uint64)
+	w.sfes = make(map[types.Address]types.SiafundElement)
+	w.mu.Unlock()
+
+	if err := w.reset(); err != nil {
+		return modules.AddContext(err, "couldn't reset database before rescanning")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Re-insert the addresses in the original order.
+	ordered := make([]types.Address, 0, len(addrs))
+	for addr := range addrs {
+		ordered = append(ordered, addr)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return addrs[ordered[i]] < addrs[ordered[j]]
+	})
+	for _, addr := range ordered {
+		if err := w.insertAddress(addr); err != nil {
+			return err
+		}
+	}
+	for addr := range watched {
+		index := w.addrs[addr]
+		w.watchedAddrs[addr] = index
+		if _, err := w.tx.Exec("INSERT INTO wt_watched (address_id) VALUES (?)", index); err != nil {
+			w.dbError = true
+			return modules.AddContext(err, "couldn't insert watched address")
+		}
+	}
+
+	return w.save()
+}
@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.uber.org/zap"
+)
+
+// errWrongSeed is returned when the wallet is unlocked with a seed
+// different from the wallet seed.
+var errWrongSeed = errors.New("provided seed does not match the wallet seed")
+
+// setLocked changes the lock state and notifies the subscribers.
+// A lock must be acquired before calling this function.
+func (w *Wallet) setLocked(locked bool, reason string) {
+	if w.lockState.Locked == locked {
+		return
+	}
+	w.lockState = modules.WalletLockEvent{
+		Locked:    locked,
+		Timestamp: time.Now(),
+		Reason:    reason,
+	}
+	w.log.Info("wallet lock state changed", zap.Bool("locked", locked), zap.String("reason", reason))
+	for c := range w.lockSubscribers {
+		select {
+		case c <- w.lockState:
+		default:
+		}
+	}
+}
+
+// Lock locks the wallet. A locked wallet keeps tracking the blockchain,
+// but refuses the spends requested by the user: sending, sweeping and
+// bumping fees, signing messages and receipts, and importing keys. The
+// satellite's own modules can still fund and sign transactions, e.g.
+// to form contracts, and the keys stay in memory, so the lock guards
+// the API rather than the key material.
+func (w *Wallet) Lock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.setLocked(true, "manual")
+}
+
+// Unlock unlocks the wallet. The seed phrase must match the wallet seed.
+func (w *Wallet) Unlock(seed string) error {
+	var entropy modules.Seed
+	if err := modules.SeedFromPhrase(&entropy, seed); err != nil {
+		return modules.AddContext(err, "unable to decode seed phrase")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entropy != w.seed {
+		return errWrongSeed
+	}
+	w.lastActivity = time.Now()
+	w.setLocked(false, "manual")
+	return nil
+}
+
+// LockState returns the current lock state of the wallet and the time
+// of the last change.
+func (w *Wallet) LockState() modules.WalletLockEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lockState
+}
+
+// SubscribeLock returns a channel that receives the lock state changes
+// of the wallet, and a function that closes the subscription.
+func (w *Wallet) SubscribeLock() (<-chan modules.WalletLockEvent, func()) {
+	c := make(chan modules.WalletLockEvent, 16)
+	w.mu.Lock()
+	w.lockSubscribers[c] = struct{}{}
+	w.mu.Unlock()
+	return c, func() {
+		w.mu.Lock()
+		delete(w.lockSubscribers, c)
+		w.mu.Unlock()
+	}
+}
+
+// autoLockCheckInterval is how often the auto-lock timer is checked.
+const autoLockCheckInterval = time.Second
+
+// Touch records wallet activity, resetting the auto-lock timer.
+func (w *Wallet) Touch() {
+	w.mu.Lock()
+	w.lastActivity = time.Now()
+	w.mu.Unlock()
+}
+
+// beginOperation marks the start of a user-requested operation that
+// must not be interrupted by the auto-lock. The internal operations of
+// the wallet and of the other modules are not affected by the lock, so
+// they neither count as activity nor postpone the lock. It returns a
+// function that marks the end of the operation.
+func (w *Wallet) beginOperation() func() {
+	w.mu.Lock()
+	w.activeOps++
+	w.lastActivity = time.Now()
+	w.mu.Unlock()
+	return func() {
+		w.mu.Lock()
+		w.activeOps--
+		w.lastActivity = time.Now()
+		w.mu.Unlock()
+	}
+}
+
+// SetAutoLock sets the period of inactivity after which the wallet is
+// locked. Zero disables the auto-lock.
+func (w *Wallet) SetAutoLock(idle time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.autoLock = idle
+	w.lastActivity = time.Now()
+}
+
+// AutoLock returns the auto-lock period of the wallet.
+func (w *Wallet) AutoLock() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.autoLock
+}
+
+// threadedAutoLock locks the wallet when it has been inactive for
+// longer than the auto-lock period. In-flight operations postpone the
+// lock until they complete.
+func (w *Wallet) threadedAutoLock() {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+
+	for {
+		select {
+		case <-w.tg.StopChan():
+			return
+		case <-time.After(autoLockCheckInterval):
+		}
+
+		w.mu.Lock()
+		if w.autoLock > 0 && !w.lockState.Locked && w.activeOps == 0 && time.Since(w.lastActivity) > w.autoLock {
+			w.setLocked(true, "timeout")
+		}
+		w.mu.Unlock()
+	}
+}
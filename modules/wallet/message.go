@@ -0,0 +1,50 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+)
+
+// errUnknownAddress is returned when the wallet doesn't hold the key
+// of an address.
+var errUnknownAddress = errors.New("wallet doesn't hold the key of the address")
+
+// PublicKey returns the public key of the given wallet address.
+func (w *Wallet) PublicKey(addr types.Address) (types.PublicKey, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key, ok := w.keys[addr]
+	if !ok {
+		return types.PublicKey{}, false
+	}
+	return key.PublicKey(), true
+}
+
+// SignMessage signs a free-form message with the key of the given
+// wallet address, e.g. to prove the ownership of the address. The
+// message is hashed with a prefix, so that the signature can't be
+// mistaken for a transaction signature.
+func (w *Wallet) SignMessage(addr types.Address, msg []byte) (types.Signature, error) {
+	w.mu.Lock()
+	locked := w.lockState.Locked
+	key, ok := w.keys[addr]
+	w.mu.Unlock()
+	if locked {
+		return types.Signature{}, modules.ErrWalletLocked
+	} else if !ok {
+		return types.Signature{}, errUnknownAddress
+	}
+	return key.SignHash(modules.MessageHash(msg)), nil
+}
+
+// VerifyMessage checks the signature of a message signed with the key
+// of the given wallet address.
+func (w *Wallet) VerifyMessage(addr types.Address, msg []byte, sig types.Signature) (bool, error) {
+	pk, ok := w.PublicKey(addr)
+	if !ok {
+		return false, errUnknownAddress
+	}
+	return modules.VerifyMessage(pk, addr, msg, sig), nil
+}
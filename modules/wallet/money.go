@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sort"
@@ -10,6 +11,7 @@ import (
 	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
 	"go.uber.org/zap"
+	"lukechampine.com/frand"
 )
 
 // sortedOutputs is a struct containing a slice of siacoin outputs and their
@@ -42,7 +44,9 @@ func (w *Wallet) DustThreshold() types.Currency {
 	return w.cm.RecommendedFee().Mul64(3)
 }
 
-// ConfirmedBalance returns the total balance of the wallet.
+// ConfirmedBalance returns the total balance of the wallet. The outputs
+// that haven't been confirmed for the required number of blocks yet are
+// counted as immature.
 func (w *Wallet) ConfirmedBalance() (siacoins, immatureSiacoins types.Currency, siafunds uint64) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -51,7 +55,7 @@ func (w *Wallet) ConfirmedBalance() (siacoins, immatureSiacoins types.Currency,
 	height := w.cm.Tip().Height
 	for _, sce := range w.sces {
 		if sce.SiacoinOutput.Value.Cmp(dustThreshold) > 0 {
-			if height >= sce.MaturityHeight {
+			if height >= sce.MaturityHeight && w.confirmed(sce, height) {
 				siacoins = siacoins.Add(sce.SiacoinOutput.Value)
 			} else {
 				immatureSiacoins = immatureSiacoins.Add(sce.SiacoinOutput.Value)
@@ -66,6 +70,96 @@ func (w *Wallet) ConfirmedBalance() (siacoins, immatureSiacoins types.Currency,
 	return
 }
 
+// SpendableBalance returns the part of the confirmed balance that can be
+// spent right now. Unlike ConfirmedBalance, it excludes the outputs that
+// are reserved by a pending operation or spent by an unconfirmed
+// transaction.
+func (w *Wallet) SpendableBalance() (siacoins types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	spendable := w.spendable()
+	height := w.cm.Tip().Height
+	for _, sce := range w.sces {
+		if spendable(sce, height) {
+			siacoins = siacoins.Add(sce.SiacoinOutput.Value)
+		}
+	}
+
+	return
+}
+
+// spendable returns a predicate reporting whether an output can be
+// spent at the given height: it must be above the dust threshold,
+// mature, confirmed, not reserved, and not spent by a txpool
+// transaction. The txpool is read once, when the predicate is created.
+// w.mu must be held while the predicate is used.
+func (w *Wallet) spendable() func(sce types.SiacoinElement, height uint64) bool {
+	inPool := make(map[types.SiacoinOutputID]bool)
+	for _, ptxn := range w.cm.PoolTransactions() {
+		for _, in := range ptxn.SiacoinInputs {
+			inPool[in.ParentID] = true
+		}
+	}
+	dustThreshold := w.DustThreshold()
+
+	return func(sce types.SiacoinElement, height uint64) bool {
+		if sce.SiacoinOutput.Value.Cmp(dustThreshold) <= 0 || height < sce.MaturityHeight || !w.confirmed(sce, height) {
+			return false
+		}
+		_, used := w.used[types.Hash256(sce.ID)]
+		return !used && !inPool[types.SiacoinOutputID(sce.ID)]
+	}
+}
+
+// confirmed returns true if the output has been confirmed for at least
+// the required number of blocks at the given height. w.mu must be held.
+func (w *Wallet) confirmed(sce types.SiacoinElement, height uint64) bool {
+	return height >= w.sceHeights[sce.SiacoinOutput.Address]+w.requiredConfirmations
+}
+
+// SetRequiredConfirmations sets the number of blocks an output must have
+// been confirmed for, in addition to its maturity, to count towards the
+// balance. Zero counts the outputs as soon as they are confirmed. The
+// setting is kept across restarts.
+func (w *Wallet) SetRequiredConfirmations(n uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.tx.Exec("UPDATE wt_info SET confirmations = ? WHERE id = 1", n); err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't save required confirmations")
+	}
+	w.requiredConfirmations = n
+	return w.save()
+}
+
+// RequiredConfirmations returns the number of blocks an output must have
+// been confirmed for to count towards the balance.
+func (w *Wallet) RequiredConfirmations() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.requiredConfirmations
+}
+
+// ClaimBalance returns the Siacoins that can be claimed by spending
+// the Siafunds of the wallet. Each Siafund is entitled to its share of
+// the growth of the Siafund pool since the output was created.
+func (w *Wallet) ClaimBalance() (claim types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cs := w.cm.TipState()
+	for _, sfe := range w.sfes {
+		if cs.SiafundPool.Cmp(sfe.ClaimStart) <= 0 {
+			continue
+		}
+		share := cs.SiafundPool.Sub(sfe.ClaimStart).Div64(cs.SiafundCount()).Mul64(sfe.SiafundOutput.Value)
+		claim = claim.Add(share)
+	}
+
+	return
+}
+
 // UnconfirmedBalance returns the balance of the wallet contained in
 // the unconfirmed transactions.
 func (w *Wallet) UnconfirmedBalance() (outgoing, incoming types.Currency) {
@@ -115,12 +209,42 @@ func (w *Wallet) AddressBalance(addr types.Address) (siacoins types.Currency, si
 
 // Fund adds Siacoin inputs with the required amount to the transaction.
 func (w *Wallet) Fund(txn *types.Transaction, amount types.Currency) (parents []types.Transaction, toSign []types.Hash256, err error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+	fr, err := w.FundDetailed(txn, amount)
+	return fr.Parents, fr.ToSign, err
+}
+
+// FundDetailed adds Siacoin inputs with the required amount to the
+// transaction. Unlike Fund, it also reports if the transaction depends
+// on any unconfirmed transactions.
+func (w *Wallet) FundDetailed(txn *types.Transaction, amount types.Currency) (fr modules.FundResult, err error) {
 	if amount.IsZero() {
-		return nil, nil, nil
+		return
 	}
+	parents, toSign, err := w.fund(txn, amount)
+	if err != nil {
+		return
+	}
+	fr.Parents = parents
+	fr.ToSign = toSign
+	for _, parent := range parents {
+		fr.UnconfirmedParents = append(fr.UnconfirmedParents, parent.ID())
+	}
+	fr.Unconfirmed = len(fr.UnconfirmedParents) > 0
+	return
+}
 
+// fund selects the inputs and the change output for Fund.
+func (w *Wallet) fund(txn *types.Transaction, amount types.Currency) (parents []types.Transaction, toSign []types.Hash256, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.addInputs(txn, amount)
+}
+
+// selectInputs selects the spendable outputs that fund the given amount,
+// largest first. If the change would be dust, more outputs are
+// selected to make it spendable where possible. Nothing is reserved.
+// w.mu must be held.
+func (w *Wallet) selectInputs(amount types.Currency) (selected []types.SiacoinElement, outputSum types.Currency, err error) {
 	var utxos []types.SiacoinElement
 	for _, sce := range w.sces {
 		utxos = append(utxos, sce)
@@ -129,20 +253,16 @@ func (w *Wallet) Fund(txn *types.Transaction, amount types.Currency) (parents []
 		return utxos[i].SiacoinOutput.Value.Cmp(utxos[j].SiacoinOutput.Value) > 0
 	})
 
-	inPool := make(map[types.SiacoinOutputID]bool)
-	for _, ptxn := range w.cm.PoolTransactions() {
-		for _, in := range ptxn.SiacoinInputs {
-			inPool[in.ParentID] = true
-		}
-	}
+	spendable := w.spendable()
+	height := w.cm.Tip().Height
 
-	var outputSum types.Currency
-	var fundingElements []types.SiacoinElement
-	for _, sce := range utxos {
-		if w.used[types.Hash256(sce.ID)] || inPool[types.SiacoinOutputID(sce.ID)] {
+	var i int
+	for ; i < len(utxos); i++ {
+		sce := utxos[i]
+		if !spendable(sce, height) {
 			continue
 		}
-		fundingElements = append(fundingElements, sce)
+		selected = append(selected, sce)
 		outputSum = outputSum.Add(sce.SiacoinOutput.Value)
 		if outputSum.Cmp(amount) >= 0 {
 			break
@@ -150,9 +270,47 @@ func (w *Wallet) Fund(txn *types.Transaction, amount types.Currency) (parents []
 	}
 
 	if outputSum.Cmp(amount) < 0 {
-		return nil, nil, modules.ErrInsufficientBalance
-	} else if outputSum.Cmp(amount) > 0 {
-		refundUC, err := w.nextAddress()
+		return nil, types.ZeroCurrency, modules.ErrInsufficientBalance
+	}
+
+	// If the change would be dust, try to pull in more inputs to make
+	// it spendable.
+	dustThreshold := w.DustThreshold()
+	if change := outputSum.Sub(amount); !change.IsZero() && change.Cmp(dustThreshold) < 0 {
+		for i++; i < len(utxos) && outputSum.Sub(amount).Cmp(dustThreshold) < 0; i++ {
+			sce := utxos[i]
+			if !spendable(sce, height) {
+				continue
+			}
+			selected = append(selected, sce)
+			outputSum = outputSum.Add(sce.SiacoinOutput.Value)
+		}
+	}
+
+	return selected, outputSum, nil
+}
+
+// addInputs adds the inputs and the change output to the transaction
+// and reserves the inputs. w.mu must be held.
+func (w *Wallet) addInputs(txn *types.Transaction, amount types.Currency) (parents []types.Transaction, toSign []types.Hash256, err error) {
+	fundingElements, outputSum, err := w.selectInputs(amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// If there are not enough inputs to make the change spendable, add
+	// the change to the miner fee instead.
+	if change := outputSum.Sub(amount); !change.IsZero() && change.Cmp(w.DustThreshold()) < 0 {
+		if len(txn.MinerFees) == 0 {
+			txn.MinerFees = append(txn.MinerFees, types.ZeroCurrency)
+		}
+		txn.MinerFees[0] = txn.MinerFees[0].Add(change)
+		amount = outputSum
+	}
+
+	if outputSum.Cmp(amount) > 0 {
+		var refundUC types.UnlockConditions
+		refundUC, err = w.nextAddress()
 		defer func() {
 			if err != nil {
 				w.markAddressUnused(refundUC)
@@ -175,22 +333,188 @@ func (w *Wallet) Fund(txn *types.Transaction, amount types.Currency) (parents []
 				UnlockConditions: types.StandardUnlockConditions(key.PublicKey()),
 			})
 			toSign[i] = types.Hash256(sce.ID)
-			w.used[sce.ID] = true
+			w.used[sce.ID] = w.tip.Height
+		}
+	}
+
+	parents = SortParents(w.cm.UnconfirmedParents(*txn))
+	if depth := ancestorDepth(parents); w.maxAncestorDepth > 0 && depth > w.maxAncestorDepth {
+		w.releaseInputs(*txn)
+		return nil, nil, fmt.Errorf("%w: %d unconfirmed ancestors in a row, at most %d allowed", errAncestorsTooDeep, depth, w.maxAncestorDepth)
+	}
+
+	return parents, toSign, nil
+}
+
+// ancestorDepth returns the length of the longest chain of unconfirmed
+// transactions in the sorted parents, i.e. the number of blocks needed
+// to confirm the child transaction in the worst case.
+func ancestorDepth(parents []types.Transaction) (depth int) {
+	creators := make(map[types.SiacoinOutputID]int)
+	depths := make([]int, len(parents))
+	for i, txn := range parents {
+		depths[i] = 1
+		for _, sci := range txn.SiacoinInputs {
+			if j, ok := creators[sci.ParentID]; ok && depths[j]+1 > depths[i] {
+				depths[i] = depths[j] + 1
+			}
+		}
+		for j := range txn.SiacoinOutputs {
+			creators[txn.SiacoinOutputID(j)] = i
+		}
+		if depths[i] > depth {
+			depth = depths[i]
+		}
+	}
+	return
+}
+
+// SetMaxAncestorDepth sets the maximum length of the chain of
+// unconfirmed transactions a funded transaction may depend on. Zero
+// removes the limit. The limit is kept across restarts.
+func (w *Wallet) SetMaxAncestorDepth(depth int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.tx.Exec("UPDATE wt_info SET max_ancestors = ? WHERE id = 1", depth); err != nil {
+		w.dbError = true
+		return modules.AddContext(err, "couldn't save maximum ancestor depth")
+	}
+	w.maxAncestorDepth = depth
+	return w.save()
+}
+
+// MaxAncestorDepth returns the maximum length of the chain of
+// unconfirmed transactions a funded transaction may depend on.
+func (w *Wallet) MaxAncestorDepth() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.maxAncestorDepth
+}
+
+// adjustFee sets the first miner fee of the unsigned transaction to the
+// fee rate times the weight the transaction will have once signed. The
+// difference to the current fee is taken from or returned to the change
+// output at the given index. If there is no change output, or if it is
+// too small, the fee is left unchanged and false is returned.
+func adjustFee(cs consensus.State, txn *types.Transaction, change int, feeRate types.Currency) bool {
+	if change < 1 || change >= len(txn.SiacoinOutputs) {
+		// The first output is the payment, not the change.
+		return false
+	}
+
+	// Each signature adds 64 bytes once the transaction is signed.
+	weight := cs.TransactionWeight(*txn) + 64*uint64(len(txn.Signatures))
+	fee := feeRate.Mul64(weight)
+	current := txn.MinerFees[0]
+	sco := &txn.SiacoinOutputs[change]
+	if fee.Cmp(current) < 0 {
+		sco.Value = sco.Value.Add(current.Sub(fee))
+	} else if diff := fee.Sub(current); sco.Value.Cmp(diff) > 0 {
+		sco.Value = sco.Value.Sub(diff)
+	} else {
+		return false
+	}
+	txn.MinerFees[0] = fee
+	return true
+}
+
+// SortParents orders the unconfirmed parents, so that every transaction
+// comes after all transactions it depends on. This is required when the
+// parents form a diamond (e.g. two parents where one spends an output
+// of the other), in which case the order returned by the chain manager
+// is not guaranteed to be valid.
+func SortParents(parents []types.Transaction) []types.Transaction {
+	// Map each created output to the transaction creating it.
+	creators := make(map[types.Hash256]int)
+	for i, txn := range parents {
+		for j := range txn.SiacoinOutputs {
+			creators[types.Hash256(txn.SiacoinOutputID(j))] = i
+		}
+		for j := range txn.SiafundOutputs {
+			creators[types.Hash256(txn.SiafundOutputID(j))] = i
+		}
+		for j := range txn.FileContracts {
+			creators[types.Hash256(txn.FileContractID(j))] = i
+		}
+	}
+
+	sorted := make([]types.Transaction, 0, len(parents))
+	visited := make([]bool, len(parents))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		txn := parents[i]
+		deps := make([]types.Hash256, 0, len(txn.SiacoinInputs)+len(txn.SiafundInputs)+len(txn.FileContractRevisions)+len(txn.StorageProofs))
+		for _, sci := range txn.SiacoinInputs {
+			deps = append(deps, types.Hash256(sci.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			deps = append(deps, types.Hash256(sfi.ParentID))
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			deps = append(deps, types.Hash256(fcr.ParentID))
+		}
+		for _, sp := range txn.StorageProofs {
+			deps = append(deps, types.Hash256(sp.ParentID))
+		}
+		for _, id := range deps {
+			if j, ok := creators[id]; ok {
+				visit(j)
+			}
 		}
+		sorted = append(sorted, txn)
+	}
+	for i := range parents {
+		visit(i)
 	}
 
-	return w.cm.UnconfirmedParents(*txn), toSign, nil
+	return sorted
 }
 
 // Release marks the outputs as unused.
 func (w *Wallet) Release(txnSet []types.Transaction) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	txids := make([]types.TransactionID, 0, len(txnSet))
 	for _, txn := range txnSet {
+		txids = append(txids, txn.ID())
+		w.releaseInputs(txn)
 		for i := range txn.SiacoinOutputs {
 			delete(w.used, types.Hash256(txn.SiacoinOutputID((i))))
 		}
 	}
+	w.log.Debug("released transaction set", zap.Stringers("txids", txids))
+}
+
+// ReleaseInputsExplicit marks the inputs of the transaction and of its
+// parents as unused. Unlike Release, it doesn't touch the outputs of
+// the transactions, so it is meant for the callers that hold the
+// parents of a transaction that was never broadcast.
+func (w *Wallet) ReleaseInputsExplicit(txn types.Transaction, parents []types.Transaction) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, parent := range parents {
+		w.releaseInputs(parent)
+	}
+	w.releaseInputs(txn)
+	w.log.Debug("released transaction inputs", zap.Stringer("txid", txn.ID()), zap.Int("parents", len(parents)))
+}
+
+// releaseInputs marks the inputs of the transaction as unused. w.mu
+// must be held.
+func (w *Wallet) releaseInputs(txn types.Transaction) {
+	for _, sci := range txn.SiacoinInputs {
+		delete(w.used, types.Hash256(sci.ParentID))
+	}
+}
+
+// newOperationID returns a random ID, which is attached to the log
+// entries of a wallet operation in order to correlate them.
+func newOperationID() string {
+	return hex.EncodeToString(frand.Bytes(8))
 }
 
 // Reserve reserves the given ids for the given duration.
@@ -200,14 +524,16 @@ func (w *Wallet) Reserve(ids []types.Hash256, duration time.Duration) error {
 
 	// Check if any of the ids are already reserved.
 	for _, id := range ids {
-		if w.used[id] {
+		if _, ok := w.used[id]; ok {
 			return fmt.Errorf("output %q already reserved", id)
 		}
 	}
 
-	// Reserve the ids.
+	// Reserve the ids. They are also recorded as reserved, so that they
+	// aren't pruned before the duration is over.
 	for _, id := range ids {
-		w.used[id] = true
+		w.used[id] = w.tip.Height
+		w.reserved[id] = struct{}{}
 	}
 
 	// Sleep for the duration and then unreserve the ids.
@@ -217,6 +543,7 @@ func (w *Wallet) Reserve(ids []types.Hash256, duration time.Duration) error {
 
 		for _, id := range ids {
 			delete(w.used, id)
+			delete(w.reserved, id)
 		}
 	})
 	return nil
@@ -226,22 +553,34 @@ func (w *Wallet) Reserve(ids []types.Hash256, duration time.Duration) error {
 // If toSign is nil, SignTransaction will automatically add Signatures for each
 // input owned by the seed. If toSign is not nil, it is a list of IDs of Signatures
 // already present in txn; SignTransaction will fill in the Signature field of each.
+// The caller records the transaction with RecordAudit once it is broadcast.
 func (w *Wallet) Sign(cs consensus.State, txn *types.Transaction, toSign []types.Hash256) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.sign(cs, txn, toSign)
+}
 
+// sign fills in the signatures of the transaction. w.mu must be held.
+func (w *Wallet) sign(cs consensus.State, txn *types.Transaction, toSign []types.Hash256) error {
 	if len(toSign) == 0 {
-		// Lazy mode: add standard sigs for every input we own.
+		// Lazy mode: add standard sigs for every input we own and
+		// co-sign the inputs of the registered multisig addresses.
 		for _, sci := range txn.SiacoinInputs {
-			if key, ok := w.keys[sci.UnlockConditions.UnlockHash()]; ok {
+			addr := sci.UnlockConditions.UnlockHash()
+			if key, ok := w.keys[addr]; ok {
 				txn.Signatures = append(txn.Signatures, StandardTransactionSignature(types.Hash256(sci.ParentID)))
 				SignTransaction(cs, txn, len(txn.Signatures)-1, key)
+			} else if _, ok := w.multisig[addr]; ok {
+				w.cosign(cs, txn, types.Hash256(sci.ParentID), addr)
 			}
 		}
 		for _, sfi := range txn.SiafundInputs {
-			if key, ok := w.keys[sfi.UnlockConditions.UnlockHash()]; ok {
+			addr := sfi.UnlockConditions.UnlockHash()
+			if key, ok := w.keys[addr]; ok {
 				txn.Signatures = append(txn.Signatures, StandardTransactionSignature(types.Hash256(sfi.ParentID)))
 				SignTransaction(cs, txn, len(txn.Signatures)-1, key)
+			} else if _, ok := w.multisig[addr]; ok {
+				w.cosign(cs, txn, types.Hash256(sfi.ParentID), addr)
 			}
 		}
 		return nil
@@ -270,14 +609,21 @@ outer:
 	for _, parent := range toSign {
 		for sigIndex, sig := range txn.Signatures {
 			if sig.ParentID == parent {
-				if addr, ok := sigAddr(parent); !ok {
+				addr, ok := sigAddr(parent)
+				if !ok {
 					return fmt.Errorf("ID %v not present in transaction", parent)
-				} else if key, ok := w.keys[addr]; !ok {
+				}
+				// A multisig signature is made with the key at its
+				// public key index.
+				key, ok := w.keys[addr]
+				if !ok {
+					key, ok = w.multisigKey(addr, sig.PublicKeyIndex)
+				}
+				if !ok {
 					return fmt.Errorf("missing key for ID %v", parent)
-				} else {
-					SignTransaction(cs, txn, sigIndex, key)
-					continue outer
 				}
+				SignTransaction(cs, txn, sigIndex, key)
+				continue outer
 			}
 		}
 		return fmt.Errorf("signature %v not present in transaction", parent)
@@ -285,32 +631,132 @@ outer:
 	return nil
 }
 
+// defaultMaxAncestorDepth is the default maximum length of the chain of
+// unconfirmed transactions a funded transaction may depend on. Longer
+// chains are not relayed by the peers.
+const defaultMaxAncestorDepth = 25
+
+// errAncestorsTooDeep is returned when funding a transaction would make
+// it depend on too long a chain of unconfirmed transactions.
+var errAncestorsTooDeep = errors.New("unconfirmed ancestor chain too deep")
+
+// EstimateSize returns the projected encoded size of a signed
+// transaction paying the given outputs, and the number of inputs it
+// would have. The inputs are selected like SendSiacoins does at the
+// recommended fee, but nothing is reserved.
+func (w *Wallet) EstimateSize(outputs []types.SiacoinOutput) (size uint64, inputs int, err error) {
+	var amount types.Currency
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	fee := w.cm.RecommendedFee().Mul64(estimatedTxnWeight)
+	txn := types.Transaction{
+		SiacoinOutputs: append([]types.SiacoinOutput(nil), outputs...),
+		MinerFees:      []types.Currency{fee},
+	}
+
+	w.mu.Lock()
+	selected, outputSum, err := w.selectInputs(amount.Add(fee))
+	w.mu.Unlock()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The change output only needs to have the right size, so the
+	// address is left empty.
+	if outputSum.Sub(amount.Add(fee)).Cmp(w.DustThreshold()) >= 0 {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value: outputSum.Sub(amount.Add(fee)),
+		})
+	}
+	for _, sce := range selected {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         types.SiacoinOutputID(sce.ID),
+			UnlockConditions: types.StandardUnlockConditions(types.PublicKey{}),
+		})
+		txn.Signatures = append(txn.Signatures, types.TransactionSignature{
+			ParentID:      sce.ID,
+			CoveredFields: types.CoveredFields{WholeTransaction: true},
+			Signature:     make([]byte, len(types.Signature{})),
+		})
+	}
+
+	return w.cm.TipState().TransactionWeight(txn), len(selected), nil
+}
+
+// estimatedTxnWeight is the estimated weight of a transaction with one
+// output, used for funding the transaction before its inputs are known.
+const estimatedTxnWeight = 750
+
 // SendSiacoins creates a transaction sending 'amount' to 'dest'. The
 // transaction is submitted to the transaction pool and is also returned. Fees
-// are added to the amount sent.
-func (w *Wallet) SendSiacoins(amount types.Currency, dest types.Address) ([]types.Transaction, error) {
+// are added to the amount sent. If feeRate (per weight unit) is zero, the
+// recommended fee rate is used.
+func (w *Wallet) SendSiacoins(amount types.Currency, dest types.Address, feeRate types.Currency) ([]types.Transaction, error) {
 	if err := w.tg.Add(); err != nil {
 		return nil, err
 	}
 	defer w.tg.Done()
+	defer w.beginOperation()()
+
+	log := w.log.With(zap.String("op", newOperationID()), zap.Stringer("destination", dest))
+	return w.fundSignBroadcast([]types.SiacoinOutput{{Value: amount, Address: dest}}, feeRate, log)
+}
 
+// FundSignBroadcast creates a transaction paying to the specified
+// outputs, funds it, signs it, and broadcasts it. Funding and signing
+// happen under a single lock, and the reserved inputs are released if
+// any of the steps fails. If feeRate (per weight unit) is zero, the
+// recommended fee rate is used.
+func (w *Wallet) FundSignBroadcast(outputs []types.SiacoinOutput, feeRate types.Currency) (types.TransactionID, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.TransactionID{}, err
+	}
+	defer w.tg.Done()
+	defer w.beginOperation()()
+
+	if len(outputs) == 0 {
+		return types.TransactionID{}, errors.New("no outputs specified")
+	}
+
+	log := w.log.With(zap.String("op", newOperationID()), zap.Int("outputs", len(outputs)))
+	txnSet, err := w.fundSignBroadcast(outputs, feeRate, log)
+	if err != nil {
+		return types.TransactionID{}, err
+	}
+	return txnSet[len(txnSet)-1].ID(), nil
+}
+
+// fundSignBroadcast implements FundSignBroadcast and returns the whole
+// transaction set, the funded transaction being the last one.
+func (w *Wallet) fundSignBroadcast(outputs []types.SiacoinOutput, feeRate types.Currency, log *zap.Logger) ([]types.Transaction, error) {
 	if !w.synced() {
 		return nil, errors.New("cannot send Siacoins until fully synced")
 	}
 
-	fee := w.cm.RecommendedFee().Mul64(750)
-	output := types.SiacoinOutput{
-		Value:   amount,
-		Address: dest,
+	if feeRate.IsZero() {
+		feeRate = w.cm.RecommendedFee()
 	}
+	var amount types.Currency
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	fee := feeRate.Mul64(estimatedTxnWeight)
 	txn := types.Transaction{
-		SiacoinOutputs: []types.SiacoinOutput{output},
+		SiacoinOutputs: append([]types.SiacoinOutput(nil), outputs...),
 		MinerFees:      []types.Currency{fee},
 	}
+	cs := w.cm.TipState()
 
-	parents, toSign, err := w.Fund(&txn, amount.Add(fee))
+	w.mu.Lock()
+	if w.lockState.Locked {
+		w.mu.Unlock()
+		return nil, modules.ErrWalletLocked
+	}
+	parents, toSign, err := w.addInputs(&txn, amount.Add(fee))
 	if err != nil {
-		w.log.Error("failed to fund transaction", zap.Error(err))
+		w.mu.Unlock()
+		log.Error("failed to fund transaction", zap.Error(err))
 		return nil, modules.AddContext(err, "unable to fund transaction")
 	}
 
@@ -321,23 +767,149 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.Address) ([]type
 		})
 	}
 
-	err = w.Sign(w.cm.TipState(), &txn, toSign)
-	if err != nil {
-		w.log.Error("failed to sign transaction", zap.Error(err))
-		w.Release(append(parents, txn))
+	// Now that the inputs are known, adjust the fee to the actual weight
+	// of the transaction. The change output, if any, follows the
+	// payment outputs.
+	change := -1
+	if len(txn.SiacoinOutputs) > len(outputs) {
+		change = len(txn.SiacoinOutputs) - 1
+	}
+	adjustFee(cs, &txn, change, feeRate)
+	fee = txn.MinerFees[0]
+
+	log = log.With(zap.Stringer("txid", txn.ID()))
+	if err := w.sign(cs, &txn, toSign); err != nil {
+		w.releaseInputs(txn)
+		w.mu.Unlock()
+		log.Error("failed to sign transaction", zap.Error(err))
 		return nil, modules.AddContext(err, "unable to sign transaction")
 	}
+	w.mu.Unlock()
 
 	txnSet := append(parents, txn)
-	_, err = w.cm.AddPoolTransactions(txnSet)
-	if err != nil {
-		w.Release(txnSet)
-		w.log.Error("transaction set rejected", zap.Error(err))
+	if _, err := w.cm.AddPoolTransactions(txnSet); err != nil {
+		w.mu.Lock()
+		w.releaseInputs(txn)
+		w.mu.Unlock()
+		log.Error("transaction set rejected", zap.Error(err))
 		return nil, modules.AddContext(err, "invalid transaction set")
 	}
 
 	w.s.BroadcastTransactionSet(txnSet)
-	w.log.Info("successfully sent amount", zap.Stringer("amount", amount), zap.Stringer("fee", fee), zap.Stringer("destination", dest))
+	log.Info("successfully sent amount", zap.Stringer("amount", amount), zap.Stringer("fee", fee))
+	if err := w.recordAudit(txn); err != nil {
+		log.Error("failed to record audit log entry", zap.Error(err))
+	}
+
+	return txnSet, nil
+}
+
+// SignReceipt signs the receipt of a transaction sent by the wallet
+// with the key of the transaction's first input.
+func (w *Wallet) SignReceipt(r *modules.SendReceipt, txn types.Transaction) error {
+	if len(txn.SiacoinInputs) == 0 {
+		return errors.New("transaction has no inputs")
+	}
+
+	w.mu.Lock()
+	locked := w.lockState.Locked
+	key, ok := w.keys[txn.SiacoinInputs[0].UnlockConditions.UnlockHash()]
+	w.mu.Unlock()
+	if locked {
+		return modules.ErrWalletLocked
+	} else if !ok {
+		return errors.New("transaction was not sent by the wallet")
+	}
+
+	pk := key.PublicKey()
+	sig := key.SignHash(r.SigHash())
+	r.PublicKey = &pk
+	r.Signature = &sig
+	return nil
+}
+
+// BumpFee replaces an unconfirmed wallet transaction with one spending the
+// same inputs but paying the given fee rate (per weight unit). The fee
+// increase is taken from the change output. The chain manager can't evict
+// a pool transaction, and it would keep both conflicting spends if the
+// replacement were added, counting the inputs twice. Until the next block
+// the local pool would also keep both, and afterwards it would drop the
+// replacement rather than the original. So the replacement is only
+// relayed to the peers, and the original stays in the local pool until
+// either of them is confirmed.
+func (w *Wallet) BumpFee(id types.TransactionID, feeRate types.Currency) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	defer w.beginOperation()()
+
+	log := w.log.With(zap.String("op", newOperationID()), zap.Stringer("txid", id))
+
+	txn, ok := w.cm.PoolTransaction(id)
+	if !ok {
+		return nil, errors.New("transaction not found in the txpool, it may have been confirmed already")
+	}
+	if len(txn.MinerFees) == 0 {
+		return nil, errors.New("transaction pays no miner fee")
+	}
+
+	// Find the change output and make sure that all inputs belong to
+	// the wallet.
+	w.mu.Lock()
+	if w.lockState.Locked {
+		w.mu.Unlock()
+		return nil, modules.ErrWalletLocked
+	}
+	change := -1
+	for i, sco := range txn.SiacoinOutputs {
+		if _, ok := w.keys[sco.Address]; ok {
+			change = i
+		}
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if _, ok := w.keys[sci.UnlockConditions.UnlockHash()]; !ok {
+			w.mu.Unlock()
+			return nil, errors.New("transaction spends inputs not owned by the wallet")
+		}
+	}
+	w.mu.Unlock()
+	if change < 1 {
+		return nil, errors.New("transaction has no change output to pay the fee from")
+	}
+
+	// The transaction shares memory with the txpool, so copy the fields
+	// being modified. Strip the signatures, so that the transaction can be
+	// re-signed.
+	txn.SiacoinOutputs = append([]types.SiacoinOutput(nil), txn.SiacoinOutputs...)
+	txn.MinerFees = append([]types.Currency(nil), txn.MinerFees...)
+	txn.Signatures = append([]types.TransactionSignature(nil), txn.Signatures...)
+	oldFee := txn.MinerFees[0]
+	toSign := make([]types.Hash256, len(txn.Signatures))
+	for i := range txn.Signatures {
+		toSign[i] = txn.Signatures[i].ParentID
+		txn.Signatures[i].Signature = nil
+	}
+
+	cs := w.cm.TipState()
+	if !adjustFee(cs, &txn, change, feeRate) {
+		return nil, errors.New("change output too small to pay the new fee")
+	}
+	if txn.MinerFees[0].Cmp(oldFee) <= 0 {
+		return nil, fmt.Errorf("new fee %v does not exceed the current fee %v", txn.MinerFees[0], oldFee)
+	}
+
+	if err := w.Sign(cs, &txn, toSign); err != nil {
+		log.Error("failed to sign transaction", zap.Error(err))
+		return nil, modules.AddContext(err, "unable to sign transaction")
+	}
+
+	txnSet := append(SortParents(w.cm.UnconfirmedParents(txn)), txn)
+	w.s.BroadcastTransactionSet(txnSet)
+	log.Info("bumped transaction fee", zap.Stringer("replacement", txn.ID()), zap.Stringer("fee", txn.MinerFees[0]))
+	if err := w.recordAudit(txn); err != nil {
+		log.Error("failed to record audit log entry", zap.Error(err))
+	}
 
 	return txnSet, nil
 }
@@ -0,0 +1,357 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/testutil"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+// checkParentOrder asserts that every transaction comes after the
+// transactions creating its inputs.
+func checkParentOrder(t *testing.T, txns []types.Transaction) {
+	t.Helper()
+	created := make(map[types.SiacoinOutputID]bool)
+	creators := make(map[types.SiacoinOutputID]bool)
+	for _, txn := range txns {
+		for i := range txn.SiacoinOutputs {
+			creators[txn.SiacoinOutputID(i)] = true
+		}
+	}
+	for i, txn := range txns {
+		for _, sci := range txn.SiacoinInputs {
+			if creators[sci.ParentID] && !created[sci.ParentID] {
+				t.Fatalf("transaction %v spends an output created later", i)
+			}
+		}
+		for j := range txn.SiacoinOutputs {
+			created[txn.SiacoinOutputID(j)] = true
+		}
+	}
+}
+
+func TestSortParents(t *testing.T) {
+	addr := types.Address(frand.Entropy256())
+	output := types.SiacoinOutput{Address: addr, Value: types.Siacoins(1)}
+
+	// A diamond: a creates two outputs, b spends the first one, and c
+	// spends the second one and the output of b.
+	a := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: frand.Entropy256()}},
+		SiacoinOutputs: []types.SiacoinOutput{output, output},
+	}
+	b := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: a.SiacoinOutputID(0)}},
+		SiacoinOutputs: []types.SiacoinOutput{output},
+	}
+	c := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{
+			{ParentID: a.SiacoinOutputID(1)},
+			{ParentID: b.SiacoinOutputID(0)},
+		},
+		SiacoinOutputs: []types.SiacoinOutput{output},
+	}
+	// An unrelated parent.
+	d := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: frand.Entropy256()}},
+		SiacoinOutputs: []types.SiacoinOutput{output},
+	}
+
+	orders := [][]types.Transaction{
+		{a, b, c, d},
+		{c, b, a, d},
+		{d, c, a, b},
+		{b, d, c, a},
+	}
+	for _, parents := range orders {
+		sorted := SortParents(parents)
+		if len(sorted) != len(parents) {
+			t.Fatalf("expected %v parents, got %v", len(parents), len(sorted))
+		}
+		checkParentOrder(t, sorted)
+		seen := make(map[types.TransactionID]bool)
+		for _, txn := range sorted {
+			seen[txn.ID()] = true
+		}
+		for _, txn := range parents {
+			if !seen[txn.ID()] {
+				t.Fatal("parent missing after sorting")
+			}
+		}
+	}
+}
+
+// newTestWallet returns a wallet without a database, backed by an
+// in-memory chain. It has one unused address ready for the change.
+func newTestWallet(t *testing.T) *Wallet {
+	n, genesis := testutil.Network()
+	store, tipState, err := chain.NewDBStore(chain.NewMemDB(), n, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Wallet{
+		cm:         chain.NewManager(store, tipState),
+		log:        zap.NewNop(),
+		addrs:      make(map[types.Address]uint64),
+		keys:       make(map[types.Address]types.PrivateKey),
+		unusedKeys: make(map[types.Address]types.UnlockConditions),
+		sces:       make(map[types.Address]types.SiacoinElement),
+		sceHeights: make(map[types.Address]uint64),
+		used:       make(map[types.Hash256]uint64),
+		reserved:   make(map[types.Hash256]struct{}),
+	}
+	uc := types.StandardUnlockConditions(types.GeneratePrivateKey().PublicKey())
+	w.unusedKeys[uc.UnlockHash()] = uc
+	return w
+}
+
+// mineTestBlocks mines n empty blocks.
+func mineTestBlocks(t *testing.T, cm *chain.Manager, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		b, ok := coreutils.MineBlock(cm, types.VoidAddress, time.Second)
+		if !ok {
+			t.Fatal("couldn't mine a block")
+		} else if err := cm.AddBlocks([]types.Block{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// addTestOutput adds a confirmed output of the given value to the
+// wallet.
+func addTestOutput(w *Wallet, value types.Currency) types.SiacoinElement {
+	sk := types.GeneratePrivateKey()
+	addr := types.StandardUnlockHash(sk.PublicKey())
+	sce := types.SiacoinElement{
+		StateElement:  types.StateElement{ID: frand.Entropy256()},
+		SiacoinOutput: types.SiacoinOutput{Address: addr, Value: value},
+	}
+	w.keys[addr] = sk
+	w.sces[addr] = sce
+	w.sceHeights[addr] = w.tip.Height
+	return sce
+}
+
+// checkBalanced asserts that the inputs of the transaction pay for
+// its outputs and fees exactly, and that no output is dust.
+func checkBalanced(t *testing.T, w *Wallet, txn types.Transaction) {
+	t.Helper()
+	var in, out types.Currency
+	for _, sci := range txn.SiacoinInputs {
+		for _, sce := range w.sces {
+			if types.SiacoinOutputID(sce.ID) == sci.ParentID {
+				in = in.Add(sce.SiacoinOutput.Value)
+			}
+		}
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if sco.Value.Cmp(w.DustThreshold()) < 0 {
+			t.Fatalf("dust output of %v", sco.Value)
+		}
+		out = out.Add(sco.Value)
+	}
+	for _, fee := range txn.MinerFees {
+		out = out.Add(fee)
+	}
+	if !in.Equals(out) {
+		t.Fatalf("inputs of %v don't match outputs and fees of %v", in, out)
+	}
+}
+
+func TestFundDustChange(t *testing.T) {
+	w := newTestWallet(t)
+	dust := w.DustThreshold()
+	if dust.IsZero() {
+		t.Fatal("zero dust threshold")
+	}
+	big := addTestOutput(w, types.Siacoins(10))
+	addTestOutput(w, types.Siacoins(1))
+
+	// The change would be dust, so the second output is pulled in to
+	// make it spendable.
+	amount := big.SiacoinOutput.Value.Sub(dust.Div64(2))
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: amount}},
+	}
+	if _, _, err := w.addInputs(&txn, amount); err != nil {
+		t.Fatal(err)
+	}
+	if len(txn.SiacoinInputs) != 2 {
+		t.Fatalf("expected 2 inputs, got %v", len(txn.SiacoinInputs))
+	} else if len(txn.SiacoinOutputs) != 2 {
+		t.Fatalf("expected a change output, got %v outputs", len(txn.SiacoinOutputs))
+	} else if len(txn.MinerFees) != 0 {
+		t.Fatal("expected no fee to be added")
+	}
+	checkBalanced(t, w, txn)
+	w.releaseInputs(txn)
+
+	// With a single output, the dust change goes to the miner fee.
+	w = newTestWallet(t)
+	big = addTestOutput(w, types.Siacoins(10))
+	txn = types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: amount}},
+	}
+	if _, _, err := w.addInputs(&txn, amount); err != nil {
+		t.Fatal(err)
+	}
+	if len(txn.SiacoinInputs) != 1 {
+		t.Fatalf("expected 1 input, got %v", len(txn.SiacoinInputs))
+	} else if len(txn.SiacoinOutputs) != 1 {
+		t.Fatalf("expected no change output, got %v outputs", len(txn.SiacoinOutputs))
+	} else if len(txn.MinerFees) != 1 || !txn.MinerFees[0].Equals(dust.Div64(2)) {
+		t.Fatalf("expected the change of %v to be added to the fee, got %v", dust.Div64(2), txn.MinerFees)
+	}
+	checkBalanced(t, w, txn)
+	w.releaseInputs(txn)
+
+	// A change just above the threshold is kept.
+	amount = big.SiacoinOutput.Value.Sub(dust)
+	txn = types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: amount}},
+	}
+	if _, _, err := w.addInputs(&txn, amount); err != nil {
+		t.Fatal(err)
+	}
+	if len(txn.SiacoinOutputs) != 2 || !txn.SiacoinOutputs[1].Value.Equals(dust) {
+		t.Fatal("expected a change output of", dust)
+	}
+	checkBalanced(t, w, txn)
+}
+
+func TestAncestorDepth(t *testing.T) {
+	if depth := ancestorDepth(nil); depth != 0 {
+		t.Fatalf("expected depth 0, got %v", depth)
+	}
+
+	// A chain of three transactions next to an independent one.
+	chain := make([]types.Transaction, 3)
+	chain[0].SiacoinOutputs = []types.SiacoinOutput{{Value: types.Siacoins(1)}}
+	for i := 1; i < len(chain); i++ {
+		chain[i].SiacoinInputs = []types.SiacoinInput{{ParentID: chain[i-1].SiacoinOutputID(0)}}
+		chain[i].SiacoinOutputs = []types.SiacoinOutput{{Value: types.Siacoins(uint32(i + 1))}}
+	}
+	other := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: frand.Entropy256()}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.Siacoins(10)}},
+	}
+	if depth := ancestorDepth(append([]types.Transaction{other}, chain...)); depth != 3 {
+		t.Fatalf("expected depth 3, got %v", depth)
+	}
+}
+
+// spendTestOutput returns a signed transaction sending the output to
+// the address.
+func spendTestOutput(cm *chain.Manager, sk types.PrivateKey, id types.SiacoinOutputID, value types.Currency, addr types.Address) types.Transaction {
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         id,
+			UnlockConditions: types.StandardUnlockConditions(sk.PublicKey()),
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{Address: addr, Value: value}},
+		Signatures: []types.TransactionSignature{{
+			ParentID:      types.Hash256(id),
+			CoveredFields: types.CoveredFields{WholeTransaction: true},
+		}},
+	}
+	sig := sk.SignHash(cm.TipState().WholeSigHash(txn, types.Hash256(id), 0, 0, nil))
+	txn.Signatures[0].Signature = sig[:]
+	return txn
+}
+
+func TestFundAncestorLimit(t *testing.T) {
+	w := newTestWallet(t)
+	sk := types.GeneratePrivateKey()
+	addr := types.StandardUnlockHash(sk.PublicKey())
+
+	// Mine a payout and let it mature.
+	var payout types.Block
+	for mature := w.cm.TipState().MaturityHeight(); w.cm.Tip().Height < mature; {
+		b, ok := coreutils.MineBlock(w.cm, addr, time.Second)
+		if !ok {
+			t.Fatal("couldn't mine a block")
+		} else if err := w.cm.AddBlocks([]types.Block{b}); err != nil {
+			t.Fatal(err)
+		}
+		if payout.ParentID == (types.BlockID{}) {
+			payout = b
+		}
+	}
+
+	// Build a chain of two unconfirmed transactions.
+	value := payout.MinerPayouts[0].Value
+	first := spendTestOutput(w.cm, sk, payout.ID().MinerOutputID(0), value, addr)
+	second := spendTestOutput(w.cm, sk, first.SiacoinOutputID(0), value, addr)
+	if _, err := w.cm.AddPoolTransactions([]types.Transaction{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	addTestOutput(w, types.Siacoins(10))
+
+	// A transaction spending the end of the chain depends on both.
+	newTxn := func() types.Transaction {
+		return types.Transaction{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: second.SiacoinOutputID(0)}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: value.Add(types.Siacoins(1))}},
+		}
+	}
+	w.maxAncestorDepth = 1
+	txn := newTxn()
+	if _, _, err := w.addInputs(&txn, types.Siacoins(1)); !errors.Is(err, errAncestorsTooDeep) {
+		t.Fatalf("expected %v, got %v", errAncestorsTooDeep, err)
+	} else if len(w.used) != 0 {
+		t.Fatal("expected the inputs to be released")
+	}
+
+	w.maxAncestorDepth = 2
+	txn = newTxn()
+	parents, _, err := w.addInputs(&txn, types.Siacoins(1))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(parents) != 2 {
+		t.Fatalf("expected 2 parents, got %v", len(parents))
+	}
+	checkParentOrder(t, parents)
+}
+
+func TestSpendable(t *testing.T) {
+	w := newTestWallet(t)
+	w.requiredConfirmations = 2
+	mineTestBlocks(t, w.cm, 5)
+	height := w.cm.Tip().Height
+
+	// Only the first output is spendable.
+	spendable := addTestOutput(w, types.Siacoins(1))
+	w.sceHeights[spendable.SiacoinOutput.Address] = height - 2
+	immature := addTestOutput(w, types.Siacoins(2))
+	immature.MaturityHeight = height + 1
+	w.sces[immature.SiacoinOutput.Address] = immature
+	w.sceHeights[immature.SiacoinOutput.Address] = 0
+	unconfirmed := addTestOutput(w, types.Siacoins(3))
+	w.sceHeights[unconfirmed.SiacoinOutput.Address] = height - 1
+	dust := addTestOutput(w, w.DustThreshold())
+	w.sceHeights[dust.SiacoinOutput.Address] = 0
+	reserved := addTestOutput(w, types.Siacoins(4))
+	w.sceHeights[reserved.SiacoinOutput.Address] = 0
+	w.used[reserved.ID] = 0
+
+	if balance := w.SpendableBalance(); !balance.Equals(types.Siacoins(1)) {
+		t.Fatalf("expected a spendable balance of %v, got %v", types.Siacoins(1), balance)
+	}
+	selected, sum, err := w.selectInputs(types.Siacoins(1))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(selected) != 1 || selected[0].ID != spendable.ID || !sum.Equals(types.Siacoins(1)) {
+		t.Fatal("expected only the spendable output to be selected")
+	}
+	if _, _, err := w.selectInputs(types.Siacoins(2)); !errors.Is(err, modules.ErrInsufficientBalance) {
+		t.Fatalf("expected %v, got %v", modules.ErrInsufficientBalance, err)
+	}
+}
@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+)
+
+var (
+	// errInvalidMultisig is returned when the number of the required
+	// signatures doesn't match the number of the public keys.
+	errInvalidMultisig = errors.New("the number of required signatures must be between 1 and the number of public keys")
+
+	// errDuplicateKey is returned when a public key is given more than
+	// once.
+	errDuplicateKey = errors.New("duplicate public key")
+
+	// errNoWalletKey is returned when the wallet is supposed to sign
+	// for a multisig address but holds none of its keys.
+	errNoWalletKey = errors.New("none of the public keys belongs to the wallet")
+)
+
+// loadMultisig loads the registered multisig addresses. The table is
+// created first if the database predates it.
+func (w *Wallet) loadMultisig() error {
+	_, err := w.db.Exec(`
+		CREATE TABLE IF NOT EXISTS wt_multisig (
+			id   BIGINT NOT NULL AUTO_INCREMENT,
+			addr BINARY(32) NOT NULL UNIQUE,
+			uc   BLOB NOT NULL,
+			PRIMARY KEY (id)
+		)
+	`)
+	if err != nil {
+		return modules.AddContext(err, "couldn't create multisig table")
+	}
+
+	rows, err := w.db.Query("SELECT addr, uc FROM wt_multisig")
+	if err != nil {
+		return modules.AddContext(err, "couldn't query multisig addresses")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var addr types.Address
+		a := make([]byte, 32)
+		var b []byte
+		if err := rows.Scan(&a, &b); err != nil {
+			return modules.AddContext(err, "couldn't scan multisig address")
+		}
+		var uc types.UnlockConditions
+		d := types.NewBufDecoder(b)
+		uc.DecodeFrom(d)
+		if err := d.Err(); err != nil {
+			return modules.AddContext(err, "couldn't decode unlock conditions")
+		}
+		copy(addr[:], a)
+		w.multisig[addr] = uc
+	}
+
+	return nil
+}
+
+// CreateMultisigAddress builds the unlock conditions of an M-of-N
+// multisig address from the given public keys, which may mix the keys
+// of the wallet with external ones. If sign is true, at least one of
+// the keys must belong to the wallet. The address is registered, so
+// that Sign adds the signatures of the wallet's keys to the inputs
+// spending from it.
+func (w *Wallet) CreateMultisigAddress(pubkeys []types.PublicKey, required uint64, sign bool) (types.UnlockConditions, types.Address, error) {
+	if required == 0 || required > uint64(len(pubkeys)) {
+		return types.UnlockConditions{}, types.Address{}, errInvalidMultisig
+	}
+
+	uc := types.UnlockConditions{SignaturesRequired: required}
+	seen := make(map[types.PublicKey]struct{})
+	for _, pk := range pubkeys {
+		if _, ok := seen[pk]; ok {
+			return types.UnlockConditions{}, types.Address{}, errDuplicateKey
+		}
+		seen[pk] = struct{}{}
+		uc.PublicKeys = append(uc.PublicKeys, pk.UnlockKey())
+	}
+	addr := uc.UnlockHash()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if sign {
+		var owned bool
+		for _, pk := range pubkeys {
+			if _, ok := w.keys[types.StandardUnlockHash(pk)]; ok {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return types.UnlockConditions{}, types.Address{}, errNoWalletKey
+		}
+	}
+
+	if _, ok := w.multisig[addr]; ok {
+		return uc, addr, nil
+	}
+
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	uc.EncodeTo(e)
+	e.Flush()
+	_, err := w.tx.Exec(`
+		INSERT INTO wt_multisig (addr, uc)
+		VALUES (?, ?)
+	`, addr[:], buf.Bytes())
+	if err != nil {
+		w.dbError = true
+		return types.UnlockConditions{}, types.Address{}, modules.ComposeErrors(modules.AddContext(err, "couldn't save multisig address"), w.save())
+	}
+	w.multisig[addr] = uc
+
+	return uc, addr, w.save()
+}
+
+// multisigKey returns the wallet's key for the public key at the given
+// index of a registered multisig address. w.mu must be held.
+func (w *Wallet) multisigKey(addr types.Address, index uint64) (types.PrivateKey, bool) {
+	uc, ok := w.multisig[addr]
+	if !ok || index >= uint64(len(uc.PublicKeys)) {
+		return nil, false
+	}
+	uk := uc.PublicKeys[index]
+	if uk.Algorithm != types.SpecifierEd25519 || len(uk.Key) != len(types.PublicKey{}) {
+		return nil, false
+	}
+	key, ok := w.keys[types.StandardUnlockHash(types.PublicKey(uk.Key))]
+	return key, ok
+}
+
+// cosign adds a signature of every wallet's key of a registered
+// multisig address to the input with the given parent ID. w.mu must
+// be held.
+func (w *Wallet) cosign(cs consensus.State, txn *types.Transaction, parent types.Hash256, addr types.Address) {
+	for i := range w.multisig[addr].PublicKeys {
+		if key, ok := w.multisigKey(addr, uint64(i)); ok {
+			sig := StandardTransactionSignature(parent)
+			sig.PublicKeyIndex = uint64(i)
+			txn.Signatures = append(txn.Signatures, sig)
+			SignTransaction(cs, txn, len(txn.Signatures)-1, key)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// respendTimeout is the number of blocks after which an output
+	// marked as spent by the wallet can be spent again, if the
+	// transaction spending it hasn't been confirmed by then.
+	respendTimeout = 40
+
+	// pruneUsedInterval is how often the expired spent marks are
+	// removed.
+	pruneUsedInterval = 10 * time.Minute
+)
+
+// pruneUsed removes the spent marks older than respendTimeout and
+// returns the number of removed marks. The outputs reserved with Reserve
+// are kept until their reservation runs out. w.mu must be held.
+func (w *Wallet) pruneUsed() (pruned int) {
+	for id, height := range w.used {
+		if w.expired(id, height) {
+			delete(w.used, id)
+			pruned++
+		}
+	}
+	return
+}
+
+// expired returns whether the spent mark set at the given height has
+// outlived respendTimeout. w.mu must be held.
+func (w *Wallet) expired(id types.Hash256, height uint64) bool {
+	if _, ok := w.reserved[id]; ok {
+		return false
+	}
+	return height+respendTimeout <= w.tip.Height
+}
+
+// threadedPruneUsed periodically removes the expired spent marks, so
+// that the outputs of the transactions that never got confirmed become
+// spendable again.
+func (w *Wallet) threadedPruneUsed() {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+
+	for {
+		select {
+		case <-w.tg.StopChan():
+			return
+		case <-time.After(pruneUsedInterval):
+		}
+
+		w.mu.Lock()
+		pruned := w.pruneUsed()
+		w.mu.Unlock()
+		if pruned > 0 {
+			w.log.Info("pruned expired spent outputs", zap.Int("pruned", pruned))
+		}
+	}
+}
+
+// SpentOutputs returns the number of outputs currently marked as spent
+// by the wallet, and how many of them have outlived respendTimeout and
+// will be pruned.
+func (w *Wallet) SpentOutputs() (count, expired int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	count = len(w.used)
+	for id, height := range w.used {
+		if w.expired(id, height) {
+			expired++
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+func TestPruneUsedReserved(t *testing.T) {
+	w := newTestWallet(t)
+
+	spent := types.Hash256{1}
+	reserved := types.Hash256{2}
+	w.used[spent] = 0
+	if err := w.Reserve([]types.Hash256{reserved}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both marks are old enough to be pruned, but the reservation is
+	// still running.
+	w.tip.Height = respendTimeout
+	if count, expired := w.SpentOutputs(); count != 2 || expired != 1 {
+		t.Fatalf("expected 2 spent outputs and 1 expired, got %v and %v", count, expired)
+	}
+	w.mu.Lock()
+	pruned := w.pruneUsed()
+	w.mu.Unlock()
+	if pruned != 1 {
+		t.Fatalf("expected 1 mark to be pruned, got %v", pruned)
+	}
+	if _, ok := w.used[spent]; ok {
+		t.Fatal("expected the expired spent mark to be pruned")
+	} else if _, ok := w.used[reserved]; !ok {
+		t.Fatal("expected the reserved output to stay marked")
+	}
+	if count, expired := w.SpentOutputs(); count != 1 || expired != 0 {
+		t.Fatalf("expected 1 spent output and none expired, got %v and %v", count, expired)
+	}
+	if err := w.Reserve([]types.Hash256{reserved}, time.Hour); err == nil {
+		t.Fatal("expected the output to still be reserved")
+	}
+}
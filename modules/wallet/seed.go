@@ -2,6 +2,8 @@ package wallet
 
 import (
 	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
 	"runtime"
 	"sync"
 
@@ -21,6 +23,10 @@ const (
 	lookaheadRescanThreshold = uint64(1000)
 )
 
+// fingerprintSalt is the public salt used for deriving the seed
+// fingerprint.
+var fingerprintSalt = []byte("sia-satellite seed fingerprint")
+
 // maxLookahead returns the size of the lookahead for a given seed progress
 // which usually is the current primarySeedProgress.
 func maxLookahead(start uint64) uint64 {
@@ -46,8 +52,9 @@ func generateKeys(seed modules.Seed, start, n uint64) []types.PrivateKey {
 }
 
 func (w *Wallet) generate(index uint64) {
-	for index > uint64(len(w.keys)) {
-		key := modules.KeyFromSeed(&w.seed, uint64(len(w.keys)))
+	// Imported keys are not derived from the seed.
+	for index > uint64(len(w.keys)-len(w.imported)) {
+		key := modules.KeyFromSeed(&w.seed, uint64(len(w.keys)-len(w.imported)))
 		addr := types.StandardUnlockHash(key.PublicKey())
 		w.keys[addr] = key
 		if err := w.insertAddress(addr); err != nil {
@@ -230,3 +237,18 @@ func (w *Wallet) RenterSeed(email string) []byte {
 	copy(renterSeed, rs[:])
 	return renterSeed
 }
+
+// Fingerprint returns a salted hash of the wallet seed. The fingerprint
+// is the same on all nodes using the same seed, but the seed cannot be
+// recovered from it, so it is safe to share, e.g. in logs.
+func (w *Wallet) Fingerprint() (fp types.Hash256, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lockState.Locked {
+		return types.Hash256{}, modules.ErrWalletLocked
+	}
+	mac := hmac.New(sha256.New, fingerprintSalt)
+	mac.Write(w.seed[:])
+	copy(fp[:], mac.Sum(nil))
+	return
+}
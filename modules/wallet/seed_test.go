@@ -0,0 +1,32 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"lukechampine.com/frand"
+)
+
+func TestFingerprintLocked(t *testing.T) {
+	w := newTestWallet(t)
+	frand.Read(w.seed[:])
+	fp, err := w.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same seed gives the same fingerprint.
+	other := newTestWallet(t)
+	other.seed = w.seed
+	if ofp, err := other.Fingerprint(); err != nil {
+		t.Fatal(err)
+	} else if ofp != fp {
+		t.Fatal("expected the same fingerprint for the same seed")
+	}
+
+	w.lockState.Locked = true
+	if _, err := w.Fingerprint(); !errors.Is(err, modules.ErrWalletLocked) {
+		t.Fatalf("expected %v, got %v", modules.ErrWalletLocked, err)
+	}
+}
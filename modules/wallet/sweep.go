@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// maxSweepInputs is the maximum number of inputs of a sweep transaction.
+// It keeps the transaction well below the size the syncer relays.
+const maxSweepInputs = 1000
+
+var (
+	// errBalanceBelowFee is returned when the spendable balance doesn't
+	// cover the fee of a sweep transaction.
+	errBalanceBelowFee = errors.New("spendable balance doesn't cover the transaction fee")
+
+	// errTooManySweepInputs is returned when the spendable outputs don't
+	// fit into one sweep transaction.
+	errTooManySweepInputs = errors.New("too many spendable outputs to sweep in one transaction")
+)
+
+// SendMax creates a transaction sending all spendable Siacoins to
+// 'dest'. The fee is deducted from the amount sent, so that no change
+// is left. The outputs that SpendableBalance doesn't count, i.e. the
+// reserved, unconfirmed, immature, and dust ones, are not swept. If
+// there are more than maxSweepInputs spendable outputs, nothing is sent.
+// If feeRate (per weight unit) is zero, the recommended fee rate is used.
+func (w *Wallet) SendMax(dest types.Address, feeRate types.Currency) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	defer w.beginOperation()()
+
+	log := w.log.With(zap.String("op", newOperationID()), zap.Stringer("destination", dest))
+	if !w.synced() {
+		return nil, errors.New("cannot send Siacoins until fully synced")
+	}
+	if feeRate.IsZero() {
+		feeRate = w.cm.RecommendedFee()
+	}
+	cs := w.cm.TipState()
+
+	w.mu.Lock()
+	if w.lockState.Locked {
+		w.mu.Unlock()
+		return nil, modules.ErrWalletLocked
+	}
+
+	// Gather the spendable outputs.
+	var txn types.Transaction
+	var total types.Currency
+	var toSign []types.Hash256
+	spendable := w.spendable()
+	for _, sce := range w.sces {
+		if !spendable(sce, cs.Index.Height) {
+			continue
+		}
+		key, ok := w.keys[sce.SiacoinOutput.Address]
+		if !ok {
+			continue
+		}
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         types.SiacoinOutputID(sce.ID),
+			UnlockConditions: types.StandardUnlockConditions(key.PublicKey()),
+		})
+		txn.Signatures = append(txn.Signatures, StandardTransactionSignature(sce.ID))
+		toSign = append(toSign, sce.ID)
+		total = total.Add(sce.SiacoinOutput.Value)
+	}
+	if len(toSign) == 0 {
+		w.mu.Unlock()
+		return nil, modules.ErrInsufficientBalance
+	} else if len(toSign) > maxSweepInputs {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d outputs, at most %d allowed; wait for the wallet to defragment them", errTooManySweepInputs, len(toSign), maxSweepInputs)
+	}
+
+	// Compute the fee from the size of the signed transaction. The
+	// encoded size of a currency grows with its value, so the total is
+	// used as a placeholder for both the payment and the fee, which
+	// makes the estimate an upper bound.
+	txn.SiacoinOutputs = []types.SiacoinOutput{{Value: total, Address: dest}}
+	txn.MinerFees = []types.Currency{total}
+	weight := cs.TransactionWeight(txn) + 64*uint64(len(txn.Signatures))
+	fee := feeRate.Mul64(weight)
+	if total.Cmp(fee) <= 0 {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v available, %v required", errBalanceBelowFee, total, fee)
+	}
+	amount := total.Sub(fee)
+	txn.SiacoinOutputs[0].Value = amount
+	txn.MinerFees[0] = fee
+
+	for _, id := range toSign {
+		w.used[id] = w.tip.Height
+	}
+	log = log.With(zap.Stringer("txid", txn.ID()))
+	if err := w.sign(cs, &txn, toSign); err != nil {
+		w.releaseInputs(txn)
+		w.mu.Unlock()
+		log.Error("failed to sign transaction", zap.Error(err))
+		return nil, modules.AddContext(err, "unable to sign transaction")
+	}
+	w.mu.Unlock()
+
+	txnSet := []types.Transaction{txn}
+	if _, err := w.cm.AddPoolTransactions(txnSet); err != nil {
+		w.mu.Lock()
+		w.releaseInputs(txn)
+		w.mu.Unlock()
+		log.Error("transaction set rejected", zap.Error(err))
+		return nil, modules.AddContext(err, "invalid transaction set")
+	}
+
+	w.s.BroadcastTransactionSet(txnSet)
+	log.Info("successfully swept the wallet", zap.Stringer("amount", amount), zap.Stringer("fee", fee), zap.Int("inputs", len(toSign)))
+	if err := w.recordAudit(txn); err != nil {
+		log.Error("failed to record audit log entry", zap.Error(err))
+	}
+
+	return txnSet, nil
+}
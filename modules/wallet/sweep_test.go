@@ -0,0 +1,32 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/types"
+)
+
+// testSyncer is a syncer that is always synced.
+type testSyncer struct {
+	modules.Syncer
+}
+
+// Synced implements modules.Syncer.
+func (testSyncer) Synced() bool { return true }
+
+func TestSendMaxInputCap(t *testing.T) {
+	w := newTestWallet(t)
+	w.s = testSyncer{}
+	mineTestBlocks(t, w.cm, 1)
+
+	for i := 0; i <= maxSweepInputs; i++ {
+		addTestOutput(w, types.Siacoins(1))
+	}
+	if _, err := w.SendMax(types.VoidAddress, types.NewCurrency64(1)); !errors.Is(err, errTooManySweepInputs) {
+		t.Fatalf("expected %v, got %v", errTooManySweepInputs, err)
+	} else if len(w.used) != 0 {
+		t.Fatalf("expected no outputs to be marked as spent, got %v", len(w.used))
+	}
+}
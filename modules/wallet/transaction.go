@@ -12,7 +12,7 @@ func (w *Wallet) checkOutput(sce types.SiacoinElement, dustThreshold types.Curre
 		return errDustOutput
 	}
 	// Check that this output has not recently been spent by the wallet.
-	if spent := w.used[sce.ID]; spent {
+	if _, spent := w.used[sce.ID]; spent {
 		return errSpentOutput
 	}
 
@@ -16,21 +16,31 @@ type chainUpdate interface {
 	ForEachSiafundElement(func(types.SiafundElement, bool))
 }
 
-func (w *Wallet) applyEvents(events []Event) {
+func (w *Wallet) applyEvents(events []Event) error {
 	for _, event := range events {
 		w.log.Info("found", zap.String("new", event.String()))
+		if err := w.insertEvent(event); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (w *Wallet) revertEvents(events []Event) {
+func (w *Wallet) revertEvents(events []Event) error {
 	for _, event := range events {
 		w.log.Info("found", zap.String("reverted", event.String()))
+		if err := w.deleteEvent(event); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (w *Wallet) addSiacoinElements(sces []types.SiacoinElement) error {
+// addSiacoinElements adds the Siacoin elements confirmed at the given
+// height.
+func (w *Wallet) addSiacoinElements(sces []types.SiacoinElement, height uint64) error {
 	for _, sce := range sces {
-		if err := w.insertSiacoinElement(sce); err != nil {
+		if err := w.insertSiacoinElement(sce, height); err != nil {
 			return modules.AddContext(err, "failed to insert output")
 		}
 		w.log.Debug("added UTXO", zap.Stringer("address", sce.SiacoinOutput.Address), zap.Stringer("value", sce.SiacoinOutput.Value))
@@ -112,7 +122,7 @@ func (w *Wallet) applyChainUpdate(cau chain.ApplyUpdate) error {
 		}
 	})
 
-	if err := w.addSiacoinElements(newSiacoinElements); err != nil {
+	if err := w.addSiacoinElements(newSiacoinElements, cau.State.Index.Height); err != nil {
 		return modules.AddContext(err, "failed to add Siacoin elements")
 	} else if err := w.removeSiacoinElements(spentSiacoinElements); err != nil {
 		return modules.AddContext(err, "failed to remove Siacoin elements")
@@ -143,7 +153,9 @@ func (w *Wallet) applyChainUpdate(cau chain.ApplyUpdate) error {
 	}
 
 	// Apply new events.
-	w.applyEvents(AppliedEvents(cau.State, cau.Block, cau, relevantAddress))
+	if err := w.applyEvents(AppliedEvents(cau.State, cau.Block, cau, relevantAddress)); err != nil {
+		return modules.AddContext(err, "failed to apply events")
+	}
 
 	// Update proofs.
 	if err := w.updateSiacoinElementProofs(cau); err != nil {
@@ -202,7 +214,9 @@ func (w *Wallet) revertChainUpdate(cru chain.RevertUpdate) error {
 	})
 
 	// Revert Siacoin element changes.
-	if err := w.addSiacoinElements(addedSiacoinElements); err != nil {
+	// The original confirmation height of the restored elements is
+	// unknown, so the height of the new tip is assumed.
+	if err := w.addSiacoinElements(addedSiacoinElements, cru.State.Index.Height); err != nil {
 		return modules.AddContext(err, "failed to add Siacoin elements")
 	} else if err := w.removeSiacoinElements(removedSiacoinElements); err != nil {
 		return modules.AddContext(err, "failed to remove Siacoin elements")
@@ -242,7 +256,9 @@ func (w *Wallet) revertChainUpdate(cru chain.RevertUpdate) error {
 	}
 
 	// Revert events.
-	w.revertEvents(AppliedEvents(cru.State, cru.Block, cru, relevantAddress))
+	if err := w.revertEvents(AppliedEvents(cru.State, cru.Block, cru, relevantAddress)); err != nil {
+		return modules.AddContext(err, "failed to revert events")
+	}
 
 	if err := w.updateTip(cru.State.Index); err != nil {
 		return modules.AddContext(err, "failed to update last indexed tip")
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"path/filepath"
@@ -33,17 +34,81 @@ type (
 		seed         modules.Seed
 		addrs        map[types.Address]uint64
 		keys         map[types.Address]types.PrivateKey
+		imported     map[types.Address]types.PrivateKey
 		unusedKeys   map[types.Address]types.UnlockConditions
 		lookahead    map[types.Address]uint64
 		watchedAddrs map[types.Address]uint64
 		sces         map[types.Address]types.SiacoinElement
+		sceHeights   map[types.Address]uint64
 		sfes         map[types.Address]types.SiafundElement
-		used         map[types.Hash256]bool
+		used         map[types.Hash256]uint64
+		reserved     map[types.Hash256]struct{}
+		multisig     map[types.Address]types.UnlockConditions
 		tip          types.ChainIndex
 		dbError      bool
+
+		// importKey encrypts the imported keys. It is loaded from a file
+		// outside of the database.
+		importKey [32]byte
+
+		// dir is the directory of the wallet's files.
+		dir string
+
+		// auditIndex and auditHash are the index and the hash of the
+		// next audit log entry's predecessor.
+		auditIndex uint64
+		auditHash  types.Hash256
+
+		// rescanChan signals the wallet to rescan the blockchain.
+		rescanChan chan struct{}
+
+		lockState       modules.WalletLockEvent
+		lockSubscribers map[chan modules.WalletLockEvent]struct{}
+
+		// autoLock is the period of inactivity after which the wallet
+		// is locked. activeOps is the number of in-flight operations
+		// that postpone the auto-lock.
+		autoLock     time.Duration
+		lastActivity time.Time
+		activeOps    int
+
+		// eventCounts is the number of recorded events per type.
+		eventCounts map[string]int
+
+		// maxAncestorDepth is the maximum length of the chain of
+		// unconfirmed transactions a funded transaction may depend on.
+		maxAncestorDepth int
+
+		// requiredConfirmations is the number of blocks an output must
+		// have been confirmed for to count towards the balance.
+		requiredConfirmations uint64
 	}
 )
 
+// Shutdown stops the wallet from accepting new operations, waits for
+// the ones in flight to finish, and saves the wallet state. If ctx
+// expires first, Shutdown returns ctx.Err() and the shutdown completes
+// in the background.
+func (w *Wallet) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	inFlight := w.activeOps
+	w.mu.Unlock()
+	w.log.Info("shutting down", zap.Int("inFlight", inFlight))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		w.log.Warn("shutdown timed out, operations still in flight")
+		return ctx.Err()
+	}
+}
+
 // Close shuts down the wallet.
 func (w *Wallet) Close() error {
 	err := w.tg.Stop()
@@ -147,6 +212,36 @@ func (w *Wallet) UnspentSiafundOutputs() (sfes []types.SiafundElement) {
 	return
 }
 
+// SiacoinElement returns the unspent SC element with the given ID,
+// together with the wallet tip its Merkle proof is valid at.
+func (w *Wallet) SiacoinElement(id types.SiacoinOutputID) (types.SiacoinElement, types.ChainIndex, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sce := range w.sces {
+		if sce.ID == types.Hash256(id) {
+			sce.MerkleProof = append([]types.Hash256(nil), sce.MerkleProof...)
+			return sce, w.tip, true
+		}
+	}
+	return types.SiacoinElement{}, w.tip, false
+}
+
+// SiafundElement returns the unspent SF element with the given ID,
+// together with the wallet tip its Merkle proof is valid at.
+func (w *Wallet) SiafundElement(id types.SiafundOutputID) (types.SiafundElement, types.ChainIndex, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sfe := range w.sfes {
+		if sfe.ID == types.Hash256(id) {
+			sfe.MerkleProof = append([]types.Hash256(nil), sfe.MerkleProof...)
+			return sfe, w.tip, true
+		}
+	}
+	return types.SiafundElement{}, w.tip, false
+}
+
 // Annotate annotates the given transactions with the wallet.
 func (w *Wallet) Annotate(pool []types.Transaction) []modules.PoolTransaction {
 	w.mu.Lock()
@@ -207,6 +302,11 @@ func (w *Wallet) subscribe() {
 		case <-w.tg.StopChan():
 			return
 		case <-reorgChan:
+		case <-w.rescanChan:
+			if err := w.rescan(); err != nil {
+				w.log.Error("failed to reset wallet before rescanning", zap.Error(err))
+				continue
+			}
 		}
 
 		if err := w.sync(w.tip); err != nil {
@@ -227,27 +327,53 @@ func New(db *sql.DB, cm *chain.Manager, s modules.Syncer, seed, dir string) (*Wa
 		return nil, modules.AddContext(err, "unable to create logger")
 	}
 
+	importKey, err := loadImportKey(dir)
+	if err != nil {
+		closeFn()
+		return nil, modules.AddContext(err, "unable to load import key")
+	}
+
 	w := &Wallet{
 		cm:           cm,
 		s:            s,
 		db:           db,
 		log:          logger,
 		closeFn:      closeFn,
-		used:         make(map[types.Hash256]bool),
+		dir:          dir,
+		importKey:    importKey,
+		used:         make(map[types.Hash256]uint64),
+		reserved:     make(map[types.Hash256]struct{}),
+		multisig:     make(map[types.Address]types.UnlockConditions),
 		addrs:        make(map[types.Address]uint64),
 		keys:         make(map[types.Address]types.PrivateKey),
+		imported:     make(map[types.Address]types.PrivateKey),
 		lookahead:    make(map[types.Address]uint64),
 		unusedKeys:   make(map[types.Address]types.UnlockConditions),
 		watchedAddrs: make(map[types.Address]uint64),
 		sces:         make(map[types.Address]types.SiacoinElement),
+		sceHeights:   make(map[types.Address]uint64),
 		sfes:         make(map[types.Address]types.SiafundElement),
+		rescanChan:   make(chan struct{}, 1),
+		eventCounts:  make(map[string]int),
+
+		maxAncestorDepth: defaultMaxAncestorDepth,
+
+		lockState:       modules.WalletLockEvent{Timestamp: time.Now()},
+		lockSubscribers: make(map[chan modules.WalletLockEvent]struct{}),
+		lastActivity:    time.Now(),
 	}
 
 	if err := w.load(); err != nil {
 		return nil, modules.AddContext(err, "unable to load wallet")
 	}
 
+	if err := w.verifyAuditLog(); err != nil {
+		return nil, modules.AddContext(err, "unable to load audit log")
+	}
+
 	go w.threadedSaveWallet()
+	go w.threadedAutoLock()
+	go w.threadedPruneUsed()
 
 	if entropy != w.seed {
 		w.log.Info("new seed detected, rescanning")
@@ -256,10 +382,14 @@ func New(db *sql.DB, cm *chain.Manager, s modules.Syncer, seed, dir string) (*Wa
 		w.keys = make(map[types.Address]types.PrivateKey)
 		w.lookahead = make(map[types.Address]uint64)
 		w.sces = make(map[types.Address]types.SiacoinElement)
+		w.sceHeights = make(map[types.Address]uint64)
 		w.sfes = make(map[types.Address]types.SiafundElement)
 		if err := w.reset(); err != nil {
 			return nil, modules.AddContext(err, "couldn't reset database before rescanning")
 		}
+		if err := w.restoreImportedKeys(); err != nil {
+			return nil, modules.AddContext(err, "couldn't restore imported keys")
+		}
 
 		go func() {
 			if err := w.tg.Add(); err != nil {
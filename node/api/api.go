@@ -1,7 +1,10 @@
 package api
 
 import (
+	"time"
+
 	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
 )
 
@@ -10,20 +13,93 @@ type DaemonVersion struct {
 	Version     string `json:"version"`
 	GitRevision string `json:"gitRevision"`
 	BuildTime   string `json:"buildTime"`
+	GoVersion   string `json:"goVersion"`
+}
+
+// DaemonModule contains the information about a module of satd.
+type DaemonModule struct {
+	Loaded   bool      `json:"loaded"`
+	LoadedAt time.Time `json:"loadedAt"`
 }
 
 // SyncerPeer contains the information about a peer.
 type SyncerPeer struct {
-	Address string `json:"address"`
-	Version string `json:"version"`
-	Inbound bool   `json:"inbound"`
+	Address        string    `json:"address"`
+	Version        string    `json:"version"`
+	Inbound        bool      `json:"inbound"`
+	ConnectedSince time.Time `json:"connectedSince"`
 }
 
 // ConsensusTipResponse is the response type for /consensus/tip.
 type ConsensusTipResponse struct {
-	Height  uint64        `json:"height"`
-	BlockID types.BlockID `json:"id"`
-	Synced  bool          `json:"synced"`
+	Height       uint64        `json:"height"`
+	BlockID      types.BlockID `json:"id"`
+	Synced       bool          `json:"synced"`
+	SyncProgress SyncProgress  `json:"syncProgress"`
+}
+
+// SyncProgress describes how far along the initial sync is.
+type SyncProgress struct {
+	Height          uint64  `json:"height"`
+	EstimatedHeight uint64  `json:"estimatedHeight"`
+	Progress        float64 `json:"progress"`
+}
+
+// ConsensusDifficulty contains the difficulty of the child of the block
+// at the given height.
+type ConsensusDifficulty struct {
+	Height     uint64         `json:"height"`
+	BlockID    types.BlockID  `json:"blockID"`
+	Difficulty consensus.Work `json:"difficulty"`
+}
+
+// ConsensusElementProofResponse is the response type for
+// /consensus/element/:id/proof. The Merkle proof of the element is valid
+// against the accumulator at the given chain index.
+type ConsensusElementProofResponse struct {
+	Type           string                       `json:"type"`
+	SiacoinElement *types.SiacoinElement        `json:"siacoinElement,omitempty"`
+	SiafundElement *types.SiafundElement        `json:"siafundElement,omitempty"`
+	Index          types.ChainIndex             `json:"index"`
+	Accumulator    consensus.ElementAccumulator `json:"accumulator"`
+}
+
+// ConsensusSiacoinOutputResponse is the response type for
+// /consensus/siacoinoutput/:id. SpentInPool is true if an unconfirmed
+// transaction spends the output.
+type ConsensusSiacoinOutputResponse struct {
+	ID             types.SiacoinOutputID `json:"id"`
+	Value          types.Currency        `json:"value"`
+	Address        types.Address         `json:"address"`
+	MaturityHeight uint64                `json:"maturityHeight"`
+	SpentInPool    bool                  `json:"spentInPool"`
+}
+
+// ConsensusSiafundOutputResponse is the response type for
+// /consensus/siafundoutput/:id. SpentInPool is true if an unconfirmed
+// transaction spends the output.
+type ConsensusSiafundOutputResponse struct {
+	ID          types.SiafundOutputID `json:"id"`
+	Value       uint64                `json:"value"`
+	Address     types.Address         `json:"address"`
+	ClaimStart  types.Currency        `json:"claimStart"`
+	SpentInPool bool                  `json:"spentInPool"`
+}
+
+// ConsensusReorg describes a change of the best chain that reverted
+// at least one block.
+type ConsensusReorg struct {
+	Timestamp      time.Time        `json:"timestamp"`
+	RevertedBlocks []types.BlockID  `json:"revertedBlocks"`
+	Depth          uint64           `json:"depth"`
+	CommonAncestor types.ChainIndex `json:"commonAncestor"`
+	Tip            types.ChainIndex `json:"tip"`
+}
+
+// TxpoolAgeResponse is the response type for /txpool/age/:id.
+type TxpoolAgeResponse struct {
+	FirstSeen uint64 `json:"firstSeen"`
+	Age       uint64 `json:"age"`
 }
 
 // TxpoolBroadcastRequest is the request type for /txpool/broadcast.
@@ -32,21 +108,76 @@ type TxpoolBroadcastRequest struct {
 	V2Transactions []types.V2Transaction `json:"v2transactions"`
 }
 
+// TxpoolBroadcastResponse is the response type for /txpool/broadcast.
+// The IDs are those of the last transaction of each set, and are zero
+// if the respective set is empty.
+type TxpoolBroadcastResponse struct {
+	ID   types.TransactionID `json:"id"`
+	V2ID types.TransactionID `json:"v2ID"`
+}
+
+// TxpoolDecodeRequest is the request type for /txpool/decode.
+type TxpoolDecodeRequest struct {
+	// Transaction is either a JSON-encoded transaction or the base64
+	// encoding of its binary form.
+	Transaction string `json:"transaction"`
+}
+
+// TxpoolDecodeResponse is the response type for /txpool/decode.
+type TxpoolDecodeResponse struct {
+	Transaction types.Transaction       `json:"transaction"`
+	Annotated   modules.PoolTransaction `json:"annotated"`
+	Fee         types.Currency          `json:"fee"`
+	Weight      uint64                  `json:"weight"`
+	FeeRate     types.Currency          `json:"feeRate"`
+}
+
+// TxpoolFeeFloorResponse is the response type for /txpool/feefloor.
+type TxpoolFeeFloorResponse struct {
+	Dynamic     types.Currency `json:"dynamic"`
+	Floor       types.Currency `json:"floor"`
+	MinRelayFee types.Currency `json:"minRelayFee"`
+}
+
 // TxpoolTransactionsResponse is the response type for /txpool/transactions.
 type TxpoolTransactionsResponse struct {
 	Transactions   []types.Transaction   `json:"transactions"`
 	V2Transactions []types.V2Transaction `json:"v2transactions"`
 }
 
+// WalletAuditResponse is the response type for /wallet/audit.
+type WalletAuditResponse struct {
+	Entries []modules.AuditEntry `json:"entries"`
+	Total   uint64               `json:"total"`
+}
+
+// WalletTransactionResponse is the response type for
+// /wallet/transaction/:id. Event is set if the transaction is confirmed,
+// Unconfirmed if it is still in the txpool.
+type WalletTransactionResponse struct {
+	Event       *modules.WalletEvent     `json:"event,omitempty"`
+	Unconfirmed *modules.PoolTransaction `json:"unconfirmed,omitempty"`
+}
+
 // WalletBalanceResponse is the response type for /wallet/balance.
 type WalletBalanceResponse struct {
-	Height           uint64         `json:"height"`
-	Siacoins         types.Currency `json:"siacoins"`
-	ImmatureSiacoins types.Currency `json:"immatureSiacoins"`
-	IncomingSiacoins types.Currency `json:"incomingSiacoins"`
-	OutgoingSiacoins types.Currency `json:"outgoingSiacoins"`
-	Siafunds         uint64         `json:"siafunds"`
-	RecommendedFee   types.Currency `json:"recommendedFee"`
+	Height            uint64         `json:"height"`
+	Siacoins          types.Currency `json:"siacoins"`
+	SpendableSiacoins types.Currency `json:"spendableSiacoins"`
+	ImmatureSiacoins  types.Currency `json:"immatureSiacoins"`
+	IncomingSiacoins  types.Currency `json:"incomingSiacoins"`
+	OutgoingSiacoins  types.Currency `json:"outgoingSiacoins"`
+	Siafunds          uint64         `json:"siafunds"`
+	ClaimBalance      types.Currency `json:"claimBalance"`
+	RecommendedFee    types.Currency `json:"recommendedFee"`
+
+	RequiredConfirmations uint64 `json:"requiredConfirmations"`
+}
+
+// WalletFeesResponse is the response type for /wallet/fees.
+type WalletFeesResponse struct {
+	RecommendedFee types.Currency `json:"recommendedFee"`
+	DustThreshold  types.Currency `json:"dustThreshold"`
 }
 
 // WalletOutputsResponse is the response type for /wallet/outputs.
@@ -55,10 +186,122 @@ type WalletOutputsResponse struct {
 	SiafundOutputs []types.SiafundElement `json:"siafundOutputs"`
 }
 
+// WalletBumpRequest is the request type for /wallet/bump.
+type WalletBumpRequest struct {
+	ID      types.TransactionID `json:"id"`
+	FeeRate types.Currency      `json:"feeRate"`
+}
+
+// WalletImportKeyRequest is the request type for /wallet/importkey.
+type WalletImportKeyRequest struct {
+	// Key is the hex-encoded 64-byte private key.
+	Key string `json:"key"`
+}
+
+// WalletMultisigRequest is the request type for /wallet/multisig/create.
+type WalletMultisigRequest struct {
+	PublicKeys []types.PublicKey `json:"publicKeys"`
+	Required   uint64            `json:"required"`
+	// Sign requires one of the keys to belong to the wallet.
+	Sign bool `json:"sign"`
+}
+
+// WalletMultisigResponse is the response type for
+// /wallet/multisig/create.
+type WalletMultisigResponse struct {
+	UnlockConditions types.UnlockConditions `json:"unlockConditions"`
+	Address          types.Address          `json:"address"`
+}
+
+// WalletSignMessageRequest is the request type for /wallet/sign/message.
+type WalletSignMessageRequest struct {
+	Address types.Address `json:"address"`
+	Message string        `json:"message"`
+}
+
+// WalletSignMessageResponse is the response type for /wallet/sign/message.
+type WalletSignMessageResponse struct {
+	Address   types.Address   `json:"address"`
+	PublicKey types.PublicKey `json:"publicKey"`
+	Signature types.Signature `json:"signature"`
+}
+
+// WalletVerifyMessageRequest is the request type for
+// /wallet/verify/message. If PublicKey is not set, the address must
+// belong to the wallet.
+type WalletVerifyMessageRequest struct {
+	Address   types.Address    `json:"address"`
+	Message   string           `json:"message"`
+	Signature types.Signature  `json:"signature"`
+	PublicKey *types.PublicKey `json:"publicKey,omitempty"`
+}
+
+// WalletVerifyMessageResponse is the response type for
+// /wallet/verify/message.
+type WalletVerifyMessageResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// WalletEstimateSizeRequest is the request type for
+// /wallet/estimate/size.
+type WalletEstimateSizeRequest struct {
+	Outputs []types.SiacoinOutput `json:"outputs"`
+}
+
+// WalletEstimateSizeResponse is the response type for
+// /wallet/estimate/size.
+type WalletEstimateSizeResponse struct {
+	Size   uint64 `json:"size"`
+	Inputs int    `json:"inputs"`
+}
+
+// WalletEventsImportResponse is the response type for
+// /wallet/events/import.
+type WalletEventsImportResponse struct {
+	Imported uint64 `json:"imported"`
+	Skipped  uint64 `json:"skipped"`
+}
+
+// WalletSpentOutputsResponse is the response type for
+// /wallet/spentoutputs.
+type WalletSpentOutputsResponse struct {
+	Count   int `json:"count"`
+	Expired int `json:"expired"`
+}
+
+// WalletSettings contains the wallet settings.
+type WalletSettings struct {
+	// AutoLock is the period of inactivity after which the wallet is
+	// locked. Zero disables the auto-lock.
+	AutoLock time.Duration `json:"autoLock"`
+
+	// MaxAncestorDepth is the maximum length of the chain of unconfirmed
+	// transactions a funded transaction may depend on. Zero removes the
+	// limit.
+	MaxAncestorDepth int `json:"maxAncestorDepth"`
+
+	// RequiredConfirmations is the number of blocks an output must have
+	// been confirmed for to count towards the balance.
+	RequiredConfirmations uint64 `json:"requiredConfirmations"`
+}
+
+// WalletUnlockRequest is the request type for /wallet/unlock.
+type WalletUnlockRequest struct {
+	Seed string `json:"seed"`
+}
+
 // WalletSendRequest is the request type for /wallet/send.
 type WalletSendRequest struct {
 	Amount      types.Currency `json:"amount"`
 	Destination types.Address  `json:"destination"`
+	FeeRate     types.Currency `json:"feeRate"`
+
+	// Sign requests the receipt to be signed by the wallet.
+	Sign bool `json:"sign"`
+
+	// Max requests all spendable Siacoins to be sent, the fee
+	// deducted. Amount is ignored then.
+	Max bool `json:"max,omitempty"`
 }
 
 // ExchangeRate contains the exchange rate of a given currency.
@@ -84,6 +327,12 @@ type RentersGET struct {
 	Renters []Renter `json:"renters"`
 }
 
+// RenterSummariesGET contains a page of the renter summaries.
+type RenterSummariesGET struct {
+	Renters []modules.RenterSummary `json:"renters"`
+	Total   int                     `json:"total"`
+}
+
 // RenterContract represents a contract formed by the renter.
 type RenterContract struct {
 	// Amount of contract funds that have been spent on downloads.
@@ -130,6 +379,12 @@ type RenterContract struct {
 	GoodForRenew bool `json:"goodforrenew"`
 	// Signals if a contract has been marked as bad.
 	BadContract bool `json:"badcontract"`
+	// Number of blocks left until the renewal window begins, at the
+	// current block height.
+	BlocksUntilRenewal uint64 `json:"blocksuntilrenewal"`
+	// Number of blocks left until the contract expires, at the current
+	// block height.
+	BlocksUntilExpiry uint64 `json:"blocksuntilexpiry"`
 }
 
 // RenterContracts contains the renter's contracts.
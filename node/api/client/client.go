@@ -1,12 +1,111 @@
 package client
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node/api"
 	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
 	"go.sia.tech/jape"
 )
 
+// ErrAPICallNotRecognized is returned when the API server doesn't
+// know the route, e.g. because the module serving it isn't loaded.
+var ErrAPICallNotRecognized = errors.New("API call not recognized")
+
+// walletLockedError is returned when the server refuses a request
+// because the wallet is locked. It matches modules.ErrWalletLocked.
+type walletLockedError struct {
+	msg string
+}
+
+// Error implements error.
+func (e walletLockedError) Error() string {
+	return e.msg
+}
+
+// Is implements errors.Is.
+func (e walletLockedError) Is(target error) bool {
+	return target == modules.ErrWalletLocked
+}
+
+// mapError converts the error messages returned by the server into
+// the typed errors the callers can check with errors.Is. The jape
+// client only preserves the response body, so the messages are
+// matched.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.TrimSpace(err.Error())
+	switch {
+	case strings.HasSuffix(msg, modules.ErrWalletLocked.Error()):
+		return walletLockedError{msg: msg}
+	case msg == "404 page not found":
+		return fmt.Errorf("%w: %s", ErrAPICallNotRecognized, msg)
+	default:
+		return err
+	}
+}
+
+// stream performs a request with a raw body and returns the response,
+// which the caller must close.
+func (c *Client) stream(method, route string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.c.BaseURL+route, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.c.Password != "" {
+		req.SetBasicAuth("", c.c.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, mapError(errors.New(string(msg)))
+	}
+	return resp, nil
+}
+
+// Exists checks if the resource at the given route exists, without
+// downloading it. A 2xx response means that it exists, and a 404 that
+// it doesn't. Only the read-only resources (pool transactions, renters,
+// and hosts) can be checked; for the other routes
+// ErrAPICallNotRecognized is returned.
+func (c *Client) Exists(resource string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, c.c.BaseURL+resource, nil)
+	if err != nil {
+		return false, err
+	}
+	if c.c.Password != "" {
+		req.SetBasicAuth("", c.c.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		return false, ErrAPICallNotRecognized
+	default:
+		return false, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+}
+
 // A Client provides methods for interacting with the API server.
 type Client struct {
 	c jape.Client
@@ -18,15 +117,40 @@ func (c *Client) DaemonVersion() (resp api.DaemonVersion, err error) {
 	return
 }
 
+// DaemonModules returns the state of the modules of satd.
+func (c *Client) DaemonModules() (resp map[string]api.DaemonModule, err error) {
+	err = c.c.GET("/daemon/modules", &resp)
+	return
+}
+
+// DaemonStop stops satd.
+func (c *Client) DaemonStop() (err error) {
+	err = c.c.POST("/daemon/stop", nil, nil)
+	return
+}
+
 // SyncerPeers returns the current peers of the syncer.
 func (c *Client) SyncerPeers() (resp []api.SyncerPeer, err error) {
 	err = c.c.GET("/syncer/peers", &resp)
 	return
 }
 
-// SyncerConnect adds the address as a peer of the syncer.
-func (c *Client) SyncerConnect(addr string) (err error) {
-	err = c.c.POST("/syncer/connect", addr, nil)
+// SyncerConnect adds the address as a peer of the syncer. If persistent
+// is true, the syncer reconnects to the peer after a restart.
+func (c *Client) SyncerConnect(addr string, persistent bool) (err error) {
+	err = c.c.POST(fmt.Sprintf("/syncer/connect?persistent=%t", persistent), addr, nil)
+	return
+}
+
+// SyncerPersistentPeers returns the addresses of the persistent peers.
+func (c *Client) SyncerPersistentPeers() (resp []string, err error) {
+	err = c.c.GET("/syncer/persistent", &resp)
+	return
+}
+
+// SyncerRemovePersistentPeer removes the address from the persistent peers.
+func (c *Client) SyncerRemovePersistentPeer(addr string) (err error) {
+	err = c.c.DELETE("/syncer/persistent/" + url.PathEscape(addr))
 	return
 }
 
@@ -43,12 +167,55 @@ func (c *Client) ConsensusNetwork() (resp *consensus.Network, err error) {
 	return
 }
 
+// ConsensusReorgs returns the most recent reorgs.
+func (c *Client) ConsensusReorgs() (resp []api.ConsensusReorg, err error) {
+	err = c.c.GET("/consensus/reorgs", &resp)
+	return
+}
+
+// ConsensusDifficulty returns the difficulty at each height between
+// start and end, inclusive.
+func (c *Client) ConsensusDifficulty(start, end uint64) (resp []api.ConsensusDifficulty, err error) {
+	err = c.c.GET(fmt.Sprintf("/consensus/difficulty?start=%d&end=%d", start, end), &resp)
+	return
+}
+
+// ConsensusElementProof returns the unspent element with the given ID
+// and its Merkle proof.
+func (c *Client) ConsensusElementProof(id types.Hash256) (resp api.ConsensusElementProofResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/consensus/element/%v/proof", id), &resp)
+	return
+}
+
+// ConsensusSiacoinOutput returns the unspent Siacoin output with the
+// given ID, if the node tracks it.
+func (c *Client) ConsensusSiacoinOutput(id types.SiacoinOutputID) (resp api.ConsensusSiacoinOutputResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/consensus/siacoinoutput/%v", id), &resp)
+	return
+}
+
+// ConsensusSiafundOutput returns the unspent Siafund output with the
+// given ID, if the node tracks it.
+func (c *Client) ConsensusSiafundOutput(id types.SiafundOutputID) (resp api.ConsensusSiafundOutputResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/consensus/siafundoutput/%v", id), &resp)
+	return
+}
+
 // ConsensusTip returns the current tip index.
 func (c *Client) ConsensusTip() (resp api.ConsensusTipResponse, err error) {
 	err = c.c.GET("/consensus/tip", &resp)
 	return
 }
 
+// ConsensusSyncProgress returns the estimated progress of the sync.
+func (c *Client) ConsensusSyncProgress() (resp api.SyncProgress, err error) {
+	var tip api.ConsensusTipResponse
+	if err = c.c.GET("/consensus/tip", &tip); err != nil {
+		return
+	}
+	return tip.SyncProgress, nil
+}
+
 // ConsensusTipState returns the current tip state.
 func (c *Client) ConsensusTipState() (resp consensus.State, err error) {
 	err = c.c.GET("/consensus/tipstate", &resp)
@@ -69,6 +236,61 @@ func (c *Client) TxpoolFee() (resp types.Currency, err error) {
 	return
 }
 
+// TxpoolFeeFloor returns the dynamic txpool fee, the configured fee
+// floor, and the resulting minimum relay fee (per weight unit).
+func (c *Client) TxpoolFeeFloor() (resp api.TxpoolFeeFloorResponse, err error) {
+	err = c.c.GET("/txpool/feefloor", &resp)
+	return
+}
+
+// TxpoolSetFeeFloor sets the minimum relay fee (per weight unit).
+func (c *Client) TxpoolSetFeeFloor(fee types.Currency) (err error) {
+	err = c.c.POST("/txpool/feefloor", fee, nil)
+	return
+}
+
+// TxpoolAge returns the height at which the transaction entered the
+// txpool and the number of blocks it has been pending since.
+func (c *Client) TxpoolAge(id types.TransactionID) (resp api.TxpoolAgeResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/txpool/age/%v", id), &resp)
+	return
+}
+
+// TxpoolPropagation returns how a recently broadcast transaction set,
+// identified by the ID of its last transaction, has propagated to the
+// peers.
+func (c *Client) TxpoolPropagation(id types.TransactionID) (resp modules.TxnPropagation, err error) {
+	err = c.c.GET(fmt.Sprintf("/txpool/propagation/%v", id), &resp)
+	return
+}
+
+// TxpoolBroadcast adds the transaction sets to the transaction pool and
+// broadcasts them, returning the IDs of the last transaction of each set.
+func (c *Client) TxpoolBroadcast(txns []types.Transaction, v2txns []types.V2Transaction) (resp api.TxpoolBroadcastResponse, err error) {
+	err = c.c.POST("/txpool/broadcast", api.TxpoolBroadcastRequest{
+		Transactions:   txns,
+		V2Transactions: v2txns,
+	}, &resp)
+	return
+}
+
+// TxpoolDecode decodes a JSON- or base64-encoded transaction and
+// returns its parsed structure together with its fee and weight.
+func (c *Client) TxpoolDecode(raw string) (resp api.TxpoolDecodeResponse, err error) {
+	err = c.c.POST("/txpool/decode", api.TxpoolDecodeRequest{Transaction: raw}, &resp)
+	return
+}
+
+// TxpoolConflicts returns the txpool transactions that spend the same
+// objects as the provided transaction set.
+func (c *Client) TxpoolConflicts(txns []types.Transaction, v2txns []types.V2Transaction) (resp api.TxpoolTransactionsResponse, err error) {
+	err = c.c.POST("/txpool/conflicts", api.TxpoolBroadcastRequest{
+		Transactions:   txns,
+		V2Transactions: v2txns,
+	}, &resp)
+	return
+}
+
 // NewClient returns a client that communicates with the API server listening
 // on the specified address.
 func NewClient() *Client {
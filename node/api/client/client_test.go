@@ -0,0 +1,43 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"go.sia.tech/jape"
+)
+
+// newTestClient returns a client of a stub server serving the routes.
+func newTestClient(t *testing.T, routes map[string]jape.Handler) *Client {
+	srv := httptest.NewServer(jape.Mux(routes))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.Client().BaseURL = srv.URL
+	return c
+}
+
+func TestWalletLockedError(t *testing.T) {
+	c := newTestClient(t, map[string]jape.Handler{
+		"GET /wallet/balance": func(jc jape.Context) {
+			jc.Error(modules.ErrWalletLocked, http.StatusLocked)
+		},
+	})
+	if _, err := c.WalletBalance(); !errors.Is(err, modules.ErrWalletLocked) {
+		t.Fatalf("expected %v, got %v", modules.ErrWalletLocked, err)
+	} else if errors.Is(err, ErrAPICallNotRecognized) {
+		t.Fatal("a locked wallet reported as a missing route")
+	}
+
+	// A server without the wallet module doesn't know the route.
+	c = newTestClient(t, map[string]jape.Handler{
+		"GET /daemon/version": func(jc jape.Context) {},
+	})
+	if _, err := c.WalletBalance(); !errors.Is(err, ErrAPICallNotRecognized) {
+		t.Fatalf("expected %v, got %v", ErrAPICallNotRecognized, err)
+	} else if errors.Is(err, modules.ErrWalletLocked) {
+		t.Fatal("a missing route reported as a locked wallet")
+	}
+}
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"fmt"
+
 	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node/api"
 )
@@ -35,6 +37,13 @@ func (c *Client) ManagerRenters() (rg api.RentersGET, err error) {
 	return
 }
 
+// ManagerRenterSummaries requests a page of the /manager/renters/summary
+// resource. sortBy is either "registered" or "spend".
+func (c *Client) ManagerRenterSummaries(offset, limit int, sortBy string) (rs api.RenterSummariesGET, err error) {
+	err = c.c.GET(fmt.Sprintf("/manager/renters/summary?offset=%d&limit=%d&sort=%s", offset, limit, sortBy), &rs)
+	return
+}
+
 // ManagerPreferences requests the /manager/preferences resource.
 func (c *Client) ManagerPreferences() (ep api.EmailPreferences, err error) {
 	err = c.c.GET("/manager/preferences", &ep)
@@ -1,7 +1,11 @@
 package client
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 
 	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node/api"
@@ -14,9 +18,42 @@ func (c *Client) WalletAddress() (addr types.Address, err error) {
 	return
 }
 
+// WalletAddressBatch returns count newly-generated addresses.
+func (c *Client) WalletAddressBatch(count uint64) (addrs []types.Address, err error) {
+	err = c.c.GET(fmt.Sprintf("/wallet/addresses/batch?count=%d", count), &addrs)
+	return
+}
+
 // WalletBalance returns the current wallet balance.
 func (c *Client) WalletBalance() (resp api.WalletBalanceResponse, err error) {
 	err = c.c.GET("/wallet/balance", &resp)
+	err = mapError(err)
+	return
+}
+
+// WalletAudit returns a page of the wallet's audit log.
+func (c *Client) WalletAudit(offset, limit uint64) (resp api.WalletAuditResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/wallet/audit?offset=%d&limit=%d", offset, limit), &resp)
+	return
+}
+
+// WalletFees returns the recommended fee per byte and the dust threshold.
+func (c *Client) WalletFees() (resp api.WalletFeesResponse, err error) {
+	err = c.c.GET("/wallet/fees", &resp)
+	return
+}
+
+// WalletFingerprint returns a salted hash of the wallet seed.
+func (c *Client) WalletFingerprint() (fp types.Hash256, err error) {
+	err = c.c.GET("/wallet/fingerprint", &fp)
+	err = mapError(err)
+	return
+}
+
+// WalletTransaction returns the wallet-relevant transaction with the
+// given ID.
+func (c *Client) WalletTransaction(id types.TransactionID) (resp api.WalletTransactionResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/wallet/transaction/%v", id), &resp)
 	return
 }
 
@@ -57,11 +94,162 @@ func (c *Client) WalletWatchedAddresses() (addrs []types.Address, err error) {
 	return
 }
 
-// WalletSendSiacoins sends a specified amount of SC to the specified address.
-func (c *Client) WalletSendSiacoins(amount types.Currency, dest types.Address) (err error) {
+// WalletSendSiacoins sends a specified amount of SC to the specified address
+// and returns the receipt of the transaction. If feeRate (per byte) is zero,
+// a dynamic fee is used. If sign is true, the receipt is signed by the wallet.
+func (c *Client) WalletSendSiacoins(amount types.Currency, dest types.Address, feeRate types.Currency, sign bool) (receipt modules.SendReceipt, err error) {
 	err = c.c.POST("/wallet/send", api.WalletSendRequest{
 		Amount:      amount,
 		Destination: dest,
+		FeeRate:     feeRate,
+		Sign:        sign,
+	}, &receipt)
+	err = mapError(err)
+	return
+}
+
+// WalletSendMax sends all spendable SC to the specified address, the
+// fee deducted, and returns the receipt of the transaction. If feeRate
+// (per byte) is zero, a dynamic fee is used. If sign is true, the
+// receipt is signed by the wallet.
+func (c *Client) WalletSendMax(dest types.Address, feeRate types.Currency, sign bool) (receipt modules.SendReceipt, err error) {
+	err = c.c.POST("/wallet/send", api.WalletSendRequest{
+		Destination: dest,
+		FeeRate:     feeRate,
+		Sign:        sign,
+		Max:         true,
+	}, &receipt)
+	err = mapError(err)
+	return
+}
+
+// WalletImportKey adds a standalone private key to the wallet.
+func (c *Client) WalletImportKey(sk types.PrivateKey) (err error) {
+	err = c.c.POST("/wallet/importkey", api.WalletImportKeyRequest{
+		Key: hex.EncodeToString(sk),
 	}, nil)
+	err = mapError(err)
+	return
+}
+
+// WalletCreateMultisig creates an M-of-N multisig address from the
+// given public keys.
+func (c *Client) WalletCreateMultisig(pubkeys []types.PublicKey, required uint64, sign bool) (resp api.WalletMultisigResponse, err error) {
+	err = c.c.POST("/wallet/multisig/create", api.WalletMultisigRequest{
+		PublicKeys: pubkeys,
+		Required:   required,
+		Sign:       sign,
+	}, &resp)
+	return
+}
+
+// WalletSignMessage signs a free-form message with the key of the
+// specified wallet address.
+func (c *Client) WalletSignMessage(addr types.Address, msg string) (resp api.WalletSignMessageResponse, err error) {
+	err = c.c.POST("/wallet/sign/message", api.WalletSignMessageRequest{
+		Address: addr,
+		Message: msg,
+	}, &resp)
+	err = mapError(err)
+	return
+}
+
+// WalletVerifyMessage checks the signature of a message. If pk is nil,
+// the address must belong to the wallet.
+func (c *Client) WalletVerifyMessage(addr types.Address, msg string, sig types.Signature, pk *types.PublicKey) (valid bool, err error) {
+	var resp api.WalletVerifyMessageResponse
+	err = c.c.POST("/wallet/verify/message", api.WalletVerifyMessageRequest{
+		Address:   addr,
+		Message:   msg,
+		Signature: sig,
+		PublicKey: pk,
+	}, &resp)
+	return resp.Valid, err
+}
+
+// WalletBumpFee replaces an unconfirmed wallet transaction with one paying
+// the specified fee rate (per byte), and returns the ID of the replacement.
+func (c *Client) WalletBumpFee(id types.TransactionID, feeRate types.Currency) (newID types.TransactionID, err error) {
+	err = c.c.POST("/wallet/bump", api.WalletBumpRequest{
+		ID:      id,
+		FeeRate: feeRate,
+	}, &newID)
+	err = mapError(err)
+	return
+}
+
+// WalletEstimateSize returns the projected size of a transaction paying
+// the given outputs, and the number of inputs it would have.
+func (c *Client) WalletEstimateSize(outputs []types.SiacoinOutput) (resp api.WalletEstimateSizeResponse, err error) {
+	err = c.c.POST("/wallet/estimate/size", api.WalletEstimateSizeRequest{Outputs: outputs}, &resp)
+	return
+}
+
+// WalletEventCounts returns the number of recorded wallet events per
+// type.
+func (c *Client) WalletEventCounts() (counts map[string]int, err error) {
+	err = c.c.GET("/wallet/events/counts", &counts)
+	return
+}
+
+// WalletSpentOutputs returns the number of outputs marked as spent by
+// the wallet.
+func (c *Client) WalletSpentOutputs() (resp api.WalletSpentOutputsResponse, err error) {
+	err = c.c.GET("/wallet/spentoutputs", &resp)
+	return
+}
+
+// WalletExportEvents writes the wallet event log to w as
+// newline-delimited JSON.
+func (c *Client) WalletExportEvents(w io.Writer) error {
+	resp, err := c.stream(http.MethodGet, "/wallet/events/export", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// WalletImportEvents reads an event log exported by WalletExportEvents
+// from r and adds the events to the wallet.
+func (c *Client) WalletImportEvents(r io.Reader) (resp api.WalletEventsImportResponse, err error) {
+	hr, err := c.stream(http.MethodPost, "/wallet/events/import", r)
+	if err != nil {
+		return
+	}
+	defer hr.Body.Close()
+	err = json.NewDecoder(hr.Body).Decode(&resp)
+	return
+}
+
+// WalletLockState returns the current lock state of the wallet.
+func (c *Client) WalletLockState() (resp modules.WalletLockEvent, err error) {
+	err = c.c.GET("/wallet/lock", &resp)
+	err = mapError(err)
+	return
+}
+
+// WalletLock locks the wallet.
+func (c *Client) WalletLock() (err error) {
+	err = c.c.POST("/wallet/lock", nil, nil)
+	return
+}
+
+// WalletUnlock unlocks the wallet with the wallet seed.
+func (c *Client) WalletUnlock(seed string) (err error) {
+	err = c.c.POST("/wallet/unlock", api.WalletUnlockRequest{Seed: seed}, nil)
+	return
+}
+
+// WalletSettings returns the wallet settings.
+func (c *Client) WalletSettings() (ws api.WalletSettings, err error) {
+	err = c.c.GET("/wallet/settings", &ws)
+	return
+}
+
+// WalletUpdateSettings updates the wallet settings.
+func (c *Client) WalletUpdateSettings(ws api.WalletSettings) (err error) {
+	err = c.c.POST("/wallet/settings", ws, nil)
 	return
 }
@@ -2,9 +2,17 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/modules/wallet"
 	"github.com/mike76-dev/sia-satellite/node/api"
+	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/gateway"
 	"go.sia.tech/core/types"
 	"go.sia.tech/jape"
@@ -16,10 +24,121 @@ func (s *server) consensusNetworkHandler(jc jape.Context) {
 
 func (s *server) consensusTipHandler(jc jape.Context) {
 	state := s.cm.TipState()
+	synced := s.s.Synced() && time.Since(state.PrevTimestamps[0]) < 24*time.Hour
 	resp := api.ConsensusTipResponse{
-		Height:  state.Index.Height,
-		BlockID: state.Index.ID,
-		Synced:  s.s.Synced() && time.Since(state.PrevTimestamps[0]) < 24*time.Hour,
+		Height:       state.Index.Height,
+		BlockID:      state.Index.ID,
+		Synced:       synced,
+		SyncProgress: syncProgress(state, synced),
+	}
+	jc.Encode(resp)
+}
+
+// syncProgress estimates how far along the sync is. The peers do not
+// report their tips, so the network height is extrapolated from the
+// timestamp of the current tip.
+func syncProgress(state consensus.State, synced bool) api.SyncProgress {
+	sp := api.SyncProgress{
+		Height:          state.Index.Height,
+		EstimatedHeight: state.Index.Height,
+		Progress:        1,
+	}
+	if synced {
+		return sp
+	}
+	if elapsed := time.Since(state.PrevTimestamps[0]); elapsed > 0 {
+		sp.EstimatedHeight += uint64(elapsed / state.BlockInterval())
+	}
+	if sp.EstimatedHeight > 0 {
+		sp.Progress = float64(sp.Height) / float64(sp.EstimatedHeight)
+	}
+	return sp
+}
+
+// consensusElementProofHandler returns an unspent element with its
+// Merkle proof. The node only keeps the proofs of the wallet's own
+// elements, so other elements are reported as not found.
+func (s *server) consensusElementProofHandler(jc jape.Context) {
+	var id types.Hash256
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+
+	resp := api.ConsensusElementProofResponse{}
+	if sce, index, ok := s.w.SiacoinElement(types.SiacoinOutputID(id)); ok {
+		resp.Type = "siacoin"
+		resp.SiacoinElement = &sce
+		resp.Index = index
+	} else if sfe, index, ok := s.w.SiafundElement(types.SiafundOutputID(id)); ok {
+		resp.Type = "siafund"
+		resp.SiafundElement = &sfe
+		resp.Index = index
+	} else {
+		jc.Error(errors.New("element not found, spent, or not tracked by the node"), http.StatusNotFound)
+		return
+	}
+
+	cs, ok := s.cm.State(resp.Index.ID)
+	if !ok {
+		jc.Error(errors.New("couldn't find consensus state of the element"), http.StatusInternalServerError)
+		return
+	}
+	resp.Accumulator = cs.Elements
+	jc.Encode(resp)
+}
+
+// errOutputNotFound is returned when the node doesn't know an unspent
+// output. Only the wallet's own outputs are tracked.
+var errOutputNotFound = errors.New("output not found, spent, or not tracked by the node")
+
+func (s *server) consensusSiacoinOutputHandler(jc jape.Context) {
+	var id types.SiacoinOutputID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	sce, _, ok := s.w.SiacoinElement(id)
+	if !ok {
+		jc.Error(errOutputNotFound, http.StatusNotFound)
+		return
+	}
+	resp := api.ConsensusSiacoinOutputResponse{
+		ID:             id,
+		Value:          sce.SiacoinOutput.Value,
+		Address:        sce.SiacoinOutput.Address,
+		MaturityHeight: sce.MaturityHeight,
+	}
+	for _, txn := range s.cm.PoolTransactions() {
+		for _, sci := range txn.SiacoinInputs {
+			if sci.ParentID == id {
+				resp.SpentInPool = true
+			}
+		}
+	}
+	jc.Encode(resp)
+}
+
+func (s *server) consensusSiafundOutputHandler(jc jape.Context) {
+	var id types.SiafundOutputID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	sfe, _, ok := s.w.SiafundElement(id)
+	if !ok {
+		jc.Error(errOutputNotFound, http.StatusNotFound)
+		return
+	}
+	resp := api.ConsensusSiafundOutputResponse{
+		ID:         id,
+		Value:      sfe.SiafundOutput.Value,
+		Address:    sfe.SiafundOutput.Address,
+		ClaimStart: sfe.ClaimStart,
+	}
+	for _, txn := range s.cm.PoolTransactions() {
+		for _, sfi := range txn.SiafundInputs {
+			if sfi.ParentID == id {
+				resp.SpentInPool = true
+			}
+		}
 	}
 	jc.Encode(resp)
 }
@@ -29,12 +148,17 @@ func (s *server) consensusTipStateHandler(jc jape.Context) {
 }
 
 func (s *server) syncerPeersHandler(jc jape.Context) {
+	connected := make(map[string]time.Time)
+	for _, info := range s.s.PeerInfo() {
+		connected[info.Address] = info.LastConnect
+	}
 	var sp []api.SyncerPeer
 	for _, p := range s.s.Peers() {
 		sp = append(sp, api.SyncerPeer{
-			Address: p.Addr(),
-			Version: p.Version(),
-			Inbound: p.Inbound,
+			Address:        p.Addr(),
+			Version:        p.Version(),
+			Inbound:        p.Inbound,
+			ConnectedSince: connected[p.Addr()],
 		})
 	}
 	jc.Encode(sp)
@@ -42,13 +166,33 @@ func (s *server) syncerPeersHandler(jc jape.Context) {
 
 func (s *server) syncerConnectHandler(jc jape.Context) {
 	var addr string
+	var persistent bool
 	if jc.Decode(&addr) != nil {
 		return
+	} else if jc.DecodeForm("persistent", &persistent) != nil {
+		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_, err := s.s.Connect(ctx, addr)
-	jc.Check("couldn't connect to peer", err)
+	if jc.Check("couldn't connect to peer", err) != nil {
+		return
+	}
+	if persistent {
+		jc.Check("couldn't save persistent peer", s.s.AddPersistentPeer(addr))
+	}
+}
+
+func (s *server) syncerPersistentHandler(jc jape.Context) {
+	jc.Encode(s.s.PersistentPeers())
+}
+
+func (s *server) syncerRemovePersistentHandler(jc jape.Context) {
+	var addr string
+	if jc.DecodeParam("addr", &addr) != nil {
+		return
+	}
+	jc.Check("couldn't remove persistent peer", s.s.RemovePersistentPeer(addr))
 }
 
 func (s *server) syncerBroadcastBlockHandler(jc jape.Context) {
@@ -77,21 +221,284 @@ func (s *server) txpoolTransactionsHandler(jc jape.Context) {
 	})
 }
 
+// txpoolSubscribeInterval is how often the txpool is checked for new
+// transactions by the subscribers.
+const txpoolSubscribeInterval = time.Second
+
+// txpoolSubscribeWriteTimeout is how long a subscriber may take to
+// receive a transaction before it is disconnected.
+const txpoolSubscribeWriteTimeout = 10 * time.Second
+
+// txpoolSubscribeHandler streams the transactions entering the txpool
+// as newline-delimited JSON. If a comma-separated list of addresses is
+// provided, the transactions touching these addresses are streamed.
+// Otherwise, the transactions relevant to the wallet are streamed.
+func (s *server) txpoolSubscribeHandler(jc jape.Context) {
+	filter := make(map[types.Address]struct{})
+	if list := jc.Request.FormValue("addresses"); list != "" {
+		for _, str := range strings.Split(list, ",") {
+			var addr types.Address
+			if jc.Check("invalid address", addr.UnmarshalText([]byte(strings.TrimSpace(str)))) != nil {
+				return
+			}
+			filter[addr] = struct{}{}
+		}
+	}
+	ownsAddress := func(addr types.Address) bool {
+		_, ok := filter[addr]
+		return ok
+	}
+
+	rc := http.NewResponseController(jc.ResponseWriter)
+	jc.ResponseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	jc.ResponseWriter.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	enc := json.NewEncoder(jc.ResponseWriter)
+
+	seen := make(map[types.TransactionID]struct{})
+	for {
+		// PoolTransactions returns a copy, so the pool is not locked
+		// while the transactions are being sent.
+		txns := s.cm.PoolTransactions()
+		inPool := make(map[types.TransactionID]struct{})
+		var ptxns []modules.PoolTransaction
+		for _, txn := range txns {
+			id := txn.ID()
+			inPool[id] = struct{}{}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			if len(filter) == 0 {
+				ptxns = append(ptxns, s.w.Annotate([]types.Transaction{txn})...)
+				continue
+			}
+			ptxn := wallet.Annotate(txn, ownsAddress)
+			if ptxn.Type != "unrelated" {
+				ptxns = append(ptxns, ptxn)
+			}
+		}
+		for _, ptxn := range ptxns {
+			rc.SetWriteDeadline(time.Now().Add(txpoolSubscribeWriteTimeout))
+			if err := enc.Encode(ptxn); err != nil {
+				return
+			}
+		}
+		if len(ptxns) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		// Forget the transactions that have left the pool.
+		seen = inPool
+
+		select {
+		case <-jc.Request.Context().Done():
+			return
+		case <-time.After(txpoolSubscribeInterval):
+		}
+	}
+}
+
 func (s *server) txpoolFeeHandler(jc jape.Context) {
 	jc.Encode(s.cm.RecommendedFee())
 }
 
+// minRelayFee returns the minimum fee per weight unit a transaction
+// set needs to pay to be relayed. It is the dynamic txpool fee clamped
+// from below by the fee floor.
+func (s *server) minRelayFee() types.Currency {
+	floor := s.s.FeeFloor()
+	fee := s.cm.RecommendedFee()
+	if fee.Cmp(floor) < 0 {
+		return floor
+	}
+	return fee
+}
+
+func (s *server) txpoolFeeFloorHandler(jc jape.Context) {
+	floor := s.s.FeeFloor()
+	jc.Encode(api.TxpoolFeeFloorResponse{
+		Dynamic:     s.cm.RecommendedFee(),
+		Floor:       floor,
+		MinRelayFee: s.minRelayFee(),
+	})
+}
+
+func (s *server) txpoolSetFeeFloorHandler(jc jape.Context) {
+	var floor types.Currency
+	if jc.Decode(&floor) != nil {
+		return
+	}
+	if jc.Check("couldn't set fee floor", s.s.SetFeeFloor(floor)) != nil {
+		return
+	}
+}
+
+// spentObjectIDs returns the IDs of the objects spent or revised by the
+// transaction.
+func spentObjectIDs(txn types.Transaction) (ids []types.Hash256) {
+	for _, sci := range txn.SiacoinInputs {
+		ids = append(ids, types.Hash256(sci.ParentID))
+	}
+	for _, sfi := range txn.SiafundInputs {
+		ids = append(ids, types.Hash256(sfi.ParentID))
+	}
+	for _, fcr := range txn.FileContractRevisions {
+		ids = append(ids, types.Hash256(fcr.ParentID))
+	}
+	for _, sp := range txn.StorageProofs {
+		ids = append(ids, types.Hash256(sp.ParentID))
+	}
+	return
+}
+
+// v2SpentObjectIDs returns the IDs of the objects spent, revised, or
+// resolved by the v2 transaction.
+func v2SpentObjectIDs(txn types.V2Transaction) (ids []types.Hash256) {
+	for _, sci := range txn.SiacoinInputs {
+		ids = append(ids, types.Hash256(sci.Parent.ID))
+	}
+	for _, sfi := range txn.SiafundInputs {
+		ids = append(ids, types.Hash256(sfi.Parent.ID))
+	}
+	for _, fcr := range txn.FileContractRevisions {
+		ids = append(ids, types.Hash256(fcr.Parent.ID))
+	}
+	for _, fcr := range txn.FileContractResolutions {
+		ids = append(ids, types.Hash256(fcr.Parent.ID))
+	}
+	return
+}
+
+// txpoolConflictsHandler returns the txpool transactions that spend
+// the same objects as the provided transaction set.
+func (s *server) txpoolConflictsHandler(jc jape.Context) {
+	var tbr api.TxpoolBroadcastRequest
+	if jc.Decode(&tbr) != nil {
+		return
+	}
+
+	objects := make(map[types.Hash256]struct{})
+	for _, txn := range tbr.Transactions {
+		for _, id := range spentObjectIDs(txn) {
+			objects[id] = struct{}{}
+		}
+	}
+	for _, txn := range tbr.V2Transactions {
+		for _, id := range v2SpentObjectIDs(txn) {
+			objects[id] = struct{}{}
+		}
+	}
+	conflicts := func(ids []types.Hash256) bool {
+		for _, id := range ids {
+			if _, ok := objects[id]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	resp := api.TxpoolTransactionsResponse{
+		Transactions:   []types.Transaction{},
+		V2Transactions: []types.V2Transaction{},
+	}
+	for _, txn := range s.cm.PoolTransactions() {
+		if conflicts(spentObjectIDs(txn)) {
+			resp.Transactions = append(resp.Transactions, txn)
+		}
+	}
+	for _, txn := range s.cm.V2PoolTransactions() {
+		if conflicts(v2SpentObjectIDs(txn)) {
+			resp.V2Transactions = append(resp.V2Transactions, txn)
+		}
+	}
+	jc.Encode(resp)
+}
+
+// decodeTransaction decodes a transaction that is either JSON-encoded
+// or the base64 encoding of its binary form.
+func decodeTransaction(raw string) (txn types.Transaction, err error) {
+	raw = strings.TrimSpace(raw)
+	if json.Valid([]byte(raw)) {
+		if err := json.Unmarshal([]byte(raw), &txn); err != nil {
+			return types.Transaction{}, modules.AddContext(err, "couldn't decode JSON transaction")
+		}
+		return txn, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return types.Transaction{}, errors.New("transaction is neither valid JSON nor base64")
+	}
+	d := types.NewBufDecoder(b)
+	txn.DecodeFrom(d)
+	if err := d.Err(); err != nil {
+		return types.Transaction{}, modules.AddContext(err, "couldn't decode binary transaction")
+	}
+	return txn, nil
+}
+
+func (s *server) txpoolDecodeHandler(jc jape.Context) {
+	var tdr api.TxpoolDecodeRequest
+	if jc.Decode(&tdr) != nil {
+		return
+	}
+	txn, err := decodeTransaction(tdr.Transaction)
+	if err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	annotated := modules.PoolTransaction{
+		ID:   txn.ID(),
+		Raw:  txn,
+		Type: "unrelated",
+		Fee:  txn.TotalFees(),
+	}
+	if ptxns := s.w.Annotate([]types.Transaction{txn}); len(ptxns) > 0 {
+		annotated = ptxns[0]
+	}
+	fee := txn.TotalFees()
+	weight := s.cm.TipState().TransactionWeight(txn)
+	resp := api.TxpoolDecodeResponse{
+		Transaction: txn,
+		Annotated:   annotated,
+		Fee:         fee,
+		Weight:      weight,
+	}
+	if weight > 0 {
+		resp.FeeRate = fee.Div64(weight)
+	}
+	jc.Encode(resp)
+}
+
 func (s *server) txpoolBroadcastHandler(jc jape.Context) {
 	var tbr api.TxpoolBroadcastRequest
 	if jc.Decode(&tbr) != nil {
 		return
 	}
+
+	// Check that the transaction set pays at least the fee floor.
+	if err := s.s.CheckRelayFee(tbr.Transactions, tbr.V2Transactions); err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	}
+	if err := s.s.CheckTransactionSetSize(tbr.Transactions, tbr.V2Transactions); err != nil {
+		jc.Error(err, http.StatusRequestEntityTooLarge)
+		return
+	}
+	// The chain manager remembers the recently rejected sets (the same
+	// applies to the sets relayed by the peers), so an identical invalid
+	// set is rejected without being validated again.
+	var resp api.TxpoolBroadcastResponse
 	if len(tbr.Transactions) != 0 {
 		_, err := s.cm.AddPoolTransactions(tbr.Transactions)
 		if jc.Check("invalid transaction set", err) != nil {
 			return
 		}
 		s.s.BroadcastTransactionSet(tbr.Transactions)
+		resp.ID = tbr.Transactions[len(tbr.Transactions)-1].ID()
 	}
 	if len(tbr.V2Transactions) != 0 {
 		index := s.cm.TipState().Index
@@ -100,5 +507,23 @@ func (s *server) txpoolBroadcastHandler(jc jape.Context) {
 			return
 		}
 		s.s.BroadcastV2TransactionSet(index, tbr.V2Transactions)
+		resp.V2ID = tbr.V2Transactions[len(tbr.V2Transactions)-1].ID()
+	}
+	jc.Encode(resp)
+}
+
+// txpoolPropagationHandler returns how a recently broadcast transaction
+// set has propagated to the peers. The set is identified by the ID of
+// its last transaction.
+func (s *server) txpoolPropagationHandler(jc jape.Context) {
+	var id types.TransactionID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	p, ok := s.s.Propagation(id)
+	if !ok {
+		jc.Error(errors.New("transaction set not broadcast recently"), http.StatusNotFound)
+		return
 	}
+	jc.Encode(p)
 }
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/mike76-dev/sia-satellite/node/api"
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/jape"
+)
+
+const (
+	// maxDifficultySpan is the maximum number of heights that can be
+	// requested at once.
+	maxDifficultySpan = 1000
+
+	// maxDifficultyCache is the maximum number of cached difficulties.
+	maxDifficultyCache = 10000
+)
+
+// A difficultyCache caches the difficulties of the blocks. The cache is
+// keyed by the block ID, so it stays valid across reorgs.
+type difficultyCache struct {
+	mu           sync.Mutex
+	difficulties map[types.BlockID]consensus.Work
+}
+
+// difficulty returns the difficulty of the child of the given block.
+func (s *server) difficulty(index types.ChainIndex) (consensus.Work, bool) {
+	s.difficulties.mu.Lock()
+	d, ok := s.difficulties.difficulties[index.ID]
+	s.difficulties.mu.Unlock()
+	if ok {
+		return d, true
+	}
+
+	cs, ok := s.cm.State(index.ID)
+	if !ok {
+		return consensus.Work{}, false
+	}
+
+	s.difficulties.mu.Lock()
+	defer s.difficulties.mu.Unlock()
+	if len(s.difficulties.difficulties) >= maxDifficultyCache {
+		// Evict a random entry.
+		for id := range s.difficulties.difficulties {
+			delete(s.difficulties.difficulties, id)
+			break
+		}
+	}
+	s.difficulties.difficulties[index.ID] = cs.Difficulty
+	return cs.Difficulty, true
+}
+
+// consensusDifficultyHandler returns the difficulty at each height in
+// the requested range of the best chain.
+func (s *server) consensusDifficultyHandler(jc jape.Context) {
+	tip := s.cm.Tip()
+	start, end := uint64(0), tip.Height
+	if jc.DecodeForm("start", &start) != nil || jc.DecodeForm("end", &end) != nil {
+		return
+	}
+	if end > tip.Height {
+		end = tip.Height
+	}
+	if start > end {
+		jc.Error(fmt.Errorf("start must not be greater than end"), http.StatusBadRequest)
+		return
+	}
+	if end-start >= maxDifficultySpan {
+		jc.Error(fmt.Errorf("span must not exceed %d blocks", maxDifficultySpan), http.StatusBadRequest)
+		return
+	}
+
+	resp := make([]api.ConsensusDifficulty, 0, end-start+1)
+	for height := start; height <= end; height++ {
+		index, ok := s.cm.BestIndex(height)
+		if !ok {
+			break
+		}
+		d, ok := s.difficulty(index)
+		if !ok {
+			break
+		}
+		resp = append(resp, api.ConsensusDifficulty{
+			Height:     index.Height,
+			BlockID:    index.ID,
+			Difficulty: d,
+		})
+	}
+	jc.Encode(resp)
+}
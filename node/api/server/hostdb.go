@@ -109,6 +109,18 @@ func (s *server) hostdbHostHandler(jc jape.Context) {
 	})
 }
 
+func (s *server) hostdbHostExistsHandler(jc jape.Context) {
+	var pk types.PublicKey
+	if jc.DecodeParam("publickey", &pk) != nil {
+		return
+	}
+	_, exists, err := s.m.Host(pk)
+	if jc.Check("unable to get host", err) != nil {
+		return
+	}
+	writeExists(jc, exists)
+}
+
 func (s *server) hostdbFilterModeHandler(jc jape.Context) {
 	fm, hostMap, netAddresses, err := s.m.Filter()
 	if jc.Check("unable to get filter mode", err) != nil {
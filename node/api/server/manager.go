@@ -2,6 +2,8 @@ package server
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/mike76-dev/sia-satellite/modules"
@@ -53,6 +55,53 @@ func (s *server) managerRentersHandler(jc jape.Context) {
 	jc.Encode(r)
 }
 
+// maxRenterSummaries is the maximum number of renter summaries that can
+// be retrieved at once.
+const maxRenterSummaries = 1000
+
+func (s *server) managerRenterSummariesHandler(jc jape.Context) {
+	offset, limit := 0, 100
+	var sortBy string
+	if jc.DecodeForm("offset", &offset) != nil || jc.DecodeForm("limit", &limit) != nil || jc.DecodeForm("sort", &sortBy) != nil {
+		return
+	}
+	if offset < 0 || limit <= 0 || limit > maxRenterSummaries {
+		jc.Error(fmt.Errorf("offset must be non-negative and limit between 1 and %d", maxRenterSummaries), http.StatusBadRequest)
+		return
+	}
+
+	summaries, err := s.m.RenterSummaries()
+	if jc.Check("couldn't retrieve renters", err) != nil {
+		return
+	}
+	switch sortBy {
+	case "", "registered":
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].Registered.Before(summaries[j].Registered)
+		})
+	case "spend":
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].TotalSpent > summaries[j].TotalSpent
+		})
+	default:
+		jc.Error(fmt.Errorf("unknown sort order %q", sortBy), http.StatusBadRequest)
+		return
+	}
+
+	resp := api.RenterSummariesGET{
+		Renters: []modules.RenterSummary{},
+		Total:   len(summaries),
+	}
+	if offset < len(summaries) {
+		end := offset + limit
+		if end > len(summaries) {
+			end = len(summaries)
+		}
+		resp.Renters = summaries[offset:end]
+	}
+	jc.Encode(resp)
+}
+
 func (s *server) managerRenterHandler(jc jape.Context) {
 	var key types.PublicKey
 	if jc.DecodeParam("publickey", &key) != nil {
@@ -67,6 +116,15 @@ func (s *server) managerRenterHandler(jc jape.Context) {
 	jc.Encode(renter)
 }
 
+func (s *server) managerRenterExistsHandler(jc jape.Context) {
+	var key types.PublicKey
+	if jc.DecodeParam("publickey", &key) != nil {
+		return
+	}
+	_, err := s.m.GetRenter(key)
+	writeExists(jc, err == nil)
+}
+
 func (s *server) managerBalanceHandler(jc jape.Context) {
 	var key types.PublicKey
 	if jc.DecodeParam("publickey", &key) != nil {
@@ -119,6 +177,21 @@ func (s *server) getContracts(contracts, oldContracts []modules.RenterContract,
 	var rc api.RenterContracts
 	currentBlockHeight := s.cm.Tip().Height
 
+	// renewWindow returns the renew window of the contract's renter.
+	renewWindows := make(map[types.PublicKey]uint64)
+	renewWindow := func(rpk types.PublicKey) uint64 {
+		if rpk == renter.PublicKey {
+			return renter.Allowance.RenewWindow
+		}
+		rw, ok := renewWindows[rpk]
+		if !ok {
+			r, _ := s.m.GetRenter(rpk)
+			rw = r.Allowance.RenewWindow
+			renewWindows[rpk] = rw
+		}
+		return rw
+	}
+
 	for _, c := range contracts {
 		// Fetch host address.
 		var netAddress string
@@ -150,6 +223,7 @@ func (s *server) getContracts(contracts, oldContracts []modules.RenterContract,
 			TotalCost:           c.TotalCost,
 			UploadSpending:      c.UploadSpending,
 		}
+		contract.BlocksUntilRenewal, contract.BlocksUntilExpiry = c.RemainingWindow(currentBlockHeight, renewWindow(c.RenterPublicKey))
 
 		// Determine contract status.
 		refreshed := s.m.RefreshedContract(c.ID)
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/modules/wallet"
+	"github.com/mike76-dev/sia-satellite/node/api"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/jape"
+)
+
+const (
+	// minRebroadcastAge is the number of blocks a transaction has to be
+	// pending before it is rebroadcast.
+	minRebroadcastAge = 3
+
+	// maxRebroadcastInterval is the number of blocks between two
+	// rebroadcasts of a transaction that has just reached
+	// minRebroadcastAge. The interval shrinks as the transaction gets
+	// older.
+	maxRebroadcastInterval = 6
+)
+
+// A poolAgeTracker records the height at which each txpool transaction
+// was first seen, and rebroadcasts the transactions that stay pending
+// for too long.
+type poolAgeTracker struct {
+	cm *chain.Manager
+	s  modules.Syncer
+
+	mu      sync.Mutex
+	heights map[types.TransactionID]uint64
+}
+
+// update records the new txpool transactions and forgets the ones that
+// have been confirmed or evicted.
+func (pt *poolAgeTracker) update() {
+	height := pt.cm.Tip().Height
+	seen := make(map[types.TransactionID]struct{})
+	for _, txn := range pt.cm.PoolTransactions() {
+		seen[txn.ID()] = struct{}{}
+	}
+	for _, txn := range pt.cm.V2PoolTransactions() {
+		seen[txn.ID()] = struct{}{}
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	for id := range pt.heights {
+		if _, ok := seen[id]; !ok {
+			delete(pt.heights, id)
+		}
+	}
+	for id := range seen {
+		if _, ok := pt.heights[id]; !ok {
+			pt.heights[id] = height
+		}
+	}
+}
+
+// age returns the number of blocks the transaction has been pending.
+func (pt *poolAgeTracker) age(id types.TransactionID) (uint64, uint64, bool) {
+	pt.update()
+	height := pt.cm.Tip().Height
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	firstSeen, ok := pt.heights[id]
+	if !ok {
+		return 0, 0, false
+	}
+	if firstSeen > height {
+		// The chain was reorged to a lower height.
+		return firstSeen, 0, true
+	}
+	return firstSeen, height - firstSeen, true
+}
+
+// shouldRebroadcast returns true if a transaction of the given age is
+// due for a rebroadcast. The older the transaction, the more often it
+// is rebroadcast.
+func shouldRebroadcast(age uint64) bool {
+	if age < minRebroadcastAge {
+		return false
+	}
+	interval := uint64(maxRebroadcastInterval)
+	if shrink := (age - minRebroadcastAge) / maxRebroadcastInterval; shrink < interval {
+		interval -= shrink
+	} else {
+		interval = 1
+	}
+	return (age-minRebroadcastAge)%interval == 0
+}
+
+// rebroadcast broadcasts the pending transactions that are due,
+// together with their unconfirmed parents, sorted so that every parent
+// comes before the transactions spending its outputs.
+func (pt *poolAgeTracker) rebroadcast() {
+	tip := pt.cm.Tip()
+	due := func(id types.TransactionID) bool {
+		pt.mu.Lock()
+		defer pt.mu.Unlock()
+		firstSeen, ok := pt.heights[id]
+		return ok && tip.Height >= firstSeen && shouldRebroadcast(tip.Height-firstSeen)
+	}
+
+	for _, txn := range pt.cm.PoolTransactions() {
+		if due(txn.ID()) {
+			pt.s.BroadcastTransactionSet(append(wallet.SortParents(pt.cm.UnconfirmedParents(txn)), txn))
+		}
+	}
+	for _, txn := range pt.cm.V2PoolTransactions() {
+		if due(txn.ID()) {
+			pt.s.BroadcastV2TransactionSet(tip, []types.V2Transaction{txn})
+		}
+	}
+}
+
+// threadedTrack updates the tracker and rebroadcasts the old
+// transactions whenever the best chain changes.
+func (pt *poolAgeTracker) threadedTrack() {
+	reorgChan := make(chan types.ChainIndex, 1)
+	unsubscribe := pt.cm.OnReorg(func(index types.ChainIndex) {
+		select {
+		case reorgChan <- index:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	for range reorgChan {
+		pt.update()
+		pt.rebroadcast()
+	}
+}
+
+// newPoolAgeTracker returns a poolAgeTracker and starts tracking the
+// txpool.
+func newPoolAgeTracker(cm *chain.Manager, s modules.Syncer) *poolAgeTracker {
+	pt := &poolAgeTracker{
+		cm:      cm,
+		s:       s,
+		heights: make(map[types.TransactionID]uint64),
+	}
+	pt.update()
+	go pt.threadedTrack()
+	return pt
+}
+
+// txpoolAgeHandler returns how many blocks a transaction has been
+// pending in the txpool.
+func (s *server) txpoolAgeHandler(jc jape.Context) {
+	var id types.TransactionID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	firstSeen, age, ok := s.ages.age(id)
+	if !ok {
+		jc.Error(errors.New("transaction not in txpool"), http.StatusNotFound)
+		return
+	}
+	jc.Encode(api.TxpoolAgeResponse{
+		FirstSeen: firstSeen,
+		Age:       age,
+	})
+}
+
+func (s *server) txpoolAgeExistsHandler(jc jape.Context) {
+	var id types.TransactionID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	_, _, ok := s.ages.age(id)
+	writeExists(jc, ok)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils"
+	"go.sia.tech/coreutils/chain"
+)
+
+// mineTestBlocks mines n blocks paying to addr and returns them.
+func mineTestBlocks(t *testing.T, cm *chain.Manager, addr types.Address, n int) []types.Block {
+	t.Helper()
+	var blocks []types.Block
+	for i := 0; i < n; i++ {
+		b, ok := coreutils.MineBlock(cm, addr, time.Second)
+		if !ok {
+			t.Fatal("couldn't mine a block")
+		} else if err := cm.AddBlocks([]types.Block{b}); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+func TestPoolAgeTrackerBounded(t *testing.T) {
+	cm := newTestChain(t)
+	pt := &poolAgeTracker{
+		cm:      cm,
+		heights: make(map[types.TransactionID]uint64),
+	}
+
+	sk := types.GeneratePrivateKey()
+	uc := types.StandardUnlockConditions(sk.PublicKey())
+	const cycles = 10
+	payouts := mineTestBlocks(t, cm, uc.UnlockHash(), cycles)
+	mineTestBlocks(t, cm, types.VoidAddress, int(cm.TipState().MaturityHeight()-cm.Tip().Height))
+
+	// Every transaction that leaves the txpool is forgotten, so repeated
+	// accept/confirm cycles don't grow the map.
+	for _, b := range payouts {
+		parentID := b.ID().MinerOutputID(0)
+		txn := types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{
+				ParentID:         parentID,
+				UnlockConditions: uc,
+			}},
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Address: types.VoidAddress,
+				Value:   b.MinerPayouts[0].Value,
+			}},
+			Signatures: []types.TransactionSignature{{
+				ParentID:      types.Hash256(parentID),
+				CoveredFields: types.CoveredFields{WholeTransaction: true},
+			}},
+		}
+		sig := sk.SignHash(cm.TipState().WholeSigHash(txn, types.Hash256(parentID), 0, 0, nil))
+		txn.Signatures[0].Signature = sig[:]
+		if _, err := cm.AddPoolTransactions([]types.Transaction{txn}); err != nil {
+			t.Fatal(err)
+		}
+		if _, age, ok := pt.age(txn.ID()); !ok || age != 0 {
+			t.Fatalf("expected the transaction to be tracked with age 0, got %v, %v", age, ok)
+		}
+
+		mineTestBlocks(t, cm, types.VoidAddress, 1)
+		if _, _, ok := pt.age(txn.ID()); ok {
+			t.Fatal("expected the confirmed transaction to be forgotten")
+		} else if len(pt.heights) != 0 {
+			t.Fatalf("expected no tracked transactions, got %v", len(pt.heights))
+		}
+	}
+}
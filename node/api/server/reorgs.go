@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/node/api"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/jape"
+)
+
+// maxReorgHistory is the number of most recent reorgs that are kept.
+const maxReorgHistory = 100
+
+// A reorgTracker follows the best chain and records the reorgs, i.e.
+// the chain changes that revert at least one block.
+type reorgTracker struct {
+	cm  *chain.Manager
+	tip types.ChainIndex
+
+	mu          sync.Mutex
+	reorgs      []api.ConsensusReorg
+	subscribers map[chan api.ConsensusReorg]struct{}
+}
+
+// update processes the chain changes since the last known tip.
+func (rt *reorgTracker) update() error {
+	var reverted []types.BlockID
+	var ancestor types.ChainIndex
+	for rt.tip != rt.cm.Tip() {
+		crus, caus, err := rt.cm.UpdatesSince(rt.tip, 100)
+		if err != nil {
+			return err
+		}
+		for _, cru := range crus {
+			reverted = append(reverted, cru.Block.ID())
+			ancestor = cru.State.Index
+			rt.tip = cru.State.Index
+		}
+		if len(caus) > 0 {
+			rt.tip = caus[len(caus)-1].State.Index
+		}
+	}
+	if len(reverted) == 0 {
+		return nil
+	}
+
+	reorg := api.ConsensusReorg{
+		Timestamp:      time.Now(),
+		RevertedBlocks: reverted,
+		Depth:          uint64(len(reverted)),
+		CommonAncestor: ancestor,
+		Tip:            rt.tip,
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.reorgs = append(rt.reorgs, reorg)
+	if len(rt.reorgs) > maxReorgHistory {
+		rt.reorgs = rt.reorgs[len(rt.reorgs)-maxReorgHistory:]
+	}
+	for c := range rt.subscribers {
+		select {
+		case c <- reorg:
+		default:
+		}
+	}
+	return nil
+}
+
+// threadedTrack updates the tracker whenever the best chain changes.
+func (rt *reorgTracker) threadedTrack() {
+	reorgChan := make(chan types.ChainIndex, 1)
+	unsubscribe := rt.cm.OnReorg(func(index types.ChainIndex) {
+		select {
+		case reorgChan <- index:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	for range reorgChan {
+		// Errors are transient, the next notification retries.
+		rt.update()
+	}
+}
+
+// history returns the recorded reorgs, most recent last.
+func (rt *reorgTracker) history() []api.ConsensusReorg {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]api.ConsensusReorg(nil), rt.reorgs...)
+}
+
+// subscribe returns a channel that receives the future reorgs, and a
+// function that closes the subscription.
+func (rt *reorgTracker) subscribe() (<-chan api.ConsensusReorg, func()) {
+	c := make(chan api.ConsensusReorg, 16)
+	rt.mu.Lock()
+	rt.subscribers[c] = struct{}{}
+	rt.mu.Unlock()
+	return c, func() {
+		rt.mu.Lock()
+		delete(rt.subscribers, c)
+		rt.mu.Unlock()
+	}
+}
+
+// newReorgTracker returns a reorgTracker starting at the current tip.
+func newReorgTracker(cm *chain.Manager) *reorgTracker {
+	rt := &reorgTracker{
+		cm:          cm,
+		tip:         cm.Tip(),
+		subscribers: make(map[chan api.ConsensusReorg]struct{}),
+	}
+	go rt.threadedTrack()
+	return rt
+}
+
+func (s *server) consensusReorgsHandler(jc jape.Context) {
+	jc.Encode(s.reorgs.history())
+}
+
+// consensusSubscribeHandler streams the reorgs as newline-delimited
+// JSON as they happen.
+func (s *server) consensusSubscribeHandler(jc jape.Context) {
+	c, unsubscribe := s.reorgs.subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(jc.ResponseWriter)
+	jc.ResponseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	jc.ResponseWriter.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	enc := json.NewEncoder(jc.ResponseWriter)
+
+	for {
+		select {
+		case <-jc.Request.Context().Done():
+			return
+		case reorg := <-c:
+			rc.SetWriteDeadline(time.Now().Add(txpoolSubscribeWriteTimeout))
+			if err := enc.Encode(reorg); err != nil {
+				return
+			} else if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -3,12 +3,17 @@ package server
 import (
 	"net"
 	"net/http"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"github.com/mike76-dev/sia-satellite/internal/build"
 	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node"
 	"github.com/mike76-dev/sia-satellite/node/api"
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
 	"go.sia.tech/coreutils/chain"
 	"go.sia.tech/jape"
 )
@@ -19,44 +24,99 @@ type server struct {
 	m  modules.Manager
 	p  modules.Portal
 	w  modules.Wallet
+
+	loadTimes map[string]time.Time
+	stopFn    func()
+	reorgs    *reorgTracker
+	ages      *poolAgeTracker
+
+	difficulties difficultyCache
 }
 
 // newServer returns an HTTP handler that serves the hsd API.
-func newServer(cm *chain.Manager, s modules.Syncer, m modules.Manager, p modules.Portal, w modules.Wallet) http.Handler {
+func newServer(cm *chain.Manager, s modules.Syncer, m modules.Manager, p modules.Portal, w modules.Wallet, loadTimes map[string]time.Time, stopFn func()) http.Handler {
 	srv := server{
 		cm: cm,
 		s:  s,
 		m:  m,
 		p:  p,
 		w:  w,
+
+		loadTimes: loadTimes,
+		stopFn:    stopFn,
+		reorgs:    newReorgTracker(cm),
+		ages:      newPoolAgeTracker(cm, s),
+
+		difficulties: difficultyCache{
+			difficulties: make(map[types.BlockID]consensus.Work),
+		},
 	}
-	return jape.Mux(map[string]jape.Handler{
-		"GET /daemon/version": srv.versionHandler,
-
-		"GET /consensus/network":  srv.consensusNetworkHandler,
-		"GET /consensus/tip":      srv.consensusTipHandler,
-		"GET /consensus/tipstate": srv.consensusTipStateHandler,
-
-		"GET  /syncer/peers":           srv.syncerPeersHandler,
-		"POST /syncer/connect":         srv.syncerConnectHandler,
-		"POST /syncer/broadcast/block": srv.syncerBroadcastBlockHandler,
-
-		"GET  /txpool/transactions": srv.txpoolTransactionsHandler,
-		"GET  /txpool/fee":          srv.txpoolFeeHandler,
-		"POST /txpool/broadcast":    srv.txpoolBroadcastHandler,
-
-		"GET    /wallet/address":     srv.walletAddressHandler,
-		"GET    /wallet/addresses":   srv.walletAddressesHandler,
-		"GET    /wallet/balance":     srv.walletBalanceHandler,
-		"GET    /wallet/txpool":      srv.walletTxpoolHandler,
-		"GET    /wallet/outputs":     srv.walletOutputsHandler,
-		"GET    /wallet/watch":       srv.walletWatchHandler,
-		"PUT    /wallet/watch/:addr": srv.walletAddWatchHandler,
-		"DELETE /wallet/watch/:addr": srv.walletRemoveWatchHandler,
-		"POST   /wallet/send":        srv.walletSendHandler,
+	routes := map[string]jape.Handler{
+		"GET  /daemon/version": srv.versionHandler,
+		"GET  /daemon/modules": srv.modulesHandler,
+		"POST /daemon/stop":    srv.stopHandler,
+
+		"GET /consensus/network":           srv.consensusNetworkHandler,
+		"GET /consensus/tip":               srv.consensusTipHandler,
+		"GET /consensus/tipstate":          srv.consensusTipStateHandler,
+		"GET /consensus/reorgs":            srv.consensusReorgsHandler,
+		"GET /consensus/subscribe":         srv.consensusSubscribeHandler,
+		"GET /consensus/difficulty":        srv.consensusDifficultyHandler,
+		"GET /consensus/element/:id/proof": srv.consensusElementProofHandler,
+		"GET /consensus/siacoinoutput/:id": srv.consensusSiacoinOutputHandler,
+		"GET /consensus/siafundoutput/:id": srv.consensusSiafundOutputHandler,
+
+		"GET  /syncer/peers":              srv.syncerPeersHandler,
+		"POST /syncer/connect":            srv.syncerConnectHandler,
+		"GET  /syncer/persistent":         srv.syncerPersistentHandler,
+		"DELETE /syncer/persistent/:addr": srv.syncerRemovePersistentHandler,
+		"POST /syncer/broadcast/block":    srv.syncerBroadcastBlockHandler,
+
+		"GET  /txpool/transactions":    srv.txpoolTransactionsHandler,
+		"GET  /txpool/fee":             srv.txpoolFeeHandler,
+		"GET  /txpool/feefloor":        srv.txpoolFeeFloorHandler,
+		"POST /txpool/feefloor":        srv.txpoolSetFeeFloorHandler,
+		"GET  /txpool/subscribe":       srv.txpoolSubscribeHandler,
+		"POST /txpool/conflicts":       srv.txpoolConflictsHandler,
+		"POST /txpool/broadcast":       srv.txpoolBroadcastHandler,
+		"POST /txpool/decode":          srv.txpoolDecodeHandler,
+		"GET  /txpool/age/:id":         srv.txpoolAgeHandler,
+		"GET  /txpool/propagation/:id": srv.txpoolPropagationHandler,
+
+		"GET    /wallet/address":         srv.walletAddressHandler,
+		"GET    /wallet/addresses":       srv.walletAddressesHandler,
+		"GET    /wallet/addresses/batch": srv.walletAddressBatchHandler,
+		"GET    /wallet/audit":           srv.walletAuditHandler,
+		"GET    /wallet/balance":         srv.walletBalanceHandler,
+		"POST   /wallet/estimate/size":   srv.walletEstimateSizeHandler,
+		"GET    /wallet/events/counts":   srv.walletEventsCountsHandler,
+		"GET    /wallet/events/export":   srv.walletEventsExportHandler,
+		"POST   /wallet/events/import":   srv.walletEventsImportHandler,
+		"GET    /wallet/fees":            srv.walletFeesHandler,
+		"GET    /wallet/fingerprint":     srv.walletFingerprintHandler,
+		"GET    /wallet/transaction/:id": srv.walletTransactionHandler,
+		"GET    /wallet/txpool":          srv.walletTxpoolHandler,
+		"GET    /wallet/outputs":         srv.walletOutputsHandler,
+		"GET    /wallet/watch":           srv.walletWatchHandler,
+		"PUT    /wallet/watch/:addr":     srv.walletAddWatchHandler,
+		"DELETE /wallet/watch/:addr":     srv.walletRemoveWatchHandler,
+		"POST   /wallet/send":            srv.walletSendHandler,
+		"GET    /wallet/spentoutputs":    srv.walletSpentOutputsHandler,
+		"POST   /wallet/bump":            srv.walletBumpHandler,
+		"POST   /wallet/importkey":       srv.walletImportKeyHandler,
+		"POST   /wallet/multisig/create": srv.walletMultisigCreateHandler,
+		"POST   /wallet/sign/message":    srv.walletSignMessageHandler,
+		"POST   /wallet/verify/message":  srv.walletVerifyMessageHandler,
+		"GET    /wallet/lock":            srv.walletLockStateHandler,
+		"POST   /wallet/lock":            srv.walletLockHandler,
+		"POST   /wallet/unlock":          srv.walletUnlockHandler,
+		"GET    /wallet/subscribe":       srv.walletSubscribeHandler,
+		"GET    /wallet/settings":        srv.walletSettingsHandler,
+		"POST   /wallet/settings":        srv.walletUpdateSettingsHandler,
 
 		"GET  /manager/averages/:currency":   srv.managerAveragesHandler,
 		"GET  /manager/renters":              srv.managerRentersHandler,
+		"GET  /manager/renters/summary":      srv.managerRenterSummariesHandler,
 		"GET  /manager/renter/:publickey":    srv.managerRenterHandler,
 		"GET  /manager/balance/:publickey":   srv.managerBalanceHandler,
 		"GET  /manager/contracts/:publickey": srv.managerContractsHandler,
@@ -78,22 +138,104 @@ func newServer(cm *chain.Manager, s modules.Syncer, m modules.Manager, p modules
 		"POST /portal/credits":      srv.portalSetCreditsHandler,
 		"GET  /portal/announcement": srv.portalAnnouncementHandler,
 		"POST /portal/announcement": srv.portalSetAnnouncementHandler,
-	})
+	}
+
+	// Any wallet API call resets the auto-lock timer.
+	for route, h := range routes {
+		if strings.Contains(route, " /wallet/") {
+			routes[route] = srv.touchWallet(h)
+		}
+	}
+
+	router := jape.Mux(routes)
+
+	// Answer HEAD requests for the read-only resources, so that the
+	// clients can check their existence without downloading them. The
+	// handlers only look the resource up and write no body.
+	heads := map[string]jape.Handler{
+		"/txpool/age/:id":            srv.txpoolAgeExistsHandler,
+		"/manager/renter/:publickey": srv.managerRenterExistsHandler,
+		"/hostdb/host/:publickey":    srv.hostdbHostExistsHandler,
+	}
+	for path, h := range heads {
+		h := h
+		router.HEAD(path, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			h(jape.Context{ResponseWriter: w, Request: req, PathParams: ps})
+		})
+	}
+
+	return router
 }
 
-func StartWeb(l net.Listener, node *node.Node, password string) error {
-	server := newServer(node.ChainManager, node.Syncer, node.Manager, node.Portal, node.Wallet)
+// writeExists answers a HEAD request with the status telling if the
+// resource exists.
+func writeExists(jc jape.Context, exists bool) {
+	if exists {
+		jc.ResponseWriter.WriteHeader(http.StatusOK)
+	} else {
+		jc.ResponseWriter.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// touchWallet wraps a wallet handler, so that it resets the wallet
+// auto-lock timer.
+func (s *server) touchWallet(h jape.Handler) jape.Handler {
+	return func(jc jape.Context) {
+		s.w.Touch()
+		h(jc)
+	}
+}
+
+// StartWeb starts serving the API. stopFn is called when a shutdown
+// is requested via the API. The returned server can be used to shut
+// the API down gracefully.
+func StartWeb(l net.Listener, node *node.Node, password string, stopFn func()) *http.Server {
+	server := newServer(node.ChainManager, node.Syncer, node.Manager, node.Portal, node.Wallet, node.LoadTimes, stopFn)
 	api := jape.BasicAuth(password)(server)
-	return http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api") {
-			r.URL.Path = strings.TrimPrefix(r.URL.Path, "/api")
-			api.ServeHTTP(w, r)
-			return
-		}
-	}))
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/api") {
+				r.URL.Path = strings.TrimPrefix(r.URL.Path, "/api")
+				api.ServeHTTP(w, r)
+				return
+			}
+		}),
+	}
+	go srv.Serve(l)
+	return srv
 }
 
 // versionHandler handles the API call that requests the daemon's version.
 func (s *server) versionHandler(jc jape.Context) {
-	jc.Encode(api.DaemonVersion{Version: build.NodeVersion, GitRevision: build.GitRevision, BuildTime: build.BuildTime})
+	jc.Encode(api.DaemonVersion{
+		Version:     build.NodeVersion,
+		GitRevision: build.GitRevision,
+		BuildTime:   build.BuildTime,
+		GoVersion:   runtime.Version(),
+	})
+}
+
+// modulesHandler handles the API call that requests the state of the
+// daemon's modules.
+func (s *server) modulesHandler(jc jape.Context) {
+	names := []string{"consensus", "syncer", "txpool", "wallet", "manager", "provider", "portal"}
+	resp := make(map[string]api.DaemonModule)
+	for _, name := range names {
+		t, ok := s.loadTimes[name]
+		resp[name] = api.DaemonModule{
+			Loaded:   ok,
+			LoadedAt: t,
+		}
+	}
+	jc.Encode(resp)
+}
+
+// stopHandler handles the API call that stops the daemon.
+func (s *server) stopHandler(jc jape.Context) {
+	// Send the response before the shutdown begins.
+	jc.ResponseWriter.WriteHeader(http.StatusOK)
+	if f, ok := jc.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	go s.stopFn()
 }
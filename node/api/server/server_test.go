@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/node"
+	"github.com/mike76-dev/sia-satellite/node/api/client"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/testutil"
+)
+
+// newTestChain returns a chain manager with an in-memory store.
+func newTestChain(t *testing.T) *chain.Manager {
+	n, genesis := testutil.Network()
+	store, tipState, err := chain.NewDBStore(chain.NewMemDB(), n, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain.NewManager(store, tipState)
+}
+
+func TestConsensusRequiresPassword(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &node.Node{ChainManager: newTestChain(t)}
+	srv := StartWeb(l, n, "password", func() {})
+	defer srv.Shutdown(context.Background())
+
+	get := func(password string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://"+l.Addr().String()+"/api/consensus/network", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Unauthenticated callers can't reach the consensus endpoints.
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Fatalf("expected status %v without a password, got %v", http.StatusUnauthorized, code)
+	}
+	if code := get("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected status %v with a wrong password, got %v", http.StatusUnauthorized, code)
+	}
+	if code := get("password"); code != http.StatusOK {
+		t.Fatalf("expected status %v with the password, got %v", http.StatusOK, code)
+	}
+}
+
+// testManager is a manager that knows a renter and a host.
+type testManager struct {
+	modules.Manager
+	renter types.PublicKey
+	host   types.PublicKey
+}
+
+// GetRenter implements modules.Manager.
+func (tm *testManager) GetRenter(pk types.PublicKey) (modules.Renter, error) {
+	if pk != tm.renter {
+		return modules.Renter{}, errors.New("renter not found")
+	}
+	return modules.Renter{PublicKey: pk}, nil
+}
+
+// Host implements modules.Manager.
+func (tm *testManager) Host(pk types.PublicKey) (modules.HostDBEntry, bool, error) {
+	return modules.HostDBEntry{}, pk == tm.host, nil
+}
+
+func TestExists(t *testing.T) {
+	tm := &testManager{
+		renter: types.GeneratePrivateKey().PublicKey(),
+		host:   types.GeneratePrivateKey().PublicKey(),
+	}
+	srv := httptest.NewServer(newServer(newTestChain(t), nil, tm, nil, nil, nil, nil))
+	defer srv.Close()
+	c := client.NewClient()
+	c.Client().BaseURL = srv.URL
+
+	other := types.GeneratePrivateKey().PublicKey()
+	tests := []struct {
+		resource string
+		exists   bool
+	}{
+		{"/manager/renter/" + tm.renter.String(), true},
+		{"/manager/renter/" + other.String(), false},
+		{"/hostdb/host/" + tm.host.String(), true},
+		{"/hostdb/host/" + other.String(), false},
+		{"/txpool/age/" + types.TransactionID{}.String(), false},
+	}
+	for _, test := range tests {
+		if exists, err := c.Exists(test.resource); err != nil {
+			t.Fatalf("%v: %v", test.resource, err)
+		} else if exists != test.exists {
+			t.Fatalf("%v: expected %v, got %v", test.resource, test.exists, exists)
+		}
+	}
+
+	// The other routes can't be checked, so no handler with side
+	// effects runs.
+	for _, resource := range []string{"/wallet/address", "/consensus/subscribe"} {
+		if _, err := c.Exists(resource); !errors.Is(err, client.ErrAPICallNotRecognized) {
+			t.Fatalf("%v: expected %v, got %v", resource, client.ErrAPICallNotRecognized, err)
+		}
+	}
+}
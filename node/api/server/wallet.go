@@ -1,11 +1,32 @@
 package server
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node/api"
 	"go.sia.tech/core/types"
 	"go.sia.tech/jape"
 )
 
+// checkWallet is like jc.Check, but reports a locked wallet with
+// http.StatusLocked, so that the clients can tell it apart from other
+// failures.
+func checkWallet(jc jape.Context, msg string, err error) error {
+	if errors.Is(err, modules.ErrWalletLocked) {
+		jc.Error(fmt.Errorf("%s: %w", msg, err), http.StatusLocked)
+		return err
+	}
+	return jc.Check(msg, err)
+}
+
 func (s *server) walletAddressHandler(jc jape.Context) {
 	uc, err := s.w.NextAddress()
 	if jc.Check("unable to generate address", err) != nil {
@@ -14,28 +35,122 @@ func (s *server) walletAddressHandler(jc jape.Context) {
 	jc.Encode(uc.UnlockHash())
 }
 
+// maxAddressBatch is the maximum number of addresses that can be
+// generated at once.
+const maxAddressBatch = 1000
+
+func (s *server) walletAddressBatchHandler(jc jape.Context) {
+	var count uint64
+	if jc.DecodeForm("count", &count) != nil {
+		return
+	}
+	if count == 0 || count > maxAddressBatch {
+		jc.Error(fmt.Errorf("count must be between 1 and %d", maxAddressBatch), http.StatusBadRequest)
+		return
+	}
+
+	ucs, err := s.w.NextAddresses(count)
+	if jc.Check("unable to generate addresses", err) != nil {
+		return
+	}
+	addrs := make([]types.Address, 0, len(ucs))
+	for _, uc := range ucs {
+		addrs = append(addrs, uc.UnlockHash())
+	}
+	jc.Encode(addrs)
+}
+
+// maxAuditEntries is the maximum number of audit log entries that can
+// be retrieved at once.
+const maxAuditEntries = 1000
+
+func (s *server) walletAuditHandler(jc jape.Context) {
+	offset, limit := uint64(0), uint64(100)
+	if jc.DecodeForm("offset", &offset) != nil || jc.DecodeForm("limit", &limit) != nil {
+		return
+	}
+	if limit == 0 || limit > maxAuditEntries {
+		jc.Error(fmt.Errorf("limit must be between 1 and %d", maxAuditEntries), http.StatusBadRequest)
+		return
+	}
+
+	entries, total, err := s.w.AuditLog(offset, limit)
+	if jc.Check("couldn't retrieve audit log", err) != nil {
+		return
+	}
+	jc.Encode(api.WalletAuditResponse{
+		Entries: entries,
+		Total:   total,
+	})
+}
+
 func (s *server) walletAddressesHandler(jc jape.Context) {
 	addrs := s.w.Addresses()
 	jc.Encode(addrs)
 }
 
+func (s *server) walletFingerprintHandler(jc jape.Context) {
+	fp, err := s.w.Fingerprint()
+	if checkWallet(jc, "couldn't compute fingerprint", err) != nil {
+		return
+	}
+	jc.Encode(fp)
+}
+
+func (s *server) walletFeesHandler(jc jape.Context) {
+	// A fee below the relay fee floor would not be relayed, so the
+	// floor is recommended if it's higher.
+	jc.Encode(api.WalletFeesResponse{
+		RecommendedFee: s.minRelayFee(),
+		DustThreshold:  s.w.DustThreshold(),
+	})
+}
+
 func (s *server) walletBalanceHandler(jc jape.Context) {
 	sc, isc, sf := s.w.ConfirmedBalance()
 	outgoing, incoming := s.w.UnconfirmedBalance()
 	height := s.w.Tip().Height
 	fee := s.cm.RecommendedFee()
 	resp := api.WalletBalanceResponse{
-		Height:           height,
-		Siacoins:         sc,
-		ImmatureSiacoins: isc,
-		IncomingSiacoins: incoming,
-		OutgoingSiacoins: outgoing,
-		Siafunds:         sf,
-		RecommendedFee:   fee,
+		Height:            height,
+		Siacoins:          sc,
+		SpendableSiacoins: s.w.SpendableBalance(),
+		ImmatureSiacoins:  isc,
+		IncomingSiacoins:  incoming,
+		OutgoingSiacoins:  outgoing,
+		Siafunds:          sf,
+		ClaimBalance:      s.w.ClaimBalance(),
+		RecommendedFee:    fee,
+
+		RequiredConfirmations: s.w.RequiredConfirmations(),
 	}
 	jc.Encode(resp)
 }
 
+func (s *server) walletTransactionHandler(jc jape.Context) {
+	var id types.TransactionID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+
+	event, ok, err := s.w.TransactionEvent(id)
+	if jc.Check("couldn't retrieve transaction", err) != nil {
+		return
+	} else if ok {
+		jc.Encode(api.WalletTransactionResponse{Event: &event})
+		return
+	}
+
+	if txn, ok := s.cm.PoolTransaction(id); ok {
+		if ptxns := s.w.Annotate([]types.Transaction{txn}); len(ptxns) > 0 {
+			jc.Encode(api.WalletTransactionResponse{Unconfirmed: &ptxns[0]})
+			return
+		}
+	}
+
+	jc.Error(errors.New("transaction not found"), http.StatusNotFound)
+}
+
 func (s *server) walletTxpoolHandler(jc jape.Context) {
 	pool := s.w.Annotate(s.cm.PoolTransactions())
 	jc.Encode(pool)
@@ -79,8 +194,289 @@ func (s *server) walletSendHandler(jc jape.Context) {
 		return
 	}
 
-	_, err := s.w.SendSiacoins(wsr.Amount, wsr.Destination)
-	if jc.Check("couldn't send Siacoins", err) != nil {
+	// A transaction paying less than the minimum relay fee would not
+	// be relayed, so reject it right away.
+	if !wsr.FeeRate.IsZero() {
+		if minFee := s.minRelayFee(); wsr.FeeRate.Cmp(minFee) < 0 {
+			jc.Error(fmt.Errorf("fee rate too low: %v per weight unit, at least %v required", wsr.FeeRate, minFee), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var txnSet []types.Transaction
+	var err error
+	if wsr.Max {
+		txnSet, err = s.w.SendMax(wsr.Destination, wsr.FeeRate)
+	} else {
+		txnSet, err = s.w.SendSiacoins(wsr.Amount, wsr.Destination, wsr.FeeRate)
+	}
+	if checkWallet(jc, "couldn't send Siacoins", err) != nil {
+		return
+	}
+
+	txn := txnSet[len(txnSet)-1]
+	if wsr.Max {
+		wsr.Amount = txn.SiacoinOutputs[0].Value
+	}
+	var fee types.Currency
+	for _, f := range txn.MinerFees {
+		fee = fee.Add(f)
+	}
+	size := s.cm.TipState().TransactionWeight(txn)
+	receipt := modules.SendReceipt{
+		TransactionID: txn.ID(),
+		Destination:   wsr.Destination,
+		Amount:        wsr.Amount,
+		Fee:           fee,
+		FeeRate:       fee.Div64(size),
+		Size:          size,
+		Link:          fmt.Sprintf("sia:%v?amount=%v&txid=%v", strings.TrimPrefix(wsr.Destination.String(), "addr:"), wsr.Amount.ExactString(), strings.TrimPrefix(txn.ID().String(), "txid:")),
+	}
+	if wsr.Sign {
+		// The transaction has already been broadcast, so a signing
+		// failure must not hide the receipt.
+		if err := s.w.SignReceipt(&receipt, txn); err != nil {
+			receipt.SignError = err.Error()
+		}
+	}
+	jc.Encode(receipt)
+}
+
+func (s *server) walletImportKeyHandler(jc jape.Context) {
+	var wir api.WalletImportKeyRequest
+	if jc.Decode(&wir) != nil {
+		return
+	}
+	sk, err := hex.DecodeString(wir.Key)
+	if jc.Check("invalid private key", err) != nil {
+		return
+	}
+	err = s.w.ImportKey(types.PrivateKey(sk))
+	if checkWallet(jc, "couldn't import private key", err) != nil {
+		return
+	}
+}
+
+func (s *server) walletMultisigCreateHandler(jc jape.Context) {
+	var req api.WalletMultisigRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	uc, addr, err := s.w.CreateMultisigAddress(req.PublicKeys, req.Required, req.Sign)
+	if jc.Check("couldn't create multisig address", err) != nil {
+		return
+	}
+	jc.Encode(api.WalletMultisigResponse{
+		UnlockConditions: uc,
+		Address:          addr,
+	})
+}
+
+func (s *server) walletSignMessageHandler(jc jape.Context) {
+	var req api.WalletSignMessageRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	sig, err := s.w.SignMessage(req.Address, []byte(req.Message))
+	if checkWallet(jc, "couldn't sign message", err) != nil {
+		return
+	}
+	pk, _ := s.w.PublicKey(req.Address)
+	jc.Encode(api.WalletSignMessageResponse{
+		Address:   req.Address,
+		PublicKey: pk,
+		Signature: sig,
+	})
+}
+
+func (s *server) walletVerifyMessageHandler(jc jape.Context) {
+	var req api.WalletVerifyMessageRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	if req.PublicKey != nil {
+		jc.Encode(api.WalletVerifyMessageResponse{
+			Valid: modules.VerifyMessage(*req.PublicKey, req.Address, []byte(req.Message), req.Signature),
+		})
+		return
+	}
+	valid, err := s.w.VerifyMessage(req.Address, []byte(req.Message), req.Signature)
+	if jc.Check("couldn't verify message", err) != nil {
+		return
+	}
+	jc.Encode(api.WalletVerifyMessageResponse{Valid: valid})
+}
+
+func (s *server) walletBumpHandler(jc jape.Context) {
+	var wbr api.WalletBumpRequest
+	if jc.Decode(&wbr) != nil {
+		return
+	}
+
+	if minFee := s.minRelayFee(); wbr.FeeRate.Cmp(minFee) < 0 {
+		jc.Error(fmt.Errorf("fee rate too low: %v per weight unit, at least %v required", wbr.FeeRate, minFee), http.StatusBadRequest)
+		return
+	}
+
+	txnSet, err := s.w.BumpFee(wbr.ID, wbr.FeeRate)
+	if checkWallet(jc, "couldn't bump transaction fee", err) != nil {
+		return
+	}
+	jc.Encode(txnSet[len(txnSet)-1].ID())
+}
+
+func (s *server) walletLockStateHandler(jc jape.Context) {
+	jc.Encode(s.w.LockState())
+}
+
+func (s *server) walletLockHandler(jc jape.Context) {
+	s.w.Lock()
+}
+
+func (s *server) walletUnlockHandler(jc jape.Context) {
+	var wur api.WalletUnlockRequest
+	if jc.Decode(&wur) != nil {
+		return
+	}
+	jc.Check("couldn't unlock wallet", s.w.Unlock(wur.Seed))
+}
+
+// walletSubscribeHandler streams the wallet lock state changes as
+// newline-delimited JSON as they happen.
+func (s *server) walletSubscribeHandler(jc jape.Context) {
+	c, unsubscribe := s.w.SubscribeLock()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(jc.ResponseWriter)
+	jc.ResponseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	jc.ResponseWriter.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	enc := json.NewEncoder(jc.ResponseWriter)
+
+	for {
+		select {
+		case <-jc.Request.Context().Done():
+			return
+		case event := <-c:
+			rc.SetWriteDeadline(time.Now().Add(txpoolSubscribeWriteTimeout))
+			if err := enc.Encode(event); err != nil {
+				return
+			} else if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *server) walletEstimateSizeHandler(jc jape.Context) {
+	var req api.WalletEstimateSizeRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	if len(req.Outputs) == 0 {
+		jc.Error(errors.New("no outputs specified"), http.StatusBadRequest)
+		return
+	}
+	size, inputs, err := s.w.EstimateSize(req.Outputs)
+	if jc.Check("couldn't estimate transaction size", err) != nil {
+		return
+	}
+	jc.Encode(api.WalletEstimateSizeResponse{
+		Size:   size,
+		Inputs: inputs,
+	})
+}
+
+func (s *server) walletEventsCountsHandler(jc jape.Context) {
+	jc.Encode(s.w.EventCounts())
+}
+
+func (s *server) walletSpentOutputsHandler(jc jape.Context) {
+	count, expired := s.w.SpentOutputs()
+	jc.Encode(api.WalletSpentOutputsResponse{
+		Count:   count,
+		Expired: expired,
+	})
+}
+
+// walletEventsExportHandler streams the whole event log as
+// newline-delimited JSON, oldest events first.
+func (s *server) walletEventsExportHandler(jc jape.Context) {
+	jc.ResponseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	jc.ResponseWriter.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(jc.ResponseWriter)
+	// The status has already been sent, so an error can only cut the
+	// stream short, leaving a truncated last line for the importer.
+	s.w.ExportEvents(func(event modules.WalletEvent) error {
+		return enc.Encode(event)
+	})
+}
+
+// importEventsBatch is the number of events imported at once.
+const importEventsBatch = 1000
+
+// walletEventsImportHandler reads an event log exported by
+// walletEventsExportHandler and adds the events to the wallet.
+func (s *server) walletEventsImportHandler(jc jape.Context) {
+	var resp api.WalletEventsImportResponse
+	dec := json.NewDecoder(jc.Request.Body)
+	batch := make([]modules.WalletEvent, 0, importEventsBatch)
+	flush := func() error {
+		imported, skipped, err := s.w.ImportEvents(batch)
+		resp.Imported += imported
+		resp.Skipped += skipped
+		batch = batch[:0]
+		return err
+	}
+	for {
+		var event modules.WalletEvent
+		if err := dec.Decode(&event); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			jc.Error(fmt.Errorf("couldn't decode event %d: %w", resp.Imported+resp.Skipped+uint64(len(batch))+1, err), http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, event)
+		if len(batch) == importEventsBatch {
+			if jc.Check("couldn't import events", flush()) != nil {
+				return
+			}
+		}
+	}
+	if jc.Check("couldn't import events", flush()) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
+func (s *server) walletSettingsHandler(jc jape.Context) {
+	jc.Encode(api.WalletSettings{
+		AutoLock:         s.w.AutoLock(),
+		MaxAncestorDepth: s.w.MaxAncestorDepth(),
+
+		RequiredConfirmations: s.w.RequiredConfirmations(),
+	})
+}
+
+func (s *server) walletUpdateSettingsHandler(jc jape.Context) {
+	var ws api.WalletSettings
+	if jc.Decode(&ws) != nil {
+		return
+	}
+	if ws.AutoLock < 0 {
+		jc.Error(errors.New("auto-lock period must not be negative"), http.StatusBadRequest)
+		return
+	} else if ws.MaxAncestorDepth < 0 {
+		jc.Error(errors.New("maximum ancestor depth must not be negative"), http.StatusBadRequest)
+		return
+	}
+	s.w.SetAutoLock(ws.AutoLock)
+	if jc.Check("couldn't set maximum ancestor depth", s.w.SetMaxAncestorDepth(ws.MaxAncestorDepth)) != nil {
+		return
+	}
+	if jc.Check("couldn't set required confirmations", s.w.SetRequiredConfirmations(ws.RequiredConfirmations)) != nil {
 		return
 	}
 }
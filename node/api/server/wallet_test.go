@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/node/api/client"
+	"go.sia.tech/core/types"
+)
+
+// testWallet is a wallet that sends a fixed transaction.
+type testWallet struct {
+	modules.Wallet
+	txn     types.Transaction
+	signErr error
+}
+
+// Touch implements modules.Wallet.
+func (tw *testWallet) Touch() {}
+
+// SendSiacoins implements modules.Wallet.
+func (tw *testWallet) SendSiacoins(amount types.Currency, dest types.Address, feeRate types.Currency) ([]types.Transaction, error) {
+	return []types.Transaction{tw.txn}, nil
+}
+
+// SignReceipt implements modules.Wallet.
+func (tw *testWallet) SignReceipt(r *modules.SendReceipt, txn types.Transaction) error {
+	return tw.signErr
+}
+
+func TestSendReceiptSignError(t *testing.T) {
+	tw := &testWallet{
+		txn: types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{Value: types.Siacoins(1)}},
+			MinerFees:      []types.Currency{types.Siacoins(1).Div64(100)},
+		},
+		signErr: modules.ErrWalletLocked,
+	}
+	srv := httptest.NewServer(newServer(newTestChain(t), nil, nil, nil, tw, nil, nil))
+	defer srv.Close()
+	c := client.NewClient()
+	c.Client().BaseURL = srv.URL
+
+	// The transaction has been broadcast, so the receipt is returned
+	// even though it couldn't be signed.
+	receipt, err := c.WalletSendSiacoins(types.Siacoins(1), types.VoidAddress, types.ZeroCurrency, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if receipt.TransactionID != tw.txn.ID() {
+		t.Fatal("wrong transaction in the receipt")
+	} else if receipt.Signature != nil || receipt.SignError != modules.ErrWalletLocked.Error() {
+		t.Fatalf("expected an unsigned receipt with the sign error, got %+v", receipt)
+	}
+
+	// Without a signing failure there is no error.
+	tw.signErr = nil
+	if receipt, err := c.WalletSendSiacoins(types.Siacoins(1), types.VoidAddress, types.ZeroCurrency, true); err != nil {
+		t.Fatal(err)
+	} else if receipt.SignError != "" {
+		t.Fatal("unexpected sign error:", receipt.SignError)
+	}
+}
+
+// DustThreshold implements modules.Wallet.
+func (tw *testWallet) DustThreshold() types.Currency {
+	return types.ZeroCurrency
+}
+
+// testSyncer is a syncer with a fixed fee floor.
+type testSyncer struct {
+	modules.Syncer
+	floor types.Currency
+}
+
+// FeeFloor implements modules.Syncer.
+func (ts *testSyncer) FeeFloor() types.Currency {
+	return ts.floor
+}
+
+func TestWalletFeesFloor(t *testing.T) {
+	cm := newTestChain(t)
+	ts := &testSyncer{}
+	srv := httptest.NewServer(newServer(cm, ts, nil, nil, &testWallet{}, nil, nil))
+	defer srv.Close()
+	c := client.NewClient()
+	c.Client().BaseURL = srv.URL
+
+	// Below the floor, the dynamic fee is recommended.
+	fees, err := c.WalletFees()
+	if err != nil {
+		t.Fatal(err)
+	} else if !fees.RecommendedFee.Equals(cm.RecommendedFee()) {
+		t.Fatalf("expected the dynamic fee %v, got %v", cm.RecommendedFee(), fees.RecommendedFee)
+	}
+
+	// Above it, the floor is.
+	ts.floor = cm.RecommendedFee().Add(types.NewCurrency64(1000))
+	if fees, err := c.WalletFees(); err != nil {
+		t.Fatal(err)
+	} else if !fees.RecommendedFee.Equals(ts.floor) {
+		t.Fatalf("expected the fee floor %v, got %v", ts.floor, fees.RecommendedFee)
+	}
+}
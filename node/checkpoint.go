@@ -0,0 +1,61 @@
+package node
+
+import (
+	"fmt"
+	"log"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+)
+
+// verifyCheckpoint checks that the best chain does not contradict the
+// trusted checkpoint. A chain that hasn't reached the checkpoint height
+// yet is accepted.
+func verifyCheckpoint(cm *chain.Manager, checkpoint types.ChainIndex) error {
+	if cm.Tip().Height < checkpoint.Height {
+		return nil
+	}
+	index, ok := cm.BestIndex(checkpoint.Height)
+	if !ok {
+		return fmt.Errorf("couldn't find block at checkpoint height %d", checkpoint.Height)
+	}
+	if index.ID != checkpoint.ID {
+		return fmt.Errorf("block %v at height %d contradicts checkpoint %v", index.ID, checkpoint.Height, checkpoint.ID)
+	}
+	return nil
+}
+
+// threadedWatchCheckpoint verifies the checkpoint every time the best
+// chain changes. A reorg can replace the blocks below the checkpoint
+// even after the chain has connected to it, so the watch lasts as long
+// as the node runs. If the best chain contradicts the checkpoint, the
+// error is sent to halt and the watch ends.
+func threadedWatchCheckpoint(cm *chain.Manager, checkpoint types.ChainIndex, halt chan<- error) {
+	reorgChan := make(chan types.ChainIndex, 1)
+	unsubscribe := cm.OnReorg(func(index types.ChainIndex) {
+		select {
+		case reorgChan <- index:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	connected := cm.Tip().Height >= checkpoint.Height
+	for index := range reorgChan {
+		if index.Height < checkpoint.Height {
+			continue
+		}
+		if err := verifyCheckpoint(cm, checkpoint); err != nil {
+			log.Printf("ERROR: the best chain contradicts the trusted checkpoint: %v\n", err)
+			select {
+			case halt <- fmt.Errorf("the best chain contradicts the trusted checkpoint: %w", err):
+			default:
+			}
+			return
+		}
+		if !connected {
+			log.Printf("Consensus: chain connected to checkpoint %v\n", checkpoint)
+			connected = true
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package node
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"path/filepath"
 	"time"
 
@@ -16,10 +17,32 @@ import (
 	"github.com/mike76-dev/sia-satellite/modules/syncer"
 	"github.com/mike76-dev/sia-satellite/modules/wallet"
 	"github.com/mike76-dev/sia-satellite/persist"
+	"go.sia.tech/core/types"
 	"go.sia.tech/coreutils"
 	"go.sia.tech/coreutils/chain"
 )
 
+// walletShutdownTimeout is how long the node waits for the wallet
+// operations in flight to finish when shutting down.
+const walletShutdownTimeout = 30 * time.Second
+
+// A ModuleInitError is returned by New when a module of the node fails
+// to initialize. It names the module and wraps the cause.
+type ModuleInitError struct {
+	Module string
+	Err    error
+}
+
+// Error implements error.
+func (e *ModuleInitError) Error() string {
+	return fmt.Sprintf("unable to initialize %s: %v", e.Module, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *ModuleInitError) Unwrap() error {
+	return e.Err
+}
+
 // Node represents a satellite node containing all required modules.
 type Node struct {
 	// Databases.
@@ -34,10 +57,23 @@ type Node struct {
 	Provider     modules.Provider
 	Wallet       modules.Wallet
 
+	// The times when the modules were loaded.
+	LoadTimes map[string]time.Time
+
+	// haltChan receives an error if the node can't keep running.
+	haltChan chan error
+
 	// The start function.
 	Start func() (stop func())
 }
 
+// Halted returns a channel that receives an error if the node can't
+// keep running, e.g. because the best chain contradicts the trusted
+// checkpoint. The node should be shut down then.
+func (n *Node) Halted() <-chan error {
+	return n.haltChan
+}
+
 // Close will call close on every module within the node, combining and
 // returning the errors.
 func (n *Node) Close() (err error) {
@@ -53,17 +89,28 @@ func (n *Node) Close() (err error) {
 		fmt.Println("Closing manager...")
 		err = modules.ComposeErrors(err, n.Manager.Close())
 	}
+	// If the wallet doesn't shut down in time, it keeps running in the
+	// background and still needs the databases, so they are left open.
+	walletRunning := false
 	if n.Wallet != nil {
 		fmt.Println("Closing wallet...")
-		err = modules.ComposeErrors(err, n.Wallet.Close())
+		ctx, cancel := context.WithTimeout(context.Background(), walletShutdownTimeout)
+		werr := n.Wallet.Shutdown(ctx)
+		cancel()
+		walletRunning = errors.Is(werr, context.DeadlineExceeded)
+		err = modules.ComposeErrors(err, werr)
 	}
 	if n.Syncer != nil {
 		fmt.Println("Closing syncer...")
 		err = modules.ComposeErrors(err, n.Syncer.Close())
 	}
 	if n.db != nil && n.bdb != nil {
-		fmt.Println("Closing databases...")
-		err = modules.ComposeErrors(err, n.db.Close(), n.bdb.Close())
+		if walletRunning {
+			fmt.Println("Wallet still running, leaving databases open")
+		} else {
+			fmt.Println("Closing databases...")
+			err = modules.ComposeErrors(err, n.db.Close(), n.bdb.Close())
+		}
 	}
 	return err
 }
@@ -80,7 +127,7 @@ func New(config *persist.SatdConfig, dbPassword, seed string, loadStartTime time
 	fmt.Println("Creating mail client...")
 	ms, err := mail.New(d)
 	if err != nil {
-		log.Fatalf("ERROR: could not create mail client: %v\n", err)
+		return nil, &ModuleInitError{Module: "mail", Err: err}
 	}
 
 	// Connect to the MySQL database.
@@ -95,11 +142,11 @@ func New(config *persist.SatdConfig, dbPassword, seed string, loadStartTime time
 	}
 	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
-		log.Fatalf("Could not connect to MySQL database: %v\n", err)
+		return nil, &ModuleInitError{Module: "mysql", Err: modules.AddContext(err, "could not connect to database")}
 	}
 	err = db.Ping()
 	if err != nil {
-		log.Fatalf("MySQL database not responding: %v\n", err)
+		return nil, &ModuleInitError{Module: "mysql", Err: modules.AddContext(err, "database not responding")}
 	}
 	db.SetConnMaxLifetime(time.Minute * 3)
 	db.SetMaxOpenConns(10)
@@ -109,7 +156,7 @@ func New(config *persist.SatdConfig, dbPassword, seed string, loadStartTime time
 	fmt.Println("Connecting to the BoltDB database...")
 	bdb, err := coreutils.OpenBoltChainDB(filepath.Join(d, "consensus.db"))
 	if err != nil {
-		log.Fatalf("Could not connect to BoltDB database: %v\n", err)
+		return nil, &ModuleInitError{Module: "consensus", Err: modules.AddContext(err, "could not open BoltDB database")}
 	}
 
 	// Create chain manager.
@@ -117,44 +164,71 @@ func New(config *persist.SatdConfig, dbPassword, seed string, loadStartTime time
 	network, genesisBlock := chain.Mainnet()
 	dbstore, tipState, err := chain.NewDBStore(bdb, network, genesisBlock)
 	if err != nil {
-		log.Fatalf("Unable to create chain manager store: %v\n", err)
+		return nil, &ModuleInitError{Module: "consensus", Err: modules.AddContext(err, "unable to create chain manager store")}
 	}
 	cm := chain.NewManager(dbstore, tipState)
+	if err := verifyResumePoint(cm); err != nil {
+		return nil, &ModuleInitError{Module: "consensus", Err: modules.AddContext(err, "consensus database is inconsistent")}
+	}
+	if tip := cm.Tip(); tip.Height > 0 {
+		fmt.Printf("Resuming consensus at height %d (%v)\n", tip.Height, tip.ID)
+	}
+	haltChan := make(chan error, 1)
+	if config.Checkpoint != "" {
+		var checkpoint types.ChainIndex
+		if err := checkpoint.UnmarshalText([]byte(config.Checkpoint)); err != nil {
+			return nil, &ModuleInitError{Module: "consensus", Err: modules.AddContext(err, "invalid checkpoint")}
+		}
+		if err := verifyCheckpoint(cm, checkpoint); err != nil {
+			return nil, &ModuleInitError{Module: "consensus", Err: modules.AddContext(err, "refusing to start")}
+		}
+		go threadedWatchCheckpoint(cm, checkpoint, haltChan)
+	}
+	loadTimes := map[string]time.Time{
+		"consensus": time.Now(),
+		"txpool":    time.Now(),
+	}
 
 	// Load syncer.
 	fmt.Println("Loading syncer...")
-	s, err := syncer.New(cm, config.GatewayAddr, d)
+	s, err := syncer.New(cm, config.GatewayAddr, d, config.RelayFanout, config.BootstrapPeers, config.BootstrapOnly, config.MaxTransactionSetSize)
 	if err != nil {
-		log.Fatalf("Unable to create syncer: %v\n", err)
+		return nil, &ModuleInitError{Module: "syncer", Err: err}
 	}
+	loadTimes["syncer"] = time.Now()
 
 	// Load wallet.
 	fmt.Println("Loading wallet...")
 	w, err := wallet.New(db, cm, s, seed, d)
 	if err != nil {
-		return nil, modules.AddContext(err, "unable to create wallet")
+		return nil, &ModuleInitError{Module: "wallet", Err: err}
 	}
+	w.SetAutoLock(time.Duration(config.AutoLock) * time.Second)
+	loadTimes["wallet"] = time.Now()
 
 	// Load manager.
 	fmt.Println("Loading manager...")
 	m, errChanM := manager.New(db, ms, cm, s, w, d, config.Name)
 	if err := modules.PeekErr(errChanM); err != nil {
-		return nil, modules.AddContext(err, "unable to create manager")
+		return nil, &ModuleInitError{Module: "manager", Err: err}
 	}
+	loadTimes["manager"] = time.Now()
 
 	// Load provider.
 	fmt.Println("Loading provider...")
-	p, errChanP := provider.New(db, s, m, config.SatelliteAddr, config.MuxAddr, d)
+	p, errChanP := provider.New(db, s, m, config.SatelliteAddr, config.MuxAddr, d, config.MaxRenewBatch, config.MaxBlockHeightLeeway, types.Siacoins(1).Mul64(config.MaxMinMaxCollateral), config.ProviderTLSCert, config.ProviderTLSKey, config.RequireRenterToken)
 	if err := modules.PeekErr(errChanP); err != nil {
-		return nil, modules.AddContext(err, "unable to create provider")
+		return nil, &ModuleInitError{Module: "provider", Err: err}
 	}
+	loadTimes["provider"] = time.Now()
 
 	// Load portal.
 	fmt.Println("Loading portal...")
 	pt, err := portal.New(config, db, ms, cm, w, m, p, d)
 	if err != nil {
-		return nil, modules.AddContext(err, "unable to create portal")
+		return nil, &ModuleInitError{Module: "portal", Err: err}
 	}
+	loadTimes["portal"] = time.Now()
 
 	// Setup complete.
 	fmt.Printf("API is now available, synchronous startup completed in %.3f seconds\n", time.Since(loadStartTime).Seconds())
@@ -169,6 +243,10 @@ func New(config *persist.SatdConfig, dbPassword, seed string, loadStartTime time
 		Portal:       pt,
 		Provider:     p,
 		Wallet:       w,
+
+		LoadTimes: loadTimes,
+
+		haltChan: haltChan,
 	}
 
 	n.Start = func() func() {
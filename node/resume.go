@@ -0,0 +1,34 @@
+package node
+
+import (
+	"fmt"
+
+	"go.sia.tech/coreutils/chain"
+)
+
+// verifyResumePoint checks that the consensus database can be resumed
+// from its tip: the tip block and its state must be stored, and the
+// block must connect to the best chain below it. The chain manager
+// persists every applied block, so an interrupted download resumes at
+// the tip instead of starting over.
+func verifyResumePoint(cm *chain.Manager) error {
+	tip := cm.Tip()
+	if tip.Height == 0 {
+		return nil
+	}
+	b, ok := cm.Block(tip.ID)
+	if !ok {
+		return fmt.Errorf("tip block %v at height %d is missing", tip.ID, tip.Height)
+	}
+	if _, ok := cm.State(tip.ID); !ok {
+		return fmt.Errorf("state of tip block %v is missing", tip.ID)
+	}
+	parent, ok := cm.BestIndex(tip.Height - 1)
+	if !ok {
+		return fmt.Errorf("couldn't find block at height %d", tip.Height-1)
+	}
+	if parent.ID != b.ParentID {
+		return fmt.Errorf("tip block %v doesn't connect to block %v at height %d", tip.ID, parent.ID, parent.Height)
+	}
+	return nil
+}
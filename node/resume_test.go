@@ -0,0 +1,61 @@
+package node
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/testutil"
+)
+
+func TestResumeAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "consensus.db")
+	n, genesis := testutil.Network()
+
+	// open opens the consensus database and returns its chain manager.
+	open := func() (*chain.Manager, func()) {
+		bdb, err := coreutils.OpenBoltChainDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, tipState, err := chain.NewDBStore(bdb, n, genesis)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chain.NewManager(store, tipState), func() { bdb.Close() }
+	}
+
+	// A fresh database starts from genesis.
+	cm, closeDB := open()
+	if err := verifyResumePoint(cm); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		b, ok := coreutils.MineBlock(cm, types.VoidAddress, time.Second)
+		if !ok {
+			t.Fatal("couldn't mine a block")
+		} else if err := cm.AddBlocks([]types.Block{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tip := cm.Tip()
+	closeDB()
+
+	// After a restart, the download resumes at the stored tip.
+	cm, closeDB = open()
+	defer closeDB()
+	if cm.Tip() != tip {
+		t.Fatalf("expected to resume at %v, got %v", tip, cm.Tip())
+	} else if err := verifyResumePoint(cm); err != nil {
+		t.Fatal(err)
+	}
+	b, ok := coreutils.MineBlock(cm, types.VoidAddress, time.Second)
+	if !ok {
+		t.Fatal("couldn't mine a block")
+	} else if err := cm.AddBlocks([]types.Block{b}); err != nil {
+		t.Fatal("couldn't extend the resumed chain:", err)
+	}
+}
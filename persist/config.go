@@ -24,6 +24,102 @@ type SatdConfig struct {
 	DBUser        string `json:"dbUser"`
 	DBName        string `json:"dbName"`
 	PortalPort    string `json:"portal"`
+
+	// AuthWindow is the time (in seconds) after the last activity
+	// when the portal authentication rate limits are reset. If zero,
+	// the default value is used.
+	AuthWindow uint64 `json:"authWindow,omitempty"`
+
+	// TemplatesDir is the directory containing the localized email
+	// templates. If empty, the built-in English templates are used.
+	TemplatesDir string `json:"templates,omitempty"`
+
+	// MailFromName is the sender name of the emails sent by the portal.
+	// If empty, "Sia Satellite" is used.
+	MailFromName string `json:"mailFromName,omitempty"`
+
+	// VerifySubject and ResetSubject are the subjects of the account
+	// verification and the password reset emails. They are overridden
+	// by the subjects of the localized templates. If empty, the
+	// built-in subjects are used.
+	VerifySubject string `json:"verifySubject,omitempty"`
+	ResetSubject  string `json:"resetSubject,omitempty"`
+
+	// Captcha is the CAPTCHA provider used by the portal ("hcaptcha"
+	// or "recaptcha"). If empty, no CAPTCHA is required.
+	Captcha string `json:"captcha,omitempty"`
+
+	// PortalBaseURL is the base URL of the links sent by the portal
+	// by email. If empty, the Referer header of the request is used.
+	PortalBaseURL string `json:"portalURL,omitempty"`
+
+	// PortalMaxBodySize is the maximum size (in bytes) of a request body
+	// accepted by the portal. If zero, the default value is used.
+	PortalMaxBodySize uint64 `json:"portalMaxBody,omitempty"`
+
+	// PortalMaxFilesBodySize is the maximum size (in bytes) of a request
+	// body deleting files via the portal. If zero, the default value is
+	// used.
+	PortalMaxFilesBodySize uint64 `json:"portalMaxFilesBody,omitempty"`
+
+	// VerifyTokenTTL is the time (in seconds) a verification link sent
+	// by email stays valid. If zero, the default of 24 hours is used.
+	VerifyTokenTTL uint64 `json:"verifyTokenTTL,omitempty"`
+
+	// ResetTokenTTL is the time (in seconds) a password reset link sent
+	// by email stays valid. If zero, the default of one hour is used.
+	ResetTokenTTL uint64 `json:"resetTokenTTL,omitempty"`
+
+	// MaxRenewBatch is the maximum number of contracts a renter can
+	// renew in one request. If zero, the default value is used.
+	MaxRenewBatch uint64 `json:"maxRenewBatch,omitempty"`
+
+	// MaxBlockHeightLeeway is the maximum BlockHeightLeeway (in blocks)
+	// a renter can set in the allowance. If zero, the default of 144
+	// blocks is used.
+	MaxBlockHeightLeeway uint64 `json:"maxBlockHeightLeeway,omitempty"`
+
+	// MaxMinMaxCollateral is the maximum MinMaxCollateral (in SC) a
+	// renter can set in the allowance. If zero, the default of 100 KS
+	// is used.
+	MaxMinMaxCollateral uint64 `json:"maxMinMaxCollateral,omitempty"`
+
+	// ProviderTLSCert and ProviderTLSKey are the paths to the
+	// certificate and the private key used for wrapping the renter
+	// RPC listener in TLS. If empty, TLS is not used.
+	ProviderTLSCert string `json:"providerTLSCert,omitempty"`
+	ProviderTLSKey  string `json:"providerTLSKey,omitempty"`
+
+	// RequireRenterToken makes the contract RPCs available only to the
+	// renters presenting a token issued by the portal.
+	RequireRenterToken bool `json:"requireRenterToken,omitempty"`
+
+	// MaxTransactionSetSize is the maximum encoded size (in bytes) of
+	// a transaction set that is relayed to the peers. If zero, the
+	// block size limit is used.
+	MaxTransactionSetSize uint64 `json:"maxTxnSetSize,omitempty"`
+
+	// RelayFanout is the number of peers a transaction set is relayed
+	// to. If zero, the square root of the peer count (but at least 3)
+	// is used. If negative, the transaction sets are relayed to all
+	// peers.
+	RelayFanout int `json:"relayFanout,omitempty"`
+
+	// Checkpoint is a trusted chain index in the <height>::<id> format.
+	// The node refuses to start if its best chain contradicts the
+	// checkpoint. If empty, no checkpoint is used.
+	Checkpoint string `json:"checkpoint,omitempty"`
+
+	// AutoLock is the time (in seconds) of inactivity after which the
+	// wallet is locked. If zero, the wallet is never locked automatically.
+	AutoLock uint64 `json:"autoLock,omitempty"`
+
+	// BootstrapPeers are the addresses the syncer is seeded with in
+	// addition to the built-in bootstrap peers. If BootstrapOnly is
+	// set, they are used instead of the built-in peers, which is
+	// useful on private networks.
+	BootstrapPeers []string `json:"bootstrapPeers,omitempty"`
+	BootstrapOnly  bool     `json:"bootstrapOnly,omitempty"`
 }
 
 // satdMetadata contains the header and version strings that identify the
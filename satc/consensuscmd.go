@@ -2,11 +2,8 @@ package main
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/spf13/cobra"
-	"go.sia.tech/core/consensus"
-	"go.sia.tech/coreutils/chain"
 )
 
 var (
@@ -32,15 +29,13 @@ Block:      %v
 Height:     %v
 `, yesNo(tip.Synced), tip.BlockID, tip.Height)
 	} else {
-		_, genesisBlock := chain.Mainnet()
-		estimatedHeight := (time.Now().Unix() - genesisBlock.Timestamp.Unix()) / int64(consensus.State{}.BlockInterval().Seconds())
-		estimatedProgress := float64(tip.Height) / float64(estimatedHeight) * 100
-		if estimatedProgress > 100 {
+		estimatedProgress := tip.SyncProgress.Progress * 100
+		if estimatedProgress > 99.9 {
 			estimatedProgress = 99.9
 		}
 		fmt.Printf(`Synced: %v
-Height: %v
+Height: %v / %v
 Progress (estimated): %.1f%%
-`, yesNo(tip.Synced), tip.Height, estimatedProgress)
+`, yesNo(tip.Synced), tip.Height, tip.SyncProgress.EstimatedHeight, estimatedProgress)
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"runtime"
 
 	"github.com/mike76-dev/sia-satellite/internal/build"
 	"github.com/spf13/cobra"
@@ -14,8 +15,24 @@ var (
 		Long:  "Print version information.",
 		Run:   wrap(versioncmd),
 	}
+
+	stopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the satd daemon",
+		Long:  "Stop the satd daemon.",
+		Run:   wrap(stopcmd),
+	}
 )
 
+// stopcmd is the handler for the command `satc stop`.
+// Stops the daemon.
+func stopcmd() {
+	if err := httpClient.DaemonStop(); err != nil {
+		die("Could not stop daemon:", err)
+	}
+	fmt.Println("satd is stopping.")
+}
+
 // version prints the version of satc and satd.
 func versioncmd() {
 	fmt.Println("Satellite Client")
@@ -24,6 +41,7 @@ func versioncmd() {
 		fmt.Println("\tGit Revision " + build.GitRevision)
 		fmt.Println("\tBuild Time   " + build.BuildTime)
 	}
+	fmt.Println("\tGo Version   " + runtime.Version())
 	dvg, err := httpClient.DaemonVersion()
 	if err != nil {
 		fmt.Println("Could not get daemon version:", err)
@@ -35,4 +53,7 @@ func versioncmd() {
 		fmt.Println("\tGit Revision " + dvg.GitRevision)
 		fmt.Println("\tBuild Time   " + dvg.BuildTime)
 	}
+	if dvg.GoVersion != "" {
+		fmt.Println("\tGo Version   " + dvg.GoVersion)
+	}
 }
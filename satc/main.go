@@ -141,6 +141,7 @@ func initCmds() *cobra.Command {
 
 	// Daemon Commands.
 	root.AddCommand(versionCmd)
+	root.AddCommand(stopCmd)
 
 	// Create command tree (alphabetized by root command).
 	root.AddCommand(consensusCmd)
@@ -168,11 +169,23 @@ func initCmds() *cobra.Command {
 	portalAnnouncementCmd.AddCommand(portalAnnouncementRemoveCmd)
 
 	root.AddCommand(syncerCmd)
-	syncerCmd.AddCommand(syncerConnectCmd, syncerPeersCmd)
+	syncerCmd.AddCommand(syncerConnectCmd, syncerPeersCmd, syncerPersistentCmd)
+	syncerConnectCmd.Flags().BoolVarP(&syncerConnectPersistent, "persistent", "p", false, "Reconnect to the peer after a restart")
+	syncerPersistentCmd.AddCommand(syncerPersistentRemoveCmd)
 
 	root.AddCommand(walletCmd)
-	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletBalanceCmd, walletSendCmd)
+	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletBalanceCmd, walletBroadcastCmd, walletBumpCmd, walletFingerprintCmd, walletImportKeyCmd, walletLockCmd, walletSendCmd, walletSettingsCmd, walletSignMessageCmd, walletUnlockCmd, walletVerifyMessageCmd)
+	walletAddressesCmd.AddCommand(walletAddressesNewCmd)
+	walletAddressesNewCmd.Flags().Uint64VarP(&walletAddressCount, "count", "c", 1, "Number of addresses to generate")
 	walletSendCmd.AddCommand(walletSendSiacoinsCmd)
+	walletSendSiacoinsCmd.Flags().StringVarP(&walletFeeRate, "fee-rate", "", "", "Fee per byte, overriding the dynamic fee (e.g. 10nS)")
+	walletSendSiacoinsCmd.Flags().BoolVarP(&walletWarnReuse, "warn-reuse", "", false, "Warn if the destination address has been used before")
+	walletSettingsCmd.Flags().StringVarP(&walletAutoLock, "auto-lock", "", "", "Lock the wallet after this period of inactivity (e.g. 15m, 0 to disable)")
+	walletSettingsCmd.Flags().IntVarP(&walletMaxAncestors, "max-ancestors", "", -1, "Maximum length of the unconfirmed parent chain (0 for unlimited)")
+	walletSettingsCmd.Flags().IntVarP(&walletConfirmations, "confirmations", "", -1, "Blocks an output must be confirmed for to count towards the balance")
+	walletSendSiacoinsCmd.Flags().BoolVarP(&walletSignReceipt, "sign", "", false, "Sign the receipt with the key of the first transaction input")
+	walletSendSiacoinsCmd.Flags().BoolVarP(&walletSendMax, "max", "", false, "Send the whole spendable balance, the fee deducted")
+	walletVerifyMessageCmd.Flags().StringVarP(&walletPublicKey, "pubkey", "", "", "Public key of the address (e.g. ed25519:...)")
 
 	return root
 }
@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -29,18 +31,68 @@ var (
 		Long:  "View the current peer list.",
 		Run:   wrap(syncerpeerscmd),
 	}
+
+	syncerPersistentCmd = &cobra.Command{
+		Use:   "persistent",
+		Short: "View a list of persistent peers",
+		Long:  "View the peers that are reconnected to after a restart.",
+		Run:   wrap(syncerpersistentcmd),
+	}
+
+	syncerPersistentRemoveCmd = &cobra.Command{
+		Use:   "remove [address]",
+		Short: "Remove a persistent peer",
+		Long:  "Remove a peer from the persistent peer list. The peer is not disconnected.",
+		Run:   wrap(syncerpersistentremovecmd),
+	}
+)
+
+var (
+	syncerConnectPersistent bool // Remember the peer across restarts.
 )
 
 // syncerconnectcmd is the handler for the command `satc syncer connect [address]`.
 // Adds a new peer to the peer list.
 func syncerconnectcmd(addr string) {
-	err := httpClient.SyncerConnect(addr)
+	err := httpClient.SyncerConnect(addr, syncerConnectPersistent)
 	if err != nil {
 		die("Could not add peer:", err)
 	}
+	if syncerConnectPersistent {
+		fmt.Println("Added", addr, "to persistent peer list.")
+		return
+	}
 	fmt.Println("Added", addr, "to peer list.")
 }
 
+// syncerpersistentcmd is the handler for the command `satc syncer persistent`.
+// Prints a list of the persistent peers.
+func syncerpersistentcmd() {
+	peers, err := httpClient.SyncerPersistentPeers()
+	if err != nil {
+		die("Could not get persistent peer list:", err)
+	}
+	if len(peers) == 0 {
+		fmt.Println("No persistent peers.")
+		return
+	}
+	fmt.Println(len(peers), "persistent peers:")
+	for _, peer := range peers {
+		fmt.Println("  " + peer)
+	}
+}
+
+// syncerpersistentremovecmd is the handler for the command
+// `satc syncer persistent remove [address]`.
+// Removes a peer from the persistent peer list.
+func syncerpersistentremovecmd(addr string) {
+	err := httpClient.SyncerRemovePersistentPeer(addr)
+	if err != nil {
+		die("Could not remove peer:", err)
+	}
+	fmt.Println("Removed", addr, "from persistent peer list.")
+}
+
 // syncercmd is the handler for the command `satc syncer`.
 // Prints the number of peers.
 func syncercmd() {
@@ -62,11 +114,18 @@ func syncerpeerscmd() {
 		fmt.Println("No peers to show.")
 		return
 	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ConnectedSince.Before(peers[j].ConnectedSince)
+	})
 	fmt.Println(len(peers), "active peers:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Version\tOutbound\tAddress")
+	fmt.Fprintln(w, "Version\tOutbound\tConnected\tAddress")
 	for _, peer := range peers {
-		fmt.Fprintf(w, "%v\t%v\t%v\n", peer.Version, yesNo(!peer.Inbound), peer.Address)
+		connected := "-"
+		if !peer.ConnectedSince.IsZero() {
+			connected = time.Since(peer.ConnectedSince).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", peer.Version, yesNo(!peer.Inbound), connected, peer.Address)
 	}
 	if err := w.Flush(); err != nil {
 		die("failed to flush writer")
@@ -1,10 +1,33 @@
 package main
 
 import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/mike76-dev/sia-satellite/modules"
+	"github.com/mike76-dev/sia-satellite/node/api/client"
 	"github.com/spf13/cobra"
 	"go.sia.tech/core/types"
+	"golang.org/x/term"
+)
+
+var (
+	walletAddressCount  uint64
+	walletFeeRate       string
+	walletWarnReuse     bool
+	walletSignReceipt   bool
+	walletSendMax       bool
+	walletAutoLock      string
+	walletPublicKey     string
+	walletMaxAncestors  int
+	walletConfirmations int
 )
 
 var (
@@ -22,6 +45,13 @@ var (
 		Run:   wrap(walletaddressescmd),
 	}
 
+	walletAddressesNewCmd = &cobra.Command{
+		Use:   "new",
+		Short: "Get new wallet addresses",
+		Long:  "Generate a batch of new wallet addresses from the wallet's seed.",
+		Run:   wrap(walletaddressesnewcmd),
+	}
+
 	walletBalanceCmd = &cobra.Command{
 		Use:   "balance",
 		Short: "View wallet balance",
@@ -29,6 +59,25 @@ var (
 		Run:   wrap(walletbalancecmd),
 	}
 
+	walletBroadcastCmd = &cobra.Command{
+		Use:   "broadcast [txn]",
+		Short: "Broadcast a transaction",
+		Long: `Broadcast a signed transaction and print its ID. 'txn' may be JSON, base64,
+or a path to a file containing either encoding.`,
+		Run: wrap(walletbroadcastcmd),
+	}
+
+	walletBumpCmd = &cobra.Command{
+		Use:   "bump [txid] [rate]",
+		Short: "Bump the fee of an unconfirmed transaction",
+		Long: `Replace an unconfirmed wallet transaction with one paying a higher fee.
+'rate' is the fee per byte and can be specified in units, e.g. 10nS. The fee increase
+is taken from the change output of the transaction. The replacement is relayed
+to the peers, while the original stays in the local txpool until either of them
+is confirmed, so bump the original transaction again if needed.`,
+		Run: wrap(walletbumpcmd),
+	}
+
 	walletCmd = &cobra.Command{
 		Use:   "wallet",
 		Short: "Perform wallet actions",
@@ -47,6 +96,63 @@ The smallest unit of Siacoins is the Hasting. One Siacoin is 10^24 Hastings. Oth
 		Run: wrap(walletbalancecmd),
 	}
 
+	walletFingerprintCmd = &cobra.Command{
+		Use:   "fingerprint",
+		Short: "View the seed fingerprint",
+		Long: `View a salted hash of the wallet seed. Two nodes using the same seed have the same fingerprint.
+The seed cannot be recovered from the fingerprint, so it is safe to share.`,
+		Run: wrap(walletfingerprintcmd),
+	}
+
+	walletImportKeyCmd = &cobra.Command{
+		Use:   "import-key",
+		Short: "Import a private key",
+		Long: `Import a standalone private key, e.g. from a paper wallet, into the wallet.
+The key is read from the standard input as a 128-character hexadecimal string.
+Imported keys are not derived from the wallet seed, so they can't be recovered from it.
+Back them up separately. The wallet rescans the blockchain after the import.`,
+		Run: wrap(walletimportkeycmd),
+	}
+
+	walletLockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the wallet",
+		Long:  "Lock the wallet. A locked wallet keeps tracking the blockchain, but refuses to send coins, bump fees, sign messages or import keys. Contract formation and renewal are not affected.",
+		Run:   wrap(walletlockcmd),
+	}
+
+	walletSettingsCmd = &cobra.Command{
+		Use:   "settings",
+		Short: "View or change the wallet settings",
+		Long: `View the wallet settings, or change them with the flags.
+--auto-lock sets the period of inactivity after which the wallet is locked, e.g. 15m. 0 disables the auto-lock.
+--max-ancestors sets the maximum length of the chain of unconfirmed transactions a new transaction may depend on. 0 removes the limit.`,
+		Run: wrap(walletsettingscmd),
+	}
+
+	walletSignMessageCmd = &cobra.Command{
+		Use:   "sign-message [addr] [message]",
+		Short: "Sign a message with a wallet address",
+		Long: `Sign a free-form message with the key of a wallet address, e.g. to prove the ownership of the address.
+The public key and the signature are printed; share them together with the message.`,
+		Run: wrap(walletsignmessagecmd),
+	}
+
+	walletVerifyMessageCmd = &cobra.Command{
+		Use:   "verify-message [addr] [message] [signature]",
+		Short: "Verify a signed message",
+		Long: `Verify a message signed with the key of an address.
+Unless the public key is supplied with --pubkey, the address must belong to the wallet.`,
+		Run: wrap(walletverifymessagecmd),
+	}
+
+	walletUnlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the wallet",
+		Long:  "Unlock the wallet. The wallet seed is read from the standard input.",
+		Run:   wrap(walletunlockcmd),
+	}
+
 	walletSendCmd = &cobra.Command{
 		Use:   "send",
 		Short: "Send Siacoins to an address",
@@ -61,8 +167,16 @@ The smallest unit of Siacoins is the Hasting. One Siacoin is 10^24 Hastings. Oth
 		Long: `Send Siacoins to an address. 'dest' must be a 76-byte hexadecimal address.
 'amount' can be specified in units, e.g. 1.23KS. Run 'wallet --help' for a list of units.
 If no unit is supplied, Hastings will be assumed.
-A dynamic transaction fee is applied depending on the size of the transaction and how busy the network is.`,
-		Run: wrap(walletsendsiacoinscmd),
+A dynamic transaction fee is applied depending on the size of the transaction and how busy the network is,
+unless a fee rate (per byte) is set with --fee-rate.
+With --max, 'amount' is omitted, and the whole spendable balance is sent, the fee deducted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if walletSendMax {
+				wrap(walletsendmaxcmd)(cmd, args)
+			} else {
+				wrap(walletsendsiacoinscmd)(cmd, args)
+			}
+		},
 	}
 )
 
@@ -87,29 +201,360 @@ func walletaddressescmd() {
 	}
 }
 
+// walletfingerprintcmd displays the fingerprint of the wallet seed.
+func walletfingerprintcmd() {
+	fp, err := httpClient.WalletFingerprint()
+	if err != nil {
+		die("Could not get seed fingerprint:", err)
+	}
+	fmt.Println(fp)
+}
+
+// walletimportkeycmd reads a private key from the standard input and
+// imports it into the wallet.
+func walletimportkeycmd() {
+	fmt.Print("Enter private key: ")
+	input, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		die("Could not read private key:", err)
+	}
+	sk, err := hex.DecodeString(strings.TrimSpace(string(input)))
+	if err != nil || len(sk) != 64 {
+		die("Invalid private key")
+	}
+	err = httpClient.WalletImportKey(types.PrivateKey(sk))
+	if err != nil {
+		die("Could not import private key:", err)
+	}
+	fmt.Println("Imported key for address", types.StandardUnlockHash(types.PrivateKey(sk).PublicKey()))
+}
+
+// walletsignmessagecmd signs a message with the key of a wallet address.
+func walletsignmessagecmd(address, msg string) {
+	var addr types.Address
+	if err := addr.UnmarshalText([]byte(address)); err != nil {
+		die("Could not parse address:", err)
+	}
+	resp, err := httpClient.WalletSignMessage(addr, msg)
+	if err != nil {
+		die("Could not sign message:", err)
+	}
+	fmt.Println("Address:   ", resp.Address)
+	fmt.Println("Public Key:", resp.PublicKey)
+	fmt.Println("Signature: ", resp.Signature)
+}
+
+// walletverifymessagecmd verifies the signature of a message.
+func walletverifymessagecmd(address, msg, signature string) {
+	var addr types.Address
+	if err := addr.UnmarshalText([]byte(address)); err != nil {
+		die("Could not parse address:", err)
+	}
+	var sig types.Signature
+	if err := sig.UnmarshalText([]byte(signature)); err != nil {
+		die("Could not parse signature:", err)
+	}
+	var pk *types.PublicKey
+	if walletPublicKey != "" {
+		pk = new(types.PublicKey)
+		if err := pk.UnmarshalText([]byte(walletPublicKey)); err != nil {
+			die("Could not parse public key:", err)
+		}
+	}
+	valid, err := httpClient.WalletVerifyMessage(addr, msg, sig, pk)
+	if err != nil {
+		die("Could not verify message:", err)
+	}
+	if !valid {
+		die("Signature is invalid")
+	}
+	fmt.Println("Signature is valid")
+}
+
+// walletlockcmd locks the wallet.
+func walletlockcmd() {
+	err := httpClient.WalletLock()
+	if err != nil {
+		die("Could not lock wallet:", err)
+	}
+	fmt.Println("Wallet locked")
+}
+
+// walletunlockcmd reads the wallet seed from the standard input and
+// unlocks the wallet.
+func walletunlockcmd() {
+	fmt.Print("Enter wallet seed: ")
+	seed, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		die("Could not read wallet seed:", err)
+	}
+	err = httpClient.WalletUnlock(strings.TrimSpace(string(seed)))
+	if err != nil {
+		die("Could not unlock wallet:", err)
+	}
+	fmt.Println("Wallet unlocked")
+}
+
+// walletsettingscmd displays or updates the wallet settings.
+func walletsettingscmd() {
+	ws, err := httpClient.WalletSettings()
+	if err != nil {
+		die("Could not get wallet settings:", err)
+	}
+	if walletAutoLock != "" || walletMaxAncestors >= 0 || walletConfirmations >= 0 {
+		if walletAutoLock != "" {
+			d, err := time.ParseDuration(walletAutoLock)
+			if err != nil || d < 0 {
+				die("Could not parse auto-lock period:", walletAutoLock)
+			}
+			ws.AutoLock = d
+		}
+		if walletMaxAncestors >= 0 {
+			ws.MaxAncestorDepth = walletMaxAncestors
+		}
+		if walletConfirmations >= 0 {
+			ws.RequiredConfirmations = uint64(walletConfirmations)
+		}
+		err = httpClient.WalletUpdateSettings(ws)
+		if err != nil {
+			die("Could not update wallet settings:", err)
+		}
+	}
+	autoLock := "disabled"
+	if ws.AutoLock > 0 {
+		autoLock = ws.AutoLock.String()
+	}
+	maxAncestors := "unlimited"
+	if ws.MaxAncestorDepth > 0 {
+		maxAncestors = fmt.Sprint(ws.MaxAncestorDepth)
+	}
+	fmt.Println("Auto-lock:              ", autoLock)
+	fmt.Println("Max unconfirmed parents:", maxAncestors)
+	fmt.Println("Required confirmations: ", ws.RequiredConfirmations)
+}
+
+// walletaddressesnewcmd fetches a batch of new addresses from the wallet.
+func walletaddressesnewcmd() {
+	addrs, err := httpClient.WalletAddressBatch(walletAddressCount)
+	if err != nil {
+		die("Could not generate new addresses:", err)
+	}
+	for _, addr := range addrs {
+		fmt.Println(addr)
+	}
+}
+
 // walletsendsiacoinscmd sends Siacoins to a destination address.
 func walletsendsiacoinscmd(amount, dest string) {
 	value, err := types.ParseCurrency(amount)
 	if err != nil {
 		die("Could not parse amount:", err)
 	}
+	walletsend(value, dest, false)
+}
+
+// walletsendmaxcmd sends all spendable Siacoins to a destination
+// address.
+func walletsendmaxcmd(dest string) {
+	walletsend(types.ZeroCurrency, dest, true)
+}
+
+// walletsend sends the amount, or the whole spendable balance if sweep
+// is true, to a destination address and prints the receipt.
+func walletsend(value types.Currency, dest string, sweep bool) {
 	var addr types.Address
 	if err := addr.UnmarshalText([]byte(dest)); err != nil {
 		die("Failed to parse destination address", err)
 	}
-	err = httpClient.WalletSendSiacoins(value, addr)
+	if walletWarnReuse {
+		seen, err := addressSeen(addr)
+		if err != nil {
+			die("Could not check the destination address:", err)
+		}
+		if seen && !askForConfirmation("The destination address has been used before. Send anyway?") {
+			fmt.Println("Aborted")
+			return
+		}
+	}
+	var feeRate types.Currency
+	if walletFeeRate != "" {
+		var err error
+		feeRate, err = types.ParseCurrency(walletFeeRate)
+		if err != nil {
+			die("Could not parse fee rate:", err)
+		}
+	}
+	var receipt modules.SendReceipt
+	var err error
+	if sweep {
+		receipt, err = httpClient.WalletSendMax(addr, feeRate, walletSignReceipt)
+	} else {
+		receipt, err = httpClient.WalletSendSiacoins(value, addr, feeRate, walletSignReceipt)
+	}
 	if err != nil {
 		die("Could not send Siacoins:", err)
 	}
-	fmt.Printf("Sent %s Hastings to %s\n", value.ExactString(), dest)
+	fmt.Printf(`Receipt:
+Transaction ID: %v
+Destination:    %v
+Amount:         %v (%v H)
+Fee:            %v
+Fee Rate:       %v/byte
+Size:           %v bytes
+Link:           %v
+`, receipt.TransactionID, receipt.Destination, receipt.Amount, receipt.Amount.ExactString(),
+		receipt.Fee, receipt.FeeRate, receipt.Size, receipt.Link)
+	if receipt.Signature != nil {
+		fmt.Printf("Public Key:     %v\nSignature:      %v\n", *receipt.PublicKey, *receipt.Signature)
+	}
+	if receipt.SignError != "" {
+		fmt.Println("Warning: the transaction was sent, but the receipt couldn't be signed:", receipt.SignError)
+	}
+}
+
+// addressSeen checks if the address belongs to the wallet, is watched
+// by the wallet, appears in the wallet event history, or is paid to by
+// a txpool transaction.
+func addressSeen(addr types.Address) (bool, error) {
+	addrs, err := httpClient.WalletAddresses()
+	if err != nil {
+		return false, err
+	}
+	watched, err := httpClient.WalletWatchedAddresses()
+	if err != nil {
+		return false, err
+	}
+	for _, a := range append(addrs, watched...) {
+		if a == addr {
+			return true, nil
+		}
+	}
+
+	if seen, err := addressInEvents(addr); err != nil || seen {
+		return seen, err
+	}
+
+	pool, err := httpClient.WalletPoolTransactions()
+	if err != nil {
+		return false, err
+	}
+	for _, pt := range pool {
+		for _, sco := range pt.Raw.SiacoinOutputs {
+			if sco.Address == addr {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// addressInEvents checks if the address appears in the wallet event
+// history, either as an address that received funds or as the
+// recipient of an output, e.g. of an earlier payment.
+func addressInEvents(addr types.Address) (bool, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(httpClient.WalletExportEvents(pw))
+	}()
+
+	dec := json.NewDecoder(pr)
+	for {
+		var event modules.WalletEvent
+		if err := dec.Decode(&event); errors.Is(err, io.EOF) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		for _, a := range event.Relevant {
+			if a == addr {
+				return true, nil
+			}
+		}
+
+		// Only the outputs are of interest, so the fields of all event
+		// types are decoded at once.
+		var data struct {
+			SiacoinOutputs []types.SiacoinElement `json:"siacoinOutputs"`
+			SiacoinOutput  *types.SiacoinElement  `json:"siacoinOutput"`
+			MissedOutputs  []types.SiacoinElement `json:"missedOutputs"`
+		}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return false, err
+		}
+		outputs := append(data.SiacoinOutputs, data.MissedOutputs...)
+		if data.SiacoinOutput != nil {
+			outputs = append(outputs, *data.SiacoinOutput)
+		}
+		for _, sce := range outputs {
+			if sce.SiacoinOutput.Address == addr {
+				return true, nil
+			}
+		}
+	}
+}
+
+// askForConfirmation prints the question and waits for the user to
+// answer it. Only 'y' and 'yes' count as a confirmation.
+func askForConfirmation(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// walletbumpcmd bumps the fee of an unconfirmed transaction.
+func walletbumpcmd(txid, rate string) {
+	var id types.TransactionID
+	if err := id.UnmarshalText([]byte(txid)); err != nil {
+		die("Could not parse transaction ID:", err)
+	}
+	feeRate, err := types.ParseCurrency(rate)
+	if err != nil {
+		die("Could not parse fee rate:", err)
+	}
+	newID, err := httpClient.WalletBumpFee(id, feeRate)
+	if err != nil {
+		die("Could not bump transaction fee:", err)
+	}
+	fmt.Printf("Replaced transaction %s with %s\n", id, newID)
+}
+
+// walletbroadcastcmd broadcasts a transaction.
+func walletbroadcastcmd(txnStr string) {
+	txn, err := parseTxn(txnStr)
+	if err != nil {
+		die("Could not decode transaction:", err)
+	}
+	resp, err := httpClient.TxpoolBroadcast([]types.Transaction{txn}, nil)
+	if err != nil {
+		die("Could not broadcast transaction:", err)
+	}
+	fmt.Println("Transaction broadcast successfully:", resp.ID)
 }
 
 // walletbalancecmd retrieves and displays information about the wallet.
 func walletbalancecmd() {
 	status, err := httpClient.WalletBalance()
-	if err != nil {
+	if errors.Is(err, client.ErrAPICallNotRecognized) {
+		die("Wallet module not loaded")
+	} else if err != nil {
 		die("Could not get wallet status:", err)
 	}
+	lock, err := httpClient.WalletLockState()
+	if err != nil {
+		die("Could not get wallet lock state:", err)
+	}
+	lockState := "Unlocked"
+	if lock.Locked {
+		lockState = "Locked"
+	}
 
 	unconfirmedBalance := status.Siacoins.Add(status.IncomingSiacoins).Sub(status.OutgoingSiacoins)
 	var delta string
@@ -120,13 +565,18 @@ func walletbalancecmd() {
 	}
 
 	fmt.Printf(`Wallet status:
+Lock State:           %v
 Height:               %v
 Confirmed SC Balance: %v
+Spendable Now:        %v
 Unconfirmed Delta:    %v
 Exact:                %v H
 SF Balance:           %v
+SF Claim Balance:     %v
 Estimated Fee:        %v / KB
-`, status.Height, status.Siacoins, delta,
+Confirmations:        %v
+`, lockState, status.Height, status.Siacoins, status.SpendableSiacoins, delta,
 		status.Siacoins.ExactString(), status.Siafunds,
-		status.RecommendedFee.Mul64(1e3))
+		status.ClaimBalance, status.RecommendedFee.Mul64(1e3),
+		status.RequiredConfirmations)
 }
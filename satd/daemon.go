@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/mike76-dev/sia-satellite/internal/build"
+	"github.com/mike76-dev/sia-satellite/modules"
 	"github.com/mike76-dev/sia-satellite/node"
 	"github.com/mike76-dev/sia-satellite/node/api/server"
 	"github.com/mike76-dev/sia-satellite/persist"
 )
 
+// shutdownGracePeriod is how long the in-flight API requests may take
+// to complete when the daemon is shutting down.
+const shutdownGracePeriod = 10 * time.Second
+
 // startDaemon starts the satd server.
 func startDaemon(config *persist.SatdConfig, apiPassword, dbPassword, seed string) error {
 	loadStart := time.Now()
@@ -29,21 +36,39 @@ func startDaemon(config *persist.SatdConfig, apiPassword, dbPassword, seed strin
 	// Start listening to the API requests.
 	l, err := net.Listen("tcp", config.APIAddr)
 	if err != nil {
-		log.Fatal(err)
+		return modules.AddContext(err, "unable to listen on the API address")
 	}
 	n, err := node.New(config, dbPassword, seed, loadStart)
 	if err != nil {
-		log.Fatal(err)
+		l.Close()
+		return err
 	}
 	log.Println("p2p: Listening on", n.Syncer.Addr())
 	stop := n.Start()
 	log.Println("api: Listening on", l.Addr())
-	go server.StartWeb(l, n, apiPassword)
+	stopCh := make(chan struct{}, 1)
+	srv := server.StartWeb(l, n, apiPassword, func() {
+		select {
+		case stopCh <- struct{}{}:
+		default:
+		}
+	})
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, os.Interrupt)
-	<-signalCh
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	var haltErr error
+	select {
+	case <-signalCh:
+	case <-stopCh:
+	case haltErr = <-n.Halted():
+		log.Println("ERROR: halting the node:", haltErr)
+	}
 	log.Println("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Unable to shut down API server:", err)
+	}
 	stop()
 
-	return nil
+	return haltErr
 }
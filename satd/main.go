@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strings"
 
+	"github.com/mike76-dev/sia-satellite/node"
 	"github.com/mike76-dev/sia-satellite/persist"
+	"go.sia.tech/core/types"
 	"golang.org/x/term"
 )
 
@@ -97,6 +102,10 @@ func main() {
 	dbUser := flag.String("db-user", "", "username for accessing the database")
 	dbName := flag.String("db-name", "", "name of MYSQL database")
 	portalPort := flag.String("portal", "", "port number the portal server listens at")
+	checkpoint := flag.String("checkpoint", "", "trusted checkpoint in the <height>::<id> format")
+	autoLock := flag.Duration("auto-lock", -1, "lock the wallet after this period of inactivity, 0 to disable")
+	bootstrapPeers := flag.String("bootstrap-peers", "", "comma-separated list of peers to bootstrap the syncer with")
+	bootstrapOnly := flag.Bool("bootstrap-only", false, "use only the supplied bootstrap peers instead of the built-in ones")
 	flag.Parse()
 	if *name != "" {
 		config.Name = *name
@@ -110,6 +119,9 @@ func main() {
 	if *satelliteAddr != "" {
 		config.SatelliteAddr = *satelliteAddr
 	}
+	if _, _, err := net.SplitHostPort(config.SatelliteAddr); err != nil {
+		log.Fatalf("Invalid satellite address %q: %v\n", config.SatelliteAddr, err)
+	}
 	if *dir != "" {
 		config.Dir = *dir
 	}
@@ -122,6 +134,34 @@ func main() {
 	if *portalPort != "" {
 		config.PortalPort = *portalPort
 	}
+	if *checkpoint != "" {
+		var index types.ChainIndex
+		if err := index.UnmarshalText([]byte(*checkpoint)); err != nil {
+			log.Fatalln("Invalid checkpoint:", err)
+		}
+		config.Checkpoint = *checkpoint
+	}
+	if *autoLock >= 0 {
+		config.AutoLock = uint64(autoLock.Seconds())
+	}
+	if *bootstrapPeers != "" {
+		config.BootstrapPeers = nil
+		for _, addr := range strings.Split(*bootstrapPeers, ",") {
+			addr = strings.TrimSpace(addr)
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				log.Fatalf("Invalid bootstrap peer %q: %v\n", addr, err)
+			}
+			config.BootstrapPeers = append(config.BootstrapPeers, addr)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "bootstrap-only" {
+			config.BootstrapOnly = *bootstrapOnly
+		}
+	})
+	if config.BootstrapOnly && len(config.BootstrapPeers) == 0 {
+		log.Fatalln("No bootstrap peers supplied while -bootstrap-only is set")
+	}
 
 	// Save the configuration.
 	err = config.Save(configDir)
@@ -147,7 +187,10 @@ func main() {
 
 	// Start satd. startDaemon will only return when it is shutting down.
 	err = startDaemon(&config, apiPassword, dbPassword, seed)
-	if err != nil {
+	var mie *node.ModuleInitError
+	if errors.As(err, &mie) {
+		log.Fatalf("satd failed to start: the %s module could not be initialized: %v\n", mie.Module, mie.Err)
+	} else if err != nil {
 		log.Fatalln(err)
 	}
 
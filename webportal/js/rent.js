@@ -313,6 +313,12 @@ function loginClick() {
 					emailErr.classList.remove('invisible');
 					window.setTimeout(function() {emailErr.classList.add('invisible')}, 3000);
 					break;
+				case 32:
+					emailErr.innerHTML = 'Account locked until ' +
+						new Date(data.lockedUntil).toLocaleString() +
+						', reset your password to unlock it now';
+					emailErr.classList.remove('invisible');
+					break;
 				default:
 			}
 		})